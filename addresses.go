@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Address Display
+// Addresses are shown the way the backend writes them, which isn't always the easiest way to read them. --addresses
+// takes a list of changes to make to how they're shown, each of which can be turned on separately:
+//
+//	localhost  127.0.0.1 and ::1 are shown as localhost
+//	any        Addresses bound to every address (0.0.0.0, :: and *) are shown as any
+//	compress   IPv6 addresses are written in their shortest form (lsof and netstat don't always)
+//	scope      IPv6 addresses lose their scope (the %eth0 of fe80::1%eth0)
+//	subnet     Remote addresses are grouped into their /24 (or /64 for IPv6), so connections from the same network
+//	           read the same, e.g. 10.1.2.0/24
+//
+// Only what's shown changes - filters and the detail pane still see the addresses as they are. Names from the hosts
+// file (--host-names) are left alone.
+
+// The changes --addresses can make
+var addressDisplays = []string{"localhost", "any", "compress", "scope", "subnet"}
+
+// checkAddressDisplays() returns an error if --addresses was given something it can't do
+func checkAddressDisplays(displays []string) error {
+	for _, display := range displays {
+		if !slices.Contains(addressDisplays, display) {
+			return fmt.Errorf(tr("error.addresses"), display, strings.Join(addressDisplays, ", "))
+		}
+	}
+	return nil
+}
+
+// displayAddress() returns an address the way --addresses says to show it. Remote addresses can be grouped into their
+// subnet, local ones can't.
+func displayAddress(address string, displays []string, remote bool) string {
+	want := func(display string) bool { return slices.Contains(displays, display) }
+
+	if address == "*" {
+		if want("any") {
+			return "any"
+		}
+		return address
+	}
+
+	// IPv6 addresses can be in brackets, and can have a scope after them
+	bracketed := strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]")
+	bare := strings.Trim(address, "[]")
+	bare, scope, scoped := strings.Cut(bare, "%")
+	ip := net.ParseIP(bare)
+	if ip == nil {
+		return address
+	}
+
+	switch {
+	case want("localhost") && (ip.Equal(net.IPv4(127, 0, 0, 1)) || ip.Equal(net.IPv6loopback)):
+		return "localhost"
+	case want("any") && ip.IsUnspecified():
+		return "any"
+	case want("subnet") && remote:
+		if ip4 := ip.To4(); ip4 != nil {
+			return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+		}
+		return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+	}
+
+	// IPv4 addresses don't have anything else to change
+	if ip.To4() != nil {
+		return address
+	}
+	if want("compress") {
+		bare = ip.String()
+	}
+	if scoped && !want("scope") {
+		bare += "%" + scope
+	}
+	if bracketed {
+		return "[" + bare + "]"
+	}
+	return bare
+}
+
+// fillAddressDisplay() changes how a process' addresses are shown, the way --addresses says to
+func fillAddressDisplay(rows []table.Row, proc process, options settings) {
+	if len(options.addressDisplays) == 0 {
+		return
+	}
+
+	for column, c := range options.columns {
+		if c.Title != "Address" && c.Title != "Local Address" && c.Title != "Remote Address" {
+			continue
+		}
+
+		for i, conn := range proc.Connections {
+			if i >= len(rows) {
+				break
+			}
+
+			// The Address column shows the remote address if there is one
+			address, remote := conn.LocalAddress, false
+			if c.Title == "Remote Address" || (c.Title == "Address" && conn.RemoteAddress != "") {
+				address, remote = conn.RemoteAddress, true
+			}
+
+			// Anything else in the cell (a name from the hosts file, or a UDP socket's lack of a remote end) stays
+			if rows[i][column] == address {
+				rows[i][column] = displayAddress(address, options.addressDisplays, remote)
+			}
+		}
+	}
+}
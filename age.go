@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Connection Age
+// A connection stuck in CLOSE_WAIT for a second is normal; one stuck there for hours is a leak. --show-age adds an Age
+// column with how long pvw has seen each connection for, and O can sort by it, oldest first, so long-lived connections
+// stand out.
+//
+// The age is how long ago pvw first saw the connection, as no backend says when a socket was made. (On Linux, the times
+// on /proc/<pid>/fd are when /proc was first asked about the file, not when the socket was made, so they're no help.)
+// Connections that were already open when pvw started could be any age, so theirs starts with a >.
+
+// connectionAge is when pvw first saw a connection
+type connectionAge struct {
+	since  time.Time
+	before bool // Whether it was already open at pvw's first collection, so is at least this old
+}
+
+// When each connection was first seen, as of the last collection. Collections happen in their own goroutines, so it's
+// behind a mutex.
+var (
+	ageMutex     sync.Mutex
+	firstSeen    map[rowKey]connectionAge
+	ageCollected bool // Whether there's been a collection yet
+)
+
+// observeConnections() records when each connection was first seen, and forgets those that have gone. It returns the
+// ages of every connection, which isn't changed afterwards, so it's safe to read from anywhere.
+func observeConnections(processes []process, at time.Time) map[rowKey]connectionAge {
+	ageMutex.Lock()
+	defer ageMutex.Unlock()
+
+	seen := make(map[rowKey]connectionAge)
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			key := connectionKey(proc.ID, conn)
+			if age, found := firstSeen[key]; found {
+				seen[key] = age
+			} else {
+				seen[key] = connectionAge{since: at, before: !ageCollected}
+			}
+		}
+	}
+
+	firstSeen = seen
+	ageCollected = true
+	return seen
+}
+
+// currentTime() returns what time it is by the clock ages are measured with, which is the real one unless a test set it
+func (o settings) currentTime() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+// formatAge() writes how long ago something was, in its largest unit: 42s, 5m, 3h or 2d
+func formatAge(age connectionAge, now time.Time) string {
+	d := now.Sub(age.since)
+	var formatted string
+	switch {
+	case d < time.Minute:
+		formatted = strconv.Itoa(int(d.Seconds())) + "s"
+	case d < time.Hour:
+		formatted = strconv.Itoa(int(d.Minutes())) + "m"
+	case d < 24*time.Hour:
+		formatted = strconv.Itoa(int(d.Hours())) + "h"
+	default:
+		formatted = strconv.Itoa(int(d.Hours()/24)) + "d"
+	}
+
+	if age.before {
+		return ">" + formatted
+	}
+	return formatted
+}
+
+// connectionAges() formats the age of each of a process' connections, or returns nil if the Age column isn't shown
+func connectionAges(proc process, options settings, now time.Time) []string {
+	if !options.showAge {
+		return nil
+	}
+	ages := make([]string, len(proc.Connections))
+	for i, conn := range proc.Connections {
+		if age, found := options.ages[connectionKey(proc.ID, conn)]; found {
+			ages[i] = formatAge(age, now)
+		}
+	}
+	return ages
+}
+
+// fillAgeColumn() fills in the Age column of each of a process' rows
+func fillAgeColumn(rows []table.Row, ages []string, options settings) {
+	if len(ages) == 0 {
+		return
+	}
+
+	column := columnIndex(options, "Age")
+	if column == -1 {
+		return
+	}
+	for i, age := range ages {
+		if i < len(rows) {
+			rows[i][column] = age
+		}
+	}
+}
+
+// oldestConnection() finds when a process' longest-open connection was first seen, for sorting by age
+func oldestConnection(proc process, options settings) (connectionAge, bool) {
+	var oldest connectionAge
+	found := false
+	for _, conn := range proc.Connections {
+		age, seen := options.ages[connectionKey(proc.ID, conn)]
+		if seen && (!found || age.since.Before(oldest.since)) {
+			oldest, found = age, true
+		}
+	}
+	return oldest, found
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Alerts
+// Watch mode is often left running in a corner while something else has your attention, so alert rules in the config
+// file can call attention back to it. Each picks out connections with the same expressions as the filter bar (see
+// filterbar.go), and either goes off when more than a number of connections match, or when a connection that matches
+// appears:
+//
+//	"alerts": [
+//		{"match": "state:TIME_WAIT", "above": 1000, "bell": true},
+//		{"match": "addr:0.0.0.0 state:LISTEN", "new": true, "message": "Something new is listening on every address"}
+//	]
+//
+// Alerts show up as a banner under the table: a count alert for as long as there are too many connections, and a new
+// connection alert for a minute. With "bell", the terminal's bell rings when the alert goes off too. They're only
+// checked in watch mode, and only against what the table is showing.
+
+// alertRule is a rule from the config file that shows a banner when its connections cross a line
+type alertRule struct {
+	Match   string `json:"match"`             // An expression in the filter bar's syntax
+	Above   int    `json:"above,omitempty"`   // Goes off when more connections than this match
+	New     bool   `json:"new,omitempty"`     // Goes off when a connection that matches appears
+	Message string `json:"message,omitempty"` // What the banner says, instead of describing the rule
+	Bell    bool   `json:"bell,omitempty"`    // Whether to ring the terminal's bell as well
+
+	filters []filterTerm // Match, parsed
+}
+
+// How long a new connection alert stays up for
+const newAlertDuration = time.Minute
+
+// How many new connection alerts the banner shows at once
+const maxRecentAlerts = 3
+
+// The style used for the alert banner
+var alertStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("15")).
+	Background(lipgloss.Color("1")).
+	Bold(true).
+	Padding(0, 1)
+
+// validate() checks an alert rule makes sense, and parses its expression
+func (r *alertRule) validate() error {
+	r.filters = parseFilters(r.Match)
+	if len(r.filters) == 0 || (r.Above > 0) == r.New {
+		return errors.New(tr("error.alert"))
+	}
+	for _, term := range r.filters {
+		if !term.valid() {
+			return errors.New(tr("error.alert-match", r.Match, term.value))
+		}
+	}
+	return nil
+}
+
+// alertState is what's remembered between refreshes to tell when alerts go off
+type alertState struct {
+	checked bool              // Whether the alerts have been checked once, so there's something to compare with
+	counts  []int             // How many connections matched each rule last time
+	seen    []map[rowKey]bool // Which connections matched each rule last time
+
+	active []string // The count alerts currently going off
+	recent []recentAlert
+}
+
+// recentAlert is a new connection alert that's still being shown
+type recentAlert struct {
+	message string
+	until   time.Time
+}
+
+// checkAlerts() checks the alert rules against what the table is showing after a refresh, updating the banner. It
+// returns a command to ring the bell if an alert with one has just gone off.
+func (m *model) checkAlerts(processes []process) tea.Cmd {
+	rules := m.settings.alerts
+	if len(rules) == 0 || m.settings.interval == 0 {
+		return nil
+	}
+
+	state := alertState{
+		checked: true,
+		counts:  make([]int, len(rules)),
+		seen:    make([]map[rowKey]bool, len(rules)),
+	}
+	now := time.Now()
+	for _, alert := range m.alerts.recent {
+		if now.Before(alert.until) {
+			state.recent = append(state.recent, alert)
+		}
+	}
+
+	bell := false
+	for i, rule := range rules {
+		state.seen[i] = make(map[rowKey]bool)
+		var appeared []string
+		for _, proc := range processes {
+			for _, conn := range proc.Connections {
+				if !matchesFilters(rule.filters, proc, conn) {
+					continue
+				}
+				state.counts[i]++
+
+				key := connectionKey(proc.ID, conn)
+				state.seen[i][key] = true
+				if m.alerts.checked && !m.alerts.seen[i][key] {
+					appeared = append(appeared, fmt.Sprintf("%s:%s %s", conn.LocalAddress, conn.LocalPort, processLabel(proc)))
+				}
+			}
+		}
+
+		switch {
+		case rule.Above > 0 && state.counts[i] > rule.Above:
+			message := rule.Message
+			if message == "" {
+				message = tr("alert.above", state.counts[i], rule.Match, rule.Above)
+			}
+			state.active = append(state.active, message)
+			// It only goes off when it crosses the line, not on every refresh while it's over it
+			if !m.alerts.checked || m.alerts.counts[i] <= rule.Above {
+				bell = bell || rule.Bell
+				m.announce(message)
+			}
+
+		case rule.New && len(appeared) > 0:
+			for _, connection := range appeared {
+				message := tr("alert.new", rule.Match, connection)
+				if rule.Message != "" {
+					message = rule.Message + " (" + connection + ")"
+				}
+				state.recent = append(state.recent, recentAlert{message: message, until: now.Add(newAlertDuration)})
+				m.announce(message)
+			}
+			bell = bell || rule.Bell
+		}
+	}
+
+	m.alerts = state
+	if bell && m.output == nil {
+		return ringBell
+	}
+	return nil
+}
+
+// ringBell() rings the terminal's bell. It's written to stderr, as the TUI owns stdout.
+func ringBell() tea.Msg {
+	fmt.Fprint(os.Stderr, "\a")
+	return nil
+}
+
+// alertLines() returns the lines of the alert banner, which is empty if nothing's going off
+func (m model) alertLines() []string {
+	var lines []string
+	for _, message := range m.alerts.active {
+		lines = append(lines, alertStyle.Render("! "+message))
+	}
+
+	// Lots of new connections at once would push the table off screen, so only the latest few are shown
+	recent := m.alerts.recent
+	if len(recent) > maxRecentAlerts {
+		lines = append(lines, alertStyle.Render(tr("alert.more", len(recent)-maxRecentAlerts)))
+		recent = recent[len(recent)-maxRecentAlerts:]
+	}
+	for _, alert := range recent {
+		lines = append(lines, alertStyle.Render("! "+alert.message))
+	}
+	return lines
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Backends
+// A backend is a way of finding every process with a socket open. lsof works everywhere, but is slow on hosts with lots
+// of connections and is missing from some minimal distros, so Linux can also use ss, netstat, or ask the kernel
+// directly over netlink or through /proc.
+
+type backend interface {
+	name() string                                                   // The name used to select the backend with --backend
+	available() bool                                                // Whether the backend can run on this system
+	capabilities() capabilities                                     // What the backend can find out with our current privileges
+	collect(ctx context.Context) ([]process, []parseWarning, error) // Gets every process with a socket open, before filtering
+}
+
+// The collectors themselves live in pkg/pvw, so other tools can use them too. collectorBackend adds the capabilities
+// the UI needs to a collector.
+type collectorBackend struct {
+	collector pvw.Collector
+	caps      func() capabilities
+}
+
+func (b collectorBackend) name() string { return b.collector.Name() }
+
+func (b collectorBackend) available() bool { return b.collector.Available() }
+
+func (b collectorBackend) capabilities() capabilities { return b.caps() }
+
+func (b collectorBackend) collect(ctx context.Context) ([]process, []parseWarning, error) {
+	return b.collector.Collect(ctx)
+}
+
+// The backends in the order they're tried when auto-detecting, which is the order pvw.Collectors() returns them in
+var backends = []backend{
+	collectorBackend{pvw.SS(), ssCapabilities},
+	collectorBackend{pvw.Lsof(), baseCapabilities},
+	collectorBackend{pvw.Netstat(), netstatCapabilities},
+	collectorBackend{pvw.Diag(), diagCapabilities},
+	collectorBackend{pvw.Proc(), baseCapabilities},
+}
+
+// selectBackend() returns the backend with the given name, or the first available backend if the name is "auto"
+func selectBackend(name string) (backend, error) {
+	if name == "auto" {
+		for _, b := range backends {
+			if b.available() {
+				return b, nil
+			}
+		}
+		return nil, errors.New(tr("error.no-backend") + "\n\n" + backendReport())
+	}
+
+	for _, b := range backends {
+		if b.name() == name {
+			if !b.available() {
+				return nil, errors.New(tr("error.backend-unavailable", name, backendProblem(name)) + "\n\n" +
+					backendReport())
+			}
+			return b, nil
+		}
+	}
+
+	return nil, errors.New(tr("error.unknown-backend", name))
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Capabilities
+// Not every backend can find out everything, and what they can find out often depends on whether we're root. Rather than
+// showing empty columns or letting actions fail, the UI uses these to hide (or explain) anything that won't work.
+
+type capabilities struct {
+	processNames bool // Can tell which process owns each socket
+	owners       bool // Can tell which user owns each process
+	directories  bool // Can get the working directory of each process
+	otherUsers   bool // Can see the processes of other users, not just our own
+	kill         bool // Can terminate processes
+	backlog      bool // Can find the backlog of listening sockets
+	tcpInfo      bool // Can find the round trip time, retransmits and congestion window of TCP connections
+}
+
+// baseCapabilities() returns the capabilities that depend on the system rather than the backend, for a backend that
+// can see which process owns each socket
+func baseCapabilities() capabilities {
+	return capabilities{
+		processNames: true,
+		owners:       true,
+		directories:  canGetCwd(),       // getCwd() uses /proc or lsof
+		otherUsers:   os.Geteuid() == 0, // Only root can look inside other users' processes
+		kill:         true,              // pvw.Kill() signals processes directly
+	}
+}
+
+// ssCapabilities() adds the backlog of listening sockets, which only ss and netlink show
+func ssCapabilities() capabilities {
+	caps := baseCapabilities()
+	caps.backlog = true
+	return caps
+}
+
+// diagCapabilities() adds the backlog of listening sockets and TCP's view of each connection, which the kernel hands
+// over along with each socket
+func diagCapabilities() capabilities {
+	caps := baseCapabilities()
+	caps.backlog = true
+	caps.tcpInfo = true
+	return caps
+}
+
+// netstatCapabilities() checks if netstat supports -p. Some builds don't, in which case we have no idea which process
+// owns each socket.
+func netstatCapabilities() capabilities {
+	if exec.Command("netstat", "-tunap").Run() != nil {
+		return capabilities{}
+	}
+	return baseCapabilities()
+}
+
+// supportsColumn() checks if the backend can fill a column in
+func (c capabilities) supportsColumn(title string) bool {
+	switch title {
+	case "PID", "Name", "Nice", "CPU", "Memory", "Pod", "Namespace", "Framework", "Privilege":
+		return c.processNames
+	case "Owner":
+		return c.owners
+	case "Directory", "Project":
+		return c.directories
+	case "Backlog":
+		return c.backlog
+	case "RTT", "Retrans", "Cwnd":
+		return c.tcpInfo
+	}
+	return true
+}
+
+// notices() explains anything the backend can't do, to be shown under the table
+func (c capabilities) notices(backendName string) []string {
+	var notices []string
+
+	if !c.processNames {
+		notices = append(notices, tr("notice.no-processes", backendName))
+	} else if !c.otherUsers {
+		notices = append(notices, tr("notice.own-processes"))
+	}
+
+	if c.processNames && !c.directories {
+		notices = append(notices, tr("notice.no-directories"))
+	}
+
+	return notices
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Capability Report
+// When no backend can run (a hardened container without lsof, ss or netstat, and /proc hidden), "install lsof" isn't
+// much help - it might not be allowed, and something else might be easier. So pvw lists every backend it tried, why
+// each can't run, and what would fix it, along with --replay, which needs no backend at all:
+//
+//	ss       ss isn't installed (it's in iproute2)
+//	lsof     lsof isn't installed (pvw needs 4.94 or later)
+//	netstat  netstat isn't installed (it's in net-tools)
+//	diag     netlink's sock_diag isn't allowed (containers often block it)
+//	proc     /proc/net/tcp can't be read: no such file or directory (is /proc mounted?)
+
+// backendProblem() says why a backend can't run, or returns "" if it can
+func backendProblem(name string) string {
+	linuxOnly := map[string]bool{"ss": true, "netstat": true, "diag": true, "proc": true}
+	if linuxOnly[name] && runtime.GOOS != "linux" {
+		return tr("report.linux-only", runtime.GOOS)
+	}
+
+	switch name {
+	case "proc":
+		if _, err := os.Stat("/proc/net/tcp"); err != nil {
+			return tr("report.proc", err)
+		}
+		return ""
+	case "diag":
+		if _, err := os.Stat("/proc/net/tcp"); err != nil {
+			return tr("report.proc", err)
+		}
+		if !pvw.Diag().Available() {
+			return tr("report.diag")
+		}
+		return ""
+	case "lsof", "ss", "netstat":
+		if pvw.Pure {
+			return tr("report.pure")
+		}
+		if !commandExists(name) {
+			return tr("report.missing-" + name)
+		}
+	}
+	return ""
+}
+
+// backendReport() lists every backend with why it can't run (or that it can), followed by what to do about it. The
+// report ends without a full stop, as it's shown at the end of an error.
+func backendReport() string {
+	var b strings.Builder
+	var available []string
+	for _, backend := range backends {
+		problem := backendProblem(backend.name())
+		if problem == "" {
+			problem = tr("report.available")
+			available = append(available, backend.name())
+		}
+		fmt.Fprintf(&b, "  %-9s%s\n", backend.name(), problem)
+	}
+
+	b.WriteString("\n")
+	if pvw.Pure && len(available) == 0 {
+		b.WriteString(tr("report.fix-pure"))
+	} else if len(available) > 0 {
+		b.WriteString(tr("report.fix-available", available[0]))
+	} else if runtime.GOOS == "linux" {
+		b.WriteString(tr("report.fix-linux"))
+	} else {
+		b.WriteString(tr("report.fix-other"))
+	}
+	return b.String()
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// commandExists() checks if a command is installed and on the $PATH. Pure builds (see pvw.Pure) never run commands, so
+// everything that would checks here first, and carries on without whatever the command would have found out.
+func commandExists(command string) bool {
+	if pvw.Pure {
+		return false
+	}
+	_, err := exec.LookPath(command)
+	return err == nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Shared Ports
+// Two processes can listen on the same port if they bind it on different addresses: one on 0.0.0.0:3000 and another
+// on 127.0.0.1:3000, or one on [::1]:3000 and another on 127.0.0.1:3000. Both look like they own the port, but each
+// only gets some of its connections, which is behind a lot of "I restarted it but I'm still getting the old one".
+//
+// The kernel gives a connection to the socket bound to exactly the address it's for, and only if there isn't one, to
+// the socket bound to every address. An IPv6 socket bound to every address gets IPv4 connections too, unless it's
+// IPv6-only, or something is listening on IPv4 already. Browsers, curl and Node usually try ::1 before 127.0.0.1 for
+// localhost, so with an IPv6/IPv4 split, http://localhost:3000 and http://127.0.0.1:3000 go to different processes.
+//
+// The sockets on a port shared like this get a ⇄ badge, and the detail pane (i) says where each address goes. Ports
+// are grouped before filtering, so the badge still shows when the filters hide the other process.
+//
+// Several processes can also be bound to exactly the same address and port, either because they all set SO_REUSEPORT
+// (which is how a lot of servers spread connections across worker processes), or because they're workers that
+// inherited one socket from the process that forked them. Either way, the kernel hands each new connection to one of
+// them, so they're one listener rather than duplicates. Their sockets get a ⧉ badge with the number of processes in
+// the group, they're kept next to each other in the table under the first of them, and the detail pane lists the
+// rest.
+
+// The badge shown on the rows of a socket that shares its port with another process' socket on a different address
+const sharedBadge = "⇄ "
+
+// The badge shown on the rows of a socket bound to the same address and port as other processes' sockets, before the
+// number of processes
+const reusedBadge = "⧉"
+
+// boundSocket is a socket bound to a port, and the process it belongs to
+type boundSocket struct {
+	pid  int
+	name string
+	conn connection
+}
+
+// label() describes the process the socket belongs to
+func (b boundSocket) label() string {
+	return processLabel(process{ID: b.pid, Name: b.name})
+}
+
+// portGroup is every socket bound to the same port with the same protocol, in the order they were found
+type portGroup []boundSocket
+
+// isBound() checks if a connection is a socket bound to a port, rather than one connected to somewhere. UDP sockets
+// don't listen, so any without a remote end count.
+func isBound(conn connection) bool {
+	return conn.LocalPort != "" && conn.RemotePort == "" && (conn.Protocol == "UDP" || isListening(conn))
+}
+
+// bindKey is what makes two bound sockets the same bind: the address and which IP version it's for
+type bindKey struct {
+	ipv6    bool
+	address string
+}
+
+// key() returns the bind the socket is for
+func (b boundSocket) key() bindKey {
+	return bindKey{b.conn.IPv6, b.conn.LocalAddress}
+}
+
+// groupBindings() groups the bound sockets by protocol and port, and whatever else key() returns for them
+func groupBindings(processes []process, key func(conn connection) string) map[string]portGroup {
+	groups := make(map[string]portGroup)
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			if isBound(conn) && !proc.Windows {
+				k := conn.Protocol + " " + conn.LocalPort + " " + key(conn)
+				groups[k] = append(groups[k], boundSocket{pid: proc.ID, name: proc.Name, conn: conn})
+			}
+		}
+	}
+	return groups
+}
+
+// bySocket() returns the groups that match, by each of their sockets
+func bySocket(groups map[string]portGroup, match func(portGroup) bool) map[rowKey]portGroup {
+	matched := make(map[rowKey]portGroup)
+	for _, group := range groups {
+		if !match(group) {
+			continue
+		}
+		for _, b := range group {
+			matched[connectionKey(b.pid, b.conn)] = group
+		}
+	}
+	return matched
+}
+
+// findSharedPorts() groups the bound sockets by protocol and port, and returns the groups with sockets from different
+// processes on different addresses, by each of their sockets
+func findSharedPorts(processes []process) map[rowKey]portGroup {
+	groups := groupBindings(processes, func(connection) string { return "" })
+	return bySocket(groups, portGroup.split)
+}
+
+// findReusedPorts() groups the bound sockets by protocol, port and address, and returns the groups with sockets from
+// more than one process, by each of their sockets
+func findReusedPorts(processes []process) map[rowKey]portGroup {
+	groups := groupBindings(processes, func(conn connection) string {
+		return fmt.Sprint(conn.IPv6, " ", conn.LocalAddress)
+	})
+	return bySocket(groups, func(g portGroup) bool { return len(g.pids()) > 1 })
+}
+
+// pids() returns the processes with sockets in a group, once each, in the order they were found
+func (g portGroup) pids() []int {
+	var pids []int
+	for _, b := range g {
+		if !slices.Contains(pids, b.pid) {
+			pids = append(pids, b.pid)
+		}
+	}
+	return pids
+}
+
+// split() checks if a port's connections are split between processes, by two of them binding different addresses
+func (g portGroup) split() bool {
+	for i, a := range g {
+		for _, b := range g[i+1:] {
+			if a.pid != b.pid && a.key() != b.key() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// receivers() returns the processes bound to exactly the given bind, once each
+func (g portGroup) receivers(key bindKey) []string {
+	var labels []string
+	for _, b := range g {
+		if b.key() == key && !slices.Contains(labels, b.label()) {
+			labels = append(labels, b.label())
+		}
+	}
+	return labels
+}
+
+// explain() says where connections to each of a port's addresses go, most specific first
+func (g portGroup) explain() []string {
+	var binds []bindKey
+	families := make(map[bool]bool) // By whether they're IPv6
+	for _, b := range g {
+		if !slices.Contains(binds, b.key()) {
+			binds = append(binds, b.key())
+		}
+		families[b.conn.IPv6] = true
+	}
+
+	// Specific addresses first, then IPv4's wildcard, then IPv6's
+	sort.SliceStable(binds, func(i, j int) bool {
+		return binds[i].address != "*" && binds[j].address == "*" ||
+			(binds[i].address == "*" && binds[j].address == "*" && !binds[i].ipv6 && binds[j].ipv6)
+	})
+
+	port := g[0].conn.LocalPort
+	var lines []string
+	for _, bind := range binds {
+		receivers := strings.Join(g.receivers(bind), ", ")
+		switch {
+		case bind.address != "*":
+			lines = append(lines, tr("shared.address", bind.address+":"+port, receivers))
+		case !bind.ipv6:
+			lines = append(lines, tr("shared.wildcard-ipv4", port, receivers))
+		case !families[false]:
+			// Nothing's on IPv4, so a dual-stack socket gets its connections too
+			lines = append(lines, tr("shared.wildcard-dual", port, receivers))
+		default:
+			lines = append(lines, tr("shared.wildcard-ipv6", port, receivers))
+		}
+	}
+
+	if families[false] && families[true] {
+		lines = append(lines, tr("shared.localhost", port))
+	}
+	return lines
+}
+
+// sharedPortLines() describes the ports a process shares with other processes for the detail pane, a port at a time
+func sharedPortLines(proc process, shared map[rowKey]portGroup) [][]string {
+	var explained [][]string
+	seen := make(map[string]bool)
+	for _, conn := range proc.Connections {
+		group, found := shared[connectionKey(proc.ID, conn)]
+		if !found || seen[conn.Protocol+conn.LocalPort] {
+			continue
+		}
+		seen[conn.Protocol+conn.LocalPort] = true
+		explained = append(explained, append([]string{tr("details.shared", conn.Protocol, conn.LocalPort)},
+			group.explain()...))
+	}
+	return explained
+}
+
+// reusedPortLines() describes the listeners a process is one of for the detail pane, a listener at a time
+func reusedPortLines(proc process, reused map[rowKey]portGroup) [][]string {
+	var explained [][]string
+	seen := make(map[string]bool)
+	for _, conn := range proc.Connections {
+		group, found := reused[connectionKey(proc.ID, conn)]
+		address := conn.LocalAddress + ":" + conn.LocalPort
+		if !found || seen[conn.Protocol+address] {
+			continue
+		}
+		seen[conn.Protocol+address] = true
+
+		lines := []string{tr("details.reused", conn.Protocol, address, len(group.pids()))}
+		labels := []string{}
+		for _, b := range group {
+			if b.pid != proc.ID && !slices.Contains(labels, b.label()) {
+				labels = append(labels, b.label())
+			}
+		}
+		lines = append(lines, labels...)
+		explained = append(explained, append(lines, tr("reused.explain")))
+	}
+	return explained
+}
+
+// groupReusedPorts() moves the processes in each group bound to the same address and port up to just after the first
+// of them, keeping the order they're in otherwise
+func groupReusedPorts(processes []process, options settings) {
+	if len(options.reusedPorts) == 0 {
+		return
+	}
+
+	index := make(map[int]int, len(processes))
+	for i, proc := range processes {
+		index[proc.ID] = i
+	}
+	grouped := make([]process, 0, len(processes))
+	placed := make(map[int]bool, len(processes))
+	var place func(proc process)
+	place = func(proc process) {
+		if placed[proc.ID] {
+			return
+		}
+		placed[proc.ID] = true
+		grouped = append(grouped, proc)
+		for _, conn := range proc.Connections {
+			for _, pid := range options.reusedPorts[connectionKey(proc.ID, conn)].pids() {
+				if i, shown := index[pid]; shown {
+					place(processes[i])
+				}
+			}
+		}
+	}
+	for _, proc := range processes {
+		place(proc)
+	}
+	copy(processes, grouped)
+}
+
+// markSharedPorts() badges the rows of the sockets on a port shared with other processes, whether they're on
+// different addresses or the same one, like markExited(). The rows are shared with the row cache, so the badged rows
+// are copies. It returns whether it badged any.
+func markSharedPorts(rows []table.Row, ends []int, processes []process, options settings) bool {
+	if len(options.sharedPorts) == 0 && len(options.reusedPorts) == 0 {
+		return false
+	}
+	status := badgeColumn(options)
+
+	badged := false
+	for i, proc := range processes {
+		// A collapsed process only has the one row, which doesn't belong to any connection in particular
+		if options.collapsed[proc.ID] && len(proc.Connections) > 1 {
+			continue
+		}
+		end := len(rows)
+		if i+1 < len(ends) {
+			end = ends[i+1]
+		}
+		for j, conn := range proc.Connections {
+			row := ends[i] + j
+			if row >= end || status >= len(rows[row]) {
+				break
+			}
+			badge := ""
+			if _, found := options.sharedPorts[connectionKey(proc.ID, conn)]; found {
+				badge += sharedBadge
+			}
+			if group, found := options.reusedPorts[connectionKey(proc.ID, conn)]; found {
+				badge += fmt.Sprintf("%s%d ", reusedBadge, len(group.pids()))
+			}
+			if badge != "" {
+				rows[row] = append(table.Row{}, rows[row]...)
+				rows[row][status] = badge + rows[row][status]
+				badged = true
+			}
+		}
+	}
+	return badged
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Packet Capture
+// Once a connection looks odd, the next step is usually watching what's going over it. w starts tshark (or tcpdump,
+// if tshark isn't installed) with a capture filter for the selected connection: its protocol, and the addresses and
+// ports at both ends, or just the port for one that's listening. For example:
+//
+//	tcpdump -nn -l -i any 'tcp and host 127.0.0.1 and port 3000 and host 127.0.0.1 and port 51000'
+//
+// Capturing needs root, so sudo is put in front if pvw isn't running as root. Inside tmux, the capture gets a new pane
+// below pvw, so the table stays in view. Elsewhere it takes over the terminal, and ctrl+c stops it and goes back.
+
+// canCapture() checks if w can do anything here
+func canCapture() bool {
+	return runtime.GOOS != "windows" && (commandExists("tshark") || commandExists("tcpdump"))
+}
+
+// captureFilter() builds a capture filter (in pcap-filter syntax) for a connection. Each end's address and port are
+// matched either way round, so packets in both directions are captured.
+func captureFilter(conn connection) string {
+	parts := []string{strings.ToLower(conn.Protocol)}
+	for _, end := range [][2]string{{conn.LocalAddress, conn.LocalPort}, {conn.RemoteAddress, conn.RemotePort}} {
+		if address := strings.Trim(end[0], "[]"); address != "" && address != "*" {
+			parts = append(parts, "host "+address)
+		}
+		if end[1] != "" && end[1] != "*" {
+			parts = append(parts, "port "+end[1])
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// captureCommand() builds the command to capture a connection's packets with
+func captureCommand(conn connection) []string {
+	// Linux can capture from every interface at once. Elsewhere there's no such thing, so loopback connections use the
+	// loopback interface, and everything else is left to the default.
+	var iface []string
+	if runtime.GOOS == "linux" {
+		iface = []string{"-i", "any"}
+	} else if address := strings.Trim(conn.LocalAddress, "[]"); address == "127.0.0.1" || address == "::1" {
+		iface = []string{"-i", "lo0"}
+	}
+
+	var command []string
+	if commandExists("tshark") {
+		// Command is `tshark -n [-i INTERFACE] -f FILTER`
+		command = append(append([]string{"tshark", "-n"}, iface...), "-f", captureFilter(conn))
+	} else {
+		// Command is `tcpdump -nn -l [-i INTERFACE] FILTER`
+		command = append(append([]string{"tcpdump", "-nn", "-l"}, iface...), captureFilter(conn))
+	}
+	return withPrivileges(command)
+}
+
+// withPrivileges() puts sudo in front of a command that needs root, unless pvw is already running as root
+func withPrivileges(command []string) []string {
+	if os.Geteuid() == 0 || !commandExists("sudo") {
+		return command
+	}
+	return append([]string{"sudo"}, command...)
+}
+
+// handOff() runs a command for a closer look at what's selected, saying what it's running first. Inside tmux it gets a
+// new pane below pvw, which waits for enter once the command stops so its output can be read. Elsewhere it takes over
+// the terminal until it's stopped with ctrl+c, like an action (see plugins.go).
+func handOff(command []string) tea.Cmd {
+	quoted := make([]string, len(command))
+	for i, word := range command {
+		quoted[i] = shellQuote(word)
+	}
+	script := "printf '%s\\n\\n' " + shellQuote(tr("handoff.running", strings.Join(command, " "))) + "; " +
+		strings.Join(quoted, " ")
+	debugf("handing off to %q", strings.Join(command, " "))
+
+	if os.Getenv("TMUX") != "" && commandExists("tmux") {
+		return func() tea.Msg {
+			// Command is `tmux split-window -v SCRIPT`
+			script += "; printf '\\n%s' " + shellQuote(tr("handoff.done")) + "; read -r _"
+			if err := exec.Command("tmux", "split-window", "-v", script).Run(); err != nil {
+				return errMsg{err}
+			}
+			return nil
+		}
+	}
+
+	return tea.Exec(actionCommand{exec.Command("sh", "-c", script)}, func(err error) tea.Msg {
+		if err != nil {
+			return errMsg{err}
+		}
+		return nil
+	})
+}
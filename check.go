@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Check Mode
+// `pvw check 3000 5173` checks whether ports are free without starting the TUI, which is handy as a pre-start hook in a
+// Makefile or npm script. Each port gets a line saying whether it's free or which process has it, and the exit code
+// says whether they're all free:
+//
+//	0  Every port is free
+//	1  Something went wrong (e.g. a port that isn't a number, or the backend failed)
+//	3  At least one port is taken
+//
+// A port counts as taken if something is listening on it (or has a UDP socket bound to it). Outgoing connections that
+// happen to use it as their local port don't count, as they don't stop anything listening there. The backend can only
+// see the processes it's allowed to look inside of, so a port it says is free is bound to make sure, and one that's
+// already in use is taken by an unknown process.
+
+// runCheck() runs check mode with the arguments after `check`, and returns the exit code
+func runCheck(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("check", pflag.ContinueOnError)
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("check.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	w = quietOutput(w, *flagQuiet)
+
+	ports, err := parsePorts(flags.Args())
+	if err != nil || len(ports) == 0 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("error.running", err))
+		}
+		flags.Usage()
+		return exitError
+	}
+
+	listeners, err := collectListeners(*flagBackend, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	code := exitOK
+	for _, port := range ports {
+		holders := listeningOn(listeners, port)
+		if len(holders) == 0 && boundElsewhere(port) {
+			code = exitConflict
+			fmt.Fprintln(w, tr("check.taken", port, tr("check.unknown-process")))
+			continue
+		}
+		if len(holders) == 0 {
+			fmt.Fprintln(w, tr("check.free", port))
+			continue
+		}
+
+		code = exitConflict
+		for _, proc := range holders {
+			fmt.Fprintln(w, tr("check.taken", port, processLabel(proc)))
+		}
+	}
+
+	return code
+}
+
+// parsePorts() converts port numbers given as arguments to ints, checking they're valid ports
+func parsePorts(args []string) ([]int, error) {
+	ports := make([]int, 0, len(args))
+	for _, arg := range args {
+		port, err := strconv.Atoi(arg)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, errors.New(tr("error.invalid-port", arg))
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// boundElsewhere() checks if a port can't be bound for TCP or UDP because something's already using it, for when the
+// backend can't see what. Ports below 1024 need root to bind, so not being allowed to doesn't mean they're in use.
+func boundElsewhere(port int) bool {
+	address := ":" + strconv.Itoa(port)
+	listener, err := net.Listen("tcp", address)
+	if err == nil {
+		listener.Close()
+		var packets net.PacketConn
+		if packets, err = net.ListenPacket("udp", address); err == nil {
+			packets.Close()
+		}
+	}
+	if err != nil {
+		debugf("port %d is free according to the backend, but can't be bound: %v", port, err)
+	}
+	return err != nil && !errors.Is(err, os.ErrPermission)
+}
+
+// collectListeners() gets every process with a port open from the named backend, along with the Windows side's
+// listeners when running under WSL (as those stop Linux programs using the port too)
+func collectListeners(backendName string, timeout time.Duration) ([]process, error) {
+	selected, err := selectBackend(backendName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	all, _, err := collectWithRetries(ctx, func() ([]process, []parseWarning, error) { return selected.collect(ctx) })
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, errors.New(tr("error.backend-timeout", selected.name(), timeout))
+	}
+	if err != nil {
+		return nil, explainFailure(err, selected.name())
+	}
+
+	if pvw.Windows().Available() {
+		if listeners, _, err := windowsListeners(ctx); err == nil {
+			all = append(all, listeners...)
+		}
+	}
+
+	return all, nil
+}
+
+// listeningOn() returns the processes listening on a port, with only those connections in each process
+func listeningOn(processes []process, port int) []process {
+	portString := strconv.Itoa(port)
+	var found []process
+
+	for _, proc := range processes {
+		var connections []connection
+		for _, conn := range proc.Connections {
+			if isListening(conn) && conn.LocalPort == portString {
+				connections = append(connections, conn)
+			}
+		}
+
+		if len(connections) > 0 {
+			proc.Connections = connections
+			found = append(found, proc)
+		}
+	}
+
+	return found
+}
+
+// isListening() checks if a connection is waiting for connections rather than connected to something. UDP sockets don't
+// have a state (and neither does anything from an lsof without the T field), so any without a remote end count as
+// listening.
+func isListening(conn connection) bool {
+	return conn.Status == "LISTEN" || (conn.Status == "" && conn.RemotePort == "")
+}
+
+// processLabel() describes a process for check mode, e.g. node (PID 1234)
+func processLabel(proc process) string {
+	if proc.ID == 0 {
+		return tr("check.unknown-process")
+	}
+
+	name := proc.Name
+	if proc.Windows {
+		name = "win:" + name
+	}
+	return tr("check.process", name, proc.ID)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// TestBoundElsewhere checks a port something's listening on counts as taken even when the backend didn't find it
+func TestBoundElsewhere(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Skip("can't listen here:", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if !boundElsewhere(port) {
+		t.Errorf("expected port %d to be taken while it's being listened on", port)
+	}
+	listener.Close()
+	if boundElsewhere(port) {
+		t.Errorf("expected port %d to be free once it's closed", port)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Threads and Children
+// Servers that fork workers (nginx, gunicorn, postgres...) show up as a parent and a handful of children, and it isn't
+// always obvious which to terminate. The detail pane (i) shows how many threads and child processes the selected
+// process has, and C filters the table down to its children (from the table or from the detail pane). esc goes back to
+// showing everything.
+//
+// The children are found when C is pressed, so ones started afterwards don't show up until it's pressed again.
+
+// processThreads() counts a process' threads, from the Threads line in /proc/<pid>/status, or `ps -M` elsewhere
+func processThreads(pid int) (int, bool) {
+	status, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "status"))
+	if err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if strings.HasPrefix(line, "Threads:") {
+				threads, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Threads:")))
+				return threads, err == nil
+			}
+		}
+		return 0, false
+	}
+	if !commandExists("ps") {
+		return 0, false
+	}
+
+	// Not Linux, so ask ps. Command is `ps -M -p PID`, which prints a header and then a line for each thread.
+	out, err := exec.Command("ps", "-M", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, false
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return len(lines) - 1, len(lines) > 1
+}
+
+// childProcesses() lists the processes started directly by a process
+func childProcesses(pid int) ([]int, error) {
+	if pvw.Pure {
+		return nil, errors.New(tr("error.pure-command", "ps"))
+	}
+
+	// Command is `ps -A -o pid= -o ppid=`, which prints every process with its parent
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var children []int
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[1] != strconv.Itoa(pid) {
+			continue
+		}
+		if child, err := strconv.Atoi(fields[0]); err == nil {
+			children = append(children, child)
+		}
+	}
+	return children, nil
+}
+
+// threadsLine() describes how many threads a process has, for the detail pane
+func threadsLine(pid int) string {
+	threads, known := processThreads(pid)
+	if !known {
+		return "?"
+	}
+	return strconv.Itoa(threads)
+}
+
+// childrenLine() describes how many children a process has, for the detail pane
+func childrenLine(pid int) string {
+	children, err := childProcesses(pid)
+	if err != nil {
+		return "?"
+	}
+	if len(children) == 0 {
+		return "0"
+	}
+	return tr("details.children-count", len(children))
+}
+
+// showChildren() filters the table down to a process' children
+func (m *model) showChildren(proc process) tea.Cmd {
+	children, err := childProcesses(proc.ID)
+	if err != nil {
+		m.err = err
+		m.fitTable()
+		return nil
+	}
+
+	m.closeDetail()
+	m.settings.children = children
+	m.settings.childrenOf = processLabel(proc)
+	m.fitTable()
+	m.announce(tr("notice.children", m.settings.childrenOf))
+	return m.rerender()
+}
+
+// showAllProcesses() stops filtering the table down to a process' children
+func (m *model) showAllProcesses() tea.Cmd {
+	m.settings.children = nil
+	m.settings.childrenOf = ""
+	m.fitTable()
+	return m.rerender()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Collapsing Processes
+// Browsers and torrent clients can have hundreds of connections open, which pushes everything else off the screen.
+// ← collapses the selected process down to a single summary row ("chrome  ▸ 87 conns"), → expands it again, and
+// enter switches between the two. Processes stay collapsed across refreshes until they're expanded or pvw quits.
+//
+// A collapsed process' row stands for its first connection, so keys that act on a connection (T, H, o...) use that
+// one.
+
+// setCollapsed() collapses or expands a process. The settings are shared with collections running in the
+// background, so the set of collapsed processes is copied rather than changed.
+func (m *model) setCollapsed(pid int, collapsed bool) {
+	updated := make(map[int]bool, len(m.settings.collapsed)+1)
+	for id := range m.settings.collapsed {
+		updated[id] = true
+	}
+	if collapsed {
+		updated[pid] = true
+	} else {
+		delete(updated, pid)
+	}
+	m.settings.collapsed = updated
+}
+
+// collapseRows() swaps a collapsed process' rows for a summary row: its first row, with the connection's details
+// replaced by how many connections it has
+func collapseRows(rows []table.Row, proc process, options settings) []table.Row {
+	if !options.collapsed[proc.ID] || len(rows) < 2 {
+		return rows
+	}
+
+	summary := make(table.Row, len(rows[0]))
+	copy(summary, rows[0])
+
+	// The summary goes in the widest of the connection's columns, so as little of it is cut off as possible
+	widest := -1
+	for column, c := range options.columns {
+		if processColumn(c.Title, options) {
+			continue
+		}
+		summary[column] = ""
+		if widest == -1 || c.Width > options.columns[widest].Width {
+			widest = column
+		}
+	}
+	if widest != -1 {
+		summary[widest] = tr("collapse.summary", len(proc.Connections))
+	}
+
+	return []table.Row{summary}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Config File
+// Anything that's too fiddly to pass as a flag every time lives in a JSON config file. By default it's
+// $XDG_CONFIG_HOME/pvw/config.json (~/.config/pvw/config.json) on Linux, or
+// ~/Library/Application Support/pvw/config.json on macOS, and --config can point pvw at a different one. It's fine for
+// the file not to exist.
+
+type config struct {
+	Columns []pluginColumn `json:"columns,omitempty"` // Extra columns, filled in by running a command for each process
+	Actions []pluginAction `json:"actions,omitempty"` // Extra keys, which run a command for the selected connection
+
+	Highlights []highlightRule `json:"highlights,omitempty"` // Rows to make stand out (see highlight.go)
+	Alerts     []alertRule     `json:"alerts,omitempty"`     // Banners to show in watch mode (see alerts.go)
+	Protected  []string        `json:"protected,omitempty"`  // Names t asks for the PID of first (see protect.go)
+
+	// Defaults the setup wizard asks about (see setup.go). Flags win over them.
+	Theme            string   `json:"theme,omitempty"`    // The table's colours: teal (the default), blue or mono
+	Show             []string `json:"show,omitempty"`     // Columns to show, by the name of their --show- flag
+	Interval         string   `json:"interval,omitempty"` // How often to refresh, the same as --interval
+	ConfirmTerminate bool     `json:"confirm_terminate,omitempty"`
+
+	HideTips     bool `json:"hide_tips,omitempty"`     // Never show the tips line (see tips.go)
+	CheckUpdates bool `json:"check_updates,omitempty"` // Check for a newer release when the TUI starts (see update.go)
+}
+
+// defaultConfigPath() returns where the config file is if --config isn't passed
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pvw", "config.json")
+}
+
+// loadConfig() reads the config file at the given path. If it doesn't exist, an empty config is returned.
+func loadConfig(path string) (config, error) {
+	var cfg config
+	if path == "" {
+		return cfg, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+// protectedNames() returns the names of the processes to protect, which are the defaults unless the config file says
+func (c config) protectedNames() []string {
+	if c.Protected != nil {
+		return c.Protected
+	}
+	return defaultProtected
+}
+
+// validate() checks everything in the config makes sense, so mistakes are caught when pvw starts rather than when
+// something is used
+func (c config) validate() error {
+	if c.Theme != "" && !slices.Contains(themes, c.Theme) {
+		return errors.New(tr("error.config-theme", c.Theme, strings.Join(themes, ", ")))
+	}
+	for _, column := range c.Show {
+		if !slices.Contains(setupColumns, column) {
+			return errors.New(tr("error.config-show", column, strings.Join(setupColumns, ", ")))
+		}
+	}
+	if _, err := time.ParseDuration(c.Interval); c.Interval != "" && err != nil {
+		return errors.New(tr("error.config-interval", c.Interval))
+	}
+
+	for i := range c.Highlights {
+		if err := c.Highlights[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.Alerts {
+		if err := c.Alerts[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, column := range c.Columns {
+		if column.Title == "" || column.Command == "" {
+			return errors.New(tr("error.plugin-column"))
+		}
+		if builtInColumn(column.Title) {
+			return errors.New(tr("error.plugin-title", column.Title))
+		}
+	}
+
+	for _, action := range c.Actions {
+		if action.Key == "" || action.Command == "" {
+			return errors.New(tr("error.plugin-action"))
+		}
+		if builtInKey(action.Key) {
+			return errors.New(tr("error.plugin-key", action.Key))
+		}
+	}
+
+	return nil
+}
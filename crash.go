@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Crash Handling
+// Collection and parsing happen inside Cmds, which bubbletea runs in their own goroutines without recovering from
+// panics. A panic there (e.g. from output a parser didn't expect) would kill pvw without restoring the terminal,
+// leaving the shell in raw mode with a stack trace smeared across it. Instead, the panic is caught and sent to Update
+// as a message, so pvw can quit properly and print what went wrong once the terminal is back to normal.
+
+// panicMsg is sent when a Cmd panics
+type panicMsg struct {
+	value any    // What the Cmd panicked with
+	stack []byte // The stack trace of the panic
+}
+
+// catchPanics() wraps a Cmd so that if it panics, a panicMsg is returned instead of crashing the whole program
+func catchPanics(cmd tea.Cmd) tea.Cmd {
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+
+				// Parsers include the stack trace from where they originally panicked
+				if recordPanic, isRecordPanic := r.(lsof.RecordPanic); isRecordPanic {
+					stack = recordPanic.Stack
+				}
+
+				debugf("recovered from a panic: %v\n%s", r, stack)
+				msg = panicMsg{value: r, stack: stack}
+			}
+		}()
+
+		return cmd()
+	}
+}
+
+// printCrash() writes out a diagnostic for a panic, including the record being parsed if there was one, for the user
+// to attach to a bug report
+func printCrash(w io.Writer, crash panicMsg, backendName string) {
+	fmt.Fprintln(w, "pvw crashed. This is a bug - please report it at https://github.com/allyring/pvw/issues and include")
+	fmt.Fprintln(w, "everything below (check it for anything you'd rather not share first).")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Backend: %s\n", backendName)
+
+	if recordPanic, isRecordPanic := crash.value.(lsof.RecordPanic); isRecordPanic {
+		fmt.Fprintf(w, "Panic: %v\n", recordPanic.Value)
+		fmt.Fprintf(w, "While parsing line %d: %q\n", recordPanic.Line, recordPanic.Record)
+	} else {
+		fmt.Fprintf(w, "Panic: %v\n", crash.value)
+	}
+
+	fmt.Fprintf(w, "\n%s", crash.stack)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Dashboard
+// A busy machine can have hundreds of rows, which is a lot to take in before knowing what to look for. D shows a
+// summary in the detail pane instead: how many connections are in each state, the processes with the most
+// connections, and the remote hosts with the most connections, each with a bar so the big ones stand out:
+//
+//	LISTEN          12  ████
+//	ESTABLISHED     40  █████████████
+//	TIME_WAIT      118  ████████████████████████████████████████
+//
+// It's built from what the table is showing, so filters and searches apply, and it's kept up to date on every refresh
+// while it's open. esc goes back to the table.
+
+// How many processes and remote hosts the dashboard lists
+const dashboardTop = 10
+
+// The longest a dashboard bar gets
+const dashboardBarWidth = 40
+
+// The style used for the dashboard's bars
+var dashboardBarStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#33a989"))
+
+// connectionState() returns a connection's state as the Status column shows it. UDP sockets don't have states, so
+// ones without a remote end get ss' name for waiting for packets, or MCAST if they're in a multicast group.
+func connectionState(conn connection) string {
+	if conn.Status != "" || conn.Protocol != "UDP" || conn.RemotePort != "" {
+		return strings.ToUpper(conn.Status)
+	}
+	if lsof.Multicast(conn.LocalAddress) {
+		return "MCAST"
+	}
+	return "UNCONN"
+}
+
+// dashboardCount is a line of the dashboard: something, and how many connections it has
+type dashboardCount struct {
+	label string
+	count int
+}
+
+// showDashboard() shows the dashboard in the detail pane, for the processes in the table
+func showDashboard(processes []process, counts []int) tea.Cmd {
+	return func() tea.Msg {
+		return detailMsg{title: tr("dashboard.title"), body: dashboardReport(processes, counts)}
+	}
+}
+
+// dashboardReport() summarises the processes' connections by state, process and remote host, with a sparkline of the
+// number of connections over the last few refreshes in watch mode
+func dashboardReport(processes []process, counts []int) string {
+	states := make(map[string]int)
+	remotes := make(map[string]int)
+	var byProcess []dashboardCount
+	total := 0
+
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			state := connectionState(conn)
+			if state == "" {
+				state = "-"
+			}
+			states[state]++
+			if conn.RemoteAddress != "" {
+				remotes[strings.Trim(conn.RemoteAddress, "[]")]++
+			}
+		}
+		byProcess = append(byProcess, dashboardCount{processLabel(proc), len(proc.Connections)})
+		total += len(proc.Connections)
+	}
+
+	var b strings.Builder
+	fmt.Fprintln(&b, tr("dashboard.summary", len(processes), total))
+	if len(counts) > 1 {
+		fmt.Fprintln(&b, tr("dashboard.history", len(counts), sparkline(counts)))
+	}
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, tr("dashboard.states"))
+	writeDashboardCounts(&b, sortedCounts(states, len(states)))
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, tr("dashboard.processes"))
+	sortCounts(byProcess)
+	if len(byProcess) > dashboardTop {
+		byProcess = byProcess[:dashboardTop]
+	}
+	writeDashboardCounts(&b, byProcess)
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, tr("dashboard.remotes"))
+	writeDashboardCounts(&b, sortedCounts(remotes, dashboardTop))
+
+	return b.String()
+}
+
+// sortedCounts() returns up to the given number of a map's counts, biggest first
+func sortedCounts(counts map[string]int, limit int) []dashboardCount {
+	var sorted []dashboardCount
+	for label, count := range counts {
+		sorted = append(sorted, dashboardCount{label, count})
+	}
+	sortCounts(sorted)
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// sortCounts() sorts counts biggest first, then by label so the order doesn't change between refreshes
+func sortCounts(counts []dashboardCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].label < counts[j].label
+	})
+}
+
+// writeDashboardCounts() writes a section of the dashboard, with a bar for each count scaled to the biggest one
+func writeDashboardCounts(b *strings.Builder, counts []dashboardCount) {
+	if len(counts) == 0 {
+		fmt.Fprintln(b, "  "+tr("dashboard.none"))
+		return
+	}
+
+	// The first count is the biggest, so the labels are padded to line the bars up
+	biggest, labelWidth := counts[0].count, 0
+	for _, c := range counts {
+		if width := lipgloss.Width(c.label); width > labelWidth {
+			labelWidth = width
+		}
+	}
+
+	for _, c := range counts {
+		bar := 1
+		if biggest > 0 {
+			bar = c.count * dashboardBarWidth / biggest
+		}
+		if bar < 1 {
+			bar = 1
+		}
+		padding := strings.Repeat(" ", labelWidth-lipgloss.Width(c.label))
+		fmt.Fprintf(b, "  %s%s  %5d  %s\n", c.label, padding, c.count, dashboardBarStyle.Render(strings.Repeat("█", bar)))
+	}
+}
+
+// refreshDashboard() rebuilds the dashboard if it's open, so it keeps up with the table. It isn't rebuilt in plain
+// mode, where it would be written out again on every refresh.
+func (m *model) refreshDashboard() {
+	if m.showDetail && m.detailTitle == tr("dashboard.title") && m.output == nil {
+		m.openDetail(detailMsg{title: tr("dashboard.title"), body: dashboardReport(m.processes, m.connectionCounts)})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Debug Logging
+// The TUI takes over stdout, so when --debug is passed everything useful for a bug report (the raw output of the
+// backend, records that couldn't be parsed, how long things took, and any processes killed) is written to a file
+// instead. Attaching that file to an issue makes parse failures on other systems much easier to track down.
+
+// Whether --debug was passed. Nothing gets logged if it wasn't, as the log package writes to stderr by default.
+var debugEnabled bool
+
+// startDebugLog() sends the log package's output to the given file, appending to it if it already exists. The file
+// should be closed once the TUI has quit.
+func startDebugLog(path string) (*os.File, error) {
+	f, err := tea.LogToFile(path, "pvw")
+	if err != nil {
+		return nil, err
+	}
+
+	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
+	debugEnabled = true
+
+	return f, nil
+}
+
+// debugf() writes a line to the debug log, if there is one
+func debugf(format string, args ...any) {
+	if debugEnabled {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Density
+// --density compact fits more onto small screens. The table loses its border, the line under its header, and the
+// padding on the left of each cell, which gives three more rows and a column's worth of width. With less space between
+// rows it's harder to tell which process a row belongs to, so the process' details (PID, Name, Owner...) are repeated
+// on every one of its rows rather than only the first. The default is comfortable.
+
+// The densities --density accepts
+const (
+	densityComfortable = "comfortable"
+	densityCompact     = "compact"
+)
+
+// The columns that describe a process rather than a connection, which only the first row of a process normally has
+var processColumnTitles = []string{
+	"PID", "Name", "Directory", "Project", "Framework", "Owner", "Nice", "CPU", "Memory", "Pod", "Namespace", "Net NS",
+}
+
+// validDensity() checks if a density is one --density accepts
+func validDensity(density string) bool {
+	return density == densityComfortable || density == densityCompact
+}
+
+// tableStyles() returns the table's styles for a density and theme (see setup.go)
+func tableStyles(density string, theme string) table.Styles {
+	s := table.DefaultStyles()
+
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("7")).
+		Background(lipgloss.Color("#33a989")).
+		Bold(false)
+
+	switch theme {
+	case "blue":
+		s.Selected = s.Selected.Background(lipgloss.Color("#3366cc"))
+	case "mono":
+		// Reversed rather than coloured, for terminals without colours (or people who'd rather not have them)
+		s.Selected = s.Selected.UnsetForeground().UnsetBackground().Reverse(true)
+		s.Header = s.Header.UnsetBorderForeground()
+	}
+
+	if density == densityCompact {
+		s.Header = s.Header.BorderBottom(false).Underline(true).PaddingLeft(0)
+		s.Cell = s.Cell.PaddingLeft(0)
+	}
+
+	return s
+}
+
+// frameStyle() returns the style the table (or detail pane) is drawn inside, which has a border unless it's compact
+func (o settings) frameStyle() lipgloss.Style {
+	if o.density == densityCompact {
+		return lipgloss.NewStyle()
+	}
+	return baseStyle
+}
+
+// headerLines() returns how many lines the table's header takes up
+func (o settings) headerLines() int {
+	if o.density == densityCompact {
+		return 1
+	}
+	return 2 // The header and the line under it
+}
+
+// frameLines() returns how many lines the table's border takes up
+func (o settings) frameLines() int {
+	if o.density == densityCompact {
+		return 0
+	}
+	return 2
+}
+
+// cellPadding() returns how much padding each cell has, on both sides together
+func (o settings) cellPadding() int {
+	if o.density == densityCompact {
+		return 1
+	}
+	return 2
+}
+
+// fillProcessColumns() copies a process' details from its first row to the rest of its rows, when compact
+func fillProcessColumns(rows []table.Row, options settings) {
+	if options.density != densityCompact || len(rows) < 2 {
+		return
+	}
+
+	for _, row := range rows[1:] {
+		copyProcessColumns(row, rows[0], options)
+	}
+}
+
+// copyProcessColumns() fills in a row's empty process columns from the first row of its process. It returns the index
+// of the first column it filled, or -1 if there weren't any to fill.
+func copyProcessColumns(row table.Row, first table.Row, options settings) int {
+	filled := -1
+	for column, c := range options.columns {
+		if !processColumn(c.Title, options) || row[column] != "" || first[column] == "" {
+			continue
+		}
+		row[column] = first[column]
+		if filled == -1 {
+			filled = column
+		}
+	}
+	return filled
+}
+
+// processColumn() checks if a column describes a process rather than a connection. Columns from the config file do.
+func processColumn(title string, options settings) bool {
+	if slices.Contains(processColumnTitles, title) {
+		return true
+	}
+	for _, plugin := range options.pluginColumns {
+		if plugin.Title == title {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Detail Pane
+// Some things have more to say about a connection than fits in a column (e.g. `kubectl describe pod`). They're shown in
+// the detail pane, which takes the table's place until it's closed with esc. While it's open, the movement keys scroll
+// it instead of the table.
+//
+// A pane about a process (see info.go) remembers which process it was, so it can be rebuilt in place, e.g. when v
+// reveals the secrets in its environment.
+
+// detailMsg opens the detail pane with some text
+type detailMsg struct {
+	title string
+	body  string
+
+	proc   *process    // The process the pane is about, if it's about one
+	conn   *connection // The process' connection that was selected, if there was one
+	reveal bool        // Whether secrets are shown in the pane
+}
+
+// The style used for the detail pane's title
+var detailTitleStyle = lipgloss.NewStyle().Bold(true)
+
+// openDetail() shows the detail pane, the same size as the table it's replacing. It's made wider if what's in it
+// wouldn't fit, as far as the terminal allows.
+func (m *model) openDetail(msg detailMsg) {
+	width := 0
+	for _, column := range m.columnWidths() {
+		width += column.Width + m.settings.cellPadding()
+	}
+	for _, line := range strings.Split(msg.body, "\n") {
+		if lineWidth := lipgloss.Width(line); lineWidth > width {
+			width = lineWidth
+		}
+	}
+	if limit := m.help.Width - m.settings.frameStyle().GetHorizontalFrameSize(); m.help.Width > 0 && width > limit {
+		width = limit
+	}
+
+	// Rebuilding the pane that's already open shouldn't lose the place in it
+	offset := 0
+	if m.showDetail && m.detailTitle == msg.title {
+		offset = m.detail.YOffset
+	}
+
+	// The table's header (and the line under it) is used for the title instead
+	m.detail = viewport.New(width, m.table.Height()+m.settings.headerLines()-1)
+	m.detail.SetContent(strings.TrimRight(msg.body, "\n"))
+	m.detail.SetYOffset(offset)
+	m.detailTitle = msg.title
+	m.detailProcess = msg.proc
+	m.detailConnection = msg.conn
+	m.revealSecrets = msg.reveal
+	m.showDetail = true
+
+	m.announce(msg.title, msg.body)
+}
+
+// closeDetail() hides the detail pane, and goes back to the table
+func (m *model) closeDetail() {
+	m.showDetail = false
+	m.detailTitle = ""
+	m.detailProcess = nil
+	m.detailConnection = nil
+	m.revealSecrets = false
+}
+
+// updateDetail() passes keys on to the detail pane while it's open, so they scroll it
+func (m model) updateDetail(msg tea.Msg) (model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.detail, cmd = m.detail.Update(msg)
+	return m, cmd
+}
+
+// detailView() renders the detail pane, with its title where the table's header would be
+func (m model) detailView() string {
+	// The viewport doesn't pad out short lines, so pad them here to keep the border where the table's was
+	return lipgloss.NewStyle().Width(m.detail.Width).Render(detailTitleStyle.Render(m.detailTitle) + "\n" + m.detail.View())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Closing Connections
+// Sometimes it's one client that's stuck, and terminating the whole server to get rid of it is overkill. ctrl+k closes
+// just the selected connection, and the process that had it open sees it reset, the same as if the other end had gone.
+// It's handy for checking a client reconnects properly, too.
+//
+// It's Linux only, as it's done with `ss -K`, which asks the kernel to destroy the socket. That needs root (or
+// CAP_NET_ADMIN), and a kernel built with CONFIG_INET_DIAG_DESTROY, which most distributions' are. Like t, it asks
+// first if "confirm_terminate" is set in the config file.
+
+// How long ss gets to close a connection
+const closeConnectionTimeout = 5 * time.Second
+
+// closeConnectionMsg says a connection was closed
+type closeConnectionMsg struct{}
+
+// canCloseConnections() checks if ctrl+k can do anything here
+func canCloseConnections() bool {
+	return runtime.GOOS == "linux" && commandExists("ss")
+}
+
+// closeConnection() closes a single connection, leaving its process running
+func closeConnection(conn connection) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), closeConnectionTimeout)
+		defer cancel()
+
+		err := pvw.CloseConnection(ctx, conn)
+		switch {
+		case errors.Is(err, pvw.ErrCantCloseConnection):
+			return errMsg{errors.New(tr("error.close-connection-unsupported"))}
+		case errors.Is(err, pvw.ErrConnectionNotClosed):
+			return errMsg{errors.New(tr("error.connection-not-closed"))}
+		case err != nil:
+			return errMsg{err}
+		}
+		return closeConnectionMsg{}
+	}
+}
+
+// closeSelectedConnection() closes the connection on the selected row, asking first if the config file says to
+func (m *model) closeSelectedConnection() tea.Cmd {
+	processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor())
+	if !exists || len(m.processes[processIndex].Connections) == 0 {
+		return nil
+	}
+	proc := m.processes[processIndex]
+	conn := proc.Connections[connectionIndex]
+	if conn.Protocol != "TCP" || conn.RemotePort == "" {
+		m.err = errors.New(tr("error.close-connection-unsupported"))
+		return nil
+	}
+
+	if !m.settings.confirmTerminate {
+		return catchPanics(closeConnection(conn))
+	}
+	remote := conn.RemoteAddress + ":" + conn.RemotePort
+	m.openPrompt(tr("prompt.close-connection", remote, proc.Name), "", func(answer string) tea.Cmd {
+		if !strings.HasPrefix(strings.ToLower(answer), "y") {
+			return nil
+		}
+		return closeConnection(conn)
+	})
+	return textinput.Blink
+}
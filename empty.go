@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Empty State
+// An empty table looks like pvw is broken, or still loading. So once a collection has finished with nothing to show,
+// the table's place is taken by a message saying so, with whatever's narrowing the table down and how to undo it:
+//
+//	No connections to show
+//
+//	Showing only:
+//	  searching for "nod" (/ to change it)
+//	  --ports 3000
+//
+// If nothing's narrowing it down, there really aren't any sockets open (that pvw can see), and it says that instead.
+
+// The style used for the empty state's heading
+var emptyTitleStyle = lipgloss.NewStyle().Bold(true)
+
+// emptyReasons() lists what's narrowing the table down, each with how to undo it if there's a key for it
+func (m model) emptyReasons() []string {
+	var reasons []string
+	if m.settings.searchTerm != "" {
+		reasons = append(reasons, tr("empty.search", m.settings.searchTerm))
+	}
+	for _, term := range m.settings.filters {
+		reasons = append(reasons, tr("empty.filter", term.String()))
+	}
+	if m.settings.childrenOf != "" {
+		reasons = append(reasons, tr("empty.children", m.settings.childrenOf))
+	}
+	if label := protocolsLabel(m.settings); label != "TCP+UDP" {
+		reasons = append(reasons, tr("empty.protocols", label))
+	}
+
+	// Anything from the command line can only be changed by starting again, so it's shown as it was passed
+	for _, flag := range []struct {
+		name   string
+		values []string
+	}{
+		{"--ports", m.settings.portFilter},
+		{"--interface", m.settings.interfaceFilter},
+		{"--host", m.settings.hostFilter},
+		{"--exclude-name", m.settings.excludeNames},
+		{"--exclude-ports", m.settings.excludePorts},
+		{"--exclude-user", m.settings.excludeUsers},
+	} {
+		if len(flag.values) > 0 {
+			reasons = append(reasons, flag.name+" "+strings.Join(flag.values, ","))
+		}
+	}
+	if len(m.settings.nameFilter) > 0 {
+		reasons = append(reasons, tr("empty.names", strings.Join(m.settings.nameFilter, ", ")))
+	}
+	if m.settings.listenOnly {
+		reasons = append(reasons, "--listen-only")
+	}
+	if !m.settings.showIPv4 || !m.settings.showIPv6 {
+		reasons = append(reasons, tr("empty.ip-versions"))
+	}
+	return reasons
+}
+
+// emptyLines() returns what to say instead of an empty table, or nil if the table isn't empty (or pvw hasn't finished
+// looking yet)
+func (m model) emptyLines() []string {
+	if len(m.rows) > 0 || m.collectedAt.IsZero() {
+		return nil
+	}
+
+	reasons := m.emptyReasons()
+	if len(reasons) == 0 {
+		return []string{tr("empty.title"), "", tr("empty.nothing")}
+	}
+
+	lines := []string{tr("empty.title"), "", tr("empty.only")}
+	for _, reason := range reasons {
+		lines = append(lines, "  "+reason)
+	}
+	return lines
+}
+
+// emptyView() renders the empty state the same size as the table it's replacing, so the border stays put. Like the
+// detail pane, it's made wider if what's in it wouldn't fit, as far as the terminal allows.
+func (m model) emptyView(lines []string) string {
+	width := 0
+	for _, column := range m.columnWidths() {
+		width += column.Width + m.settings.cellPadding()
+	}
+	for _, line := range lines {
+		if lineWidth := lipgloss.Width(line); lineWidth > width {
+			width = lineWidth
+		}
+	}
+	if limit := m.help.Width - m.settings.frameStyle().GetHorizontalFrameSize(); m.help.Width > 0 && width > limit {
+		width = limit
+	}
+
+	lines[0] = emptyTitleStyle.Render(lines[0])
+	body := strings.Join(lines, "\n")
+	height := m.table.Height() + m.settings.headerLines()
+	return lipgloss.NewStyle().Width(width).Height(height).MaxHeight(height).Render(body)
+}
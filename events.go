@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Connection Events
+// Watch mode only sees what's open at the moment it collects, so A keeps a log of what's changed, newest first, in the
+// detail pane:
+//
+//	15:04:05.123  connect  TCP  curl (PID 4242)  10.0.0.2:51000 → 93.184.216.34:443
+//	15:04:05.120  close    TCP  nginx (PID 880)  10.0.0.2:80 → 10.0.0.9:50122
+//	15:04:03.000  opened   UDP  dnsmasq (PID 77)  *:53
+//
+// Between collections it's the connections that opened or closed since the last one. A connection that opens and
+// closes in between is never seen, which for a health check or a busy HTTP client is most of them. So with --ebpf on
+// Linux, the kernel says as it happens whenever a TCP connection is connected, accepted or closed, using bpftrace to
+// attach eBPF probes to tcp_connect, inet_csk_accept and tcp_close. Only bpftrace's output is read, so pvw doesn't
+// need to load eBPF programs itself, but it does need bpftrace installed, a kernel with BTF, and root; sudo is asked
+// for the password before the table takes over the terminal. If any of that fails, the log carries on with what
+// collections see.
+//
+// The kernel's events are for every process, whatever's filtered, and UDP doesn't have any. In plain mode they're
+// written out as they happen, as the pane isn't kept up to date there.
+
+// How many events the log keeps
+const maxEvents = 1000
+
+// How many events a bpftrace can get ahead of the log by before it's held up
+const eventBuffer = 256
+
+// connectionEvent is a line of the event log
+type connectionEvent struct {
+	at       time.Time
+	kind     string // "connect", "accept" or "close" from the kernel, or "opened" or "closed" between collections
+	pid      int
+	name     string
+	protocol string
+	local    string // Address and port, e.g. 127.0.0.1:8080
+	remote   string // The same, or "" if there isn't a remote end
+}
+
+// connectionEventMsg is an event from the kernel, as it happened
+type connectionEventMsg struct {
+	event connectionEvent
+}
+
+// tracerStoppedMsg says bpftrace has stopped, and why if it wasn't asked to
+type tracerStoppedMsg struct {
+	err error
+}
+
+// eventTracer is a running bpftrace, whose events are sent on events until it stops
+type eventTracer struct {
+	cmd    *exec.Cmd
+	events chan tea.Msg
+}
+
+// The bpftrace probes, with what each sees as its socket. An accepted socket is what inet_csk_accept returns, which is
+// nothing if it failed.
+var bpftraceProbes = []struct {
+	probes string
+	socket string
+}{
+	{"kprobe:tcp_connect,kprobe:tcp_close", "arg0"},
+	{"kretprobe:inet_csk_accept", "retval"},
+}
+
+// bpftraceScript() builds the script for bpftrace, which writes a line for each event:
+//
+//	kprobe:tcp_connect 4242 51000 443 10.0.0.2 93.184.216.34 curl
+//
+// The probe, PID, local port, remote port, local address and remote address, and the process' name last as it can have
+// spaces in. The remote port is in network order, so it's swapped around.
+func bpftraceScript() string {
+	var script strings.Builder
+	for _, probe := range bpftraceProbes {
+		fmt.Fprintf(&script, `%s {
+	$sk = (struct sock *)%s;
+	if ($sk != 0) {
+		$dport = (($sk->__sk_common.skc_dport & 0xff) << 8) | ($sk->__sk_common.skc_dport >> 8);
+		if ($sk->__sk_common.skc_family == 2) {
+			printf("%%s %%d %%d %%d %%s %%s %%s\n", probe, pid, $sk->__sk_common.skc_num, $dport,
+				ntop($sk->__sk_common.skc_rcv_saddr), ntop($sk->__sk_common.skc_daddr), comm);
+		} else if ($sk->__sk_common.skc_family == 10) {
+			printf("%%s %%d %%d %%d %%s %%s %%s\n", probe, pid, $sk->__sk_common.skc_num, $dport,
+				ntop($sk->__sk_common.skc_v6_rcv_saddr.in6_u.u6_addr8),
+				ntop($sk->__sk_common.skc_v6_daddr.in6_u.u6_addr8), comm);
+		}
+	}
+}
+`, probe.probes, probe.socket)
+	}
+	return script.String()
+}
+
+// joinAddress() puts an address and port together the way the table does, with IPv6 addresses in brackets and * for
+// every address
+func joinAddress(address string, port string) string {
+	if address == "0.0.0.0" || address == "::" {
+		address = "*"
+	} else if strings.Contains(address, ":") {
+		address = "[" + address + "]"
+	}
+	return address + ":" + port
+}
+
+// parseBpftraceEvent() parses a line of bpftrace's output (see bpftraceScript()). It returns false for anything that
+// isn't an event, like the line bpftrace writes once the probes are attached.
+func parseBpftraceEvent(line string, at time.Time) (connectionEvent, bool) {
+	fields := strings.SplitN(line, " ", 7)
+	if len(fields) < 7 {
+		return connectionEvent{}, false
+	}
+	kind := map[string]string{
+		"kprobe:tcp_connect":        "connect",
+		"kprobe:tcp_close":          "close",
+		"kretprobe:inet_csk_accept": "accept",
+	}[fields[0]]
+	pid, err := strconv.Atoi(fields[1])
+	if kind == "" || err != nil {
+		return connectionEvent{}, false
+	}
+
+	event := connectionEvent{
+		at:       at,
+		kind:     kind,
+		pid:      pid,
+		name:     fields[6],
+		protocol: "TCP",
+		local:    joinAddress(fields[4], fields[2]),
+	}
+	// A socket closed before it was connected doesn't have a remote end
+	if fields[3] != "0" {
+		event.remote = joinAddress(fields[5], fields[3])
+	}
+	return event, true
+}
+
+// startTracer() starts bpftrace in the background, asking for sudo's password first if pvw isn't running as root
+func startTracer() (*eventTracer, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New(tr("error.ebpf-linux"))
+	}
+	if !commandExists("bpftrace") {
+		return nil, errors.New(tr("error.ebpf-missing"))
+	}
+
+	// Command is `bpftrace -B line -e SCRIPT`, with its output written a line at a time so events aren't held back
+	command := []string{"bpftrace", "-B", "line", "-e", bpftraceScript()}
+	if os.Geteuid() != 0 {
+		if !commandExists("sudo") {
+			return nil, errors.New(tr("error.ebpf-root"))
+		}
+		// Ask for the password now, while the terminal is still pvw's to lend
+		validate := exec.Command("sudo", "-v")
+		validate.Stdin, validate.Stdout, validate.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := validate.Run(); err != nil {
+			return nil, errors.New(tr("error.ebpf-root"))
+		}
+		command = append([]string{"sudo", "-n"}, command...)
+	}
+	debugf("tracing connections with %q", strings.Join(command[:len(command)-1], " "))
+
+	cmd := exec.Command(command[0], command[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	tracer := &eventTracer{cmd: cmd, events: make(chan tea.Msg, eventBuffer)}
+	go tracer.read(stdout, &stderr)
+	return tracer, nil
+}
+
+// read() sends each of bpftrace's events on, and then why it stopped
+func (t *eventTracer) read(stdout io.Reader, stderr *strings.Builder) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if event, isEvent := parseBpftraceEvent(scanner.Text(), time.Now()); isEvent {
+			t.events <- connectionEventMsg{event}
+		}
+	}
+
+	err := t.cmd.Wait()
+	if err != nil {
+		// bpftrace says what went wrong on its last line, e.g. that the kernel doesn't have BTF
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		err = errors.New(tr("error.ebpf-stopped", err, lines[len(lines)-1]))
+	}
+	t.events <- tracerStoppedMsg{err}
+	close(t.events)
+}
+
+// stop() stops bpftrace, which sudo passes on
+func (t *eventTracer) stop() {
+	if t.cmd.Process != nil {
+		t.cmd.Process.Signal(os.Interrupt)
+	}
+}
+
+// waitForEvent() waits for bpftrace's next event, like waitForPartial()
+func waitForEvent(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, open := <-events
+		if !open {
+			return nil
+		}
+		return msg
+	}
+}
+
+// connectionEvents() lists every connection in the table as an event, by what identifies it
+func connectionEvents(processes []process) map[rowKey]connectionEvent {
+	events := make(map[rowKey]connectionEvent)
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			event := connectionEvent{
+				pid:      proc.ID,
+				name:     proc.Name,
+				protocol: conn.Protocol,
+				local:    conn.LocalAddress + ":" + conn.LocalPort,
+			}
+			if conn.RemotePort != "" {
+				event.remote = conn.RemoteAddress + ":" + conn.RemotePort
+			}
+			events[connectionKey(proc.ID, conn)] = event
+		}
+	}
+	return events
+}
+
+// connectionChanges() returns the connections that opened and closed between two collections, in a steady order
+func connectionChanges(earlier, later map[rowKey]connectionEvent, at time.Time) []connectionEvent {
+	var changes []connectionEvent
+	for key, event := range later {
+		if _, found := earlier[key]; !found {
+			event.at, event.kind = at, "opened"
+			changes = append(changes, event)
+		}
+	}
+	for key, event := range earlier {
+		if _, found := later[key]; !found {
+			event.at, event.kind = at, "closed"
+			changes = append(changes, event)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.kind != b.kind {
+			return a.kind == "closed"
+		}
+		if a.pid != b.pid {
+			return a.pid < b.pid
+		}
+		return a.local+a.remote < b.local+b.remote
+	})
+	return changes
+}
+
+// logEvents() adds events to the log, dropping the oldest once it's full
+func (m *model) logEvents(events ...connectionEvent) {
+	m.events = append(m.events, events...)
+	if len(m.events) > maxEvents {
+		m.events = append([]connectionEvent(nil), m.events[len(m.events)-maxEvents:]...)
+	}
+}
+
+// logChanges() logs the connections that opened and closed since the last collection. The first collection doesn't
+// have anything to compare with.
+func (m *model) logChanges(running []process) {
+	current := connectionEvents(running)
+	if m.eventConnections != nil {
+		m.logEvents(connectionChanges(m.eventConnections, current, time.Now())...)
+	}
+	m.eventConnections = current
+}
+
+// The kinds of event, in the order they're described in
+var eventKinds = []string{"connect", "accept", "close", "opened", "closed"}
+
+// formatEvent() writes an event as a line of the log, with its kind padded to the widest in this language
+func formatEvent(event connectionEvent) string {
+	kindWidth := 0
+	for _, kind := range eventKinds {
+		if width := lipgloss.Width(tr("events." + kind)); width > kindWidth {
+			kindWidth = width
+		}
+	}
+	kind := tr("events." + event.kind)
+
+	where := event.local
+	if event.remote != "" {
+		where += " → " + event.remote
+	}
+	label := processLabel(process{ID: event.pid, Name: event.name})
+	return fmt.Sprintf("%s  %s%s  %-3s  %s  %s", event.at.Format("15:04:05.000"), kind,
+		strings.Repeat(" ", kindWidth-lipgloss.Width(kind)), event.protocol, label, where)
+}
+
+// eventsReport() lists the events, newest first
+func eventsReport(events []connectionEvent, tracing bool) string {
+	var b strings.Builder
+	if !tracing {
+		fmt.Fprintln(&b, tr("events.polling"))
+		fmt.Fprintln(&b)
+	}
+	if len(events) == 0 {
+		fmt.Fprintln(&b, tr("events.none"))
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		fmt.Fprintln(&b, formatEvent(events[i]))
+	}
+	return b.String()
+}
+
+// showEvents() opens the event log
+func (m *model) showEvents() {
+	m.openDetail(detailMsg{title: tr("events.title"), body: eventsReport(m.events, m.tracer != nil)})
+}
+
+// refreshEvents() rebuilds the event log if it's open, like refreshDashboard()
+func (m *model) refreshEvents() {
+	if m.showDetail && m.detailTitle == tr("events.title") && m.output == nil {
+		m.showEvents()
+	}
+}
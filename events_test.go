@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBpftraceEvent(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		line    string
+		want    connectionEvent
+		isEvent bool
+	}{
+		{
+			name: "connect",
+			line: "kprobe:tcp_connect 4242 51000 443 10.0.0.2 93.184.216.34 curl",
+			want: connectionEvent{at: at, kind: "connect", pid: 4242, name: "curl", protocol: "TCP",
+				local: "10.0.0.2:51000", remote: "93.184.216.34:443"},
+			isEvent: true,
+		},
+		{
+			name: "accept over IPv6, with a space in the name",
+			line: "kretprobe:inet_csk_accept 880 8080 50122 ::1 ::1 Web Content",
+			want: connectionEvent{at: at, kind: "accept", pid: 880, name: "Web Content", protocol: "TCP",
+				local: "[::1]:8080", remote: "[::1]:50122"},
+			isEvent: true,
+		},
+		{
+			name: "closing a listener",
+			line: "kprobe:tcp_close 880 8080 0 0.0.0.0 0.0.0.0 nginx",
+			want: connectionEvent{at: at, kind: "close", pid: 880, name: "nginx", protocol: "TCP",
+				local: "*:8080"},
+			isEvent: true,
+		},
+		{name: "bpftrace attaching", line: "Attaching 3 probes..."},
+		{name: "another probe", line: "kprobe:tcp_sendmsg 1 2 3 1.1.1.1 2.2.2.2 x"},
+		{name: "not a PID", line: "kprobe:tcp_connect abc 51000 443 10.0.0.2 93.184.216.34 curl"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, isEvent := parseBpftraceEvent(test.line, at)
+			if isEvent != test.isEvent || got != test.want {
+				t.Errorf("got %+v (%t), want %+v (%t)", got, isEvent, test.want, test.isEvent)
+			}
+		})
+	}
+}
+
+// TestConnectionChanges checks connections are logged as opened and closed between two collections, closed first
+func TestConnectionChanges(t *testing.T) {
+	earlier := connectionEvents([]process{
+		{ID: 1, Name: "nginx", Connections: []connection{
+			{Protocol: "TCP", LocalAddress: "*", LocalPort: "80", Status: "LISTEN"},
+			{Protocol: "TCP", LocalAddress: "10.0.0.2", LocalPort: "80", RemoteAddress: "10.0.0.9", RemotePort: "5000"},
+		}},
+	})
+	later := connectionEvents([]process{
+		{ID: 1, Name: "nginx", Connections: []connection{
+			{Protocol: "TCP", LocalAddress: "*", LocalPort: "80", Status: "LISTEN"},
+		}},
+		{ID: 2, Name: "dnsmasq", Connections: []connection{{Protocol: "UDP", LocalAddress: "*", LocalPort: "53"}}},
+	})
+
+	at := time.Now()
+	want := []connectionEvent{
+		{at: at, kind: "closed", pid: 1, name: "nginx", protocol: "TCP", local: "10.0.0.2:80", remote: "10.0.0.9:5000"},
+		{at: at, kind: "opened", pid: 2, name: "dnsmasq", protocol: "UDP", local: "*:53"},
+	}
+	got := connectionChanges(earlier, later, at)
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, want %+v", got[i], want[i])
+		}
+	}
+}
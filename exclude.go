@@ -0,0 +1,28 @@
+package main
+
+import "golang.org/x/exp/slices"
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Exclude Filters
+// Some processes are always there and never interesting (rapportd, mDNSResponder, a browser with hundreds of
+// connections), and the filters can only say what to show, not what to hide. --exclude-name, --exclude-ports and
+// --exclude-user hide them instead, and are checked after everything else:
+//
+//	--exclude-name    Process names, which can be patterns and are matched the way --match says (see patterns.go)
+//	--exclude-ports   Ports, ranges and patterns, the same as --ports, hiding connections with either end on one
+//	--exclude-user    The owners whose processes are hidden
+//
+// In the filter bar, a ! in front of a filter hides what it matches (see filterbar.go).
+
+// excluded() checks if a connection (or its whole process) is hidden by the --exclude flags
+func excluded(proc process, conn connection, options settings) bool {
+	if len(options.excludeNames) > 0 && nameMatches(options.excludeNames, proc.Name, options.match) {
+		return true
+	}
+	if len(options.excludeUsers) > 0 && slices.Contains(options.excludeUsers, proc.Username) {
+		return true
+	}
+	return len(options.excludePorts) > 0 &&
+		(portMatches(options.excludePorts, conn.LocalPort) || portMatches(options.excludePorts, conn.RemotePort))
+}
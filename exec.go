@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Batch Actions
+// --exec runs a command for every process that matches the filters, instead of starting the TUI. The command is a
+// template, with these placeholders filled in for each process:
+//
+//	{pid}    The process ID
+//	{name}   The process name
+//	{owner}  The user that owns the process
+//	{port}   The first matching local port
+//	{ports}  Every matching local port, separated by commas
+//
+// The same PVW_* environment variables that plugin actions get are set too, for the first matching connection. For
+// example, to lower the priority of everything listening on ports 9000 to 9100:
+//
+//	pvw --listen-only --ports 9000-9100 --exec 'renice 10 -p {pid}'
+//
+// --dry-run prints the commands without running them, and --confirm asks before running each one. The exit code is 0
+// if every command worked, 1 if a command failed or there's no sh to run them with, or 2 if nothing matched (see
+// exit.go). Sockets without a known (Linux) process don't count as matches, as there's nothing to run a command on.
+
+// runExec() runs a command template for each process that made it through the filters, and returns the exit code
+func runExec(template string, options settings, dryRun bool, confirm bool, w io.Writer) int {
+	if pvw.Pure && !dryRun {
+		fmt.Fprintln(os.Stderr, tr("error.pure-command", "sh"))
+		return exitError
+	}
+	// Windows usually doesn't have sh, and nor do some containers
+	if !dryRun && !commandExists("sh") {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.no-shell")))
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.timeout)
+
+	// Collect the same way the TUI does, so every filter applies
+	var processes []process
+	switch msg := checkProcesses(ctx, cancel, options, nil, nil)().(type) {
+	case processesMsg:
+		for _, proc := range msg.processes {
+			// There's nothing to run a command on for sockets without a known (Linux) process
+			if proc.ID != 0 && !proc.Windows {
+				processes = append(processes, proc)
+			}
+		}
+	case collectErrMsg:
+		fmt.Fprintln(os.Stderr, tr("error.running", msg.err))
+		return exitError
+	}
+
+	if len(processes) == 0 {
+		fmt.Fprintln(os.Stderr, tr("exec.none"))
+		return exitNoMatch
+	}
+
+	answers := bufio.NewReader(os.Stdin)
+	code := exitOK
+	for _, proc := range processes {
+		command := expandTemplate(template, proc)
+		if dryRun {
+			fmt.Fprintln(w, command)
+			continue
+		}
+
+		if confirm {
+			// Asked on stderr, so it's still asked with --quiet
+			fmt.Fprint(os.Stderr, tr("exec.confirm", command))
+			answer, _ := answers.ReadString('\n')
+			if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y") {
+				continue
+			}
+		}
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = connectionEnvironment(proc, proc.Connections[0])
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, w, os.Stderr
+		debugf("running %q for %d", command, proc.ID)
+
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, tr("exec.failed", command, err))
+			code = exitError
+		}
+	}
+
+	return code
+}
+
+// expandTemplate() fills in the placeholders in a command template for a process. Names and owners are quoted, as
+// they can have spaces in them.
+func expandTemplate(template string, proc process) string {
+	var ports []string
+	for _, conn := range proc.Connections {
+		if !slices.Contains(ports, conn.LocalPort) {
+			ports = append(ports, conn.LocalPort)
+		}
+	}
+
+	port := ""
+	if len(ports) > 0 {
+		port = ports[0]
+	}
+
+	return strings.NewReplacer(
+		"{pid}", strconv.Itoa(proc.ID),
+		"{name}", shellQuote(proc.Name),
+		"{owner}", shellQuote(proc.Username),
+		"{port}", port,
+		"{ports}", strings.Join(ports, ","),
+	).Replace(template)
+}
+
+// shellQuote() quotes a value so sh sees it as a single word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
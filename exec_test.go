@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// execSettings() returns the settings --exec collects with, from a backend serving the given processes
+func execSettings(processes ...process) settings {
+	return settings{
+		match: matchExact, showTCP: true, showUDP: true, showIPv4: true, showIPv6: true, timeout: time.Second,
+		backend: &fakeBackend{snapshots: [][]process{processes}},
+	}
+}
+
+// TestExecNothingToRun checks sockets without a process to run a command on don't count as matches
+func TestExecNothingToRun(t *testing.T) {
+	unknown := listeningProcess(0, "", "80")
+	windows := listeningProcess(4, "System", "445")
+	windows.Windows = true
+
+	var out strings.Builder
+	if code := runExec("echo {pid}", execSettings(unknown, windows), true, false, &out); code != exitNoMatch {
+		t.Errorf("expected exit code %d, got %d", exitNoMatch, code)
+	}
+	if out.Len() > 0 {
+		t.Errorf("expected no commands, got %q", out.String())
+	}
+
+	out.Reset()
+	options := execSettings(unknown, listeningProcess(100, "node", "3000"))
+	if code := runExec("echo {pid}", options, true, false, &out); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+	if out.String() != "echo 100\n" {
+		t.Errorf("expected only node's command, got %q", out.String())
+	}
+}
+
+// TestExecWithoutShell checks a missing sh is an error, rather than every command failing one by one
+func TestExecWithoutShell(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	options := execSettings(listeningProcess(100, "node", "3000"))
+	if code := runExec("echo {pid}", options, false, false, io.Discard); code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+}
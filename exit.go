@@ -0,0 +1,35 @@
+package main
+
+import "io"
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Exit Codes
+// Everything that runs without the TUI (the subcommands, --exec and --check-policy) exits with the same codes, so
+// scripts can tell what happened without reading the output:
+//
+//	0  Everything's fine: the ports are free, the policy is met, nothing changed...
+//	1  Something went wrong, e.g. a flag that doesn't make sense or a backend that failed
+//	2  Nothing matched, e.g. no processes for --exec or no history for who-had
+//	3  Something's in the way: a port is taken, the policy isn't met, or a snapshot differs
+//
+// --quiet stops them printing anything but errors, for scripts that only want the exit code:
+//
+//	if pvw check --quiet 3000; then npm run dev; fi
+//
+// pvw free is the exception, as the port it prints is the point of running it.
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitNoMatch  = 2
+	exitConflict = 3
+)
+
+// quietOutput() returns where results should be written: w, or nowhere with --quiet
+func quietOutput(w io.Writer, quiet bool) io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return w
+}
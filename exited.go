@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/exp/slices"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Exited Processes
+// The table is a snapshot, so by the time an action runs on the selected process it might have exited. Rather than
+// showing whatever error the action got (signalling a process that's gone fails with "no such process"), it says the
+// process has already exited and refreshes the table.
+//
+// In watch mode, a process that exits would otherwise vanish from under the cursor between one refresh and the next,
+// with nothing to say it was ever there. So processes that were in the table last refresh and have exited since are
+// kept for exitedDuration, greyed out and with a ✕ badge, before they're dropped. Ones that are still running but have
+// closed their ports, or no longer match the filters, just go.
+
+// How long a process that's exited stays in the table in watch mode
+const exitedDuration = 10 * time.Second
+
+// The badge shown on the rows of a process that's exited
+const exitedBadge = "✕ "
+
+// The style used for the rows of a process that's exited
+var exitedStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("240"))
+
+// exitedMsg says an action was run on a process that had already exited
+type exitedMsg struct{ proc process }
+
+// ifRunning() returns a command that runs cmd if the process is still running, or says it's exited if it isn't.
+// Processes we can't check on (unknown ones, and Windows ones under WSL) are assumed to be running.
+func ifRunning(proc process, cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		if proc.ID != 0 && !proc.Windows && !pvw.Exists(proc.ID) {
+			return exitedMsg{proc}
+		}
+		return cmd()
+	}
+}
+
+// exitedError() returns the error an action on a process should show, turning "no such process" into exitedMsg
+func exitedError(proc process, err error) tea.Msg {
+	if pvw.Exited(err) {
+		return exitedMsg{proc}
+	}
+	return errMsg{err}
+}
+
+// keepExited() adds the processes that were in the table last refresh but have exited since back onto the end of
+// filtered, for exitedDuration after they were first seen to be gone. It returns when each of the processes it added
+// went, which is kept in the row cache so the next refresh knows how long they've been gone.
+func keepExited(filtered []process, previous rowCache, options settings) ([]process, map[int]time.Time) {
+	if options.interval == 0 || len(previous) == 0 {
+		return filtered, nil
+	}
+
+	present := make(map[int]bool, len(filtered))
+	for _, proc := range filtered {
+		present[proc.ID] = true
+	}
+
+	now := time.Now()
+	exited := make(map[int]time.Time)
+	var pids []int
+	for pid, cached := range previous {
+		// A process that's still running has only closed its ports (or been filtered out), so it hasn't exited
+		if present[pid] || pid == 0 || cached.proc.Windows || pvw.Exists(pid) {
+			continue
+		}
+		if cached.exited.IsZero() {
+			cached.exited = now
+		}
+		if now.Sub(cached.exited) < exitedDuration {
+			exited[pid] = cached.exited
+			pids = append(pids, pid)
+		}
+	}
+
+	// The row cache is a map, so they're sorted to keep their order the same from one refresh to the next
+	slices.Sort(pids)
+	for _, pid := range pids {
+		filtered = append(filtered, previous[pid].proc)
+	}
+	return filtered, exited
+}
+
+// markExited() badges the rows of the processes keepExited() added, and remembers when they went in the row cache.
+// The rows are shared with the row cache, so the badged rows are copies.
+func markExited(rows []table.Row, ends []int, processes []process, cache rowCache, exited map[int]time.Time,
+	options settings) {
+	status := badgeColumn(options)
+
+	for i, proc := range processes {
+		since, isExited := exited[proc.ID]
+		if !isExited {
+			continue
+		}
+
+		cached := cache[proc.ID]
+		cached.exited = since
+		cache[proc.ID] = cached
+
+		end := len(rows)
+		if i+1 < len(ends) {
+			end = ends[i+1]
+		}
+		for row := ends[i]; row < end; row++ {
+			badged := append(table.Row{}, rows[row]...)
+			if status < len(badged) {
+				badged[status] = exitedBadge + badged[status]
+			}
+			rows[row] = badged
+		}
+	}
+}
+
+// withoutExited() returns the processes that haven't exited, for anything that counts what's in the table
+func withoutExited(processes []process, cache rowCache) []process {
+	running := make([]process, 0, len(processes))
+	for _, proc := range processes {
+		if cache[proc.ID].exited.IsZero() {
+			running = append(running, proc)
+		}
+	}
+	return running
+}
+
+// exitedProcess() checks if a process in the table is only there because it's exited (see keepExited())
+func (m model) exitedProcess(pid int) bool {
+	return !m.rowCache[pid].exited.IsZero()
+}
+
+// dimExitedRows() greys out the lines of the rendered table that belong to processes that have exited, working out
+// which row each line is from the same way highlightRows() does
+func (m model) dimExitedRows(view string) string {
+	if m.settings.interval == 0 {
+		return view
+	}
+
+	lines := strings.Split(view, "\n")
+	for i := range lines {
+		row := m.tableTop + i - m.settings.headerLines()
+		if row < 0 || row >= len(m.rows) || row == m.table.Cursor() {
+			continue
+		}
+		if processIndex, _, exists := m.rowLocation(row); exists && m.exitedProcess(m.processes[processIndex].ID) {
+			lines[i] = exitedStyle.Render(lines[i])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Backend Failures
+// When a backend fails, its error ("exit status 1") rarely says why. So failures are sorted into a few kinds, each with
+// a message saying what to do about it:
+//
+//   - missing: the backend's command has gone since pvw started, e.g. it was uninstalled, or $PATH changed
+//   - permission: it wasn't allowed to run, or to look at what it needed to
+//   - transient: it was interrupted, killed, or ran short of something (memory, file descriptors), which usually goes
+//     away by itself, so it's tried again a couple of times before giving up
+//
+// Anything else is shown with the first line of what the backend wrote to stderr, which is usually the useful part.
+// Exiting with 1 after finding nothing isn't a failure at all, and lsof's collector already returns no processes then.
+
+// How many more times a backend is run after a transient failure, and how long to wait before the first of them (each
+// one after waits longer)
+const collectRetries = 2
+
+var collectRetryDelay = 200 * time.Millisecond
+
+// failureKind is what sort of failure a backend had
+type failureKind int
+
+const (
+	failureOther failureKind = iota
+	failureMissing
+	failurePermission
+	failureTransient
+)
+
+// What backends write to stderr for each kind of failure, lowercased
+var (
+	permissionMessages = []string{"permission denied", "operation not permitted", "not permitted"}
+	transientMessages  = []string{
+		"resource temporarily unavailable", "interrupted system call", "too many open files", "cannot allocate memory",
+	}
+)
+
+// failureStderr() returns what a failed command wrote to stderr, if the error has it
+func failureStderr(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return ""
+}
+
+// classifyFailure() works out what sort of failure a backend had
+func classifyFailure(err error) failureKind {
+	stderr := strings.ToLower(failureStderr(err))
+	var exitErr *exec.ExitError
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return failureMissing
+	case errors.Is(err, fs.ErrPermission), containsAny(stderr, permissionMessages):
+		return failurePermission
+	case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR), errors.Is(err, syscall.EMFILE),
+		errors.Is(err, syscall.ENFILE), errors.Is(err, syscall.ENOMEM), containsAny(stderr, transientMessages):
+		return failureTransient
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == -1:
+		// It was killed by a signal, and not by us (timeouts are checked first), e.g. by the OOM killer
+		return failureTransient
+	}
+	return failureOther
+}
+
+// containsAny() checks if a string contains any of the given strings
+func containsAny(s string, parts []string) bool {
+	for _, part := range parts {
+		if strings.Contains(s, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectWithRetries() runs a collection, running it again after a transient failure unless the context's done
+func collectWithRetries(
+	ctx context.Context, collect func() ([]process, []parseWarning, error),
+) ([]process, []parseWarning, error) {
+	for attempt := 0; ; attempt++ {
+		all, warnings, err := collect()
+		if err == nil || attempt == collectRetries || ctx.Err() != nil || classifyFailure(err) != failureTransient {
+			return all, warnings, err
+		}
+
+		debugf("collection failed, trying again: %v", err)
+		select {
+		case <-ctx.Done():
+			return all, warnings, err
+		case <-time.After(collectRetryDelay * time.Duration(attempt+1)):
+		}
+	}
+}
+
+// explainFailure() turns a backend's error into one that says what went wrong, and what to do about it
+func explainFailure(err error, name string) error {
+	switch classifyFailure(err) {
+	case failureMissing:
+		return errors.New(tr("error.backend-missing", name))
+	case failurePermission:
+		return errors.New(tr("error.backend-permission", name))
+	case failureTransient:
+		return errors.New(tr("error.backend-transient", name, collectRetries+1, err))
+	}
+
+	if stderr := failureStderr(err); stderr != "" {
+		line, _, _ := strings.Cut(stderr, "\n")
+		return errors.New(tr("error.backend-failed", name, err, line))
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
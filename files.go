@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Open Files
+// A server that has run out of file descriptors can't accept any more connections, but usually keeps listening, so
+// from the outside it looks like it's hanging. The detail pane (i) shows how many files the process has open against
+// its limit (RLIMIT_NOFILE), with a warning once it's close.
+//
+// On Linux both come from /proc. Elsewhere the files are counted with lsof, and the limit isn't known, as it can only
+// be read from inside the process.
+
+// How full (as a fraction of the limit) a process' file descriptors have to be before it gets a warning
+const fileLimitWarning = 0.8
+
+// The badge shown next to the open files of a process that's near its limit
+const fileLimitBadge = "⚠ "
+
+// openFilesLine() describes how many files a process has open, for the detail pane
+func openFilesLine(pid int) string {
+	count, err := openFileCount(pid)
+	if err != nil {
+		return tr("details.files-unknown", err)
+	}
+
+	limit, hasLimit := openFileLimit(pid)
+	if !hasLimit {
+		return strconv.Itoa(count)
+	}
+
+	line := fmt.Sprintf("%d / %d", count, limit)
+	if float64(count) >= fileLimitWarning*float64(limit) {
+		line += "  " + fileLimitBadge + tr("details.files-near-limit")
+	}
+	return line
+}
+
+// openFileCount() counts the file descriptors a process has open
+func openFileCount(pid int) (int, error) {
+	fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+	if err == nil {
+		return len(fds), nil
+	}
+	if !os.IsNotExist(err) || !commandExists("lsof") {
+		return 0, err
+	}
+
+	// Not Linux, so ask lsof. Command is `lsof -a -p PID -d 0-999999 -F f`, which prints a line starting with f for each
+	// numbered file descriptor (leaving out the cwd, txt, mem... entries, which aren't descriptors).
+	out, err := exec.Command("lsof", "-a", "-p", strconv.Itoa(pid), "-d", "0-999999", "-F", "f").Output()
+	if err != nil && len(out) == 0 {
+		return 0, err
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "f") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// openFileLimit() gets the soft limit on a process' open files from /proc/<pid>/limits, which has a line like
+// `Max open files            1024                 524288               files`
+func openFileLimit(pid int) (int, bool) {
+	limits, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "limits"))
+	if err != nil {
+		return 0, false
+	}
+
+	for _, line := range strings.Split(string(limits), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			return 0, false
+		}
+		// The limit can also be "unlimited", which there's no point comparing against
+		limit, err := strconv.Atoi(fields[0])
+		return limit, err == nil && limit > 0
+	}
+
+	return 0, false
+}
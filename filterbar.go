@@ -0,0 +1,357 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Filter Bar
+// Search only looks at process names. F opens the filter bar, which takes filters on any of these fields:
+//
+//	name:node     The process name
+//	pid:1234      The process ID
+//	user:root     The process' owner
+//	port:3000     The local or remote port, or a range (port:9000-9100)
+//	addr:10.0.0.1 The local or remote address
+//	proto:tcp     The protocol
+//	state:LISTEN  The connection's status
+//
+// Values are matched ignoring case, and a value starting with ~ only has to be part of the field (name:~node matches
+// nodejs too). Values can also be globs or regular expressions (name:node*, name:/^java/), the same as the names given
+// on the command line. Words without a field are the same as name:~word. Filters on the same field are alternatives
+// (port:80 port:443 shows both), and filters on different fields all have to match. tab completes the field or value
+// being typed.
+//
+// A ! in front of a filter hides what it matches instead (!name:chrome, or !chrome), whatever the other filters say.
+//
+// Filters are applied as they're typed. enter keeps them and esc goes back to the ones from before the bar was opened.
+// Once the bar is closed, the filters are shown as chips under the table, and x removes the last one.
+
+// The fields the filter bar knows about
+var filterFields = []string{"name", "pid", "user", "port", "addr", "proto", "state"}
+
+// The values offered when completing fields that only have a few
+var filterValues = map[string][]string{
+	"proto": {"tcp", "udp"},
+	"state": {
+		"LISTEN", "ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT", "CLOSE_WAIT",
+		"LAST_ACK", "CLOSING", "CLOSED", "UNCONN", "MCAST",
+	},
+}
+
+// filterTerm is a single filter from the filter bar, e.g. port:3000
+type filterTerm struct {
+	field     string // One of filterFields, or "" if it isn't one
+	value     string
+	substring bool // Whether the value only has to be part of the field (~)
+	negated   bool // Whether it hides what it matches (!)
+}
+
+// The style used for the chips showing the filters that are applied
+var chipStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("7")).
+	Background(lipgloss.Color("238")).
+	Padding(0, 1)
+
+// The style used for chips with a field that doesn't exist
+var badChipStyle = chipStyle.Copy().Background(lipgloss.Color("52"))
+
+// String() writes a filter back out the way it's typed
+func (t filterTerm) String() string {
+	value := t.value
+	if t.substring {
+		value = "~" + value
+	}
+	if t.negated {
+		return "!" + t.field + ":" + value
+	}
+	return t.field + ":" + value
+}
+
+// valid() checks if a filter is on a field that exists
+func (t filterTerm) valid() bool {
+	return t.field != ""
+}
+
+// parseFilters() splits what was typed in the filter bar into filters. Filters on fields that don't exist, or with
+// patterns that can't be used, are kept (with no field) so they can be shown, but they don't filter anything.
+func parseFilters(expression string) []filterTerm {
+	var terms []filterTerm
+
+	for _, word := range strings.Fields(expression) {
+		negated := strings.HasPrefix(word, "!")
+		field, value, hasField := strings.Cut(strings.TrimPrefix(word, "!"), ":")
+		if !hasField {
+			// A bare word searches names, the same as the search bar, unless it's a pattern
+			field, value = "name", "~"+field
+			if isPattern(value[1:]) {
+				value = value[1:]
+			}
+		}
+
+		term := filterTerm{field: strings.ToLower(field), value: value, negated: negated}
+		if strings.HasPrefix(term.value, "~") {
+			term.value, term.substring = term.value[1:], true
+		}
+		if !slices.Contains(filterFields, term.field) || (!term.substring && checkPattern(term.value) != nil) {
+			term.field, term.value = "", word
+		}
+		if term.value == "" && term.valid() {
+			// Still being typed
+			continue
+		}
+
+		terms = append(terms, term)
+	}
+
+	return terms
+}
+
+// formatFilters() writes filters back out the way they're typed
+func formatFilters(terms []filterTerm) string {
+	words := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term.valid() {
+			words = append(words, term.String())
+		} else {
+			words = append(words, term.value)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// matchesFilters() checks if a connection (and its process) matches every field that's being filtered on, at least one
+// of the filters on each field, and none of the negated filters
+func matchesFilters(terms []filterTerm, proc process, conn connection) bool {
+	matched := make(map[string]bool)
+	for _, term := range terms {
+		if !term.valid() {
+			continue
+		}
+		if term.negated {
+			if termMatches(term, proc, conn) {
+				return false
+			}
+			continue
+		}
+		if _, seen := matched[term.field]; !seen {
+			matched[term.field] = false
+		}
+		if termMatches(term, proc, conn) {
+			matched[term.field] = true
+		}
+	}
+
+	for _, ok := range matched {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// termMatches() checks if a single filter matches a connection
+func termMatches(term filterTerm, proc process, conn connection) bool {
+	switch term.field {
+	case "name":
+		return valueMatches(term, proc.Name)
+	case "pid":
+		return valueMatches(term, strconv.Itoa(proc.ID))
+	case "user":
+		return valueMatches(term, proc.Username)
+	case "port":
+		if !term.substring {
+			return portMatches([]string{term.value}, conn.LocalPort) || portMatches([]string{term.value}, conn.RemotePort)
+		}
+		return valueMatches(term, conn.LocalPort) || valueMatches(term, conn.RemotePort)
+	case "addr":
+		// Sockets bound to every address are shown as *, but are usually thought of as 0.0.0.0 or ::
+		if conn.LocalAddress == "*" && (term.value == "0.0.0.0" || term.value == "::") {
+			return true
+		}
+		return valueMatches(term, strings.Trim(conn.LocalAddress, "[]")) ||
+			valueMatches(term, strings.Trim(conn.RemoteAddress, "[]"))
+	case "proto":
+		return valueMatches(term, conn.Protocol)
+	case "state":
+		// UDP sockets without a remote end are shown as UNCONN or MCAST, so they can be filtered by that too
+		return valueMatches(term, connectionState(conn))
+	}
+	return false
+}
+
+// valueMatches() checks if a value matches a filter (which can be a pattern, see patterns.go), ignoring case
+func valueMatches(term filterTerm, value string) bool {
+	if term.substring {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(term.value))
+	}
+	return patternMatches(term.value, value, true)
+}
+
+// openFilterBar() opens the filter bar, with the filters that are applied already in it
+func (m *model) openFilterBar() {
+	m.filterInput = textinput.New()
+	m.filterInput.Prompt = tr("filter.prompt") + " "
+	m.filterInput.Placeholder = tr("filter.placeholder")
+	m.filterInput.CharLimit = 256
+	m.filterInput.Width = 48
+	m.filterInput.SetValue(formatFilters(m.settings.filters))
+	m.filterInput.CursorEnd()
+	m.filterInput.Focus()
+
+	m.filtersBefore = m.settings.filters
+	m.showFilter = true
+	m.table.Blur()
+	m.announce(tr("plain.filter"))
+}
+
+// closeFilterBar() goes back to the table
+func (m *model) closeFilterBar() {
+	m.filterInput.Blur()
+	m.showFilter = false
+	m.table.Focus()
+	m.fitTable()
+}
+
+// updateFilterBar() handles a key press while the filter bar is open
+func (m model) updateFilterBar(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEnter:
+		m.closeFilterBar()
+		m.announce(tr("plain.filters", formatFilters(m.settings.filters)))
+		return m, nil
+
+	case key.Matches(msg, keys.Escape):
+		m.closeFilterBar()
+		m.settings.filters = m.filtersBefore
+		return m, m.rerender()
+
+	case msg.Type == tea.KeyTab:
+		m.filterInput.SetValue(m.completeFilter(m.filterInput.Value()))
+		m.filterInput.CursorEnd()
+	}
+
+	var cmd tea.Cmd
+	if msg.Type != tea.KeyTab {
+		m.filterInput, cmd = m.filterInput.Update(msg)
+	}
+
+	// Apply the filters as they're typed
+	m.settings.filters = parseFilters(m.filterInput.Value())
+	return m, tea.Batch(cmd, m.rerender())
+}
+
+// removeLastFilter() takes off the filter that was added last
+func (m *model) removeLastFilter() tea.Cmd {
+	if len(m.settings.filters) == 0 {
+		return nil
+	}
+
+	// The filters are shared with collections running in the background, so they're copied rather than changed
+	m.settings.filters = append([]filterTerm{}, m.settings.filters[:len(m.settings.filters)-1]...)
+	m.fitTable()
+	m.announce(tr("plain.filters", formatFilters(m.settings.filters)))
+	return m.rerender()
+}
+
+// completeFilter() completes the last word in the filter bar: the field if there isn't a : yet, or the value if the
+// field has values to offer. If more than one thing fits, it's completed as far as they all agree.
+func (m model) completeFilter(expression string) string {
+	start := strings.LastIndex(expression, " ") + 1
+	if strings.HasPrefix(expression[start:], "!") {
+		start++
+	}
+	word := expression[start:]
+
+	var candidates []string
+	field, value, hasField := strings.Cut(word, ":")
+	if !hasField {
+		for _, f := range filterFields {
+			candidates = append(candidates, f+":")
+		}
+	} else {
+		prefix := field + ":"
+		if strings.HasPrefix(value, "~") {
+			prefix, value = prefix+"~", value[1:]
+		}
+		for _, v := range m.filterValuesFor(strings.ToLower(field)) {
+			candidates = append(candidates, prefix+v)
+		}
+		word = prefix + value
+	}
+
+	var matching []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			matching = append(matching, candidate)
+		}
+	}
+	if len(matching) == 0 {
+		return expression
+	}
+
+	return expression[:start] + commonPrefix(matching)
+}
+
+// filterValuesFor() returns the values to offer when completing a field: the fixed ones for protocols and states, or
+// the names and owners of the processes collected last
+func (m model) filterValuesFor(field string) []string {
+	if values, fixed := filterValues[field]; fixed {
+		return values
+	}
+
+	seen := make(map[string]bool)
+	for _, proc := range m.snapshot {
+		switch field {
+		case "name":
+			seen[proc.Name] = true
+		case "user":
+			seen[proc.Username] = true
+		}
+	}
+	delete(seen, "")
+
+	values := make([]string, 0, len(seen))
+	for value := range seen {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// commonPrefix() returns the longest start that every string shares
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// filterChips() renders the filters that are applied as chips, or "" if there aren't any
+func (m model) filterChips() string {
+	if len(m.settings.filters) == 0 || m.showFilter {
+		return ""
+	}
+
+	chips := []string{tr("filter.applied")}
+	for _, term := range m.settings.filters {
+		if term.valid() {
+			chips = append(chips, chipStyle.Render(term.String()))
+		} else {
+			chips = append(chips, badChipStyle.Render(term.value+"?"))
+		}
+	}
+	return strings.Join(chips, " ") + " " + noticeStyle.Render(tr("filter.remove"))
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestTermMatchesState(t *testing.T) {
+	nginx := process{ID: 1, Name: "nginx"}
+	listening := connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "80", Status: "LISTEN"}
+	unconnected := connection{Protocol: "UDP", LocalAddress: "*", LocalPort: "53"}
+	multicast := connection{Protocol: "UDP", LocalAddress: "224.0.0.251", LocalPort: "5353"}
+
+	tests := []struct {
+		filter string
+		conn   connection
+		want   bool
+	}{
+		{"state:listen", listening, true},
+		{"state:UNCONN", listening, false},
+		{"state:UNCONN", unconnected, true},
+		{"state:unconn", unconnected, true},
+		{"state:MCAST", multicast, true},
+		{"state:UNCONN", multicast, false},
+		{"state:~CONN", unconnected, true},
+	}
+
+	for _, test := range tests {
+		terms := parseFilters(test.filter)
+		if len(terms) != 1 {
+			t.Fatalf("%q parsed to %+v", test.filter, terms)
+		}
+		if got := termMatches(terms[0], nginx, test.conn); got != test.want {
+			t.Errorf("%q on %+v: got %t, want %t", test.filter, test.conn, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Firewall
+// A service listening on 0.0.0.0 is only reachable from other machines if the firewall lets connections through. The
+// Exposed column reads the firewall's rules (ufw, nftables or iptables on Linux, pf on macOS) and works out whether
+// each listening port is allowed in from outside:
+//
+//	yes    The port is listening on an external address, and the firewall allows it
+//	no     The firewall blocks it
+//	local  The port is only listening on loopback, so it can't be reached from outside whatever the firewall says
+//	?      No firewall could be found, or its rules couldn't be read, usually because pvw isn't running as root
+//
+// This is a best guess rather than a full firewall simulator. Rules that only match some source addresses or
+// interfaces are skipped (apart from ufw's, which count as allowing the port), as are NAT and forwarding rules, so
+// it's worth double checking anything surprising.
+
+// firewallRule allows or blocks a range of ports. A rule with no protocol matches TCP and UDP, and a family of 0
+// matches IPv4 and IPv6.
+type firewallRule struct {
+	protocol string
+	family   int
+	from, to int
+	allow    bool
+}
+
+// firewall is the rules read from the firewall, in the order they're checked. The first rule that matches a port
+// decides it, and the policy is used if none do.
+type firewall struct {
+	name    string
+	rules   []firewallRule
+	policy  bool // Whether ports are allowed if no rules match
+	unknown bool // Set if the rules couldn't be read
+}
+
+// The firewall's rules, behind a mutex as formatting happens in several goroutines. They're read again if they're older
+// than firewallReloadInterval, so rule changes show up on processes that change afterwards.
+var (
+	firewallMutex  sync.Mutex
+	loadedFirewall *firewall
+	firewallLoaded time.Time
+)
+
+const firewallReloadInterval = 5 * time.Second
+
+// currentFirewall() returns the firewall's rules, reading them again if they're out of date
+func currentFirewall() *firewall {
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
+
+	if loadedFirewall == nil || time.Since(firewallLoaded) > firewallReloadInterval {
+		loadedFirewall = loadFirewall()
+		firewallLoaded = time.Now()
+		debugf("firewall is %s with %d rules (policy allow: %t, unknown: %t)",
+			loadedFirewall.name, len(loadedFirewall.rules), loadedFirewall.policy, loadedFirewall.unknown)
+	}
+
+	return loadedFirewall
+}
+
+// loadFirewall() finds the firewall that's in use and reads its rules. If none of them can be found, which is also what
+// happens when they're not on the PATH or can't be run at all, whether ports are exposed isn't known.
+func loadFirewall() *firewall {
+	if runtime.GOOS == "darwin" && commandExists("pfctl") {
+		return loadPf()
+	}
+
+	// ufw is a front end for nftables/iptables, but its own rules are much easier to read, so it goes first
+	if commandExists("ufw") {
+		if fw := loadUfw(); fw != nil {
+			return fw
+		}
+	}
+	if commandExists("nft") {
+		return loadNft()
+	}
+	if commandExists("iptables") {
+		return loadIptables()
+	}
+
+	return &firewall{name: "none", unknown: true}
+}
+
+// exposed() works out if a connection can be reached from outside. Only listening sockets (and UDP sockets without a
+// remote end) are checked - the value is empty for everything else.
+func (fw *firewall) exposed(conn connection) string {
+	if !isListening(conn) {
+		return ""
+	}
+
+	if loopbackAddress(conn.LocalAddress) {
+		return tr("exposed.local")
+	}
+	if fw.unknown {
+		return "?"
+	}
+
+	port, err := strconv.Atoi(conn.LocalPort)
+	if err != nil {
+		return "?"
+	}
+
+	family := 4
+	if conn.IPv6 {
+		family = 6
+	}
+
+	allowed := fw.policy
+	for _, rule := range fw.rules {
+		if rule.matches(conn.Protocol, family, port) {
+			allowed = rule.allow
+			break
+		}
+	}
+
+	if allowed {
+		return tr("exposed.yes")
+	}
+	return tr("exposed.no")
+}
+
+// matches() checks if a rule applies to a port
+func (r firewallRule) matches(protocol string, family int, port int) bool {
+	if r.protocol != "" && !strings.EqualFold(r.protocol, protocol) {
+		return false
+	}
+	if r.family != 0 && r.family != family {
+		return false
+	}
+	return port >= r.from && port <= r.to
+}
+
+// loopbackAddress() checks if an address (in lsof's format) can only be reached from this machine
+func loopbackAddress(address string) bool {
+	return strings.HasPrefix(address, "127.") || address == "[::1]" || address == "localhost"
+}
+
+// fillExposedColumn() fills in the Exposed column for every row of a process, as each connection can be different
+func fillExposedColumn(rows []table.Row, proc process, options settings) {
+	if !options.exposed || proc.Windows {
+		return
+	}
+
+	column := columnIndex(options, "Exposed")
+	if column == -1 {
+		return
+	}
+
+	fw := currentFirewall()
+	for i, conn := range proc.Connections {
+		if i < len(rows) {
+			rows[i][column] = fw.exposed(conn)
+		}
+	}
+}
+
+// parsePortRange() parses a port or range of ports, e.g. 80, 1000:2000 or 1000-2000
+func parsePortRange(spec string) (int, int, bool) {
+	from, to, isRange := strings.Cut(spec, ":")
+	if !isRange {
+		from, to, isRange = strings.Cut(spec, "-")
+	}
+	if !isRange {
+		to = from
+	}
+
+	fromPort, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, false
+	}
+	toPort, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, false
+	}
+	return fromPort, toPort, true
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// ufw
+
+// loadUfw() reads ufw's rules from `ufw status verbose`, or returns nil if ufw isn't turned on. The output looks like:
+//
+//	Status: active
+//	Default: deny (incoming), allow (outgoing), disabled (routed)
+//
+//	To                         Action      From
+//	--                         ------      ----
+//	22/tcp                     ALLOW IN    Anywhere
+//	80,443/tcp                 ALLOW IN    Anywhere
+//	6000:6007/tcp              DENY IN     Anywhere
+//	22/tcp (v6)                ALLOW IN    Anywhere (v6)
+func loadUfw() *firewall {
+	out, err := exec.Command("ufw", "status", "verbose").Output()
+	if err != nil {
+		// ufw needs root to show its status
+		debugf("ufw status failed: %v", err)
+		return &firewall{name: "ufw", unknown: true}
+	}
+	return parseUfw(string(out))
+}
+
+// parseUfw() converts the output of ufw status verbose to rules, or returns nil if ufw isn't turned on
+func parseUfw(raw string) *firewall {
+	if !strings.Contains(raw, "Status: active") {
+		return nil
+	}
+
+	fw := &firewall{name: "ufw"}
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "Default:") {
+			fw.policy = strings.Contains(line, "allow (incoming)")
+			continue
+		}
+
+		family := 4
+		if strings.Contains(line, "(v6)") {
+			family = 6
+			line = strings.ReplaceAll(line, "(v6)", "")
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		action := strings.ToUpper(fields[1])
+		if action != "ALLOW" && action != "DENY" && action != "REJECT" && action != "LIMIT" {
+			continue
+		}
+
+		// The To column is a list of ports, optionally followed by a protocol. App profiles (e.g. OpenSSH) are skipped.
+		ports, protocol, _ := strings.Cut(fields[0], "/")
+		for _, spec := range strings.Split(ports, ",") {
+			from, to, valid := parsePortRange(spec)
+			if !valid {
+				continue
+			}
+			fw.rules = append(fw.rules, firewallRule{
+				protocol: protocol,
+				family:   family,
+				from:     from,
+				to:       to,
+				allow:    action == "ALLOW" || action == "LIMIT",
+			})
+		}
+	}
+
+	return fw
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// nftables
+
+// loadNft() reads the input chains from `nft list ruleset`, which looks like:
+//
+//	table inet filter {
+//		chain input {
+//			type filter hook input priority filter; policy drop;
+//			ct state established,related accept
+//			iif "lo" accept
+//			tcp dport 22 accept
+//			tcp dport { 80, 443 } accept
+//		}
+//	}
+//
+// iptables-nft's rules show up here too, so this covers most modern distros using iptables as well.
+func loadNft() *firewall {
+	out, err := exec.Command("nft", "list", "ruleset").Output()
+	if err != nil {
+		debugf("nft list ruleset failed: %v", err)
+		return &firewall{name: "nftables", unknown: true}
+	}
+	return parseNft(string(out))
+}
+
+// parseNft() converts the output of nft list ruleset to rules, from the chains that filter incoming packets
+func parseNft(raw string) *firewall {
+	fw := &firewall{name: "nftables", policy: true}
+	family := 0
+	inChain := false   // Whether we're in a chain at all
+	inputHook := false // Whether the chain we're in filters incoming packets
+
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+
+		switch {
+		case strings.HasPrefix(line, "table "):
+			family = 0
+			if len(fields) > 1 && fields[1] == "ip" {
+				family = 4
+			} else if len(fields) > 1 && fields[1] == "ip6" {
+				family = 6
+			}
+
+		case strings.HasPrefix(line, "chain "):
+			inChain = true
+			inputHook = false
+
+		case line == "}":
+			inChain = false
+			inputHook = false
+
+		case inChain && strings.HasPrefix(line, "type filter hook input"):
+			inputHook = true
+			if strings.Contains(line, "policy drop") {
+				fw.policy = false
+			}
+
+		case inputHook:
+			fw.rules = append(fw.rules, parseNftRule(line, family)...)
+		}
+	}
+
+	return fw
+}
+
+// parseNftRule() converts a rule in an input chain to firewallRules, if it's one we understand. A rule on a set of
+// ports becomes a rule for each port in the set.
+func parseNftRule(line string, family int) []firewallRule {
+	rule := firewallRule{family: family, from: 0, to: 65535}
+
+	switch {
+	case strings.HasSuffix(line, "accept"):
+		rule.allow = true
+	case strings.HasSuffix(line, "drop"), strings.HasSuffix(line, "reject"):
+		rule.allow = false
+	default:
+		return nil
+	}
+
+	// Rules for established connections, loopback, or particular sources don't say anything about new connections from
+	// outside
+	for _, skip := range []string{"ct state", "iif", "saddr", "icmp"} {
+		if strings.Contains(line, skip) {
+			return nil
+		}
+	}
+
+	fields := strings.Fields(line)
+	for i := 0; i+2 < len(fields); i++ {
+		if (fields[i] == "tcp" || fields[i] == "udp") && fields[i+1] == "dport" {
+			rule.protocol = strings.ToUpper(fields[i])
+
+			// The port can be a single port, a range, or a set like { 80, 443 }
+			specs := []string{fields[i+2]}
+			if fields[i+2] == "{" {
+				specs = nil
+				for _, field := range fields[i+3:] {
+					if field == "}" {
+						break
+					}
+					specs = append(specs, strings.TrimSuffix(field, ","))
+				}
+			}
+
+			var rules []firewallRule
+			for _, spec := range specs {
+				if from, to, valid := parsePortRange(spec); valid {
+					rule.from, rule.to = from, to
+					rules = append(rules, rule)
+				}
+			}
+			return rules
+		}
+	}
+
+	// A rule with no conditions at all applies to every port
+	if len(fields) == 1 {
+		return []firewallRule{rule}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// iptables
+
+// loadIptables() reads the INPUT chain from `iptables -S INPUT` and `ip6tables -S INPUT`, which look like:
+//
+//	-P INPUT DROP
+//	-A INPUT -i lo -j ACCEPT
+//	-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+//	-A INPUT -p tcp -m multiport --dports 80,443 -j ACCEPT
+func loadIptables() *firewall {
+	fw := &firewall{name: "iptables", policy: true}
+
+	for _, command := range []struct {
+		name   string
+		family int
+	}{{"iptables", 4}, {"ip6tables", 6}} {
+		if !commandExists(command.name) {
+			continue
+		}
+
+		out, err := exec.Command(command.name, "-S", "INPUT").Output()
+		if err != nil {
+			debugf("%s -S INPUT failed: %v", command.name, err)
+			return &firewall{name: "iptables", unknown: true}
+		}
+
+		rules, policy := parseIptables(string(out), command.family)
+		fw.rules = append(fw.rules, rules...)
+
+		// There's one policy for the whole firewall, so if either blocks by default we say it does
+		fw.policy = fw.policy && policy
+	}
+
+	return fw
+}
+
+// parseIptables() converts the output of iptables -S INPUT to rules, and whether the chain allows by default
+func parseIptables(raw string, family int) ([]firewallRule, bool) {
+	var rules []firewallRule
+	policy := true
+
+	for _, line := range strings.Split(raw, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "-P" {
+			policy = fields[2] == "ACCEPT"
+			continue
+		}
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+
+		rule := firewallRule{family: family, from: 0, to: 65535}
+		var ports []string
+		target := ""
+		skip := false
+
+		for i := 2; i < len(fields); i++ {
+			next := ""
+			if i+1 < len(fields) {
+				next = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "-j":
+				target = next
+			case "-p":
+				rule.protocol = strings.ToUpper(next)
+			case "--dport":
+				ports = []string{next}
+			case "--dports":
+				ports = strings.Split(next, ",")
+			case "-i", "-s", "--state", "--ctstate", "!":
+				// Rules for particular interfaces or sources, or established connections, are skipped
+				skip = true
+			}
+		}
+
+		if skip || (target != "ACCEPT" && target != "DROP" && target != "REJECT") {
+			continue
+		}
+		rule.allow = target == "ACCEPT"
+
+		if len(ports) == 0 {
+			rules = append(rules, rule)
+			continue
+		}
+		for _, spec := range ports {
+			if from, to, valid := parsePortRange(spec); valid {
+				rule.from, rule.to = from, to
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return rules, policy
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// pf
+
+// loadPf() reads macOS's pf rules from `pfctl -s rules`, if pf is turned on. The rules look like:
+//
+//	block drop in all
+//	pass in quick proto tcp from any to any port = 22 flags S/SA keep state
+//
+// Unlike the other firewalls, pf uses the last rule that matches unless a rule is marked quick, so the rules are
+// reordered to work the same way as the others.
+func loadPf() *firewall {
+	info, err := exec.Command("pfctl", "-s", "info").Output()
+	if err != nil {
+		debugf("pfctl -s info failed: %v", err)
+		return &firewall{name: "pf", unknown: true}
+	}
+	if !strings.Contains(string(info), "Status: Enabled") {
+		return &firewall{name: "none", policy: true}
+	}
+
+	out, err := exec.Command("pfctl", "-s", "rules").Output()
+	if err != nil {
+		debugf("pfctl -s rules failed: %v", err)
+		return &firewall{name: "pf", unknown: true}
+	}
+	return parsePf(string(out))
+}
+
+// parsePf() converts the output of pfctl -s rules to rules, reordered so the first one that matches decides
+func parsePf(raw string) *firewall {
+	fw := &firewall{name: "pf", policy: true}
+	var quick, last []firewallRule
+
+	for _, line := range strings.Split(raw, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "pass" && fields[0] != "block") || !slices.Contains(fields, "in") {
+			continue
+		}
+		if slices.Contains(fields, "on") && slices.Contains(fields, "lo0") {
+			continue
+		}
+
+		rule := firewallRule{allow: fields[0] == "pass", from: 0, to: 65535}
+		for i, field := range fields {
+			if field == "proto" && i+1 < len(fields) {
+				rule.protocol = strings.ToUpper(fields[i+1])
+			}
+			if field == "inet" {
+				rule.family = 4
+			} else if field == "inet6" {
+				rule.family = 6
+			}
+			if field == "port" && i+2 < len(fields) && fields[i+1] == "=" {
+				if port, err := strconv.Atoi(fields[i+2]); err == nil {
+					rule.from, rule.to = port, port
+				}
+			}
+		}
+
+		if slices.Contains(fields, "quick") {
+			quick = append(quick, rule)
+		} else {
+			last = append(last, rule)
+		}
+	}
+
+	// Quick rules win straight away, in order. Otherwise the last matching rule wins, so check those backwards.
+	fw.rules = quick
+	for i := len(last) - 1; i >= 0; i-- {
+		fw.rules = append(fw.rules, last[i])
+	}
+
+	return fw
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		spec     string
+		from, to int
+		valid    bool
+	}{
+		{"80", 80, 80, true},
+		{"1000:2000", 1000, 2000, true},
+		{"1000-2000", 1000, 2000, true},
+		{" 443", 443, 443, true},
+		{"http", 0, 0, false},
+		{"80:", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		from, to, valid := parsePortRange(test.spec)
+		if from != test.from || to != test.to || valid != test.valid {
+			t.Errorf("parsePortRange(%q) = %d, %d, %t, want %d, %d, %t",
+				test.spec, from, to, valid, test.from, test.to, test.valid)
+		}
+	}
+}
+
+// TestParseFirewalls checks each firewall's rules are read in the order they decide ports, skipping the ones that
+// only apply to some sources, interfaces or established connections
+func TestParseFirewalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		fw     *firewall
+		rules  []firewallRule
+		policy bool
+	}{
+		{
+			name: "ufw",
+			fw: parseUfw(`Status: active
+Logging: on (low)
+Default: deny (incoming), allow (outgoing), disabled (routed)
+New profiles: skip
+
+To                         Action      From
+--                         ------      ----
+22/tcp                     ALLOW IN    Anywhere
+80,443/tcp                 ALLOW IN    Anywhere
+6000:6007/tcp              DENY IN     Anywhere
+53                         LIMIT IN    Anywhere
+OpenSSH                    ALLOW IN    Anywhere
+22/tcp (v6)                ALLOW IN    Anywhere (v6)
+`),
+			rules: []firewallRule{
+				{protocol: "tcp", family: 4, from: 22, to: 22, allow: true},
+				{protocol: "tcp", family: 4, from: 80, to: 80, allow: true},
+				{protocol: "tcp", family: 4, from: 443, to: 443, allow: true},
+				{protocol: "tcp", family: 4, from: 6000, to: 6007},
+				{family: 4, from: 53, to: 53, allow: true},
+				{protocol: "tcp", family: 6, from: 22, to: 22, allow: true},
+			},
+		},
+		{
+			name: "nftables",
+			fw: parseNft(`table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		ct state established,related accept
+		iif "lo" accept
+		ip saddr 10.0.0.0/8 tcp dport 5432 accept
+		tcp dport 22 accept
+		tcp dport { 80, 443 } accept
+		udp dport 60000-61000 accept
+		tcp dport 3306 drop
+		reject
+	}
+	chain output {
+		type filter hook output priority filter; policy accept;
+		tcp dport 25 drop
+	}
+}
+table ip6 extra {
+	chain input {
+		type filter hook input priority 0; policy accept;
+		tcp dport 8080 accept
+	}
+}
+`),
+			rules: []firewallRule{
+				{protocol: "TCP", from: 22, to: 22, allow: true},
+				{protocol: "TCP", from: 80, to: 80, allow: true},
+				{protocol: "TCP", from: 443, to: 443, allow: true},
+				{protocol: "UDP", from: 60000, to: 61000, allow: true},
+				{protocol: "TCP", from: 3306, to: 3306},
+				{from: 0, to: 65535},
+				{protocol: "TCP", family: 6, from: 8080, to: 8080, allow: true},
+			},
+		},
+		{
+			name: "pf",
+			fw: parsePf(`block drop in all
+pass in quick on lo0 all flags S/SA keep state
+pass in quick proto tcp from any to any port = 22 flags S/SA keep state
+pass in inet proto tcp from any to any port = 80 flags S/SA keep state
+pass out all flags S/SA keep state
+`),
+			// The quick rule first, then the others backwards, as pf uses the last one that matches
+			rules: []firewallRule{
+				{protocol: "TCP", from: 22, to: 22, allow: true},
+				{protocol: "TCP", family: 4, from: 80, to: 80, allow: true},
+				{from: 0, to: 65535},
+			},
+			policy: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.fw == nil {
+				t.Fatal("expected the rules to be read")
+			}
+			if !slices.Equal(test.fw.rules, test.rules) {
+				t.Errorf("got rules %+v, want %+v", test.fw.rules, test.rules)
+			}
+			if test.fw.policy != test.policy {
+				t.Errorf("got policy %t, want %t", test.fw.policy, test.policy)
+			}
+		})
+	}
+
+	if fw := parseUfw("Status: inactive\n"); fw != nil {
+		t.Errorf("expected ufw to be skipped when it's turned off, got %+v", fw)
+	}
+}
+
+func TestParseIptables(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		rules  []firewallRule
+		policy bool
+	}{
+		{
+			name: "dropping by default",
+			raw: `-P INPUT DROP
+-A INPUT -i lo -j ACCEPT
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -s 10.0.0.0/8 -p tcp -m tcp --dport 5432 -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -p tcp -m multiport --dports 80,443 -j ACCEPT
+-A INPUT -p udp -m udp --dport 6000:6007 -j REJECT --reject-with icmp-port-unreachable
+-A INPUT -p tcp -m tcp --dport 2222 -j f2b-sshd
+-A INPUT -j LOG
+`,
+			rules: []firewallRule{
+				{protocol: "TCP", family: 4, from: 22, to: 22, allow: true},
+				{protocol: "TCP", family: 4, from: 80, to: 80, allow: true},
+				{protocol: "TCP", family: 4, from: 443, to: 443, allow: true},
+				{protocol: "UDP", family: 4, from: 6000, to: 6007},
+			},
+		},
+		{
+			name: "dropping with a rule",
+			raw:  "-P INPUT ACCEPT\n-A INPUT -p tcp --dport 8080 -j ACCEPT\n-A INPUT -j DROP\n",
+			rules: []firewallRule{
+				{protocol: "TCP", family: 4, from: 8080, to: 8080, allow: true},
+				{family: 4, from: 0, to: 65535},
+			},
+			policy: true,
+		},
+		{name: "no rules", raw: "-P INPUT ACCEPT\n", policy: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, policy := parseIptables(test.raw, 4)
+			if !slices.Equal(rules, test.rules) {
+				t.Errorf("got rules %+v, want %+v", rules, test.rules)
+			}
+			if policy != test.policy {
+				t.Errorf("got policy %t, want %t", policy, test.policy)
+			}
+		})
+	}
+}
+
+// TestExposed checks the first rule that matches a listening port decides it, and the policy does if none do
+func TestExposed(t *testing.T) {
+	fw := &firewall{rules: []firewallRule{
+		{protocol: "tcp", from: 22, to: 22, allow: true},
+		{family: 6, from: 0, to: 65535},
+		{protocol: "UDP", family: 4, from: 53, to: 53, allow: true},
+	}}
+
+	tests := []struct {
+		name string
+		conn connection
+		want string
+	}{
+		{"allowed", connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "22", Status: "LISTEN"}, "yes"},
+		{
+			"allowed over IPv6 before the IPv6 rule",
+			connection{Protocol: "TCP", IPv6: true, LocalAddress: "*", LocalPort: "22", Status: "LISTEN"},
+			"yes",
+		},
+		{"left to the policy", connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "80", Status: "LISTEN"}, "no"},
+		{"UDP", connection{Protocol: "UDP", LocalAddress: "*", LocalPort: "53"}, "yes"},
+		{"UDP over IPv6", connection{Protocol: "UDP", IPv6: true, LocalAddress: "*", LocalPort: "53"}, "no"},
+		{
+			"loopback",
+			connection{Protocol: "TCP", LocalAddress: "127.0.0.1", LocalPort: "80", Status: "LISTEN"},
+			"local",
+		},
+		{
+			"IPv6 loopback",
+			connection{Protocol: "TCP", IPv6: true, LocalAddress: "[::1]", LocalPort: "80", Status: "LISTEN"},
+			"local",
+		},
+		{
+			"not listening",
+			connection{Protocol: "TCP", LocalAddress: "10.0.0.2", LocalPort: "22", RemoteAddress: "10.0.0.9",
+				RemotePort: "50000", Status: "ESTABLISHED"},
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		if got := fw.exposed(test.conn); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	unknown := &firewall{unknown: true}
+	listening := connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "22", Status: "LISTEN"}
+	if got := unknown.exposed(listening); got != "?" {
+		t.Errorf("expected ? when the rules couldn't be read, got %q", got)
+	}
+}
+
+// TestNoFirewall checks listening ports aren't reported as exposed when no firewall can be found to read
+func TestNoFirewall(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	listening := connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "22", Status: "LISTEN"}
+	if got := loadFirewall().exposed(listening); got != "?" {
+		t.Errorf("expected ? without a firewall to read, got %q", got)
+	}
+}
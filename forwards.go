@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Port Forwards
+// A port forwarder (ssh -L, kubectl port-forward, socat) listens on one port and passes whatever connects to it on to
+// somewhere else, so its row only tells half the story: ssh listening on 8080 says nothing about where 8080 goes. L
+// lists every forwarder in the table as a chain from where it listens to where it forwards to, and the detail pane (i)
+// shows a forwarder's chains too:
+//
+//	ssh (PID 4100)
+//	  127.0.0.1:8080 → db.internal:5432 (via 203.0.113.7:22)
+//
+//	kubectl (PID 4200)
+//	  127.0.0.1:9090 → pod/web:80 (via 10.0.0.1:6443)
+//
+// ssh -R forwards listen on the server instead, and are listed from there, e.g. "9000 on the server → localhost:3000".
+//
+// Where a forward goes isn't anywhere in its sockets (ssh and kubectl send everything down the one connection to the
+// server), so it's read from the forwarder's command line. "via" is where that connection goes. socat makes a new
+// connection for each one it accepts, so if its command line can't be read, those are shown instead. Forwards set up
+// in ssh's config rather than on its command line can't be seen.
+
+// The forwarders pvw knows how to read the command line of, by name
+var forwarders = map[string]string{
+	"ssh":     "ssh",
+	"autossh": "ssh",
+	"kubectl": "kubectl",
+	"oc":      "kubectl",
+	"socat":   "socat",
+}
+
+// The ssh flags that take a value, other than -L, -R and -D
+const sshValueFlags = "BbcEeFIiJlmOopQSWw"
+
+// The kubectl flags that take a value as the next argument, which isn't the resource or a port
+var kubectlValueFlags = []string{
+	"-n", "--namespace", "--address", "--context", "--cluster", "--user", "--kubeconfig", "--pod-running-timeout",
+}
+
+// forwardSpec is a forward as it's written on a command line: the port it listens on ("" if kubectl picks one), and
+// where it goes
+type forwardSpec struct {
+	port   string
+	target string
+}
+
+// forwardChain is a forward that's listening: where, where to, and the connections it's going through
+type forwardChain struct {
+	listen string
+	target string
+	via    []string
+}
+
+// String() writes a chain the way it's listed
+func (c forwardChain) String() string {
+	if len(c.via) == 0 {
+		return c.listen + " → " + c.target
+	}
+	return c.listen + " → " + c.target + " " + tr("forwards.via", strings.Join(c.via, ", "))
+}
+
+// forwarderKind() returns which forwarder a process is ("ssh", "kubectl" or "socat"), or "" if it isn't one
+func forwarderKind(proc process) string {
+	if proc.Windows {
+		return ""
+	}
+	return forwarders[filepath.Base(proc.Name)]
+}
+
+// splitForward() splits a forward like [::1]:8080:db:5432 at its colons, leaving those inside brackets alone
+func splitForward(spec string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, spec[start:])
+}
+
+// sshOption is one of ssh's forwarding flags (L, R or D) and its value
+type sshOption struct {
+	flag  byte
+	value string
+}
+
+// sshOptions() finds the forwarding flags in ssh's arguments. Flags can be run together (-NL 8080:db:5432), and the
+// value can follow its flag straight away (-L8080:db:5432).
+func sshOptions(args []string) []sshOption {
+	var options []sshOption
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			if arg[j] == 'L' || arg[j] == 'R' || arg[j] == 'D' {
+				option := sshOption{flag: arg[j], value: arg[j+1:]}
+				if option.value == "" && i+1 < len(args) {
+					option.value = args[i+1]
+				}
+				if option.value != "" {
+					options = append(options, option)
+				}
+				break
+			}
+			// The rest of the argument is another flag's value
+			if strings.IndexByte(sshValueFlags, arg[j]) != -1 {
+				break
+			}
+		}
+	}
+	return options
+}
+
+// sshForwards() reads the -L and -D forwards from ssh's arguments, which listen on this machine
+func sshForwards(args []string) []forwardSpec {
+	var specs []forwardSpec
+	for _, option := range sshOptions(args) {
+		parts := splitForward(option.value)
+		n := len(parts)
+		switch {
+		case option.flag == 'D':
+			// [bind:]port, forwarding to wherever the SOCKS client asks
+			specs = append(specs, forwardSpec{port: parts[n-1], target: tr("forwards.socks")})
+		case option.flag == 'L' && n >= 2 && strings.HasPrefix(parts[n-1], "/"):
+			// [bind:]port:/remote/socket
+			specs = append(specs, forwardSpec{port: parts[n-2], target: parts[n-1]})
+		case option.flag == 'L' && n >= 3:
+			// [bind:]port:host:hostport
+			specs = append(specs, forwardSpec{port: parts[n-3], target: parts[n-2] + ":" + parts[n-1]})
+		}
+	}
+	return specs
+}
+
+// sshRemoteForwards() reads the -R forwards from ssh's arguments, which listen on the server and forward back through
+// this machine. Their port is the server's [bind:]port as it was written, as there's nothing here to match it with.
+func sshRemoteForwards(args []string) []forwardSpec {
+	var specs []forwardSpec
+	for _, option := range sshOptions(args) {
+		if option.flag != 'R' {
+			continue
+		}
+		parts := splitForward(option.value)
+		n := len(parts)
+		switch {
+		case n >= 2 && strings.HasPrefix(parts[n-1], "/"):
+			// [bind:]port:/local/socket
+			specs = append(specs, forwardSpec{port: strings.Join(parts[:n-1], ":"), target: parts[n-1]})
+		case n >= 3:
+			// [bind:]port:host:hostport
+			port := strings.Join(parts[:n-2], ":")
+			specs = append(specs, forwardSpec{port: port, target: parts[n-2] + ":" + parts[n-1]})
+		default:
+			// [bind:]port, a SOCKS proxy on the server
+			specs = append(specs, forwardSpec{port: option.value, target: tr("forwards.socks")})
+		}
+	}
+	return specs
+}
+
+// kubectlForwards() reads the resource and ports from kubectl port-forward's arguments
+func kubectlForwards(args []string) []forwardSpec {
+	var positional []string
+	forwarding := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "port-forward":
+			forwarding = true
+		case strings.HasPrefix(arg, "-"):
+			if !strings.Contains(arg, "=") && slices.Contains(kubectlValueFlags, arg) {
+				i++
+			}
+		case forwarding:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < 2 {
+		return nil
+	}
+
+	// A resource without a type is a pod
+	resource := positional[0]
+	if !strings.Contains(resource, "/") {
+		resource = "pod/" + resource
+	}
+
+	var specs []forwardSpec
+	for _, ports := range positional[1:] {
+		local, remote, found := strings.Cut(ports, ":")
+		if !found {
+			remote = local
+		}
+		specs = append(specs, forwardSpec{port: local, target: resource + ":" + remote})
+	}
+	return specs
+}
+
+// socatForwards() reads socat's listening address and where it sends what connects to it
+func socatForwards(args []string) []forwardSpec {
+	var addresses []string
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			addresses = append(addresses, arg)
+		}
+	}
+	if len(addresses) != 2 {
+		return nil
+	}
+
+	for i, address := range addresses {
+		kind, rest, _ := strings.Cut(address, ":")
+		if !strings.HasSuffix(strings.ToUpper(kind), "-LISTEN") {
+			continue
+		}
+		port, _, _ := strings.Cut(rest, ",")
+
+		// The other address is where it forwards to, without socat's options, or the type when it's a plain one
+		target, _, _ := strings.Cut(addresses[1-i], ",")
+		if kind, rest, found := strings.Cut(target, ":"); found {
+			switch strings.ToUpper(kind) {
+			case "TCP", "TCP4", "TCP6", "UDP", "UDP4", "UDP6", "OPENSSL", "TCP-CONNECT", "UDP-CONNECT":
+				target = rest
+			}
+		}
+		return []forwardSpec{{port: port, target: target}}
+	}
+	return nil
+}
+
+// forwardingChains() works out where each of a forwarder's ports goes, or returns nil if it isn't a forwarder
+func forwardingChains(proc process) []forwardChain {
+	if forwarderKind(proc) == "" {
+		return nil
+	}
+	return chainsFromArgs(proc, processArgs(proc.ID))
+}
+
+// chainsFromArgs() works out where each of a forwarder's ports goes from its command line
+func chainsFromArgs(proc process, args []string) []forwardChain {
+	kind := forwarderKind(proc)
+
+	// The connections that aren't to its own ports are the ones it's forwarding through
+	listening := make(map[string]bool)
+	for _, conn := range proc.Connections {
+		if conn.Status == "LISTEN" {
+			listening[conn.LocalPort] = true
+		}
+	}
+	var onward []string
+	for _, conn := range proc.Connections {
+		if conn.RemotePort != "" && !listening[conn.LocalPort] {
+			if remote := conn.RemoteAddress + ":" + conn.RemotePort; !slices.Contains(onward, remote) {
+				onward = append(onward, remote)
+			}
+		}
+	}
+
+	var specs, remote []forwardSpec
+	switch {
+	case len(args) == 0:
+	case kind == "ssh":
+		specs, remote = sshForwards(args), sshRemoteForwards(args)
+	case kind == "kubectl":
+		specs = kubectlForwards(args)
+	case kind == "socat":
+		specs = socatForwards(args)
+	}
+
+	var chains []forwardChain
+	for _, conn := range proc.Connections {
+		if conn.Status != "LISTEN" {
+			continue
+		}
+		listen := conn.LocalAddress + ":" + conn.LocalPort
+
+		matched := false
+		for _, spec := range specs {
+			if spec.port == "" || spec.port == conn.LocalPort {
+				chains = append(chains, forwardChain{listen: listen, target: spec.target, via: onward})
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// socat's own connections are where it forwards to, rather than what it's forwarding through
+		if kind == "socat" && len(onward) > 0 {
+			chains = append(chains, forwardChain{listen: listen, target: strings.Join(onward, ", ")})
+		} else {
+			chains = append(chains, forwardChain{listen: listen, target: tr("forwards.unknown"), via: onward})
+		}
+	}
+	for _, spec := range remote {
+		listen := tr("forwards.remote", spec.port)
+		chains = append(chains, forwardChain{listen: listen, target: spec.target, via: onward})
+	}
+	return chains
+}
+
+// showForwards() lists the forwarding chains in the detail pane, for the processes in the table
+func showForwards(processes []process) tea.Cmd {
+	return func() tea.Msg {
+		return detailMsg{title: tr("forwards.title"), body: forwardsReport(processes)}
+	}
+}
+
+// forwardsReport() lists every forwarder with its chains, in the order they're in the table
+func forwardsReport(processes []process) string {
+	var b strings.Builder
+	for _, proc := range processes {
+		chains := forwardingChains(proc)
+		if len(chains) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			fmt.Fprintln(&b)
+		}
+		fmt.Fprintln(&b, processLabel(proc))
+		for _, chain := range chains {
+			fmt.Fprintln(&b, "  "+chain.String())
+		}
+	}
+
+	if b.Len() == 0 {
+		return tr("forwards.none") + "\n"
+	}
+	return b.String()
+}
+
+// refreshForwards() rebuilds the forwarding chains if they're open, like refreshDashboard()
+func (m *model) refreshForwards() {
+	if m.showDetail && m.detailTitle == tr("forwards.title") && m.output == nil {
+		m.openDetail(detailMsg{title: tr("forwards.title"), body: forwardsReport(m.processes)})
+	}
+}
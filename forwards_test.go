@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestSshForwards(t *testing.T) {
+	socks := tr("forwards.socks")
+	tests := []struct {
+		name    string
+		command string
+		want    []forwardSpec
+	}{
+		{"local forward", "ssh -N -L 8080:db.internal:5432 bastion", []forwardSpec{{"8080", "db.internal:5432"}}},
+		{"bind address", "ssh -L 127.0.0.1:8080:db:5432 bastion", []forwardSpec{{"8080", "db:5432"}}},
+		{"bound everywhere", "ssh -L *:8080:db:5432 bastion", []forwardSpec{{"8080", "db:5432"}}},
+		{"empty bind address", "ssh -L :8080:db:5432 bastion", []forwardSpec{{"8080", "db:5432"}}},
+		{"IPv6 bind address", "ssh -L [::1]:8080:db:5432 bastion", []forwardSpec{{"8080", "db:5432"}}},
+		{"IPv6 target", "ssh -L 8080:[2001:db8::7]:5432 bastion", []forwardSpec{{"8080", "[2001:db8::7]:5432"}}},
+		{"value straight after the flag", "ssh -L8080:db:5432 bastion", []forwardSpec{{"8080", "db:5432"}}},
+		{"flags run together", "ssh -fNL 8080:db:5432 bastion", []forwardSpec{{"8080", "db:5432"}}},
+		{"to a socket", "ssh -L 2375:/var/run/docker.sock host", []forwardSpec{{"2375", "/var/run/docker.sock"}}},
+		{
+			"to a socket with a bind address",
+			"ssh -L 127.0.0.1:2375:/var/run/docker.sock host",
+			[]forwardSpec{{"2375", "/var/run/docker.sock"}},
+		},
+		{"SOCKS proxy", "ssh -D 1080 host", []forwardSpec{{"1080", socks}}},
+		{"SOCKS proxy with a bind address", "ssh -D 127.0.0.1:1080 host", []forwardSpec{{"1080", socks}}},
+		{"SOCKS proxy on IPv6", "ssh -ND[::1]:1080 host", []forwardSpec{{"1080", socks}}},
+		{
+			"several",
+			"ssh -p 2222 -L 8080:web:80 -D 1080 -L 127.0.0.1:5432:db:5432 host",
+			[]forwardSpec{{"8080", "web:80"}, {"1080", socks}, {"5432", "db:5432"}},
+		},
+		// Remote forwards listen on the server, so there's nothing listening here for them
+		{"remote forward", "ssh -R 9000:localhost:3000 host", nil},
+		{"remote forward with a bind address", "ssh -R 0.0.0.0:9000:localhost:3000 host", nil},
+		// The letters in other flags' values aren't flags
+		{"in another flag's value", "ssh -oLogLevel=ERROR -i ~/.ssh/id_L host", nil},
+		{"in a value run together", "ssh -pL22 host", nil},
+		{"long options", "ssh --L 8080:db:5432 host", nil},
+		{"no forwards", "ssh host uptime", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sshForwards(strings.Fields(test.command)); !slices.Equal(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSshRemoteForwards(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []forwardSpec
+	}{
+		{"remote forward", "ssh -N -R 9000:localhost:3000 host", []forwardSpec{{"9000", "localhost:3000"}}},
+		{"bind address", "ssh -R 0.0.0.0:9000:localhost:3000 host", []forwardSpec{{"0.0.0.0:9000", "localhost:3000"}}},
+		{"IPv6 bind address", "ssh -R [::]:9000:[::1]:3000 host", []forwardSpec{{"[::]:9000", "[::1]:3000"}}},
+		{"flags run together", "ssh -fNR9000:localhost:3000 host", []forwardSpec{{"9000", "localhost:3000"}}},
+		{"from a socket", "ssh -R 9000:/run/app.sock host", []forwardSpec{{"9000", "/run/app.sock"}}},
+		{"SOCKS proxy on the server", "ssh -R 1080 host", []forwardSpec{{"1080", tr("forwards.socks")}}},
+		{"local forwards", "ssh -L 8080:db:5432 -D 1080 host", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sshRemoteForwards(strings.Fields(test.command)); !slices.Equal(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKubectlForwards(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []forwardSpec
+	}{
+		{"service", "kubectl port-forward svc/web 8080:80", []forwardSpec{{"8080", "svc/web:80"}}},
+		{"pod without a type", "kubectl port-forward web 9090", []forwardSpec{{"9090", "pod/web:9090"}}},
+		{
+			"several ports, one picked by kubectl",
+			"kubectl -n prod port-forward deploy/api 5000:5000 :443",
+			[]forwardSpec{{"5000", "deploy/api:5000"}, {"", "deploy/api:443"}},
+		},
+		{
+			"flags with values",
+			"kubectl port-forward --address 0.0.0.0 --namespace=prod pod/db 5432",
+			[]forwardSpec{{"5432", "pod/db:5432"}},
+		},
+		{"no ports", "kubectl port-forward pod/db", nil},
+		{"not forwarding", "kubectl get pods web", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := kubectlForwards(strings.Fields(test.command)); !slices.Equal(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSocatForwards(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []forwardSpec
+	}{
+		{"TCP", "socat TCP-LISTEN:8080,fork,reuseaddr TCP:db:5432", []forwardSpec{{"8080", "db:5432"}}},
+		{"listening second", "socat TCP:db:5432 TCP-LISTEN:8080,fork", []forwardSpec{{"8080", "db:5432"}}},
+		{"UDP", "socat -d -d UDP4-LISTEN:53 UDP:8.8.8.8:53", []forwardSpec{{"53", "8.8.8.8:53"}}},
+		{
+			"to a socket",
+			"socat TCP-LISTEN:2375,bind=127.0.0.1 UNIX-CONNECT:/var/run/docker.sock",
+			[]forwardSpec{{"2375", "UNIX-CONNECT:/var/run/docker.sock"}},
+		},
+		{"not listening", "socat - TCP:db:5432", nil},
+		{"one address", "socat TCP-LISTEN:8080", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := socatForwards(strings.Fields(test.command)); !slices.Equal(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Dev Servers
+// "My server says 3000 but the browser can't connect" usually means it isn't on 3000: vite moved on to 5174 because
+// 5173 was taken, or PORT was set in a .env that never gets read. pvw recognises the common dev servers from their
+// command lines (vite, next, rails, django's runserver and flask run), works out which port each was told to use, and
+// compares it with the ports it's actually listening on.
+//
+// The port a dev server was told to use comes from the first of these that sets it:
+//
+//  1. Its command line (--port 5173, -p 3000, runserver 0.0.0.0:8000)
+//  2. Its environment (PORT, FLASK_RUN_PORT)
+//  3. The .env (or .flaskenv) in its working directory, for the dev servers that read one
+//  4. Its config file, for vite (server.port in vite.config.*)
+//  5. The dev server's default
+//
+// --show-framework adds a Framework column naming the dev server ("vite"), and saying which port it wanted if it isn't
+// listening there ("vite, wants 5173"). The detail pane says where the port came from, and what usually causes it to
+// end up somewhere else.
+
+// framework is a dev server pvw knows how to read the port of
+type framework struct {
+	name        string
+	defaultPort string
+	portFlags   []string // The flags that set the port, which take it as the next argument or after an =
+	portEnv     []string // The environment variables that set the port
+	dotenv      []string // The files in the working directory it reads its environment from
+	configs     []string // The config files in the working directory that can set the port (see configPort())
+	hint        string   // The key of what usually explains it listening somewhere else
+}
+
+// The dev servers pvw recognises, by name
+var frameworks = map[string]framework{
+	"vite": {
+		name: "vite", defaultPort: "5173", portFlags: []string{"--port"},
+		configs: []string{"vite.config.ts", "vite.config.js", "vite.config.mjs", "vite.config.mts"},
+		hint:    "framework.hint-vite",
+	},
+	"next": {
+		name: "next", defaultPort: "3000", portFlags: []string{"-p", "--port"}, portEnv: []string{"PORT"},
+		hint: "framework.hint-next",
+	},
+	"rails": {
+		name: "rails", defaultPort: "3000", portFlags: []string{"-p", "--port"}, portEnv: []string{"PORT"},
+		dotenv: []string{".env"}, hint: "framework.hint-restarted",
+	},
+	"django": {
+		name: "django", defaultPort: "8000", hint: "framework.hint-restarted",
+	},
+	"flask": {
+		name: "flask", defaultPort: "5000", portFlags: []string{"-p", "--port"}, portEnv: []string{"FLASK_RUN_PORT"},
+		dotenv: []string{".flaskenv", ".env"}, hint: "framework.hint-flask",
+	},
+}
+
+// devServer is a dev server that's running, with the port it was told to use and where that came from
+type devServer struct {
+	framework
+	port   string
+	source string // The flag, variable or file that set the port, or "" if it's the default
+}
+
+// Matches server.port in a vite config, e.g. `port: 4000,`
+var viteConfigPortRegex = regexp.MustCompile(`\bport\s*:\s*([0-9]{1,5})\b`)
+
+// detectFramework() works out which dev server a command line runs, and the arguments after the dev server's name. It
+// returns false if it isn't one pvw recognises.
+func detectFramework(args []string) (framework, []string, bool) {
+	for i, arg := range args {
+		name := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+		rest := args[i+1:]
+		command := ""
+		if len(rest) > 0 {
+			command = rest[0]
+		}
+
+		switch {
+		case name == "vite" && command != "build" && command != "optimize":
+			f := frameworks["vite"]
+			if command == "preview" {
+				f.defaultPort = "4173"
+			}
+			return f, rest, true
+		case name == "next" && (command == "dev" || command == "start"):
+			return frameworks["next"], rest[1:], true
+		case name == "rails" && (command == "server" || command == "s"):
+			return frameworks["rails"], rest[1:], true
+		case name == "manage" && command == "runserver":
+			return frameworks["django"], rest[1:], true
+		case name == "flask" && command == "run":
+			return frameworks["flask"], rest[1:], true
+		case arg == "-m" && command == "flask" && len(rest) > 1 && rest[1] == "run":
+			return frameworks["flask"], rest[2:], true
+		}
+	}
+	return framework{}, nil, false
+}
+
+// flagPort() reads the port from the dev server's arguments, or returns "" if it isn't set there. django takes it as
+// an [address:]port argument instead of a flag.
+func flagPort(f framework, args []string) (string, string) {
+	if f.name == "django" {
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				parts := splitForward(arg)
+				return parts[len(parts)-1], "runserver"
+			}
+		}
+		return "", ""
+	}
+
+	for i, arg := range args {
+		for _, flag := range f.portFlags {
+			if strings.HasPrefix(arg, flag+"=") {
+				return strings.TrimPrefix(arg, flag+"="), flag
+			}
+			if arg == flag && i+1 < len(args) {
+				return args[i+1], flag
+			}
+		}
+	}
+	return "", ""
+}
+
+// readDotenv() reads the variables set in a .env file, ignoring comments, `export` and quotes
+func readDotenv(filename string) map[string]string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	variables := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			// A quoted value ends at its closing quote, and anything after that is a comment
+			if end := strings.IndexByte(value[1:], value[0]); end != -1 {
+				value = value[1 : end+1]
+			}
+		} else {
+			value, _, _ = strings.Cut(value, " #")
+		}
+		variables[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return variables
+}
+
+// configPort() reads the port from the first of a dev server's config files in a directory that sets one
+func configPort(f framework, directory string) (string, string) {
+	for _, config := range f.configs {
+		contents, err := os.ReadFile(filepath.Join(directory, config))
+		if err != nil {
+			continue
+		}
+		if match := viteConfigPortRegex.FindSubmatch(contents); match != nil {
+			return string(match[1]), config
+		}
+	}
+	return "", ""
+}
+
+// configuredPort() works out which port a dev server was told to use, from its arguments, its environment (as
+// NAME=value strings) and the files in its working directory, in that order
+func configuredPort(f framework, args []string, environment []string, directory string) devServer {
+	server := devServer{framework: f, port: f.defaultPort}
+
+	if port, source := flagPort(f, args); port != "" {
+		server.port, server.source = port, source
+		return server
+	}
+
+	for _, variable := range f.portEnv {
+		for _, set := range environment {
+			if value := strings.TrimPrefix(set, variable+"="); value != set && value != "" {
+				server.port, server.source = value, variable
+				return server
+			}
+		}
+	}
+
+	if directory == "" {
+		return server
+	}
+	for _, dotenv := range f.dotenv {
+		variables := readDotenv(filepath.Join(directory, dotenv))
+		for _, variable := range f.portEnv {
+			if value := variables[variable]; value != "" {
+				server.port, server.source = value, dotenv
+				return server
+			}
+		}
+	}
+	if port, source := configPort(f, directory); port != "" {
+		server.port, server.source = port, source
+	}
+	return server
+}
+
+// findDevServer() works out if a process is a dev server, and which port it was told to use
+func findDevServer(proc process) (devServer, bool) {
+	if proc.ID == 0 || proc.Windows {
+		return devServer{}, false
+	}
+
+	f, args, found := detectFramework(processArgs(proc.ID))
+	if !found {
+		return devServer{}, false
+	}
+
+	// Its environment is only read if it could say something, as it's another file to read
+	var environment []string
+	if len(f.portEnv) > 0 {
+		environment, _ = processEnvironmentVariables(proc.ID)
+	}
+	directory := proc.Directory
+	if directory == "" {
+		directory, _ = getCwd(proc.ID)
+	}
+	return configuredPort(f, args, environment, directory), true
+}
+
+// listeningPorts() returns the TCP ports a process is listening on
+func listeningPorts(proc process) []string {
+	var ports []string
+	for _, conn := range proc.Connections {
+		if conn.Protocol == "TCP" && isListening(conn) && !slices.Contains(ports, conn.LocalPort) {
+			ports = append(ports, conn.LocalPort)
+		}
+	}
+	return ports
+}
+
+// mismatched() checks if a dev server isn't listening on the port it was told to use
+func (s devServer) mismatched(proc process) bool {
+	ports := listeningPorts(proc)
+	return len(ports) > 0 && !slices.Contains(ports, s.port)
+}
+
+// label() describes a dev server the way the Framework column shows it
+func (s devServer) label(proc process) string {
+	if s.mismatched(proc) {
+		return tr("framework.wants", s.name, s.port)
+	}
+	return s.name
+}
+
+// devServerLines() describes a process' dev server for the detail pane, or returns nil if it isn't one
+func devServerLines(proc process) []string {
+	server, found := findDevServer(proc)
+	if !found {
+		return nil
+	}
+
+	source := tr("framework.default")
+	if server.source != "" {
+		source = server.source
+	}
+	lines := []string{tr("framework.configured", server.name, server.port, source)}
+	if server.mismatched(proc) {
+		lines = append(lines,
+			tr("framework.mismatch", strings.Join(listeningPorts(proc), ", ")),
+			tr(server.hint),
+		)
+	}
+	return lines
+}
+
+// fillFrameworkColumn() fills in the Framework column of a process' first row
+func fillFrameworkColumn(rows []table.Row, proc process, options settings) {
+	if !options.showFramework || len(rows) == 0 {
+		return
+	}
+
+	column := columnIndex(options, "Framework")
+	if column == -1 {
+		return
+	}
+	if server, found := findDevServer(proc); found {
+		rows[0][column] = server.label(proc)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfiguredPort checks each dev server is recognised from its command line, and that the port it was told to use
+// is read from the first place that sets it
+func TestConfiguredPort(t *testing.T) {
+	directory := t.TempDir()
+	files := map[string]string{
+		".env":           "# local settings\nexport PORT=4000\nFLASK_RUN_PORT='5050' # flask\n",
+		"vite.config.ts": "export default defineConfig({\n\tserver: { port: 4321, strictPort: false },\n})\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		command     string
+		environment []string
+		name        string
+		port        string
+		source      string
+	}{
+		{"node /app/node_modules/.bin/vite --port 5200", nil, "vite", "5200", "--port"},
+		{"node /app/node_modules/.bin/vite", nil, "vite", "4321", "vite.config.ts"},
+		{"node /app/node_modules/vite/bin/vite.js preview --port=4200", nil, "vite", "4200", "--port"},
+		{"node /app/node_modules/next/dist/bin/next dev -p 3100", []string{"PORT=3200"}, "next", "3100", "-p"},
+		{"node /app/node_modules/next/dist/bin/next start", []string{"PORT=3200"}, "next", "3200", "PORT"},
+		{"node /app/node_modules/next/dist/bin/next dev", nil, "next", "3000", ""},
+		{"ruby bin/rails server", nil, "rails", "4000", ".env"},
+		{"python manage.py runserver 0.0.0.0:8100", nil, "django", "8100", "runserver"},
+		{"python manage.py runserver", nil, "django", "8000", ""},
+		{"python -m flask run", nil, "flask", "5050", ".env"},
+		{"/venv/bin/flask run --port 5100", []string{"FLASK_RUN_PORT=5200"}, "flask", "5100", "--port"},
+	} {
+		f, args, found := detectFramework(strings.Fields(test.command))
+		if !found {
+			t.Errorf("expected %q to be recognised as %s", test.command, test.name)
+			continue
+		}
+
+		server := configuredPort(f, args, test.environment, directory)
+		if server.name != test.name || server.port != test.port || server.source != test.source {
+			t.Errorf("%q: expected %s on %s from %q, got %s on %s from %q", test.command, test.name, test.port,
+				test.source, server.name, server.port, server.source)
+		}
+	}
+
+	for _, command := range []string{"node server.js", "node /app/node_modules/.bin/vite build", "ruby bin/rails console"} {
+		if f, _, found := detectFramework(strings.Fields(command)); found {
+			t.Errorf("expected %q not to be a dev server, got %s", command, f.name)
+		}
+	}
+}
+
+// TestDevServerMismatch checks a dev server is only said to want another port when it isn't listening on it
+func TestDevServerMismatch(t *testing.T) {
+	server := devServer{framework: frameworks["vite"], port: "5173"}
+
+	if label := server.label(listeningProcess(100, "node", "5173")); label != "vite" {
+		t.Errorf("expected vite on its own port to be labelled vite, got %q", label)
+	}
+	if label := server.label(listeningProcess(100, "node", "5174")); label != "vite, wants 5173" {
+		t.Errorf("expected vite on another port to say which it wants, got %q", label)
+	}
+}
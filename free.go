@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Free Port Mode
+// `pvw free --range 3000-3999` prints a port in the range that nothing is using, so scripts can pick a port for a dev
+// server without guessing. It works from the same snapshot of sockets as the TUI, then double checks the port by
+// binding to it, in case something took it in between. The exit code is 0 if a port was found, 1 if something went wrong,
+// or 3 if every port in the range is taken (see exit.go). The port is what it's for, so --quiet still prints it, and
+// only leaves out saying that none were free.
+
+// The range used if --range isn't passed
+const defaultFreeRange = "3000-3999"
+
+// runFree() runs free port mode with the arguments after `free`, and returns the exit code
+func runFree(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("free", pflag.ContinueOnError)
+	flagRange := flags.String("range", defaultFreeRange, tr("flag.range"))
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("free.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	from, to, valid := parsePortRange(*flagRange)
+	if !valid || from < 1 || to > 65535 || from > to {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.invalid-range", *flagRange)))
+		return exitError
+	}
+
+	all, err := collectListeners(*flagBackend, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	// Any port with a socket on it is out, not just listening ones, as binding to it could still fail
+	used := make(map[string]bool)
+	for _, proc := range all {
+		for _, conn := range proc.Connections {
+			used[conn.LocalPort] = true
+		}
+	}
+
+	for port := from; port <= to; port++ {
+		if used[strconv.Itoa(port)] || !canBind(port) {
+			continue
+		}
+
+		fmt.Fprintln(w, port)
+		return exitOK
+	}
+
+	fmt.Fprintln(quietOutput(os.Stderr, *flagQuiet), tr("free.none", *flagRange))
+	return exitConflict
+}
+
+// canBind() checks that a TCP port can actually be listened on, by listening on it and closing it straight away
+func canBind(port int) bool {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		debugf("port %d is free according to the backend, but can't be bound: %v", port, err)
+		return false
+	}
+	listener.Close()
+	return true
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Protocol Guessing
+// The port number is only a hint about what's listening (lots of things end up on 8080). With --guess-protocols, pvw
+// connects to each listening TCP port and works out what's there from what it says:
+//
+//   - Many protocols (SSH, SMTP, FTP, POP3, IMAP, MySQL, VNC) send a banner as soon as they're connected to.
+//   - Otherwise, pvw tries a TLS handshake, then sends a harmless request for HTTP (HEAD /), Redis (PING) and
+//     Postgres (an SSL request), stopping at the first one that gets an answer it recognises.
+//
+// Each connection only waits briefly, and the result is cached per address and port so each port is only probed once.
+
+// How long to wait for each connection and answer while guessing
+const guessTimeout = 500 * time.Millisecond
+
+// The guesses made so far, by address and port. Formatting happens in several goroutines, so it's behind a mutex.
+var (
+	guessMutex sync.Mutex
+	guesses    = make(map[string]string)
+)
+
+// banners maps the start of a banner to the protocol that sends it
+var banners = []struct {
+	prefix   string
+	protocol string
+}{
+	{"SSH-", "SSH"},
+	{"+OK", "POP3"},
+	{"* OK", "IMAP"},
+	{"RFB ", "VNC"},
+	{"AMQP", "AMQP"},
+}
+
+// guessProtocol() works out what protocol a listening port speaks, or "?" if it couldn't tell
+func guessProtocol(conn connection) string {
+	address, err := probeAddress(conn)
+	if err != nil {
+		return ""
+	}
+
+	guessMutex.Lock()
+	guess, exists := guesses[address]
+	guessMutex.Unlock()
+	if exists {
+		return guess
+	}
+
+	guess = identify(address)
+	debugf("guessed %s is %s", address, guess)
+
+	guessMutex.Lock()
+	guesses[address] = guess
+	guessMutex.Unlock()
+
+	return guess
+}
+
+// identify() connects to a port and works out what it is, first from its banner, then by sending requests to it
+func identify(address string) string {
+	banner := exchange(address, nil)
+	if len(banner) > 0 {
+		return identifyBanner(banner)
+	}
+
+	if speaksTLS(address, guessTimeout) {
+		return "TLS"
+	}
+
+	if answer := exchange(address, []byte("HEAD / HTTP/1.0\r\n\r\n")); bytes.HasPrefix(answer, []byte("HTTP/")) {
+		return "HTTP"
+	}
+
+	if answer := exchange(address, []byte("PING\r\n")); bytes.HasPrefix(answer, []byte("+PONG")) ||
+		bytes.HasPrefix(answer, []byte("-NOAUTH")) || bytes.HasPrefix(answer, []byte("-ERR")) {
+		return "Redis"
+	}
+
+	// Postgres' SSLRequest: a length of 8, then the magic number 80877103. Postgres answers with a single S or N.
+	sslRequest := []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}
+	if answer := exchange(address, sslRequest); len(answer) == 1 && (answer[0] == 'S' || answer[0] == 'N') {
+		return "Postgres"
+	}
+
+	return "?"
+}
+
+// identifyBanner() works out the protocol from the banner a service sent when it was connected to
+func identifyBanner(banner []byte) string {
+	text := string(banner)
+
+	for _, b := range banners {
+		if strings.HasPrefix(text, b.prefix) {
+			return b.protocol
+		}
+	}
+
+	// SMTP and FTP both greet with 220, but FTP servers usually say so
+	if strings.HasPrefix(text, "220") {
+		if strings.Contains(strings.ToUpper(text), "FTP") {
+			return "FTP"
+		}
+		return "SMTP"
+	}
+
+	// MySQL's handshake is a packet with a 3 byte length and a sequence number of 0, then protocol version 10
+	if len(banner) > 4 && banner[3] == 0 && banner[4] == 10 {
+		return "MySQL"
+	}
+
+	return "?"
+}
+
+// exchange() connects to a port, sends a request if there is one, and returns the start of whatever comes back. Nothing
+// is returned if the connection fails or nothing comes back in time.
+func exchange(address string, request []byte) []byte {
+	c, err := net.DialTimeout("tcp", address, guessTimeout)
+	if err != nil {
+		return nil
+	}
+	defer c.Close()
+
+	c.SetDeadline(time.Now().Add(guessTimeout))
+	if request != nil {
+		if _, err := c.Write(request); err != nil {
+			return nil
+		}
+	}
+
+	buffer := make([]byte, 256)
+	n, _ := c.Read(buffer)
+	return buffer[:n]
+}
+
+// fillGuessColumn() fills in the Protocol Guess column for every listening connection of a process
+func fillGuessColumn(rows []table.Row, proc process, options settings) {
+	if !options.guessProtocols {
+		return
+	}
+
+	column := columnIndex(options, "Protocol Guess")
+	if column == -1 {
+		return
+	}
+
+	for i, conn := range proc.Connections {
+		if i < len(rows) && isListening(conn) && conn.Protocol == "TCP" {
+			rows[i][column] = guessProtocol(conn)
+		}
+	}
+}
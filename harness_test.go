@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Test Harness
+// The TUI is tested by driving the model the way bubbletea would: sending it key presses, running the commands it
+// returns and feeding their messages back in, then looking at what it renders and which row is selected. Processes
+// come from fakeBackend, which serves scripted snapshots instead of running lsof, so every test sees the same thing.
+
+// The columns the tests' tables have
+var testColumns = []table.Column{
+	{Title: "PID", Width: 5}, {Title: "Name", Width: 10}, {Title: "Port", Width: 5}, {Title: "Status", Width: 11},
+}
+
+// How long a command gets to return its message before the test fails. Timers never fire in the tests and cursors
+// don't blink, so only a command that's stuck takes anywhere near this long.
+const commandTimeout = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	if err := setLanguage("en"); err != nil {
+		panic(err)
+	}
+	keys = newKeyMap()
+
+	// Tests send watch mode's ticks themselves when they want one
+	after = func(time.Duration, func(time.Time) tea.Msg) tea.Cmd { return nil }
+	os.Exit(m.Run())
+}
+
+// fakeBackend serves a scripted snapshot for each collection, and keeps serving the last one once it runs out. If
+// there are any failures, the first collections fail with them instead, one each.
+type fakeBackend struct {
+	mutex     sync.Mutex
+	snapshots [][]process
+	failures  []error
+	collected int
+}
+
+func (b *fakeBackend) name() string    { return "fake" }
+func (b *fakeBackend) available() bool { return true }
+
+func (b *fakeBackend) capabilities() capabilities {
+	return capabilities{processNames: true, owners: true, otherUsers: true, kill: true}
+}
+
+func (b *fakeBackend) collect(ctx context.Context) ([]process, []parseWarning, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.failures) > 0 {
+		err := b.failures[0]
+		b.failures = b.failures[1:]
+		return nil, nil, err
+	}
+
+	snapshot := b.snapshots[len(b.snapshots)-1]
+	if b.collected < len(b.snapshots) {
+		snapshot = b.snapshots[b.collected]
+	}
+	b.collected++
+
+	// Callers may change what they're given, so each collection gets its own copy
+	processes := make([]process, len(snapshot))
+	for i, proc := range snapshot {
+		processes[i] = proc
+		processes[i].Connections = append([]connection{}, proc.Connections...)
+	}
+	return processes, nil, nil
+}
+
+// listeningProcess() returns a process listening on the given TCP ports
+func listeningProcess(pid int, name string, ports ...string) process {
+	proc := process{ID: pid, Name: name, Username: "sam"}
+	for _, port := range ports {
+		proc.Connections = append(proc.Connections, connection{
+			Protocol: "TCP", Status: "LISTEN", LocalAddress: "*", LocalPort: port,
+		})
+	}
+	return proc
+}
+
+// testModel() is a running pvw, started with the settings changed by configure and showing the backend's first
+// snapshot
+func testModel(t *testing.T, backend *fakeBackend, configure func(*settings)) *harness {
+	t.Helper()
+
+	// A new pvw hasn't seen any connections yet, whatever earlier tests' have
+	ageMutex.Lock()
+	firstSeen, ageCollected = nil, false
+	ageMutex.Unlock()
+
+	options := settings{
+		columns:   testColumns,
+		match:     matchExact,
+		showTCP:   true,
+		showUDP:   true,
+		showIPv4:  true,
+		showIPv6:  true,
+		timeout:   time.Second,
+		backend:   backend,
+		height:    10,
+		density:   densityComfortable,
+		collapsed: make(map[int]bool),
+	}
+	if configure != nil {
+		configure(&options)
+	}
+
+	tbl := table.New(
+		table.WithColumns(translateColumns(options.columns)),
+		table.WithFocused(true),
+		table.WithHeight(options.height),
+	)
+	tbl.SetStyles(tableStyles(options.density, options.theme))
+
+	h := &harness{t: t, m: model{
+		table:     tbl,
+		settings:  options,
+		textInput: textinput.New(),
+		keys:      keys,
+		help:      help.New(),
+	}}
+	h.run(h.m.Init())
+	return h
+}
+
+// harness drives a model the way bubbletea would
+type harness struct {
+	t *testing.T
+	m model
+}
+
+// send() sends the model a message, and runs the commands it returns until there aren't any left
+func (h *harness) send(msg tea.Msg) {
+	h.t.Helper()
+	updated, cmd := h.m.Update(msg)
+	h.m = updated.(model)
+	h.stopBlinking()
+	h.run(cmd)
+}
+
+// stopBlinking() stops the cursors in the model's text inputs from blinking. A blinking cursor sends a message every
+// half a second for as long as its input is open, so a test waiting for it would never finish.
+func (h *harness) stopBlinking() {
+	for _, input := range []*textinput.Model{&h.m.textInput, &h.m.prompt, &h.m.filterInput, &h.m.keySearch} {
+		input.SetCursorMode(textinput.CursorStatic)
+	}
+}
+
+// run() runs a command, and sends its message back to the model
+func (h *harness) run(cmd tea.Cmd) {
+	h.t.Helper()
+	if cmd == nil {
+		return
+	}
+
+	result := make(chan tea.Msg, 1)
+	go func() { result <- cmd() }()
+
+	var msg tea.Msg
+	select {
+	case msg = <-result:
+	case <-time.After(commandTimeout):
+		h.t.Fatalf("a command didn't return its message within %s", commandTimeout)
+	}
+
+	// Quitting has nothing to send back
+	if msg == nil || msg == tea.Quit() {
+		return
+	}
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			h.run(cmd)
+		}
+		return
+	}
+	h.send(msg)
+}
+
+// press() presses each key in turn. Names like enter and esc are special keys, and anything else is typed.
+func (h *harness) press(presses ...string) {
+	h.t.Helper()
+	special := map[string]tea.KeyType{
+		"enter": tea.KeyEnter, "esc": tea.KeyEsc, "tab": tea.KeyTab, "up": tea.KeyUp, "down": tea.KeyDown,
+		"backspace": tea.KeyBackspace, "ctrl+k": tea.KeyCtrlK,
+		"ctrl+r": tea.KeyCtrlR,
+	}
+
+	for _, press := range presses {
+		if keyType, ok := special[press]; ok {
+			h.send(tea.KeyMsg{Type: keyType})
+			continue
+		}
+		for _, r := range press {
+			h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+}
+
+// selectedPID() returns the PID of the process the selected row belongs to
+func (h *harness) selectedPID() int {
+	h.t.Helper()
+	processIndex, _, exists := h.m.rowLocation(h.m.table.Cursor())
+	if !exists {
+		h.t.Fatalf("no row is selected (cursor %d of %d rows)", h.m.table.Cursor(), len(h.m.rows))
+	}
+	return h.m.processes[processIndex].ID
+}
+
+// shownPIDs() returns the PIDs of the processes in the table, in order
+func (h *harness) shownPIDs() []int {
+	var pids []int
+	for _, proc := range h.m.processes {
+		pids = append(pids, proc.ID)
+	}
+	return pids
+}
+
+// expectView() fails the test if the rendered TUI doesn't contain each of the strings
+func (h *harness) expectView(wanted ...string) {
+	h.t.Helper()
+	view := h.m.View()
+	for _, s := range wanted {
+		if !strings.Contains(view, s) {
+			h.t.Errorf("expected the view to contain %q, got:\n%s", s, view)
+		}
+	}
+}
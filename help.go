@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Help, Man Page and Completions
+// pvw has too many flags for pflag's flat list to be readable, so --help, the man page (`pvw man`) and the shell
+// completion scripts (`pvw completion bash|zsh|fish`) are all made from the spec below: the subcommands, the flags in
+// groups, and some examples. The flags themselves are still defined in main(), and anything left out of the groups
+// ends up under "Other", so a new flag is never missing from the help - but it should be given a group.
+//
+// The subcommands' own flags are only in their own --help (and not in the completions), as they're short.
+
+// flagGroup is a heading in the help, and the flags under it
+type flagGroup struct {
+	title string // The locale key for the heading
+	flags []string
+}
+
+// The main command's flags, grouped as they're shown in the help and man page
+var flagGroups = []flagGroup{
+	{"usage.columns", []string{
+		"show-process-id", "show-process-name", "show-cwd", "show-project", "show-framework", "show-owner", "show-nice",
+		"show-resources", "show-protocol", "show-addresses", "show-full-connection", "show-status", "show-age", "show-peers",
+		"show-queues", "show-tcp-info", "show-interface", "show-exposed", "show-privileges", "show-proto-names",
+		"show-all", "host-names", "addresses", "guess-protocols", "kubernetes", "windows", "all-namespaces",
+	}},
+	{"usage.filters", []string{
+		"listen-only", "show-closed", "tcp", "udp", "ipv4", "ipv6", "ports", "interface", "host", "match", "exclude-name",
+		"exclude-ports", "exclude-user", "hide-self", "sort",
+	}},
+	{"usage.display", []string{
+		"interval", "reactive", "ebpf", "height", "alt-screen", "density", "fixed-widths", "title", "plain",
+		"accessible", "lang", "read-only", "config", "no-persist",
+	}},
+	{"usage.scripting", []string{"json", "exec", "dry-run", "confirm", "check-policy", "quiet", "record", "replay"}},
+	{"usage.troubleshooting", []string{"backend", "timeout", "debug"}},
+}
+
+// The subcommands, in the order they're listed. Each has a <name>.usage locale key, as its own --help prints.
+var subcommands = []string{
+	"check", "free", "listen", "multicast", "snapshot", "diff", "who-had", "setup", "schema", "version", "self-update", "man",
+	"completion",
+}
+
+// The examples at the end of the help, with the locale keys describing them
+var usageExamples = [][2]string{
+	{"pvw -n node postgres", "usage.example-names"},
+	{"pvw -l --ports 3000,8000-8100", "usage.example-ports"},
+	{"pvw -w 2s --alt-screen", "usage.example-watch"},
+	{"pvw check 5432 || echo taken", "usage.example-check"},
+	{"pvw --exec 'kill {pid}' --confirm node", "usage.example-exec"},
+}
+
+// The shells `pvw completion` can write a script for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// groupedFlags() returns the main command's flags in their groups, with any that aren't in one under "Other"
+func groupedFlags(flags *pflag.FlagSet) []flagGroup {
+	grouped := make([]flagGroup, 0, len(flagGroups)+1)
+	seen := make(map[string]bool)
+
+	for _, group := range flagGroups {
+		var names []string
+		for _, name := range group.flags {
+			if flags.Lookup(name) != nil {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+		grouped = append(grouped, flagGroup{group.title, names})
+	}
+
+	var other []string
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if !seen[flag.Name] && !flag.Hidden {
+			other = append(other, flag.Name)
+		}
+	})
+	if len(other) > 0 {
+		grouped = append(grouped, flagGroup{"usage.other", other})
+	}
+	return grouped
+}
+
+// subcommandUsage() splits a subcommand's usage text into its synopsis and description
+func subcommandUsage(name string) (string, string) {
+	synopsis, description, _ := strings.Cut(tr(name+".usage"), "\n\n")
+	synopsis, _, _ = strings.Cut(synopsis, "\n")
+
+	// Drop the "Usage:" in front, whatever the language
+	if _, rest, found := strings.Cut(synopsis, " "); found {
+		synopsis = rest
+	}
+	return synopsis, description
+}
+
+// printUsage() writes the grouped --help
+func printUsage(w io.Writer, flags *pflag.FlagSet) {
+	fmt.Fprintln(w, tr("usage.synopsis"))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, tr("usage.description"))
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, tr("usage.commands"))
+	for _, name := range subcommands {
+		_, description := subcommandUsage(name)
+		fmt.Fprintf(w, "  %-13s%s\n", name, firstSentence(description))
+	}
+
+	for _, group := range groupedFlags(flags) {
+		section := pflag.NewFlagSet("", pflag.ContinueOnError)
+		section.SortFlags = false
+		for _, name := range group.flags {
+			section.AddFlag(flags.Lookup(name))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, tr(group.title))
+		fmt.Fprint(w, section.FlagUsages())
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, tr("usage.examples"))
+	for _, example := range usageExamples {
+		fmt.Fprintf(w, "  %-42s%s\n", example[0], tr(example[1]))
+	}
+}
+
+// firstSentence() returns the first sentence of a description, for the list of subcommands
+func firstSentence(description string) string {
+	if end := strings.Index(description, ". "); end != -1 {
+		return description[:end+1]
+	}
+	return description
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Man Page
+
+// roffEscape() escapes text for roff, which treats backslashes, and dots and quotes at the start of a line, specially
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeManPage() writes pvw's man page, in roff
+func writeManPage(w io.Writer, flags *pflag.FlagSet) {
+	fmt.Fprintln(w, `.TH PVW 1 "" "pvw" "User Commands"`)
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintln(w, `pvw \- `+roffEscape(tr("usage.name")))
+
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintln(w, ".B pvw")
+	fmt.Fprintln(w, `[\fIflags\fR] [\fINAME\fR...]`)
+	fmt.Fprintln(w, ".br")
+	fmt.Fprintln(w, ".B pvw")
+	fmt.Fprintln(w, `\fICOMMAND\fR [\fIflags\fR]`)
+
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, roffEscape(tr("usage.description")))
+
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, name := range subcommands {
+		synopsis, description := subcommandUsage(name)
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintln(w, `.B `+roffEscape(synopsis))
+		fmt.Fprintln(w, roffEscape(description))
+	}
+
+	fmt.Fprintln(w, ".SH OPTIONS")
+	for _, group := range groupedFlags(flags) {
+		fmt.Fprintln(w, ".SS "+roffEscape(strings.TrimSuffix(tr(group.title), ":")))
+		for _, name := range group.flags {
+			flag := flags.Lookup(name)
+			fmt.Fprintln(w, ".TP")
+			if flag.Shorthand != "" {
+				fmt.Fprintf(w, ".BR \\-%s \", \" \\-\\-%s\n", flag.Shorthand, roffEscape(flag.Name))
+			} else {
+				fmt.Fprintf(w, ".B \\-\\-%s\n", roffEscape(flag.Name))
+			}
+			fmt.Fprintln(w, roffEscape(flag.Usage))
+		}
+	}
+
+	fmt.Fprintln(w, ".SH EXAMPLES")
+	for _, example := range usageExamples {
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintln(w, `.B `+roffEscape(example[0]))
+		fmt.Fprintln(w, roffEscape(tr(example[1])))
+	}
+
+	fmt.Fprintln(w, ".SH FILES")
+	fmt.Fprintln(w, ".TP")
+	fmt.Fprintln(w, `.I ~/.config/pvw/config.json`)
+	fmt.Fprintln(w, roffEscape(tr("usage.file-config")))
+	fmt.Fprintln(w, ".TP")
+	fmt.Fprintln(w, `.I ~/.local/state/pvw/`)
+	fmt.Fprintln(w, roffEscape(tr("usage.file-state")))
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Completion Scripts
+
+// zshDescription() escapes a description for zsh's _arguments, where brackets and colons mean something
+func zshDescription(text string) string {
+	return strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(text)
+}
+
+// writeCompletion() writes the completion script for a shell
+func writeCompletion(w io.Writer, shell string, flags *pflag.FlagSet) {
+	var names []string
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden {
+			return
+		}
+		names = append(names, "--"+flag.Name)
+		if flag.Shorthand != "" {
+			names = append(names, "-"+flag.Shorthand)
+		}
+	})
+
+	switch shell {
+	case "bash":
+		fmt.Fprintln(w, "# bash completion for pvw - add `source <(pvw completion bash)` to ~/.bashrc")
+		fmt.Fprintln(w, "_pvw() {")
+		fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}"`)
+		fmt.Fprintln(w, `	if [ "$COMP_CWORD" -eq 1 ] && [[ "$cur" != -* ]]; then`)
+		fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(subcommands, " ")))
+		fmt.Fprintln(w, "\t\treturn")
+		fmt.Fprintln(w, "\tfi")
+		fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+		fmt.Fprintln(w, "}")
+		fmt.Fprintln(w, "complete -F _pvw pvw")
+
+	case "zsh":
+		fmt.Fprintln(w, "#compdef pvw")
+		fmt.Fprintln(w, "# zsh completion for pvw - save as _pvw somewhere on $fpath")
+		fmt.Fprintln(w, "_pvw() {")
+		fmt.Fprintln(w, "\tlocal -a commands")
+		fmt.Fprintln(w, "\tcommands=(")
+		for _, name := range subcommands {
+			_, description := subcommandUsage(name)
+			fmt.Fprintf(w, "\t\t'%s:%s'\n", name, zshDescription(firstSentence(description)))
+		}
+		fmt.Fprintln(w, "\t)")
+		fmt.Fprintln(w, "\t_arguments -s \\")
+		flags.VisitAll(func(flag *pflag.Flag) {
+			if flag.Hidden {
+				return
+			}
+			description := zshDescription(flag.Usage)
+			if flag.Shorthand != "" {
+				fmt.Fprintf(w, "\t\t'(-%s --%s)'{-%s,--%s}'[%s]' \\\n", flag.Shorthand, flag.Name, flag.Shorthand,
+					flag.Name, description)
+			} else {
+				fmt.Fprintf(w, "\t\t'--%s[%s]' \\\n", flag.Name, description)
+			}
+		})
+		fmt.Fprintln(w, "\t\t'1: :_describe command commands' \\")
+		fmt.Fprintln(w, "\t\t'*:name:'")
+		fmt.Fprintln(w, "}")
+		fmt.Fprintln(w, `_pvw "$@"`)
+
+	case "fish":
+		fmt.Fprintln(w, "# fish completion for pvw - save as ~/.config/fish/completions/pvw.fish")
+		for _, name := range subcommands {
+			_, description := subcommandUsage(name)
+			fmt.Fprintf(w, "complete -c pvw -n __fish_use_subcommand -f -a %s -d %s\n", name,
+				shellQuote(firstSentence(description)))
+		}
+		flags.VisitAll(func(flag *pflag.Flag) {
+			if flag.Hidden {
+				return
+			}
+			short := ""
+			if flag.Shorthand != "" {
+				short = " -s " + flag.Shorthand
+			}
+			fmt.Fprintf(w, "complete -c pvw -l %s%s -d %s\n", flag.Name, short, shellQuote(flag.Usage))
+		})
+	}
+}
+
+// runCompletion() runs completion mode with the arguments after `completion`, and returns the exit code
+func runCompletion(args []string, w io.Writer, flags *pflag.FlagSet) int {
+	if len(args) != 1 || !slices.Contains(completionShells, args[0]) {
+		fmt.Fprintln(os.Stderr, tr("completion.usage"))
+		return exitError
+	}
+	writeCompletion(w, args[0], flags)
+	return exitOK
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Highlight Rules
+// Filters hide whatever doesn't match, but some things are worth keeping in view and noticing: anything root-owned
+// listening on every address, or anything on port 22. Highlight rules in the config file pick out connections with
+// the same expressions as the filter bar (see filterbar.go), and give their rows a colour, a badge, or both:
+//
+//	"highlights": [
+//		{"match": "user:root addr:0.0.0.0 state:LISTEN", "color": "red", "badge": "⚠"},
+//		{"match": "port:22", "color": "yellow"}
+//	]
+//
+// Colours can be a name (red, green, yellow, blue, magenta, cyan, white, grey), an ANSI colour number or a hex colour.
+// The first rule that matches a connection is the one used. The selected row keeps the selection's colours, so it's
+// still clear which one it is.
+
+// highlightRule is a rule from the config file giving matching rows a colour or badge
+type highlightRule struct {
+	Match string `json:"match"` // An expression in the filter bar's syntax
+	Color string `json:"color"`
+	Badge string `json:"badge"`
+
+	filters []filterTerm // Match, parsed
+}
+
+// The colour names highlight rules can use, and their ANSI colour numbers
+var highlightColors = map[string]string{
+	"red": "1", "green": "2", "yellow": "3", "blue": "4", "magenta": "5", "cyan": "6", "white": "7", "grey": "8",
+	"gray": "8",
+}
+
+// Colours given as numbers or hex, which lipgloss takes as they are
+var rawColorRegex = regexp.MustCompile(`^([0-9]{1,3}|#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6})$`)
+
+// color() returns the colour a rule gives its rows, as lipgloss takes it
+func (r highlightRule) color() lipgloss.Color {
+	if number, named := highlightColors[strings.ToLower(r.Color)]; named {
+		return lipgloss.Color(number)
+	}
+	return lipgloss.Color(r.Color)
+}
+
+// validate() checks a rule makes sense, and parses its expression
+func (r *highlightRule) validate() error {
+	r.filters = parseFilters(r.Match)
+	if len(r.filters) == 0 || (r.Color == "" && r.Badge == "") {
+		return errors.New(tr("error.highlight"))
+	}
+	for _, term := range r.filters {
+		if !term.valid() {
+			return errors.New(tr("error.highlight-match", r.Match, term.value))
+		}
+	}
+
+	_, named := highlightColors[strings.ToLower(r.Color)]
+	if r.Color != "" && !named && !rawColorRegex.MatchString(r.Color) {
+		return errors.New(tr("error.highlight-color", r.Color))
+	}
+	return nil
+}
+
+// highlightFor() returns the first rule that matches a connection and gives it what's wanted (a colour or a badge),
+// or nil if none do
+func highlightFor(rules []highlightRule, proc process, conn connection, wanted func(highlightRule) bool) *highlightRule {
+	for i, rule := range rules {
+		if wanted(rule) && matchesFilters(rule.filters, proc, conn) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// fillHighlightBadges() puts the badges from highlight rules on the rows they match, in the Status column if there is
+// one or the first column otherwise
+func fillHighlightBadges(rows []table.Row, proc process, options settings) {
+	if len(options.highlights) == 0 || len(options.columns) == 0 {
+		return
+	}
+
+	column := badgeColumn(options)
+
+	hasBadge := func(rule highlightRule) bool { return rule.Badge != "" }
+	for i, conn := range proc.Connections {
+		if i >= len(rows) {
+			break
+		}
+		if rule := highlightFor(options.highlights, proc, conn, hasBadge); rule != nil {
+			rows[i][column] = rule.Badge + " " + rows[i][column]
+		}
+	}
+}
+
+// highlightRows() colours the lines of the rendered table whose rows a highlight rule matches. The table can't colour
+// single rows itself, so the lines are coloured after it's rendered, working out which row each line is from how far
+// the table is scrolled (see sticky.go).
+func (m model) highlightRows(view string) string {
+	if len(m.settings.highlights) == 0 {
+		return view
+	}
+
+	hasColor := func(rule highlightRule) bool { return rule.Color != "" }
+	lines := strings.Split(view, "\n")
+	for i := range lines {
+		row := m.tableTop + i - m.settings.headerLines()
+		if row < 0 || row >= len(m.rows) || row == m.table.Cursor() {
+			continue
+		}
+
+		processIndex, connectionIndex, exists := m.rowLocation(row)
+		if !exists {
+			continue
+		}
+		proc := m.processes[processIndex]
+		if m.exitedProcess(proc.ID) {
+			continue // Greyed out instead (see exited.go)
+		}
+		if rule := highlightFor(m.settings.highlights, proc, proc.Connections[connectionIndex], hasColor); rule != nil {
+			lines[i] = lipgloss.NewStyle().Foreground(rule.color()).Render(lines[i])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
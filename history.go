@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Port History
+// Something freed port 3000 a minute ago - what was it? Every time the TUI collects, it remembers which process was
+// listening on each port, and `pvw who-had 3000` lists every process it's seen there, most recent first:
+//
+//	PROCESS  PID    OWNER  PROTO  FIRST SEEN           LAST SEEN
+//	node     41235  alice  TCP    2024-05-02 10:14:09  now
+//	vite     40112  alice  TCP    2024-05-02 09:02:51  12m ago
+//
+// Only what pvw has seen is remembered, so it's most useful with pvw left open in watch mode. The subcommands, --json,
+// --exec and --check-policy only look, so they don't add to it. The history is kept in the state directory
+// ($XDG_STATE_HOME/pvw/history.json, usually ~/.local/state), and only the maxHistory most recently seen are kept.
+// Delete the file to forget it all.
+
+// A process seen listening on a port
+type binding struct {
+	Port      int       `json:"port"`
+	Protocol  string    `json:"protocol"`
+	PID       int       `json:"pid"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// How many bindings to remember
+const maxHistory = 1000
+
+// How often the history is saved when all that's changed is when things were last seen. New bindings are saved
+// straight away.
+const historySaveInterval = 30 * time.Second
+
+// The history, as it's been seen by this pvw. Collections happen in their own goroutines, so it's behind a mutex.
+var (
+	historyMutex sync.Mutex
+	history      map[string]binding
+	historySaved time.Time
+)
+
+// stateDir() returns the directory pvw keeps what it remembers in, or "" if there isn't one
+func stateDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "pvw")
+}
+
+// historyPath() returns where the history is kept
+func historyPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "history.json")
+}
+
+// bindingOf() returns the binding for a listener, without when it was seen
+func bindingOf(l listener) binding {
+	b := binding{Port: l.port, Protocol: l.protocol, PID: l.proc.ID, Name: l.proc.Name, Owner: l.proc.Username}
+	if l.proc.Windows {
+		b.Name = "win:" + b.Name
+	}
+	return b
+}
+
+// key() identifies a binding, so seeing the same process on the same port again updates it
+func (b binding) key() string {
+	return fmt.Sprintf("%s/%d/%d/%s", b.Protocol, b.Port, b.PID, b.Name)
+}
+
+// rememberListeners() adds every listener in a collection to the history, and saves it if anything new was seen
+func rememberListeners(processes []process) {
+	path := historyPath()
+	if path == "" {
+		return
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	if history == nil {
+		history = loadHistory(path)
+	}
+
+	now := time.Now()
+	changed := false
+	for _, l := range findListeners(processes, true, true) {
+		b := bindingOf(l)
+		if existing, seen := history[b.key()]; seen {
+			existing.LastSeen = now
+			history[b.key()] = existing
+			continue
+		}
+		b.FirstSeen, b.LastSeen = now, now
+		history[b.key()] = b
+		changed = true
+	}
+
+	if changed || time.Since(historySaved) > historySaveInterval {
+		if err := saveHistory(path); err != nil {
+			debugf("couldn't save the port history: %v", err)
+		}
+		historySaved = now
+	}
+}
+
+// loadHistory() reads the history from a file, or returns an empty one if there isn't one yet
+func loadHistory(path string) map[string]binding {
+	bindings := make(map[string]binding)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return bindings
+	}
+
+	var saved []binding
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		debugf("ignoring the port history in %s: %v", path, err)
+		return bindings
+	}
+	for _, b := range saved {
+		bindings[b.key()] = b
+	}
+	return bindings
+}
+
+// saveHistory() writes the history to its file, merged with whatever other pvws have saved there since it was read.
+// It's written to a temporary file then moved into place, so a pvw reading it never sees half of it.
+func saveHistory(path string) error {
+	for key, b := range loadHistory(path) {
+		existing, seen := history[key]
+		switch {
+		case !seen:
+			history[key] = b
+		case b.LastSeen.After(existing.LastSeen):
+			existing.LastSeen = b.LastSeen
+			history[key] = existing
+		}
+	}
+
+	// Keep the most recently seen
+	bindings := make([]binding, 0, len(history))
+	for _, b := range history {
+		bindings = append(bindings, b)
+	}
+	sortBindings(bindings)
+	if len(bindings) > maxHistory {
+		for _, b := range bindings[maxHistory:] {
+			delete(history, b.key())
+		}
+		bindings = bindings[:maxHistory]
+	}
+
+	raw, err := json.Marshal(bindings)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	temporary := path + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(temporary, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// sortBindings() sorts bindings with the most recently seen first
+func sortBindings(bindings []binding) {
+	sort.SliceStable(bindings, func(i, j int) bool {
+		return bindings[i].LastSeen.After(bindings[j].LastSeen)
+	})
+}
+
+// runWhoHad() runs who-had mode with the arguments after `who-had`, and returns the exit code
+func runWhoHad(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("who-had", pflag.ContinueOnError)
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("who-had.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	w = quietOutput(w, *flagQuiet)
+
+	ports, err := parsePorts(flags.Args())
+	if err != nil || len(ports) != 1 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("error.running", err))
+		}
+		flags.Usage()
+		return exitError
+	}
+	port := ports[0]
+
+	// Collecting adds whatever's listening now to the history, so the answer is up to date
+	listeners, err := collectListeners(*flagBackend, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+	current := make(map[string]bool)
+	for _, l := range findListeners(listeningOn(listeners, port), true, true) {
+		current[bindingOf(l).key()] = true
+	}
+
+	var found []binding
+	for _, b := range loadHistory(historyPath()) {
+		if b.Port == port {
+			found = append(found, b)
+		}
+	}
+	if len(found) == 0 {
+		fmt.Fprintln(w, tr("who-had.none", port))
+		return exitNoMatch
+	}
+	sortBindings(found)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, tr("who-had.header"))
+	for _, b := range found {
+		lastSeen := tr("who-had.ago", formatUptime(time.Since(b.LastSeen)))
+		if current[b.key()] {
+			lastSeen = tr("who-had.now")
+		}
+
+		name, pid, owner := b.Name, strconv.Itoa(b.PID), b.Owner
+		if name == "" {
+			name = "?"
+		}
+		if b.PID == 0 {
+			pid = "?"
+		}
+		if owner == "" {
+			owner = "?"
+		}
+
+		firstSeen := b.FirstSeen.Format("2006-01-02 15:04:05")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", name, pid, owner, b.Protocol, firstSeen, lastSeen)
+	}
+	tw.Flush()
+
+	return exitOK
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Hosts File Names
+// Developers running lots of sites locally often give each one its own loopback alias in /etc/hosts (myapp.local on
+// 127.0.0.2, api.local on 127.0.0.3...), which makes a column of 127.0.0.x addresses hard to tell apart. With
+// --host-names, local addresses are shown as the first name the hosts file gives them instead, and --host only shows
+// sockets that can be reached at the given names, which includes the ones bound to every address (the same as
+// --interface).
+
+// The hosts file's names, by address. Formatting happens in several goroutines, so it's behind a mutex. It's read again
+// whenever the file changes.
+var (
+	hostsMutex    sync.Mutex
+	hostsNames    map[string][]string
+	hostsModified time.Time
+)
+
+// hostsPath() returns where the hosts file is
+func hostsPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// currentHosts() returns the names the hosts file gives each address, reading it again if it's changed
+func currentHosts() map[string][]string {
+	hostsMutex.Lock()
+	defer hostsMutex.Unlock()
+
+	info, err := os.Stat(hostsPath())
+	if err != nil {
+		return hostsNames
+	}
+	if hostsNames != nil && info.ModTime().Equal(hostsModified) {
+		return hostsNames
+	}
+
+	file, err := os.Open(hostsPath())
+	if err != nil {
+		debugf("couldn't read the hosts file: %v", err)
+		return hostsNames
+	}
+	defer file.Close()
+
+	hostsNames = make(map[string][]string)
+	hostsModified = info.ModTime()
+
+	// Each line is an address and then its names, e.g. `127.0.0.2  myapp.local www.myapp.local  # comment`
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		address := normaliseAddress(fields[0])
+		if address == "" {
+			continue
+		}
+		hostsNames[address] = append(hostsNames[address], fields[1:]...)
+	}
+
+	return hostsNames
+}
+
+// normaliseAddress() writes an address the same way whichever backend it came from, so it can be looked up, or returns
+// "" if it isn't an IP address
+func normaliseAddress(address string) string {
+	ip := net.ParseIP(strings.Trim(address, "[]"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// hostName() returns the first name the hosts file gives an address, or "" if it doesn't give it one
+func hostName(address string) string {
+	if names := currentHosts()[normaliseAddress(address)]; len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// atHost() checks if a connection can be reached at one of the given names from the hosts file
+func atHost(conn connection, names []string) bool {
+	if conn.LocalAddress == "*" {
+		return true
+	}
+
+	for _, name := range currentHosts()[normaliseAddress(conn.LocalAddress)] {
+		for _, wanted := range names {
+			if strings.EqualFold(name, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fillHostNames() swaps the local addresses of a process' connections for their names in the hosts file, where they
+// have one. The Windows side has its own hosts file, so its sockets are left alone.
+func fillHostNames(rows []table.Row, proc process, options settings) {
+	if !options.hostNames || proc.Windows {
+		return
+	}
+
+	for column, c := range options.columns {
+		if c.Title != "Local Address" && c.Title != "Address" {
+			continue
+		}
+
+		for i, conn := range proc.Connections {
+			// The Address column shows the remote address if there is one, which isn't ours to name
+			if i >= len(rows) || (c.Title == "Address" && conn.RemoteAddress != "") {
+				continue
+			}
+			if name := hostName(conn.LocalAddress); name != "" {
+				rows[i][column] = name
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Translations
+// Every string shown to the user (help text, column titles, prompts and errors) is looked up by key in a locale file,
+// so pvw can be translated without touching the code. The locale files are JSON maps of keys to strings, and live in
+// the locales directory. They're embedded into the binary, so there's nothing extra to install.
+//
+// To add a language, copy locales/en.json to locales/<language code>.json and translate the values. Any keys that are
+// missing fall back to English, so a partial translation still works.
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// The language used when nothing else has been chosen, and for any strings a locale is missing
+const defaultLanguage = "en"
+
+// The strings for the language being used, and the English strings to fall back to
+var (
+	currentStrings  map[string]string
+	fallbackStrings map[string]string
+)
+
+// loadLocale() reads the strings for a language from its locale file
+func loadLocale(language string) (map[string]string, error) {
+	raw, err := localeFiles.ReadFile(path.Join("locales", language+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	strs := make(map[string]string)
+	if err := json.Unmarshal(raw, &strs); err != nil {
+		return nil, fmt.Errorf("locale file for %s is invalid: %w", language, err)
+	}
+	return strs, nil
+}
+
+// availableLanguages() returns the codes of every language there's a locale file for
+func availableLanguages() []string {
+	entries, _ := localeFiles.ReadDir("locales")
+
+	var languages []string
+	for _, entry := range entries {
+		languages = append(languages, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(languages)
+
+	return languages
+}
+
+// setLanguage() switches every string to the given language. An error is returned if there isn't a locale for it.
+func setLanguage(language string) error {
+	var err error
+
+	if fallbackStrings == nil {
+		if fallbackStrings, err = loadLocale(defaultLanguage); err != nil {
+			return err
+		}
+	}
+
+	strs, err := loadLocale(language)
+	if err != nil {
+		return errors.New(tr("error.unknown-language", language, strings.Join(availableLanguages(), ", ")))
+	}
+
+	currentStrings = strs
+	return nil
+}
+
+// tr() returns the string for a key in the current language, formatted with the given arguments like fmt.Sprintf. If
+// the current language doesn't have the string, the English one is used, and if that's missing too the key is returned
+// so that it's obvious something needs adding.
+func tr(key string, args ...any) string {
+	str, exists := currentStrings[key]
+	if !exists {
+		str, exists = fallbackStrings[key]
+	}
+	if !exists {
+		return key
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(str, args...)
+	}
+	return str
+}
+
+// languageFromArgs() finds the language passed with --lang. The flag descriptions need translating before pflag can
+// parse the flags, so --lang has to be found by hand first.
+func languageFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--lang=") {
+			return strings.TrimPrefix(arg, "--lang=")
+		}
+		if arg == "--lang" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// languageFromEnvironment() works out the language from the locale environment variables, in the order POSIX says they
+// take priority. A locale like de_DE.UTF-8 becomes de. If the language isn't one pvw has, English is used.
+func languageFromEnvironment() string {
+	for _, variable := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(variable)
+		if locale == "" {
+			continue
+		}
+
+		// The language is everything before the territory, encoding or modifier
+		language := strings.ToLower(locale)
+		if end := strings.IndexAny(language, "_.@-"); end != -1 {
+			language = language[:end]
+		}
+
+		if _, err := loadLocale(language); err == nil {
+			return language
+		}
+		return defaultLanguage
+	}
+	return defaultLanguage
+}
+
+// columnTitle() translates a column's title. Plugin columns from the config file don't have translations, so they're
+// left as they are.
+func columnTitle(title string) string {
+	if translated := tr("column." + title); translated != "column."+title {
+		return translated
+	}
+	return title
+}
+
+// translateColumns() returns a copy of the columns with their titles translated, for displaying in the table. The
+// English titles are still used everywhere else to tell the columns apart.
+func translateColumns(columns []table.Column) []table.Column {
+	translated := make([]table.Column, len(columns))
+	for i, column := range columns {
+		translated[i] = column
+		translated[i].Title = columnTitle(column.Title)
+	}
+	return translated
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Process Details
+// i shows everything we can find out about the selected process in the detail pane: its command line, owner, working
+// directory, open files (see files.go), threads and children (see children.go), any connections stuck closing (see
+// waits.go), where its ports forward to if it's a port forwarder (see forwards.go), the port it was told to use if it's
+// a dev server (see frameworks.go), what lets it listen on ports below 1024 (see privileges.go), who else gets
+// connections to its ports (see binds.go), the local processes it's connected to (see peers.go), then its environment.
+// The environment often explains why a server is on an unexpected port (a PORT left over from another project, say),
+// but it's also where secrets live, so anything that looks like one is masked until v is pressed.
+//
+// On Linux the environment is read from /proc/<pid>/environ. Elsewhere it comes from `ps eww`, which puts it on the end
+// of the command line, so values with spaces in them can get cut short.
+
+// The text shown in place of a masked value
+const maskedValue = "••••••••"
+
+// Parts of variable names that usually mean the value is a secret
+var secretNames = []string{
+	"SECRET", "TOKEN", "PASSWORD", "PASSWD", "PASS", "KEY", "CREDENTIAL", "AUTH", "PRIVATE", "SESSION", "COOKIE",
+	"SIGNATURE", "SALT",
+}
+
+// Parts of variable names that are about where a server listens, which are shown first
+var networkNames = []string{"PORT", "HOST", "ADDR", "BIND", "LISTEN", "URL"}
+
+// Matches the password in a URL, e.g. the hunter2 in postgres://user:hunter2@db:5432
+var urlPasswordRegex = regexp.MustCompile(`(://[^:/@\s]+:)([^@\s]+)(@)`)
+
+// showDetails() shows the details of a process in the detail pane, with secrets shown if reveal is set, and how the
+// selected connection is doing if there is one. The settings are for what was found out about every process at the
+// last collection, like who's at the other end of each connection.
+func showDetails(proc process, conn *connection, options settings, reveal bool) tea.Cmd {
+	return func() tea.Msg {
+		return detailMsg{
+			title:  processLabel(proc),
+			body:   processDetails(proc, conn, options, reveal),
+			proc:   &proc,
+			conn:   conn,
+			reveal: reveal,
+		}
+	}
+}
+
+// processDetails() describes a process for the detail pane, starting with how the selected connection is doing
+func processDetails(proc process, conn *connection, options settings, reveal bool) string {
+	var b strings.Builder
+
+	if proc.Windows {
+		// Windows processes can't be looked inside of from WSL
+		fmt.Fprintln(&b, tr("details.windows"))
+		return b.String()
+	}
+
+	directory := proc.Directory
+	if directory == "" {
+		directory, _ = getCwd(proc.ID)
+	}
+
+	writeFields(&b, [][2]string{
+		{tr("details.command"), processCommand(proc.ID)},
+		{tr("details.owner"), proc.Username},
+		{tr("details.directory"), directory},
+		{tr("details.project"), projectName(directory)},
+		{tr("details.files"), openFilesLine(proc.ID)},
+		{tr("details.threads"), threadsLine(proc.ID)},
+		{tr("details.children"), childrenLine(proc.ID)},
+	})
+	fmt.Fprintln(&b)
+
+	// How the selected connection is doing, which is the question when it's slow (see tcphealth.go)
+	if conn != nil && hasTCPHealth(*conn) {
+		fmt.Fprintln(&b, tr("details.tcp-health", conn.LocalAddress+":"+conn.LocalPort,
+			conn.RemoteAddress+":"+conn.RemotePort))
+		if health, err := tcpHealth(proc, *conn); err != nil {
+			fmt.Fprintln(&b, "  "+tr("details.no-tcp-health", err))
+		} else {
+			fields := tcpHealthFields(health)
+			for i := range fields {
+				fields[i][0] = "  " + fields[i][0]
+			}
+			writeFields(&b, fields)
+			fmt.Fprintln(&b, "  "+tcpHealthVerdict(health))
+		}
+		fmt.Fprintln(&b)
+	}
+
+	// Connections stuck closing are usually why someone's looking at a process in the first place (see waits.go)
+	if stuck := countStuck(proc, nil); stuck.worthMentioning() {
+		fmt.Fprintln(&b, tr("details.stuck"))
+		writeStuck(&b, stuck, time.Now())
+		fmt.Fprintln(&b)
+	}
+
+	// As are where a port forwarder's ports go (see forwards.go), and the local processes it's talking to (see
+	// peers.go)
+	if chains := forwardingChains(proc); len(chains) > 0 {
+		fmt.Fprintln(&b, tr("details.forwards"))
+		for _, chain := range chains {
+			fmt.Fprintln(&b, "  "+chain.String())
+		}
+		fmt.Fprintln(&b)
+	}
+
+	// A dev server listening somewhere other than where it was told to is usually why it can't be reached (see
+	// frameworks.go)
+	if lines := devServerLines(proc); len(lines) > 0 {
+		fmt.Fprintln(&b, tr("details.framework"))
+		for _, line := range lines {
+			fmt.Fprintln(&b, "  "+line)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	// A launchd job comes straight back if it's terminated, so it's worth knowing which it is (see launchd.go)
+	if label, isJob := launchdJob(proc); isJob {
+		fmt.Fprintln(&b, tr("details.launchd-job", label))
+		fmt.Fprintln(&b)
+	}
+
+	// The services systemd or launchd is holding sockets for (see services.go)
+	if lines := serviceSocketLines(proc); len(lines) > 0 {
+		fmt.Fprintln(&b, tr("details.sockets"))
+		for _, line := range lines {
+			fmt.Fprintln(&b, "  "+line)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	// How it's allowed to listen on ports below 1024 (see privileges.go)
+	if lines := privilegeLines(proc); len(lines) > 0 {
+		fmt.Fprintln(&b, tr("details.privileges"))
+		for _, line := range lines {
+			fmt.Fprintln(&b, "  "+line)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	// Ports it only gets some of the connections to, because another process is bound to them too (see binds.go)
+	shared := append(sharedPortLines(proc, options.sharedPorts), reusedPortLines(proc, options.reusedPorts)...)
+	for _, lines := range shared {
+		fmt.Fprintln(&b, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintln(&b, "  "+line)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	if connected := processPeers(proc, options.peers); len(connected) > 0 {
+		fmt.Fprintln(&b, tr("details.peers"))
+		for _, line := range connected {
+			fmt.Fprintln(&b, "  "+line)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	environment, err := processEnvironmentVariables(proc.ID)
+	if err != nil {
+		fmt.Fprintln(&b, tr("details.no-environment", err))
+		return b.String()
+	}
+
+	if reveal {
+		fmt.Fprintln(&b, tr("details.environment-revealed"))
+	} else {
+		fmt.Fprintln(&b, tr("details.environment"))
+	}
+
+	// Anything about ports and addresses goes first, as that's usually what we're looking for
+	network, rest := splitEnvironment(environment)
+	for _, variable := range append(network, rest...) {
+		name, value, _ := strings.Cut(variable, "=")
+		if !reveal {
+			value = maskSecret(name, value)
+		}
+		fmt.Fprintf(&b, "  %s=%s\n", name, value)
+	}
+
+	return b.String()
+}
+
+// writeFields() writes labelled values one per line, with the values lined up after the longest label (which depends on
+// the language)
+func writeFields(b *strings.Builder, fields [][2]string) {
+	width := 0
+	for _, field := range fields {
+		if w := utf8.RuneCountInString(field[0]); w > width {
+			width = w
+		}
+	}
+
+	for _, field := range fields {
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(field[0]))
+		fmt.Fprintf(b, "%s%s  %s\n", field[0], padding, field[1])
+	}
+}
+
+// processCommand() gets a process' full command line
+func processCommand(pid int) string {
+	if cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline")); err == nil {
+		// Arguments are separated by NULs, and can have newlines in them, which would break up the pane
+		return strings.Join(strings.Fields(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '}))), " ")
+	}
+
+	if !commandExists("ps") {
+		return ""
+	}
+
+	// Command is `ps -o command= -p PID`
+	out, _ := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// processArgs() gets a process' arguments. On Linux they're exactly as it was given them, but elsewhere they come from
+// splitting `ps`' command line at spaces, so an argument with spaces in it comes out as several.
+func processArgs(pid int) []string {
+	cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err == nil && len(cmdline) > 0 {
+		return strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
+	}
+	return strings.Fields(processCommand(pid))
+}
+
+// processEnvironmentVariables() gets a process' environment as NAME=value strings, sorted by name
+func processEnvironmentVariables(pid int) ([]string, error) {
+	var environment []string
+
+	raw, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "environ"))
+	switch {
+	case err == nil:
+		for _, variable := range strings.Split(string(raw), "\x00") {
+			if strings.Contains(variable, "=") {
+				environment = append(environment, variable)
+			}
+		}
+
+	case os.IsNotExist(err) && commandExists("ps"):
+		// Not Linux, so ask ps. Command is `ps eww -o command= -p PID`, which prints the command then the environment.
+		out, psErr := exec.Command("ps", "eww", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+		if psErr != nil {
+			return nil, psErr
+		}
+		environment = environmentFromPs(string(out))
+
+	default:
+		return nil, err
+	}
+
+	sort.Strings(environment)
+	return environment, nil
+}
+
+// Matches the start of an environment variable in `ps eww`'s output
+var psVariableRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// environmentFromPs() picks the environment out of `ps eww`'s output. The variables come after the command's own
+// arguments, so everything from the first word that looks like NAME=value counts (apart from arguments to the command
+// that happen to look like one, which can't be told apart).
+func environmentFromPs(out string) []string {
+	var environment []string
+
+	for _, word := range strings.Fields(out) {
+		switch {
+		case psVariableRegex.MatchString(word):
+			environment = append(environment, word)
+		case len(environment) > 0:
+			// A value with a space in it
+			environment[len(environment)-1] += " " + word
+		}
+	}
+
+	return environment
+}
+
+// splitEnvironment() splits the environment into the variables about ports and addresses, and the rest
+func splitEnvironment(environment []string) ([]string, []string) {
+	var network, rest []string
+
+	for _, variable := range environment {
+		name, _, _ := strings.Cut(variable, "=")
+		if nameContains(name, networkNames) {
+			network = append(network, variable)
+		} else {
+			rest = append(rest, variable)
+		}
+	}
+
+	return network, rest
+}
+
+// maskSecret() hides a value if it looks like a secret, either from its name or because it's a URL with a password
+func maskSecret(name string, value string) string {
+	if value != "" && nameContains(name, secretNames) {
+		return maskedValue
+	}
+	return urlPasswordRegex.ReplaceAllString(value, "${1}"+maskedValue+"${3}")
+}
+
+// nameContains() checks if a variable name contains any of the given parts, ignoring case
+func nameContains(name string, parts []string) bool {
+	upper := strings.ToUpper(name)
+	for _, part := range parts {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Interfaces
+// A listener on 10.8.0.2 is only reachable over the VPN, while one on 192.168.1.20 is open to the LAN, but the addresses
+// alone don't make that obvious. With --show-interface, the Interface column shows which interface each socket's local
+// address belongs to (lo, eth0, wg0...), or * for sockets bound to every interface. --interface only shows sockets that
+// can be reached on the given interfaces, which includes the ones bound to every interface.
+
+// The interfaces' addresses, by interface name. Formatting happens in several goroutines, so it's behind a mutex. It's
+// read again every interfaceReloadInterval, as VPNs come and go.
+var (
+	interfaceMutex  sync.Mutex
+	interfaceAddrs  map[string][]*net.IPNet
+	interfaceLoaded time.Time
+)
+
+const interfaceReloadInterval = 5 * time.Second
+
+// currentInterfaces() returns each interface's addresses, reading them again if they're out of date
+func currentInterfaces() map[string][]*net.IPNet {
+	interfaceMutex.Lock()
+	defer interfaceMutex.Unlock()
+
+	if interfaceAddrs == nil || time.Since(interfaceLoaded) > interfaceReloadInterval {
+		interfaceAddrs = make(map[string][]*net.IPNet)
+		interfaceLoaded = time.Now()
+
+		interfaces, err := net.Interfaces()
+		if err != nil {
+			debugf("couldn't list interfaces: %v", err)
+		}
+		for _, iface := range interfaces {
+			addrs, err := iface.Addrs()
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if ipNet, ok := addr.(*net.IPNet); ok {
+					interfaceAddrs[iface.Name] = append(interfaceAddrs[iface.Name], ipNet)
+				}
+			}
+		}
+	}
+
+	return interfaceAddrs
+}
+
+// interfaceOf() returns the name of the interface an address belongs to, * if it's bound to every interface, or ? if
+// it isn't one of ours
+func interfaceOf(address string) string {
+	if address == "*" {
+		return "*"
+	}
+
+	ip := net.ParseIP(strings.Trim(address, "[]"))
+	if ip == nil {
+		return "?"
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4 // IPv4 mapped IPv6 addresses are really IPv4 addresses
+	}
+
+	// An exact match comes first, but anything in 127.0.0.0/8 is loopback, even though only 127.0.0.1 is assigned
+	interfaces := currentInterfaces()
+	for name, addrs := range interfaces {
+		for _, addr := range addrs {
+			if addr.IP.Equal(ip) {
+				return name
+			}
+		}
+	}
+	if ip.IsLoopback() {
+		for name, addrs := range interfaces {
+			for _, addr := range addrs {
+				if addr.IP.IsLoopback() && addr.Contains(ip) {
+					return name
+				}
+			}
+		}
+	}
+
+	return "?"
+}
+
+// onInterface() checks if a connection can be reached on one of the given interfaces
+func onInterface(conn connection, names []string) bool {
+	name := interfaceOf(conn.LocalAddress)
+	return name == "*" || slices.Contains(names, name)
+}
+
+// fillInterfaceColumn() fills in the Interface column for every connection of a process. The Windows side's sockets
+// are on Windows' interfaces, so they're left empty.
+func fillInterfaceColumn(rows []table.Row, proc process, options settings) {
+	if !options.showInterface || proc.Windows {
+		return
+	}
+
+	column := columnIndex(options, "Interface")
+	if column == -1 {
+		return
+	}
+
+	for i, conn := range proc.Connections {
+		if i < len(rows) {
+			rows[i][column] = interfaceOf(conn.LocalAddress)
+		}
+	}
+}
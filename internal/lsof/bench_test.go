@@ -0,0 +1,60 @@
+package lsof
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// The benchmarks parse and format the output of a big host: 2,000 processes with 50,000 sockets between them, a mix
+// of listeners and established IPv4 and IPv6 connections. Run them with
+//
+//	go test ./internal/lsof -run '^$' -bench . -benchmem
+//
+// The budget is for both together to take under 100ms on a laptop, as that's the wait on every refresh. Anything that
+// makes them much slower (or allocate much more) needs a good reason.
+
+// bigOutput() generates lsof output with the given number of processes, each with the given number of sockets
+func bigOutput(processes int, sockets int) []byte {
+	var b bytes.Buffer
+	for p := 0; p < processes; p++ {
+		fmt.Fprintf(&b, "p%d\ncworker-%d\nLuser%d\n", 1000+p, p%50, p%7)
+		for s := 0; s < sockets; s++ {
+			switch s % 3 {
+			case 0:
+				fmt.Fprintf(&b, "f%d\ntIPv4\nPTCP\nn*:%d\nTST=LISTEN\nTQR=0\nTQS=0\n", s+3, 10000+p)
+			case 1:
+				fmt.Fprintf(&b, "f%d\ntIPv4\nPTCP\nn10.0.%d.%d:%d->10.1.%d.%d:443\nTST=ESTABLISHED\nTQR=0\nTQS=%d\n",
+					s+3, p%250, s%250, 30000+s, s%250, p%250, s)
+			case 2:
+				fmt.Fprintf(&b, "f%d\ntIPv6\nPTCP\nn[::1]:%d->[fe80::%x]:5432\nTST=TIME_WAIT\nTQR=0\nTQS=0\n",
+					s+3, 40000+s, p)
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	output := bigOutput(2000, 25)
+	b.SetBytes(int64(len(output)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := Parse(bytes.NewReader(output)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	processes, _, err := Parse(bytes.NewReader(bigOutput(2000, 25)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Format(processes, allColumns, true)
+	}
+}
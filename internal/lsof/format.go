@@ -0,0 +1,220 @@
+package lsof
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Formatting
+
+// Shown instead of the remote end of UDP sockets that aren't connected to anything, so it's clear there isn't one
+// rather than looking like it's missing
+const noPeer = "—"
+
+// FormatRTT formats a round trip time to a precision that's worth reading, e.g. 0.21ms, 14ms or 1.2s
+func FormatRTT(rtt time.Duration) string {
+	milliseconds := float64(rtt) / float64(time.Millisecond)
+	switch {
+	case milliseconds < 10:
+		return strconv.FormatFloat(milliseconds, 'f', 2, 64) + "ms"
+	case milliseconds < 1000:
+		return strconv.FormatFloat(milliseconds, 'f', 0, 64) + "ms"
+	}
+	return strconv.FormatFloat(milliseconds/1000, 'f', 1, 64) + "s"
+}
+
+// Multicast checks if an address (in lsof's format) is a multicast group: 224.0.0.0/4 for IPv4, or ff00::/8 for IPv6
+func Multicast(address string) bool {
+	address = strings.Trim(address, "[]")
+	if strings.HasPrefix(strings.ToLower(address), "ff") && strings.Contains(address, ":") {
+		return true
+	}
+
+	first, _, found := strings.Cut(address, ".")
+	if !found {
+		return false
+	}
+	octet, err := strconv.Atoi(first)
+	return err == nil && octet >= 224 && octet <= 239
+}
+
+// Format takes the slice of process structs given and converts to the table rows that get rendered, with a value
+// for each of the given columns. If serviceNames is true, friendly service names are shown instead of port numbers
+// where we have them. The index of the first row of each process is also returned.
+func Format(processes []Process, columns []table.Column, serviceNames bool) ([]table.Row, []int) {
+	// Loop through each process, and create a row based on the columns we have, then add that to a row slice. Every
+	// row's cells come from one big slice, rather than making each row separately, as there can be tens of thousands.
+	total := 0
+	for _, proc := range processes {
+		total += len(proc.Connections)
+	}
+	rows := make([]table.Row, 0, total)
+	rowStarts := make([]int, 0, len(processes))
+	cells := make([]string, total*len(columns))
+
+	for _, proc := range processes {
+		rowStarts = append(rowStarts, len(rows))
+
+		for connIndex, conn := range proc.Connections {
+
+			// The row's capacity is capped, so appending to it can't spill into the next row
+			start := len(rows) * len(columns)
+			row := table.Row(cells[start : start+len(columns) : start+len(columns)])
+
+			// Loop through each column in columns and use a switch-case on its title to get the value to set at its index
+			for columnIndex, column := range columns {
+
+				value := ""
+
+				switch column.Title {
+
+				case "PID":
+					if connIndex == 0 {
+						value = strconv.Itoa(proc.ID)
+						if proc.ID == 0 {
+							// The backend couldn't tell us which process owns these sockets
+							value = "-"
+						}
+					}
+					break
+
+				case "Name":
+					if connIndex == 0 {
+						value = proc.Name
+						if proc.ID == 0 {
+							value = "(unknown)"
+						}
+						if proc.Windows {
+							// Flag processes that are on the Windows side of WSL, as they can't be found from Linux
+							value = "win:" + value
+						}
+					}
+					break
+
+				case "Directory":
+					if connIndex == 0 {
+						value = proc.Directory
+					}
+					break
+
+				case "Owner":
+					if connIndex == 0 {
+						value = proc.Username
+					}
+					break
+
+				case "Protocol":
+					value = conn.Protocol
+					break
+
+				case "Address":
+					// If there is a remote address, use that
+					if conn.RemoteAddress != "" {
+						value = conn.RemoteAddress
+					} else {
+						// If not, then use local address
+						value = conn.LocalAddress
+					}
+					break
+
+				case "Port":
+					if conn.RemoteAddress != "" {
+						if serviceNames && conn.RemoteName != "" {
+							value = conn.RemoteName
+						} else {
+							value = conn.RemotePort
+						}
+					} else {
+						// If not, then use local address
+						if serviceNames && conn.LocalName != "" {
+							value = conn.LocalName
+						} else {
+							value = conn.LocalPort
+						}
+					}
+					break
+
+				case "Local Address":
+					value = conn.LocalAddress
+					break
+				case "Local Port":
+					if serviceNames && conn.LocalName != "" {
+						value = conn.LocalName
+					} else {
+						value = conn.LocalPort
+					}
+					break
+				case "Remote Address":
+					value = conn.RemoteAddress
+					if conn.Protocol == "UDP" && conn.RemotePort == "" {
+						value = noPeer
+					}
+					break
+				case "Remote Port":
+					if serviceNames && conn.RemoteName != "" {
+						value = conn.RemoteName
+					} else {
+						value = conn.RemotePort
+					}
+					if conn.Protocol == "UDP" && conn.RemotePort == "" {
+						value = noPeer
+					}
+					break
+
+				case "Status":
+					value = strings.ToTitle(conn.Status)
+					if value == "" && conn.Protocol == "UDP" && conn.RemotePort == "" {
+						// UDP sockets don't have states, but ones without a remote end are waiting for packets like a
+						// listening TCP socket, so give them ss' name for it rather than leaving them blank. Sockets
+						// bound to a multicast group get the group instead, as that's what they're listening to.
+						value = "UNCONN"
+						if Multicast(conn.LocalAddress) {
+							value = "MCAST"
+						}
+					}
+					break
+
+				case "Recv-Q":
+					value = strconv.Itoa(conn.RecvQueue)
+					break
+				case "Send-Q":
+					value = strconv.Itoa(conn.SendQueue)
+					break
+				case "Backlog":
+					// Only listening sockets have a backlog, and not every backend knows it
+					if conn.Status == "LISTEN" && conn.Backlog > 0 {
+						value = strconv.Itoa(conn.Backlog)
+					}
+					break
+
+				// Only connected TCP sockets have these, and not every backend knows them
+				case "RTT":
+					if conn.RTT > 0 {
+						value = FormatRTT(conn.RTT)
+					}
+					break
+				case "Retrans":
+					if conn.CongestionWindow > 0 {
+						value = strconv.Itoa(conn.Retransmits)
+					}
+					break
+				case "Cwnd":
+					if conn.CongestionWindow > 0 {
+						value = strconv.Itoa(conn.CongestionWindow)
+					}
+					break
+
+				}
+				row[columnIndex] = value
+
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, rowStarts
+}
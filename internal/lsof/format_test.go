@@ -0,0 +1,72 @@
+package lsof
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// Every column pvw knows about, in the order they're shown
+var allColumns = []table.Column{
+	{Title: "PID"}, {Title: "Name"}, {Title: "Directory"}, {Title: "Owner"},
+	{Title: "Protocol"}, {Title: "Address"}, {Title: "Port"},
+	{Title: "Local Address"}, {Title: "Local Port"}, {Title: "Remote Address"}, {Title: "Remote Port"},
+	{Title: "Status"}, {Title: "Recv-Q"}, {Title: "Send-Q"}, {Title: "Backlog"},
+}
+
+// TestFormatGolden formats a fixture with every column, with and without service names
+func TestFormatGolden(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "linux_debian.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	processes, _, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, serviceNames := range []bool{false, true} {
+		rows, rowStarts := Format(processes, allColumns, serviceNames)
+
+		if len(rowStarts) != len(processes) {
+			t.Fatalf("got %d row starts for %d processes", len(rowStarts), len(processes))
+		}
+
+		var b strings.Builder
+		for i, row := range rows {
+			b.WriteString(strings.Join(row, " | "))
+			if i < len(rows)-1 {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString("\n")
+
+		golden := filepath.Join("testdata", "format.golden")
+		if serviceNames {
+			golden = filepath.Join("testdata", "format_service_names.golden")
+		}
+		checkGolden(t, golden, b.String())
+	}
+}
+
+func TestFormatRTT(t *testing.T) {
+	for _, test := range []struct {
+		rtt  time.Duration
+		want string
+	}{
+		{45 * time.Microsecond, "0.04ms"},
+		{2500 * time.Microsecond, "2.50ms"},
+		{87 * time.Millisecond, "87ms"},
+		{1250 * time.Millisecond, "1.2s"},
+	} {
+		if got := FormatRTT(test.rtt); got != test.want {
+			t.Errorf("FormatRTT(%v) = %q, want %q", test.rtt, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+// Package lsof contains the types pvw uses to describe processes and their connections, along with the parser for
+// lsof's field output (lsof -F) and the formatter that turns processes into table rows.
+//
+// The other backends (ss, netstat and /proc) convert their output into the same types, so everything after collection
+// works the same no matter where the processes came from.
+package lsof
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Important Structs
+
+// Process is a process. Contains a PID used to terminate the process later, the name of the executable responsible for
+// that process, an array of the ports it uses, and the username of the user that created that process. Windows is set
+// for processes on the Windows side of WSL, whose IDs are Windows PIDs rather than Linux ones. NetNamespace is set for
+// processes in a different network namespace to pvw's own, and Stopped for processes that have been paused (e.g. with
+// SIGSTOP).
+type Process struct {
+	ID           int
+	Name         string
+	Directory    string
+	Connections  []Connection
+	Username     string
+	Windows      bool
+	NetNamespace string
+	Stopped      bool
+}
+
+// Connection is a connection. Contains a protocol type (typically tcp or udp), connection status, remote address and
+// port, local address and port, and friendly name for the remote port / local port if listening.
+type Connection struct {
+	Protocol string
+	Status   string
+
+	LocalName  string
+	RemoteName string
+
+	RemotePort    string
+	RemoteAddress string
+
+	LocalPort    string
+	LocalAddress string
+
+	IPv6 bool
+
+	// Queue sizes, if the backend knows them. RecvQueue is the bytes waiting to be read, or for a listening socket, the
+	// connections waiting to be accepted. SendQueue is the bytes that haven't been acknowledged yet. Backlog is how many
+	// connections a listening socket can queue before it starts dropping them, or 0 if it's not known.
+	RecvQueue int
+	SendQueue int
+	Backlog   int
+
+	// TCP's view of a connection, if the backend knows it. RTT is the smoothed round trip time, Retransmits is how many
+	// segments have had to be sent again, and CongestionWindow is how many segments can be in flight at once. They're
+	// all 0 for listening sockets, UDP, and backends that don't know them.
+	RTT              time.Duration
+	Retransmits      int
+	CongestionWindow int
+
+	// The backend's raw output for the connection, exactly as it was read, if KeepRecords is set. It's only for
+	// debugging, so it's ignored when comparing connections.
+	Record string
+}
+
+// KeepRecords makes the parsers keep the raw record for each connection in its Record, so a connection that was parsed
+// wrongly can be shown next to what it was parsed from. It's off by default, as copying every line of the output into
+// strings is slow on big hosts.
+var KeepRecords bool
+
+// Warning is a problem found in one record of a backend's output. The record is skipped rather than failing the whole
+// refresh.
+type Warning struct {
+	Line   int    // The line number of the record in the raw output
+	Record string // The raw record
+	Reason string // Why it couldn't be parsed
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s (%q)", w.Line, w.Reason, w.Record)
+}
+
+// RecordPanic is what a parser panics with if it panics part way through a record, so the record that caused it can
+// be shown when pvw crashes rather than just a stack trace.
+type RecordPanic struct {
+	Warning        // The record being parsed when the panic happened, with the panic as the reason
+	Value   any    // What the parser originally panicked with
+	Stack   []byte // The stack trace from the original panic
+}
+
+// AnnotatePanic should be deferred by parsers, with a pointer to the record currently being parsed. If the parser
+// panics, it panics again with a RecordPanic that includes that record.
+func AnnotatePanic(current *Warning) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	// Don't wrap a panic that already knows its record (e.g. from a parser inside another parser)
+	if _, annotated := r.(RecordPanic); annotated {
+		panic(r)
+	}
+
+	record := *current
+	record.Reason = fmt.Sprint(r)
+	panic(RecordPanic{Warning: record, Value: r, Stack: debug.Stack()})
+}
+
+// Equal checks if two processes have the same information and the same connections, in the same order
+func (p Process) Equal(other Process) bool {
+	if p.ID != other.ID || p.Name != other.Name || p.Username != other.Username || p.Directory != other.Directory ||
+		p.Windows != other.Windows || p.NetNamespace != other.NetNamespace || p.Stopped != other.Stopped {
+		return false
+	}
+
+	if len(p.Connections) != len(other.Connections) {
+		return false
+	}
+
+	for i := range p.Connections {
+		a, b := p.Connections[i], other.Connections[i]
+		a.Record, b.Record = "", ""
+		if a != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Parsing
+
+// Parse reads the output of `lsof -i -Pn -F cPnpLTt` and converts it to a slice of process structs. Every process with
+// a valid connection is returned, so any filtering needs to be done afterwards.
+//
+// lsof's output is a list of fields, one per line, where the first character says what the field is. A 'p' field
+// starts a new process, and an 'f' or 't' field starts a new file (connection) in that process. Fields are handled by
+// what they are rather than where they are, so fields we don't know about (or didn't ask for) are skipped. If a record
+// can't be parsed, a warning is added and it's skipped instead of failing the whole refresh. The only error returned
+// is one from reading r.
+func Parse(r io.Reader) ([]Process, []Warning, error) {
+	return ParseProgressively(r, nil)
+}
+
+// ParseProgressively is Parse, but calls partial with every process parsed so far each time another one is finished,
+// so something slow to produce its output (like lsof on a big host) can be shown while it's still being read. partial
+// can be nil. The slice it's given is only valid until it returns, but the processes in it won't change, so they can be
+// copied out of it and kept.
+func ParseProgressively(r io.Reader, partial func([]Process)) ([]Process, []Warning, error) {
+	// Create a new slice of processes
+	allProcesses := make([]Process, 0)
+	var warnings []Warning
+
+	// The process and connection currently being parsed
+	var currentProcess *Process
+	var currentConnection *Connection
+	var scratch Connection  // Where connections are parsed, as they're copied into their process once they're done
+	skipConnection := false // Set if the current connection is invalid, so it isn't added
+
+	// The lines of the current process before its first file, and of the current file, if KeepRecords is set
+	var processRecord, connectionRecord []string
+
+	// finishConnection() adds the connection we've been parsing to its process, if it's valid
+	finishConnection := func() {
+		if currentProcess != nil && currentConnection != nil && !skipConnection && currentConnection.LocalPort != "" {
+			if KeepRecords {
+				record := append(append([]string{}, processRecord...), connectionRecord...)
+				currentConnection.Record = strings.Join(record, "\n")
+			}
+			currentProcess.Connections = append(currentProcess.Connections, *currentConnection)
+		}
+		currentConnection = nil
+		skipConnection = false
+		connectionRecord = nil
+	}
+
+	// finishProcess() adds the process we've been parsing to the slice, if it has any valid connections
+	finishProcess := func() {
+		finishConnection()
+		if currentProcess != nil && len(currentProcess.Connections) > 0 {
+			allProcesses = append(allProcesses, *currentProcess)
+			if partial != nil {
+				partial(allProcesses)
+			}
+		}
+		currentProcess = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	// Lines are read as bytes, and only copied into strings when they're kept, as big hosts have hundreds of thousands
+	// of them (see bench_test.go)
+	var line []byte
+
+	// warn() adds a warning about the line being parsed
+	warn := func(reason string) {
+		warnings = append(warnings, Warning{Line: lineNumber, Record: string(line), Reason: reason})
+	}
+
+	// Keep track of the record being parsed, so it can be shown if something panics. It's only turned into a string if
+	// that happens.
+	var current Warning
+	defer AnnotatePanic(&current)
+	defer func() { current.Line, current.Record = lineNumber, string(line) }()
+
+	for scanner.Scan() {
+		line = scanner.Bytes()
+		lineNumber++
+
+		if len(line) == 0 {
+			continue
+		}
+
+		// The first character is the field identifier, and the rest is the value
+		field, value := line[0], line[1:]
+
+		switch field {
+		case 'p':
+			// p: Process ID. This starts a new process.
+			finishProcess()
+
+			pid, err := strconv.Atoi(string(value))
+			if err != nil {
+				// Skip everything until the next process
+				warn("invalid process ID")
+				break
+			}
+			currentProcess = &Process{ID: pid}
+			processRecord = nil
+
+		case 'c', 'L':
+			// c: Command name, L: Login name of the process' owner
+			if currentProcess == nil {
+				// Either there's no process yet, or its PID was invalid
+				break
+			}
+			if field == 'c' {
+				currentProcess.Name = string(value)
+			} else {
+				currentProcess.Username = string(value)
+			}
+
+		case 'f', 't':
+			// f: File descriptor, t: Type of file (IPv4 or IPv6). Either one starts a new file, but some versions of
+			// lsof always print an f field before the t field, so only start a new file with t if the current one
+			// already has a type.
+			if currentProcess == nil {
+				break
+			}
+			if field == 'f' || currentConnection == nil || currentConnection.Protocol != "" || currentConnection.LocalPort != "" {
+				finishConnection()
+				scratch = Connection{}
+				currentConnection = &scratch
+			}
+			if field == 't' {
+				currentConnection.IPv6 = string(value) == "IPv6"
+			}
+
+		case 'P':
+			// P: Protocol (TCP or UDP)
+			if currentConnection != nil {
+				currentConnection.Protocol = intern(value)
+			}
+
+		case 'n':
+			// n: Local and remote addresses and ports.
+			if currentConnection == nil {
+				break
+			}
+
+			if string(value) == "*:*" {
+				// *:* usually indicates some unimportant connection, so we just make that connection invalid
+				// This might be wrong! If you want to submit an issue about this, then feel free!
+				skipConnection = true
+				break
+			}
+
+			if !parseName(string(value), currentConnection) {
+				warn("invalid address")
+				skipConnection = true
+			}
+
+		case 'T':
+			// T: TCP/TPI information. We use the state (TST=) and queue sizes (TQR=, TQS=), but there can be others
+			// depending on the system.
+			if currentConnection == nil {
+				break
+			}
+			switch {
+			case bytes.HasPrefix(value, []byte("ST=")):
+				currentConnection.Status = strings.ToTitle(intern(value[3:]))
+			case bytes.HasPrefix(value, []byte("QR=")):
+				currentConnection.RecvQueue, _ = strconv.Atoi(string(value[3:]))
+			case bytes.HasPrefix(value, []byte("QS=")):
+				currentConnection.SendQueue, _ = strconv.Atoi(string(value[3:]))
+			}
+
+		default:
+			// A field we don't use, so skip it
+		}
+
+		// Keep the line with the record it belongs to. This is after the field's been handled, as a new process or
+		// file finishes the previous record first.
+		if KeepRecords {
+			if currentConnection != nil {
+				connectionRecord = append(connectionRecord, string(line))
+			} else if currentProcess != nil {
+				processRecord = append(processRecord, string(line))
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, warnings, err
+	}
+
+	// Add the last process
+	finishProcess()
+
+	// Gone through all processes, so now return the final slice of process structs
+	return allProcesses, warnings, nil
+}
+
+// parseName() parses lsof's name field (localAddress:localPort->remoteAddress:remotePort, or just
+// localAddress:localPort if there isn't a remote end) into a connection. IPv6 addresses are in brackets, e.g.
+// [::1]:8080. Returns false if the name isn't in a format we understand.
+func parseName(name string, conn *Connection) bool {
+	local, remote, connected := strings.Cut(name, "->")
+	if strings.Contains(remote, "->") {
+		return false
+	}
+
+	localAddress, localPort, ok := splitAddress(local)
+	if !ok {
+		return false
+	}
+
+	// As ports are strings, we can handle ports like '*' without conversions.
+	conn.LocalAddress = localAddress
+	conn.LocalPort = localPort
+
+	// If there is a ->, then there is a clear local and remote connection
+	if connected {
+		remoteAddress, remotePort, ok := splitAddress(remote)
+		if !ok {
+			return false
+		}
+		conn.RemoteAddress = remoteAddress
+		conn.RemotePort = remotePort
+	}
+
+	// outbound connections use the remote port for the friendly name, and listening ones use the local port
+	conn.SetServiceNames()
+
+	return true
+}
+
+// splitAddress() splits an address like 127.0.0.1:80 or [::1]:80 into the address and the port. The port is always
+// after the last colon, as IPv6 addresses are in brackets.
+func splitAddress(address string) (string, string, bool) {
+	i := strings.LastIndex(address, ":")
+	if i < 1 || i == len(address)-1 {
+		return "", "", false
+	}
+
+	return address[:i], address[i+1:], true
+}
+
+// The values that come up over and over again in lsof's output, so they don't need a new string each time
+var internedValues = map[string]string{
+	"TCP": "TCP", "UDP": "UDP",
+	"LISTEN": "LISTEN", "ESTABLISHED": "ESTABLISHED", "TIME_WAIT": "TIME_WAIT", "CLOSE_WAIT": "CLOSE_WAIT",
+	"SYN_SENT": "SYN_SENT", "SYN_RECV": "SYN_RECV", "FIN_WAIT1": "FIN_WAIT1", "FIN_WAIT2": "FIN_WAIT2",
+	"LAST_ACK": "LAST_ACK", "CLOSING": "CLOSING", "CLOSED": "CLOSED", "IDLE": "IDLE", "BOUND": "BOUND",
+}
+
+// intern() returns a value as a string, reusing the same string for values that come up often
+func intern(value []byte) string {
+	// The compiler doesn't copy value to look it up in the map
+	if interned, ok := internedValues[string(value)]; ok {
+		return interned
+	}
+	return string(value)
+}
@@ -0,0 +1,171 @@
+package lsof
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run `go test ./internal/lsof -update` to rewrite the golden files after changing the parser on purpose
+var update = flag.Bool("update", false, "update the golden files")
+
+// dump() writes out parsed processes and warnings in a stable, readable format to compare against the golden files
+func dump(processes []Process, warnings []Warning) string {
+	var b strings.Builder
+
+	for _, proc := range processes {
+		fmt.Fprintf(&b, "process %d name=%q user=%q\n", proc.ID, proc.Name, proc.Username)
+		for _, conn := range proc.Connections {
+			fmt.Fprintf(&b, "\t%s ipv6=%t local=%s:%s (%s) remote=%s:%s (%s) status=%q queues=%d/%d\n",
+				conn.Protocol, conn.IPv6,
+				conn.LocalAddress, conn.LocalPort, conn.LocalName,
+				conn.RemoteAddress, conn.RemotePort, conn.RemoteName,
+				conn.Status, conn.RecvQueue, conn.SendQueue)
+		}
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(&b, "warning %s\n", warning)
+	}
+
+	return b.String()
+}
+
+// checkGolden() compares got with the golden file, or rewrites the golden file if -update was passed
+func checkGolden(t *testing.T, goldenPath string, got string) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run with -update to create it)", err)
+	}
+
+	if got != string(want) {
+		t.Errorf("output doesn't match %s\n--- got ---\n%s\n--- want ---\n%s", goldenPath, got, want)
+	}
+}
+
+// TestParseGolden parses every captured lsof output in testdata and compares the result with its golden file
+func TestParseGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		name := strings.TrimSuffix(filepath.Base(fixture), ".txt")
+
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			processes, warnings, err := Parse(f)
+			if err != nil {
+				t.Fatalf("Parse returned an error: %v", err)
+			}
+
+			checkGolden(t, strings.TrimSuffix(fixture, ".txt")+".golden", dump(processes, warnings))
+		})
+	}
+}
+
+// TestParseEmpty checks that no output (lsof found nothing) gives no processes rather than an error
+func TestParseEmpty(t *testing.T) {
+	processes, warnings, err := Parse(strings.NewReader(""))
+	if err != nil || len(processes) != 0 || len(warnings) != 0 {
+		t.Errorf("Parse(\"\") = %v, %v, %v, want no processes, warnings or error", processes, warnings, err)
+	}
+}
+
+// TestParseProgressively checks partial results grow by one process at a time, and end up the same as Parse's
+func TestParseProgressively(t *testing.T) {
+	output, err := os.ReadFile(filepath.Join("testdata", "linux_debian.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	var last string
+	processes, warnings, err := ParseProgressively(strings.NewReader(string(output)), func(partial []Process) {
+		sizes = append(sizes, len(partial))
+		last = dump(partial, nil)
+	})
+	if err != nil {
+		t.Fatalf("ParseProgressively returned an error: %v", err)
+	}
+
+	for i, size := range sizes {
+		if size != i+1 {
+			t.Fatalf("expected partial result %d to have %d processes, got %d", i, i+1, size)
+		}
+	}
+	if len(sizes) != len(processes) || last != dump(processes, nil) {
+		t.Errorf("expected the last partial result to be everything, got %d partial results for %d processes", len(sizes), len(processes))
+	}
+
+	whole, wholeWarnings, _ := Parse(strings.NewReader(string(output)))
+	if dump(processes, warnings) != dump(whole, wholeWarnings) {
+		t.Error("expected ParseProgressively to return the same as Parse")
+	}
+}
+
+// TestKeepRecords checks each connection's record is its process' lines followed by its own file's lines, and that
+// it doesn't make connections unequal
+func TestKeepRecords(t *testing.T) {
+	output := "p100\ncnode\nLsam\nf3\ntIPv4\nPTCP\nn*:3000\nTST=LISTEN\n" +
+		"f4\ntIPv4\nPTCP\nn127.0.0.1:3000->127.0.0.1:51000\n"
+
+	KeepRecords = true
+	defer func() { KeepRecords = false }()
+	processes, _, err := Parse(strings.NewReader(output))
+	if err != nil || len(processes) != 1 || len(processes[0].Connections) != 2 {
+		t.Fatalf("Parse returned %v, %v", processes, err)
+	}
+
+	want := "p100\ncnode\nLsam\nf4\ntIPv4\nPTCP\nn127.0.0.1:3000->127.0.0.1:51000"
+	if got := processes[0].Connections[1].Record; got != want {
+		t.Errorf("expected the second connection's record to be %q, got %q", want, got)
+	}
+
+	KeepRecords = false
+	without, _, _ := Parse(strings.NewReader(output))
+	if without[0].Connections[0].Record != "" || !processes[0].Equal(without[0]) {
+		t.Error("expected records to only be kept when asked for, and to be ignored by Equal")
+	}
+}
+
+// TestAnnotatePanic checks that a panic while parsing gets wrapped with the record that caused it
+func TestAnnotatePanic(t *testing.T) {
+	defer func() {
+		recordPanic, isRecordPanic := recover().(RecordPanic)
+		if !isRecordPanic {
+			t.Fatalf("expected a RecordPanic")
+		}
+		if recordPanic.Line != 3 || recordPanic.Record != "n*:80" || recordPanic.Value != "oops" {
+			t.Errorf("got %+v, want line 3, record \"n*:80\" and value \"oops\"", recordPanic.Warning)
+		}
+	}()
+
+	current := Warning{Line: 3, Record: "n*:80"}
+	func() {
+		defer AnnotatePanic(&current)
+		panic("oops")
+	}()
+}
@@ -0,0 +1,87 @@
+package lsof
+
+// ServiceNames is a hashmap of port numbers to service names, used to show friendly names instead of port numbers
+var ServiceNames = map[string]string{
+	"20":    "ftp-data",
+	"21":    "ftp",
+	"22":    "ssh",
+	"23":    "telnet",
+	"25":    "smtp",
+	"69":    "tftp",
+	"80":    "http",
+	"88":    "kerberos-auth",
+	"106":   "macos-password",
+	"109":   "pop2",
+	"110":   "pop3",
+	"115":   "sftp",
+	"118":   "sql",
+	"123":   "ntp",
+	"137":   "netBIOS-ns",
+	"138":   "netBIOS-data",
+	"139":   "netBIOS-ssn",
+	"143":   "imap",
+	"194":   "irc",
+	"220":   "imap3",
+	"389":   "ldap",
+	"443":   "https",
+	"445":   "microsoft-ds",
+	"464":   "kerberos-passwd",
+	"543":   "kerberos-login",
+	"544":   "kerberos-remote",
+	"587":   "smtp-msa",
+	"593":   "ms-dcom",
+	"636":   "ldap-ssl",
+	"691":   "ms-exchange",
+	"749":   "kerberos-admin",
+	"902":   "vmware-server",
+	"989":   "ftp-data-ssl",
+	"990":   "ftp-ssl",
+	"992":   "telnet-ssl",
+	"995":   "pop3-ssl",
+	"1025":  "ms-rpc",
+	"1194":  "openvpn",
+	"1337":  "waste",
+	"1433":  "mssql-server",
+	"1434":  "mssql-monitor",
+	"1589":  "cisco-vqp",
+	"1725":  "steam",
+	"1883":  "mqtt",
+	"2049":  "nfs",
+	"2082":  "cpanel",
+	"2083":  "cpanel-ssl, radsec",
+	"2483":  "oracle-db",
+	"2484":  "oracle-db",
+	"2967":  "symantec-av",
+	"3074":  "xbox-live",
+	"3306":  "mysql",
+	"3389":  "rdp",
+	"5432":  "postgres-sql",
+	"6665":  "irc",
+	"6669":  "irc",
+	"6881":  "bittorrent",
+	"6999":  "bittorrent",
+	"6970":  "quicktime",
+	"8086":  "kaspersky-av",
+	"8087":  "kaspersky-av",
+	"8222":  "vmware-server",
+	"9100":  "pdl",
+	"10000": "backup-exec",
+	"12345": "netbus",
+	"27374": "sub7",
+	"18006": "back-orifice",
+	"25565": "minecraft-server",
+}
+
+// SetServiceNames sets the friendly names of a connection's ports: the remote port for outbound connections, or the
+// local port if we're listening.
+func (c *Connection) SetServiceNames() {
+	if c.RemoteAddress != "" {
+		if name, exists := ServiceNames[c.RemotePort]; exists {
+			c.RemoteName = name
+		}
+	} else {
+		if name, exists := ServiceNames[c.LocalPort]; exists {
+			c.LocalName = name
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// JSON Output
+// --json prints what the table would show as JSON instead of starting the TUI, for other tools to read. The fields are
+// pvw's promise to those tools, so they're kept separate from the structs pvw uses inside (which change whenever it
+// needs them to), and every document has a schema_version:
+//
+//	{"schema_version": 1, "collected_at": "...", "processes": [{"pid": 41235, "name": "node", "connections": [...]}]}
+//
+// Adding a field doesn't change the version, but renaming, removing or changing the meaning of one does. `pvw schema`
+// prints the JSON Schema for the current version, which describes every field. The exit code is 0, or 2 if nothing
+// matched the filters (see exit.go).
+
+// The version of the JSON output. Bump it (and jsonSchema) when a field is renamed, removed or means something else.
+const jsonSchemaVersion = 1
+
+// jsonDocument is what --json prints
+type jsonDocument struct {
+	SchemaVersion int           `json:"schema_version"`
+	CollectedAt   time.Time     `json:"collected_at"`
+	Processes     []jsonProcess `json:"processes"`
+}
+
+// jsonProcess is a process in the JSON output
+type jsonProcess struct {
+	PID          int              `json:"pid"` // 0 if the backend couldn't tell which process it is
+	Name         string           `json:"name"`
+	Owner        string           `json:"owner,omitempty"`
+	Directory    string           `json:"directory,omitempty"` // Only with --show-cwd
+	Windows      bool             `json:"windows"`
+	NetNamespace string           `json:"net_namespace,omitempty"`
+	Stopped      bool             `json:"stopped"`
+	Connections  []jsonConnection `json:"connections"`
+}
+
+// jsonConnection is a connection in the JSON output
+type jsonConnection struct {
+	Protocol      string `json:"protocol"`
+	Status        string `json:"status"`
+	IPVersion     int    `json:"ip_version"`
+	LocalAddress  string `json:"local_address"`
+	LocalPort     int    `json:"local_port"`
+	RemoteAddress string `json:"remote_address,omitempty"`
+	RemotePort    int    `json:"remote_port,omitempty"`
+	Listening     bool   `json:"listening"`
+	RecvQueue     int    `json:"recv_queue"`
+	SendQueue     int    `json:"send_queue"`
+	Backlog       int    `json:"backlog,omitempty"`
+	RTT           int64  `json:"rtt_us,omitempty"`
+	Retransmits   int    `json:"retransmits,omitempty"`
+	Cwnd          int    `json:"congestion_window,omitempty"`
+}
+
+// toJSON() converts processes to their JSON output
+func toJSON(processes []process, collectedAt time.Time) jsonDocument {
+	document := jsonDocument{
+		SchemaVersion: jsonSchemaVersion,
+		CollectedAt:   collectedAt,
+		Processes:     make([]jsonProcess, 0, len(processes)),
+	}
+
+	for _, proc := range processes {
+		p := jsonProcess{
+			PID:          proc.ID,
+			Name:         proc.Name,
+			Owner:        proc.Username,
+			Directory:    proc.Directory,
+			Windows:      proc.Windows,
+			NetNamespace: proc.NetNamespace,
+			Stopped:      proc.Stopped,
+			Connections:  make([]jsonConnection, 0, len(proc.Connections)),
+		}
+
+		for _, conn := range proc.Connections {
+			c := jsonConnection{
+				Protocol:      conn.Protocol,
+				Status:        connectionState(conn), // UDP sockets get the same states the Status column gives them
+				IPVersion:     4,
+				LocalAddress:  strings.Trim(conn.LocalAddress, "[]"),
+				RemoteAddress: strings.Trim(conn.RemoteAddress, "[]"),
+				Listening:     isListening(conn),
+				RecvQueue:     conn.RecvQueue,
+				SendQueue:     conn.SendQueue,
+				Backlog:       conn.Backlog,
+				RTT:           conn.RTT.Microseconds(),
+				Retransmits:   conn.Retransmits,
+				Cwnd:          conn.CongestionWindow,
+			}
+			if conn.IPv6 {
+				c.IPVersion = 6
+			}
+			// Ports that aren't numbers (e.g. the * of an unconnected socket) are left out
+			c.LocalPort, _ = strconv.Atoi(conn.LocalPort)
+			c.RemotePort, _ = strconv.Atoi(conn.RemotePort)
+			p.Connections = append(p.Connections, c)
+		}
+
+		document.Processes = append(document.Processes, p)
+	}
+
+	return document
+}
+
+// runJSON() prints what the table would show as JSON, and returns the exit code
+func runJSON(options settings, w io.Writer) int {
+	ctx, cancel := context.WithTimeout(context.Background(), options.timeout)
+
+	// Collect the same way the TUI does, so every filter applies
+	var processes []process
+	switch msg := checkProcesses(ctx, cancel, options, nil, nil)().(type) {
+	case processesMsg:
+		processes = msg.processes
+	case collectErrMsg:
+		fmt.Fprintln(os.Stderr, tr("error.running", msg.err))
+		return exitError
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(toJSON(processes, time.Now())); err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	if len(processes) == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// runSchema() prints the JSON Schema for --json, and returns the exit code
+func runSchema(args []string, w io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, tr("schema.usage"))
+		if args[0] == "-h" || args[0] == "--help" {
+			return exitOK
+		}
+		return exitError
+	}
+
+	fmt.Fprint(w, jsonSchema)
+	return exitOK
+}
+
+// The JSON Schema for --json's output. Keep it in step with the structs above.
+const jsonSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/allyring/pvw/schema/v1.json",
+  "title": "pvw --json output",
+  "type": "object",
+  "required": ["schema_version", "collected_at", "processes"],
+  "properties": {
+    "schema_version": {
+      "description": "The version of this schema. It changes when a field is renamed, removed or changes meaning.",
+      "const": 1
+    },
+    "collected_at": {
+      "description": "When the sockets were collected.",
+      "type": "string",
+      "format": "date-time"
+    },
+    "processes": {
+      "description": "The processes with sockets that matched the filters.",
+      "type": "array",
+      "items": { "$ref": "#/$defs/process" }
+    }
+  },
+  "$defs": {
+    "process": {
+      "type": "object",
+      "required": ["pid", "name", "windows", "stopped", "connections"],
+      "properties": {
+        "pid": { "description": "The process ID, or 0 if the backend couldn't tell which process it is.", "type": "integer" },
+        "name": { "description": "The process name, as the backend reports it (lsof cuts long names short).", "type": "string" },
+        "owner": { "description": "The user the process runs as.", "type": "string" },
+        "directory": { "description": "The process' working directory. Only with --show-cwd.", "type": "string" },
+        "windows": { "description": "Whether it's a Windows process seen from WSL, whose pid is a Windows PID.", "type": "boolean" },
+        "net_namespace": { "description": "The network namespace, if it isn't pvw's own (with --all-namespaces).", "type": "string" },
+        "stopped": { "description": "Whether the process is paused (e.g. with SIGSTOP).", "type": "boolean" },
+        "connections": { "type": "array", "items": { "$ref": "#/$defs/connection" } }
+      }
+    },
+    "connection": {
+      "type": "object",
+      "required": ["protocol", "status", "ip_version", "local_address", "local_port", "listening", "recv_queue", "send_queue"],
+      "properties": {
+        "protocol": { "description": "TCP or UDP.", "type": "string" },
+        "status": { "description": "The TCP state (LISTEN, ESTABLISHED...), or UNCONN or MCAST for UDP sockets without a remote end.", "type": "string" },
+        "ip_version": { "enum": [4, 6] },
+        "local_address": { "description": "The local address, or * if it's bound to every address.", "type": "string" },
+        "local_port": { "description": "The local port, or 0 if there isn't one.", "type": "integer" },
+        "remote_address": { "description": "The remote address, if there's a remote end.", "type": "string" },
+        "remote_port": { "description": "The remote port, if there's a remote end.", "type": "integer" },
+        "listening": { "description": "Whether it's waiting for connections (or packets, for UDP).", "type": "boolean" },
+        "recv_queue": { "description": "Bytes waiting to be read, or connections waiting to be accepted for a listening socket.", "type": "integer" },
+        "send_queue": { "description": "Bytes that haven't been acknowledged yet.", "type": "integer" },
+        "backlog": { "description": "How many connections a listening socket can queue, if the backend knows.", "type": "integer" },
+        "rtt_us": { "description": "The smoothed round trip time of a TCP connection in microseconds, if the backend knows (diag only).", "type": "integer" },
+        "retransmits": { "description": "How many segments a TCP connection has had to send again, if the backend knows (diag only).", "type": "integer" },
+        "congestion_window": { "description": "How many segments a TCP connection can have in flight, if the backend knows (diag only).", "type": "integer" }
+      }
+    }
+  }
+}
+`
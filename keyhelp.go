@@ -0,0 +1,243 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Help Overlay
+// There are far too many keys to fit under the table, so ? opens an overlay with every one of them instead, grouped
+// into navigation, filtering, actions and views. Typing searches the keys and what they do, which is quicker than
+// reading the lot when you know roughly what you're after. The overlay is built from the key bindings themselves, so
+// it always shows the keys as they're actually bound (action keys from the config file included), and keys that can't
+// be used right now (t in read-only mode, the replay keys when nothing's being replayed) are greyed out.
+
+// keyCategory is a heading in the overlay, and the keys under it
+type keyCategory struct {
+	title    string // The locale key for the heading
+	bindings []key.Binding
+}
+
+// The style used for the overlay's headings
+var keyCategoryStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#33a989"))
+
+// The style used for the keys themselves
+var keyNameStyle = lipgloss.NewStyle().Bold(true)
+
+// The gap between the overlay's columns
+const keyColumnGap = 4
+
+// keyCategories() groups every key, including the table's own keys for moving around
+func (m model) keyCategories() []keyCategory {
+	k, table := m.keys, m.table.KeyMap
+	return []keyCategory{
+		{"keys.navigation", []key.Binding{
+			k.Up, k.Down, table.PageUp, table.PageDown, table.HalfPageUp, table.HalfPageDown, table.GotoTop,
+			table.GotoBottom, k.Toggle, k.Collapse, k.Expand, k.Peer, k.PreviousFrame, k.NextFrame, k.FirstFrame,
+			k.LastFrame,
+		}},
+		{"keys.filtering", []key.Binding{k.Search, k.Filter, k.Unfilter, k.Protocols, k.Self, k.Children, k.Escape}},
+		{"keys.actions", append([]key.Binding{
+			k.Terminate, k.Close, k.Renice, k.Stop, k.Continue, k.ProbeTLS, k.ProbeHTTP, k.Open, k.OpenDir, k.Latency,
+			k.Capture, k.Trace, k.Reestablish, k.DescribePod, k.Refresh,
+		}, k.Actions...)},
+		{"keys.views", []key.Binding{
+			k.Info, k.Reveal, k.Dashboard, k.NetTop, k.Events, k.Waits, k.Forwards, k.Tunnels, k.Sockets, k.Multicast,
+			k.Sort, k.Top, k.DismissTips, k.Help, k.Quit,
+		}},
+	}
+}
+
+// openKeyHelp() opens the overlay, with an empty search
+func (m *model) openKeyHelp() {
+	m.keySearch = textinput.New()
+	m.keySearch.Placeholder = tr("keys.search")
+	m.keySearch.Focus()
+	m.showKeyHelp = true
+	m.keyScroll = 0
+	m.table.Blur()
+}
+
+// closeKeyHelp() goes back to the table
+func (m *model) closeKeyHelp() {
+	m.keySearch.Blur()
+	m.showKeyHelp = false
+	m.table.Focus()
+}
+
+// updateKeyHelp() handles a key press while the overlay is open. Keys are typed into the search, apart from esc (which
+// clears the search, or closes the overlay if it's already empty), ? on an empty search, and the arrows and page keys,
+// which scroll.
+func (m model) updateKeyHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEsc && m.keySearch.Value() != "":
+		m.keySearch.SetValue("")
+		m.keyScroll = 0
+		return m, nil
+
+	case msg.Type == tea.KeyEsc, msg.Type == tea.KeyCtrlC, key.Matches(msg, keys.Help) && m.keySearch.Value() == "":
+		m.closeKeyHelp()
+		return m, nil
+
+	case msg.Type == tea.KeyUp:
+		m.scrollKeyHelp(-1)
+		return m, nil
+	case msg.Type == tea.KeyDown:
+		m.scrollKeyHelp(1)
+		return m, nil
+	case msg.Type == tea.KeyPgUp:
+		m.scrollKeyHelp(-m.keyHelpHeight())
+		return m, nil
+	case msg.Type == tea.KeyPgDown:
+		m.scrollKeyHelp(m.keyHelpHeight())
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	before := m.keySearch.Value()
+	m.keySearch, cmd = m.keySearch.Update(msg)
+	if m.keySearch.Value() != before {
+		m.keyScroll = 0
+	}
+	return m, cmd
+}
+
+// scrollKeyHelp() scrolls the overlay, without going past either end
+func (m *model) scrollKeyHelp(lines int) {
+	m.keyScroll += lines
+	if most := len(m.keyHelpLines()) - m.keyHelpHeight(); m.keyScroll > most {
+		m.keyScroll = most
+	}
+	if m.keyScroll < 0 {
+		m.keyScroll = 0
+	}
+}
+
+// keyHelpHeight() returns how many lines of keys the overlay has room for: the whole terminal, less its border, the
+// search and the line under it. Until the terminal's size is known, it's as tall as the table and what's around it.
+func (m model) keyHelpHeight() int {
+	height := m.windowHeight
+	if height == 0 {
+		height = m.settings.height + m.chromeHeight()
+	}
+	height -= 1 + m.settings.frameLines() + 2
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+// keyMatches() checks if a key or what it does contains the search, ignoring case
+func keyMatches(binding key.Binding, search string) bool {
+	help := binding.Help()
+	return strings.Contains(strings.ToLower(help.Key+" "+help.Desc), strings.ToLower(search))
+}
+
+// keyCategoryBlock() renders a heading and the keys under it that match the search, or "" if none do
+func keyCategoryBlock(category keyCategory, search string) string {
+	var entries [][2]string
+	var enabled []bool
+	keyWidth := 0
+	for _, binding := range category.bindings {
+		help := binding.Help()
+		if help.Key == "" || !keyMatches(binding, search) {
+			continue
+		}
+		entries = append(entries, [2]string{help.Key, help.Desc})
+		enabled = append(enabled, binding.Enabled())
+		if width := lipgloss.Width(help.Key); width > keyWidth {
+			keyWidth = width
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+
+	lines := []string{keyCategoryStyle.Render(tr(category.title))}
+	for i, entry := range entries {
+		name := entry[0] + strings.Repeat(" ", keyWidth-lipgloss.Width(entry[0]))
+		if enabled[i] {
+			lines = append(lines, keyNameStyle.Render(name)+"  "+entry[1])
+		} else {
+			// Greyed out, and said in words too for terminals without colour
+			lines = append(lines, noticeStyle.Render(name+"  "+tr("keys.unavailable", entry[1])))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// keyHelpLines() lays out the categories with keys matching the search side by side, as many as fit across the
+// terminal, and returns the lines
+func (m model) keyHelpLines() []string {
+	var blocks []string
+	for _, category := range m.keyCategories() {
+		if block := keyCategoryBlock(category, m.keySearch.Value()); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 {
+		return []string{tr("keys.none", m.keySearch.Value())}
+	}
+
+	width := m.help.Width - m.settings.frameStyle().GetHorizontalFrameSize()
+	if m.help.Width == 0 {
+		width = 80
+	}
+
+	// Fill each row with as many categories as fit, then start the next
+	var rows []string
+	var row []string
+	rowWidth := 0
+	gap := strings.Repeat(" ", keyColumnGap)
+	for _, block := range blocks {
+		blockWidth := lipgloss.Width(block)
+		if len(row) > 0 && rowWidth+keyColumnGap+blockWidth > width {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row, rowWidth = nil, 0
+		}
+		if len(row) > 0 {
+			row = append(row, gap)
+			rowWidth += keyColumnGap
+		}
+		row = append(row, block)
+		rowWidth += blockWidth
+	}
+	rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+
+	return strings.Split(strings.Join(rows, "\n\n"), "\n")
+}
+
+// keyHelpView() renders the overlay, which takes the place of everything else
+func (m model) keyHelpView() string {
+	lines := m.keyHelpLines()
+	height := m.keyHelpHeight()
+
+	start := m.keyScroll
+	if start > len(lines)-1 {
+		start = len(lines) - 1
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + height
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	body := strings.Join(lines[start:end], "\n")
+	if padding := height - (end - start); padding > 0 {
+		body += strings.Repeat("\n", padding)
+	}
+
+	hint := tr("keys.hint")
+	if end < len(lines) || start > 0 {
+		hint = tr("keys.hint-scroll")
+	}
+	return "\n" + m.settings.frameStyle().Render(body) + "\n" + m.keySearch.View() + "\n" + noticeStyle.Render(hint)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Kubernetes
+// On a Kubernetes node, lots of processes belong to containers, and the PID on its own doesn't say much. With
+// --kubernetes, the Pod and Namespace columns show which pod each process is running in.
+//
+// Each container's processes are put in a cgroup named after the pod's UID, which we can read from /proc. The kubelet
+// keeps each pod's logs in /var/log/pods/<namespace>_<name>_<uid>, which gives us the pod's name. If that's not readable
+// (e.g. we're not on the node itself), kubectl is asked instead.
+
+// A pod, as found from its UID
+type podInfo struct {
+	namespace string
+	name      string
+}
+
+// Matches the pod UID in a cgroup path. The cgroupfs driver uses pod<uid>, and the systemd driver uses
+// kubepods-<qos>-pod<uid>.slice with underscores instead of dashes.
+var podUIDRegex = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+// Where the kubelet keeps the logs of each pod
+const podLogDirectory = "/var/log/pods"
+
+// The pods we know about, by UID. Formatting happens in several goroutines, so it's behind a mutex. It's reloaded when
+// a pod we don't know about shows up, but not more than once every podReloadInterval.
+var (
+	podsMutex  sync.Mutex
+	pods       map[string]podInfo
+	podsLoaded time.Time
+)
+
+const podReloadInterval = 5 * time.Second
+
+// podUID() finds the UID of the pod a process is running in, or an empty string if it's not in a pod
+func podUID(pid int) string {
+	cgroup, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+
+	match := podUIDRegex.FindStringSubmatch(string(cgroup))
+	if match == nil {
+		return ""
+	}
+	return strings.ReplaceAll(match[1], "_", "-")
+}
+
+// loadPods() finds every pod on the node from the kubelet's log directory, or from kubectl if that doesn't work
+func loadPods() map[string]podInfo {
+	found := make(map[string]podInfo)
+
+	entries, _ := os.ReadDir(podLogDirectory)
+	for _, entry := range entries {
+		// Each directory is named <namespace>_<name>_<uid>. Neither namespaces nor pod names can have underscores in.
+		parts := strings.Split(entry.Name(), "_")
+		if len(parts) == 3 {
+			found[parts[2]] = podInfo{namespace: parts[0], name: parts[1]}
+		}
+	}
+
+	if len(found) == 0 && commandExists("kubectl") {
+		// Command is `kubectl get pods --all-namespaces`, printing the UID, namespace and name of each pod on a line
+		out, err := exec.Command("kubectl", "get", "pods", "--all-namespaces", "-o",
+			`jsonpath={range .items[*]}{.metadata.uid} {.metadata.namespace} {.metadata.name}{"\n"}{end}`).Output()
+		if err != nil {
+			debugf("kubectl get pods failed: %v", err)
+		}
+
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 3 {
+				found[fields[0]] = podInfo{namespace: fields[1], name: fields[2]}
+			}
+		}
+	}
+
+	debugf("found %d pods", len(found))
+	return found
+}
+
+// lookupPod() finds the pod a process is running in
+func lookupPod(pid int) (podInfo, bool) {
+	uid := podUID(pid)
+	if uid == "" {
+		return podInfo{}, false
+	}
+
+	podsMutex.Lock()
+	defer podsMutex.Unlock()
+
+	pod, exists := pods[uid]
+	if !exists && time.Since(podsLoaded) > podReloadInterval {
+		// It's probably a new pod, so look again
+		pods = loadPods()
+		podsLoaded = time.Now()
+		pod, exists = pods[uid]
+	}
+
+	return pod, exists
+}
+
+// fillKubernetesColumns() fills in the Pod and Namespace columns on the first row of a process, the same as the other
+// process columns
+func fillKubernetesColumns(rows []table.Row, proc process, options settings) {
+	if len(rows) == 0 || !options.kubernetes || proc.ID == 0 || proc.Windows {
+		return
+	}
+
+	pod, exists := lookupPod(proc.ID)
+	if !exists {
+		return
+	}
+
+	for i, column := range options.columns {
+		switch column.Title {
+		case "Pod":
+			rows[0][i] = pod.name
+		case "Namespace":
+			rows[0][i] = pod.namespace
+		}
+	}
+}
+
+// describePod() runs `kubectl describe pod` for the pod a process is running in, and shows it in the detail pane
+func describePod(pid int) tea.Cmd {
+	return func() tea.Msg {
+		pod, exists := lookupPod(pid)
+		if !exists {
+			return errMsg{fmt.Errorf(tr("error.no-pod"), pid)}
+		}
+
+		// Command is `kubectl describe pod -n NAMESPACE NAME`
+		out, err := exec.Command("kubectl", "describe", "pod", "-n", pod.namespace, pod.name).CombinedOutput()
+		if err != nil && len(out) == 0 {
+			return errMsg{err}
+		}
+
+		return detailMsg{title: pod.namespace + "/" + pod.name, body: string(out)}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// launchd Jobs
+// On macOS, launchd does what systemd does on Linux (see services.go): it can hold a port open for a job and start the
+// job when a connection comes in, and it restarts jobs that exit when they're meant to stay running, so terminating
+// one just gets a new one a moment later. Y lists launchd's jobs that listen on ports, by their labels:
+//
+//   - Jobs with Sockets in their property list, which launchd listens on for them, from the property lists in
+//     /Library/LaunchDaemons, /Library/LaunchAgents and ~/Library/LaunchAgents (Apple's own are left out)
+//   - Jobs that are running and listening on ports themselves, from `launchctl list`, which only has the jobs in the
+//     domain pvw is running in: the system's when it's root, and the user's otherwise
+//
+// s unloads the selected job (`launchctl unload`), which stops it and anything launchd's listening on for it, and c
+// loads it again. Only jobs with a property list pvw found can be loaded and unloaded, and the system's need root.
+// The rows of sockets launchd holds are named after their jobs ("launchd socket → com.example.api"), and the detail
+// pane says which job a process is.
+
+// The directories with the property lists of jobs that weren't installed by Apple, and whether they're the user's
+var launchdDirectories = []struct {
+	path string
+	user bool
+}{
+	{"/Library/LaunchDaemons", false},
+	{"/Library/LaunchAgents", true},
+	{"~/Library/LaunchAgents", true},
+}
+
+// launchdPlist is the part of a job's property list pvw needs, once it's been converted to JSON by plutil
+type launchdPlist struct {
+	Label   string
+	Sockets map[string]json.RawMessage // Each is a dictionary, or an array of them
+}
+
+// launchdSocket is one of a job's Sockets
+type launchdSocket struct {
+	SockServiceName string // A port number or service name
+	SockType        string // stream (the default) or dgram
+	SockNodeName    string // The address to listen on, or every address if it's not set
+}
+
+// parseLaunchctlList() parses the output of `launchctl list`, which has a line for each job with its PID (or - if it
+// isn't running), its last exit status and its label. It returns the labels of the running jobs by PID, and every
+// job that's loaded.
+func parseLaunchctlList(output string) (map[int]string, map[string]bool) {
+	running := make(map[int]string)
+	loaded := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] == "PID" {
+			continue
+		}
+		label := strings.Join(fields[2:], " ")
+		loaded[label] = true
+		if pid, err := strconv.Atoi(fields[0]); err == nil {
+			running[pid] = label
+		}
+	}
+	return running, loaded
+}
+
+// parsePlistSockets() reads the ports a job's property list (as JSON) has launchd listen on for it
+func parsePlistSockets(contents []byte) (string, []socketListen, error) {
+	var plist launchdPlist
+	if err := json.Unmarshal(contents, &plist); err != nil {
+		return "", nil, err
+	}
+
+	var listens []socketListen
+	for _, raw := range plist.Sockets {
+		var sockets []launchdSocket
+		if json.Unmarshal(raw, &sockets) != nil {
+			var socket launchdSocket
+			if err := json.Unmarshal(raw, &socket); err != nil {
+				continue
+			}
+			sockets = []launchdSocket{socket}
+		}
+
+		for _, socket := range sockets {
+			protocol, network := "TCP", "tcp"
+			if socket.SockType == "dgram" {
+				protocol, network = "UDP", "udp"
+			}
+			port, err := net.LookupPort(network, socket.SockServiceName)
+			if socket.SockServiceName == "" || err != nil {
+				continue
+			}
+			address := socket.SockNodeName
+			if address == "" {
+				address = "*"
+			}
+			listens = append(listens, socketListen{protocol, address, strconv.Itoa(port)})
+		}
+	}
+	return plist.Label, listens, nil
+}
+
+// readLaunchdPlist() reads a job's property list, which can be XML or binary, by having plutil convert it to JSON
+func readLaunchdPlist(path string) (string, []socketListen, error) {
+	// Command is `plutil -convert json -o - -- PATH`
+	out, err := exec.Command("plutil", "-convert", "json", "-o", "-", "--", path).Output()
+	if err != nil {
+		return "", nil, err
+	}
+	return parsePlistSockets(out)
+}
+
+// launchdSockets() lists launchd's jobs that listen on ports, from their property lists, then the running ones in the
+// processes
+func launchdSockets(processes []process) ([]serviceSocket, error) {
+	if runtime.GOOS != "darwin" || !commandExists("launchctl") {
+		return nil, errors.New(tr("error.no-service-manager"))
+	}
+
+	out, err := exec.Command("launchctl", "list").Output()
+	if err != nil {
+		return nil, explainFailure(err, "launchctl")
+	}
+	running, loaded := parseLaunchctlList(string(out))
+	state := func(label string) string {
+		for _, runningLabel := range running {
+			if runningLabel == label {
+				return "running"
+			}
+		}
+		if loaded[label] {
+			return "loaded"
+		}
+		return "unloaded"
+	}
+
+	var sockets []serviceSocket
+	plists := make(map[string]string) // By label, for the running jobs
+	home, _ := os.UserHomeDir()
+	for _, directory := range launchdDirectories {
+		path := directory.path
+		if strings.HasPrefix(path, "~/") {
+			path = filepath.Join(home, path[2:])
+		}
+		files, _ := filepath.Glob(filepath.Join(path, "*.plist"))
+		for _, file := range files {
+			label, listens, err := readLaunchdPlist(file)
+			if err != nil || label == "" {
+				continue
+			}
+			plists[label] = file
+			if len(listens) > 0 {
+				sockets = append(sockets, serviceSocket{
+					unit: label, manager: "launchd", plist: file, user: directory.user, state: state(label),
+					listens: listens,
+				})
+			}
+		}
+	}
+
+	// Jobs that listen on ports themselves don't say so in their property lists
+	for _, proc := range processes {
+		label, isJob := running[proc.ID]
+		if !isJob || slices.IndexFunc(sockets, func(s serviceSocket) bool { return s.unit == label }) != -1 {
+			continue
+		}
+		socket := serviceSocket{unit: label, manager: "launchd", plist: plists[label], state: state(label)}
+		for _, conn := range proc.Connections {
+			if isBound(conn) {
+				socket.listens = append(socket.listens, socketListen{conn.Protocol, conn.LocalAddress, conn.LocalPort})
+			}
+		}
+		if len(socket.listens) > 0 {
+			sockets = append(sockets, socket)
+		}
+	}
+	return sockets, nil
+}
+
+// launchdJob() returns the label of the launchd job a process is, if it's one
+func launchdJob(proc process) (string, bool) {
+	if runtime.GOOS != "darwin" || proc.Windows || !commandExists("launchctl") {
+		return "", false
+	}
+	out, err := exec.Command("launchctl", "list").Output()
+	if err != nil {
+		return "", false
+	}
+	running, _ := parseLaunchctlList(string(out))
+	label, isJob := running[proc.ID]
+	return label, isJob
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// TestParseLaunchctlList checks running jobs are found by PID, and jobs that aren't running are still loaded
+func TestParseLaunchctlList(t *testing.T) {
+	output := "PID\tStatus\tLabel\n412\t0\tcom.example.api\n-\t0\tcom.example.worker\n-\t78\tcom.apple.something\n"
+	running, loaded := parseLaunchctlList(output)
+
+	if running[412] != "com.example.api" || len(running) != 1 {
+		t.Errorf("expected only com.example.api to be running, got %v", running)
+	}
+	if !loaded["com.example.worker"] || loaded["PID"] {
+		t.Errorf("expected the jobs that aren't running to be loaded, got %v", loaded)
+	}
+}
+
+// TestParsePlistSockets checks the ports launchd listens on for a job are read from both forms of Sockets
+func TestParsePlistSockets(t *testing.T) {
+	plist := `{
+		"Label": "com.example.api",
+		"Sockets": {
+			"Listeners": {"SockServiceName": "8080", "SockNodeName": "127.0.0.1"},
+			"Both": [{"SockServiceName": "http"}, {"SockServiceName": "5353", "SockType": "dgram"}],
+			"Unix": {"SockPathName": "/var/run/api.sock"}
+		}
+	}`
+	label, listens, err := parsePlistSockets([]byte(plist))
+	if err != nil || label != "com.example.api" {
+		t.Fatalf("expected com.example.api, got %q (%v)", label, err)
+	}
+
+	expected := []socketListen{{"TCP", "127.0.0.1", "8080"}, {"TCP", "*", "80"}, {"UDP", "*", "5353"}}
+	for _, listen := range expected {
+		found := false
+		for _, got := range listens {
+			found = found || got == listen
+		}
+		if !found {
+			t.Errorf("expected %v in %v", listen, listens)
+		}
+	}
+	if len(listens) != len(expected) {
+		t.Errorf("expected %d sockets, got %v", len(expected), listens)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import "strings"
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Layout
+// The table gets however many rows are left once everything else (the search bar, notices, errors and help) has been
+// given room. When running inline, it's --height rows tall unless the terminal is too short for that, and in the
+// alternate screen it fills the whole terminal.
+
+// chromeHeight() returns the number of lines used by everything apart from the table's rows
+func (m model) chromeHeight() int {
+	// The blank line at the top, the table's border and header, the search bar, and the gap above the help
+	lines := 1 + m.settings.frameLines() + m.settings.headerLines() + 1 + 1
+
+	lines += len(m.alertLines())
+	lines += len(m.shownNotices())
+	if _, shown := m.truncatedLine(); shown {
+		lines++
+	}
+	if m.filterChips() != "" {
+		lines++
+	}
+	if m.currentTip() != "" {
+		lines++
+	}
+	if len(m.warnings) > 0 {
+		lines++
+	}
+	if m.err != nil {
+		lines += strings.Count(m.err.Error(), "\n") + 1
+	}
+
+	lines += strings.Count(m.help.View(m.keys), "\n") + 1
+
+	return lines
+}
+
+// shownNotices() returns the notices to show under the table: the backend's, then whose children are being shown (see
+// children.go) and where we are in a recording (see record.go), if either applies
+func (m model) shownNotices() []string {
+	notices := append([]string{}, m.notices...)
+	if m.settings.childrenOf != "" {
+		notices = append(notices, tr("notice.children", m.settings.childrenOf))
+	}
+	if replay, isReplay := m.replaying(); isReplay {
+		notices = append(notices, replay.status())
+	}
+	return notices
+}
+
+// tableHeight() returns the number of rows the table should show. Until the terminal's size is known, the table is
+// the height that was asked for.
+func (m model) tableHeight() int {
+	height := m.settings.height
+	if m.windowHeight == 0 {
+		return height
+	}
+
+	available := m.windowHeight - m.chromeHeight()
+	if m.settings.altScreen || available < height {
+		height = available
+	}
+
+	// Always show at least one row, even if the terminal is tiny
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+// fitTable() resizes the table to fit, if the space it has has changed. SetHeight re-renders every row, so it's only
+// called when needed.
+func (m *model) fitTable() {
+	if height := m.tableHeight(); height != m.table.Height() {
+		m.table.SetHeight(height)
+		m.trackTop()
+	}
+}
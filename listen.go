@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Listen Mode
+// Most of the time the question is "what's running on my machine?", not "what's every connection doing?". `pvw listen`
+// answers that with one line per listening port: the port, who it can be reached by (the scope), the process and how
+// long it's been running. A service listening on both 0.0.0.0 and [::] only gets one line, as it's the same port.
+//
+// Scopes are:
+//
+//	all    Bound to every interface, so reachable from other machines (firewall permitting)
+//	local  Bound to loopback, so only reachable from this machine
+//	NAME   Bound to one interface's address (e.g. wg0), so only reachable through that interface
+
+// A listening port, as shown in listen mode
+type listener struct {
+	port     int
+	protocol string
+	scope    string
+	proc     process
+}
+
+// runListen() runs listen mode with the arguments after `listen`, and returns the exit code
+func runListen(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("listen", pflag.ContinueOnError)
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagTCP := flags.BoolP("tcp", "t", false, tr("flag.tcp"))
+	flagUDP := flags.BoolP("udp", "u", false, tr("flag.udp"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("listen.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	w = quietOutput(w, *flagQuiet)
+
+	all, err := collectListeners(*flagBackend, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	showTCP, showUDP := *flagTCP || !*flagUDP, *flagUDP || !*flagTCP
+	listeners := findListeners(all, showTCP, showUDP)
+	uptimes := processUptimes(listeners)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, tr("listen.header"))
+	for _, l := range listeners {
+		name := l.proc.Name
+		if l.proc.Windows {
+			name = "win:" + name
+		}
+		if name == "" {
+			name = "?"
+		}
+
+		pid, uptime := "?", "?"
+		if l.proc.ID != 0 {
+			pid = strconv.Itoa(l.proc.ID)
+		}
+		if u, exists := uptimes[l.proc.ID]; exists && !l.proc.Windows {
+			uptime = formatUptime(u)
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", l.port, l.protocol, l.scope, name, pid, uptime)
+	}
+	tw.Flush()
+
+	if len(listeners) == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// findListeners() returns one listener for each port each process is listening on, sorted by port
+func findListeners(processes []process, showTCP bool, showUDP bool) []listener {
+	var listeners []listener
+	seen := make(map[string]bool)
+
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			if !isListening(conn) || (conn.Protocol == "TCP" && !showTCP) || (conn.Protocol == "UDP" && !showUDP) {
+				continue
+			}
+
+			port, err := strconv.Atoi(conn.LocalPort)
+			if err != nil {
+				continue
+			}
+
+			l := listener{port: port, protocol: conn.Protocol, scope: listenScope(conn.LocalAddress), proc: proc}
+
+			// The same port on IPv4 and IPv6 is still one service
+			id := fmt.Sprintf("%d/%t/%s/%d/%s", proc.ID, proc.Windows, l.protocol, l.port, l.scope)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			listeners = append(listeners, l)
+		}
+	}
+
+	sort.SliceStable(listeners, func(i, j int) bool {
+		if listeners[i].port != listeners[j].port {
+			return listeners[i].port < listeners[j].port
+		}
+		return listeners[i].protocol < listeners[j].protocol
+	})
+
+	return listeners
+}
+
+// listenScope() describes who can reach an address: all, local, or the name of the interface it's on
+func listenScope(address string) string {
+	if address == "*" {
+		return "all"
+	}
+	if loopbackAddress(address) {
+		return "local"
+	}
+	if name := interfaceOf(address); name != "?" {
+		return name
+	}
+	return address
+}
+
+// processUptimes() finds how long each process has been running, using `ps -o etime`. Processes ps can't find are left
+// out.
+func processUptimes(listeners []listener) map[int]time.Duration {
+	uptimes := make(map[int]time.Duration)
+
+	var pids []string
+	for _, l := range listeners {
+		if l.proc.ID != 0 && !l.proc.Windows {
+			pids = append(pids, strconv.Itoa(l.proc.ID))
+		}
+	}
+	if len(pids) == 0 || !commandExists("ps") {
+		return uptimes
+	}
+
+	// Command is `ps -o pid= -o etime= -p PID,PID...`
+	out, err := exec.Command("ps", "-o", "pid=", "-o", "etime=", "-p", strings.Join(pids, ",")).Output()
+	if err != nil && len(out) == 0 {
+		debugf("ps failed to get uptimes: %v", err)
+		return uptimes
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if elapsed, valid := parseElapsed(fields[1]); valid {
+			uptimes[pid] = elapsed
+		}
+	}
+
+	return uptimes
+}
+
+// parseElapsed() parses the elapsed time ps gives, which looks like [[dd-]hh:]mm:ss
+func parseElapsed(etime string) (time.Duration, bool) {
+	var days int
+	if d, rest, found := strings.Cut(etime, "-"); found {
+		var err error
+		if days, err = strconv.Atoi(d); err != nil {
+			return 0, false
+		}
+		etime = rest
+	}
+
+	// Seconds come last, so work backwards: seconds, minutes, then hours
+	parts := strings.Split(etime, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	elapsed := time.Duration(days) * 24 * time.Hour
+	unit := time.Second
+	for i := len(parts) - 1; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, false
+		}
+		elapsed += time.Duration(n) * unit
+		unit *= 60
+	}
+
+	return elapsed, true
+}
+
+// formatUptime() shows a duration as its two largest units, e.g. 3d4h, 2h15m or 45s
+func formatUptime(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	seconds := int(d/time.Second) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm%ds", minutes, seconds)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
@@ -3,6 +3,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"golang.org/x/exp/slices"
 	"runtime"
@@ -12,71 +14,158 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
 	// For handling CLI flags (CLI switches) (standard flag module isn't POSIX compliant)
 	"github.com/spf13/pflag"
 
-	// For running commands and exiting
+	// The process structs, lsof parser and table formatter
+	"github.com/allyring/pvw/internal/lsof"
+
+	// The collectors, and the helper to kill processes
+	"github.com/allyring/pvw/pkg/pvw"
+
+	// For debug logging
+	"log"
+
+	// For running commands and exiting, and writing in plain mode
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	// For formatting output & parsing input
+	"math"
 	"strconv"
 	"strings"
+
+	// For watch mode
+	"time"
 )
 
 // ---------------------------------------------------------------------------------------------------------------------
 
 // Important Structs
 
-// A process. Contains a PID used to terminate the process later, the name of the executable responsible for that process,
-// an array of the ports it uses, and the username of the user that created that process
-type process struct {
-	id          int
-	name        string
-	directory   string
-	connections []connection
-	username    string
-}
-
-// A connection. Contains a protocol type (typically tcp or udp), connection status, remote address and port,
-// local address and port, and friendly name for the remote port / local port if listening.
-type connection struct {
-	protocol string
-	status   string
-
-	localName  string
-	remoteName string
-
-	remotePort    string
-	remoteAddress string
+// The process and connection structs live in the internal/lsof package along with the parser, so they can be tested
+// on their own. Every backend converts its output to them. A process contains a PID, name, owner, working directory
+// and the connections it has open, and a connection contains the protocol, status, and local and remote addresses.
+type process = lsof.Process
+type connection = lsof.Connection
 
-	localPort    string
-	localAddress string
-
-	ipv6 bool
-}
+// A record in a backend's output that couldn't be parsed, and was skipped
+type parseWarning = lsof.Warning
 
 // The settings struct. Contains all the settings for parsing and rendering the table
 type settings struct {
 	readOnly   bool // Allow process termination
 	showClosed bool // Allow closed ports to be displayed
 	listenOnly bool // Filter to ports that are listening
-	getCwd     bool // Enable getting the CWD of a process
+
+	hideSelf bool         // Whether to leave pvw and its children out of the table (see self.go)
+	self     map[int]bool // pvw's PID and its children's, as of the last collection. Replaced rather than changed.
+
+	terminals  map[string]bool // The terminals that count as pvw's own (see terminal.go)
+	onTerminal map[int]bool    // The processes in one of them at the last collection. Replaced rather than changed.
+	getCwd     bool            // Enable getting the CWD of a process
 
 	showIPv6 bool // Enable IPv6
 	showIPv4 bool // Enable IPv4
 
+	showTCP bool // Show TCP sockets
+	showUDP bool // Show UDP sockets
+
 	columns      []table.Column // The columns that have been selected for rendering
 	serviceNames bool           // Whether to resolve service names from ports
 
-	portFilter []string // The port numbers to filter by - don't filter if empty
-	nameFilter []string // The port names to filter by - don't filter if empty
+	portFilter      []string // The port numbers (or ranges, e.g. 9000-9100) to filter by - don't filter if empty
+	interfaceFilter []string // The interfaces to filter by - don't filter if empty
+	hostFilter      []string // The names from the hosts file to filter by - don't filter if empty
+	nameFilter      []string // The port names to filter by - don't filter if empty
+	match           string   // How names are matched against the name filter: exact, substring or fuzzy (see patterns.go)
+
+	// What to hide, checked after everything else (see exclude.go)
+	excludeNames []string
+	excludePorts []string
+	excludeUsers []string
+
+	highlights       []highlightRule // Rows to make stand out, from the config file (see highlight.go)
+	alerts           []alertRule     // What to show a banner for in watch mode, from the config file (see alerts.go)
+	confirmTerminate bool            // Whether t asks before terminating, from the config file
+	tips             bool            // Whether the tips line is shown (see tips.go)
+	checkUpdates     bool            // Whether to check for a newer release when starting (see update.go)
+	protected        []string        // Names of processes t asks for the PID of before terminating (see protect.go)
+	ancestors        []int           // The processes pvw is running under, which are always protected
+
+	filters []filterTerm // The filters from the filter bar (see filterbar.go). Copied rather than changed.
+
+	collapsed map[int]bool // The processes collapsed to a single row (see collapse.go). Copied rather than changed.
+
+	children   []int  // The processes to filter to, after jumping to a process' children with C
+	childrenOf string // Whose children are being shown, e.g. "nginx (PID 1234)" - don't filter if empty
 
 	searchTerm    string // The search term - gets added onto the nameFilter if not an empty string
 	displaySearch bool   // Whether to display the search bar or not
+
+	interval time.Duration // How often to refresh automatically (watch mode) - disabled if 0
+	timeout  time.Duration // How long the backend is allowed to run before it's killed
+	reactive bool          // Whether watch mode skips collecting while the socket tables are the same (see reactive.go)
+	ebpf     bool          // Whether to have the kernel say when connections open and close (see events.go)
+
+	pluginColumns []pluginColumn // Extra columns from the config file
+	actions       []pluginAction // Extra keys from the config file
+
+	altScreen bool // Whether to run full-screen in the alternate screen, rather than inline
+	height    int  // How many rows the table shows when running inline
+
+	kubernetes bool // Whether to find the Kubernetes pod each process is running in
+	windows    bool // Whether to add the Windows side's listeners when running under WSL
+	exposed    bool // Whether to check the firewall to see if listening ports can be reached from outside
+
+	showInterface bool // Whether to show the interface each socket's local address belongs to
+	hostNames     bool // Whether to show local addresses as their names from the hosts file
+
+	addressDisplays []string // How to change the way addresses are shown (see addresses.go)
+	allNamespaces   bool     // Whether to add the sockets in every other network namespace
+
+	recorder      *recorder // Where to record every collection to, if --record was passed
+	recordHistory bool      // Whether collections are remembered for pvw who-had, which only the TUI does (see history.go)
+
+	title   bool   // Whether to set the terminal's title to a summary of what's shown
+	density string // How tightly the table is packed (see density.go)
+	theme   string // The colours the table is drawn with
+
+	fixedWidths bool // Whether to keep the columns at the widths they're set up with, rather than fitting them to the rows
+
+	generation int // Goes up each time the search, filters, sort and so on change, so results from before can be spotted
+
+	showNice bool // Whether to show each process' nice value
+
+	showResources bool                  // Whether to show each process' CPU and memory (see resources.go)
+	sortBy        string                // What the table's sorted by: "", "cpu" or "memory"
+	resources     map[int]resourceUsage // Each process' CPU and memory at the last collection, if either is needed
+
+	showAge bool                     // Whether to show how long each connection has been open for (see age.go)
+	ages    map[rowKey]connectionAge // When each connection was first seen, as of the last collection
+	now     func() time.Time         // The clock the ages are measured with, if it isn't the real one (see age.go)
+
+	showProject bool // Whether to show the project each process' working directory is in (see projects.go)
+
+	showFramework bool // Whether to show the dev server each process is, and the port it wants (see frameworks.go)
+
+	showPrivileges bool // Whether to show what lets each process listen on ports below 1024 (see privileges.go)
+
+	showPeers bool            // Whether to show the process at the other end of each local connection (see peers.go)
+	peers     map[rowKey]peer // The other end of each connection between local processes, as of the last collection
+
+	sharedPorts map[rowKey]portGroup // The sockets on ports split between processes, as of the last collection
+	reusedPorts map[rowKey]portGroup // The sockets bound to the same address and port as other processes' sockets
+
+	guessProtocols bool // Whether to connect to listening ports to guess what protocol they speak
+
+	backend backend // The backend used to find processes with sockets open (lsof, ss, netstat or proc)
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -89,7 +178,18 @@ type model struct {
 	rowStarts []int       // The end of each process's list of open ports
 	processes []process   // A slice of process structs
 	err       error       // The most recent error
-	lsofOut   string      // The most recent lsof output as plaintext
+	snapshot  []process   // Every process from the most recent collection, before filtering
+	rowCache  rowCache    // The rows each process produced last refresh, reused if the process hasn't changed
+
+	// Refresh state. Only one collection runs at a time - any refreshes requested while one is running get merged
+	// into a single refresh that starts once it has finished.
+	collecting    bool               // Whether a collection is currently running
+	refreshQueued bool               // Whether another refresh was requested while collecting
+	cancelCollect context.CancelFunc // Cancels the running collection (used when quitting)
+	collectedAt   time.Time          // When the processes in the table were collected (see reuse.go)
+	partials      chan processesMsg  // The first collection's partial results, or nil once it's done (see progressive.go)
+	socketDigest  uint64             // The socket tables as of the last collection in watch mode (see reactive.go)
+	digestedAt    time.Time          // When the socket tables were last found to have changed
 
 	// Settings are stored in the settings struct. Includes render and parsing settings
 	settings settings
@@ -97,6 +197,65 @@ type model struct {
 	// Text input items
 	textInput textinput.Model
 
+	// The filter bar (see filterbar.go), shown in the search bar's place
+	filterInput   textinput.Model
+	filtersBefore []filterTerm // The filters from before the bar was opened, which esc goes back to
+	showFilter    bool
+
+	prompt     textinput.Model      // Asks for a value an action needs, in the search bar's place
+	promptDone func(string) tea.Cmd // Runs the action with the value, and is nil when there isn't a prompt open
+
+	// Notes about anything the backend can't do, shown under the table
+	notices []string
+
+	warnings []parseWarning // Records from the most recent collection that couldn't be parsed
+
+	windowHeight int // The height of the terminal, or 0 until bubbletea tells us
+
+	rows      []table.Row    // The rows currently in the table, as the table doesn't let us read them back
+	columns   []table.Column // The columns as they're currently shown, fitted to the rows (see widths.go)
+	truncated bool           // Whether any cell in the table is cut off, so there's a line under it (see truncated.go)
+
+	alerts alertState // What the alert rules matched last refresh, and which are going off (see alerts.go)
+
+	connectionCounts []int     // How many connections were in the table after each of the last few refreshes (see sparkline.go)
+	tableTop         int       // The row at the top of the table, as the table doesn't say how far it's scrolled either
+	output           io.Writer // Where to write to in plain mode, or nil if the table is being drawn as normal
+
+	crash *panicMsg // Set if a Cmd panicked, so the crash can be printed once the terminal has been restored
+
+	// The detail pane, shown instead of the table (see detail.go)
+	detail           viewport.Model
+	detailTitle      string
+	detailProcess    *process    // The process the pane is about, if it's about one
+	detailConnection *connection // The process' connection that was selected when the pane was opened
+	revealSecrets    bool        // Whether secrets in the pane are shown rather than masked
+	showDetail       bool
+
+	// The ssh tunnels panel (see tunnels.go), shown in the detail pane
+	tunnels      []sshTunnel
+	tunnelCursor int
+
+	// The service sockets panel (see services.go), shown in the detail pane
+	serviceSockets []serviceSocket
+	socketCursor   int
+
+	// The net top view (see nettop.go), shown in the detail pane
+	netTop           *trafficSample // The last measurement, or nil until there's been one
+	netTopGeneration int            // How many times the view's been opened
+
+	// The event log (see events.go), shown in the detail pane
+	events           []connectionEvent
+	eventConnections map[rowKey]connectionEvent // The connections as of the last collection, or nil before the first
+	tracer           *eventTracer               // The running bpftrace with --ebpf, or nil
+
+	tipsLearned map[string]bool // The tips whose keys have been pressed (see tips.go)
+
+	// The help overlay (see keyhelp.go), shown instead of everything else
+	keySearch   textinput.Model
+	keyScroll   int
+	showKeyHelp bool
+
 	// Used in help menu
 	keys       keyMap         // The keymap used
 	help       help.Model     // The help bubble that gets rendered
@@ -113,13 +272,32 @@ type model struct {
 func (e errMsg) Error() string { return e.err.Error() }
 
 type processesMsg struct { // A struct comprised of process structs and table rows
-	processes []process
-	rows      []table.Row
-	ends      []int
-	raw       string
+	all        []process // Every process the backend found, before filtering
+	processes  []process
+	rows       []table.Row
+	ends       []int
+	cache      rowCache // The row cache to use for the next refresh
+	unchanged  bool     // True if nothing changed since the last refresh, so the table doesn't need touching
+	collected  bool     // True if this came from running lsof, rather than re-rendering the last output
+	partial    bool     // True if this is what the first collection has found so far (see progressive.go)
+	generation int      // The settings' generation it was filtered with, which is out of date if they've changed since
+
+	collectedAt time.Time                // When the backend finished, which no process in it started after (see reuse.go)
+	self        map[int]bool             // pvw's own processes when it was collected (see self.go)
+	onTerminal  map[int]bool             // The processes running in pvw's terminal when it was collected (see terminal.go)
+	resources   map[int]resourceUsage    // Each process' CPU and memory, if shown or sorted by (see resources.go)
+	ages        map[rowKey]connectionAge // When each connection was first seen (see age.go)
+	peers       map[rowKey]peer          // The other end of each connection between local processes (see peers.go)
+	sharedPorts map[rowKey]portGroup     // The sockets on ports split between processes (see binds.go)
+	reusedPorts map[rowKey]portGroup     // The sockets bound to the same address and port as other processes' sockets
+
+	warnings []parseWarning // Records the backend couldn't parse, and skipped
 }
-type errMsg struct{ err error } // An error message.
-type terminateMsg struct{}      // The message returned when terminating a process doesn't error. This then results
+type errMsg struct{ err error }        // An error message.
+type collectErrMsg struct{ err error } // An error message from running lsof, which also means the collection finished
+type tickMsg struct{}                  // Sent every refresh interval when watch mode is enabled
+type refreshMsg struct{}               // Asks for the processes to be refreshed
+type terminateMsg struct{}             // The message returned when terminating a process doesn't error. This then results
 // in another command being issued to get the latest slice of processes, which
 // should have the terminated process removed if it was successful.
 
@@ -139,43 +317,260 @@ type keyMap struct {
 
 	Help key.Binding
 	Quit key.Binding
+
+	DescribePod key.Binding // Only enabled with --kubernetes
+	ProbeTLS    key.Binding
+	ProbeHTTP   key.Binding
+	Open        key.Binding
+	Latency     key.Binding
+	OpenDir     key.Binding // Opens the working directory in the editor, file manager or a terminal (see workdir.go)
+	Capture     key.Binding
+	Trace       key.Binding
+	Record      key.Binding // Left out of the help, as it's only for bug reports (see records.go)
+	Protocols   key.Binding // Switches between TCP and UDP, with the help saying which are shown
+	Multicast   key.Binding
+	Dashboard   key.Binding
+	NetTop      key.Binding
+	Events      key.Binding
+	Waits       key.Binding
+	Self        key.Binding // Shows or hides pvw's own processes, with the help saying which it'll do
+	Info        key.Binding
+	Filter      key.Binding
+	Unfilter    key.Binding // Removes the last filter
+	Toggle      key.Binding // Collapses or expands the selected process
+	Collapse    key.Binding
+	Expand      key.Binding
+	Children    key.Binding
+	Forwards    key.Binding
+	Tunnels     key.Binding
+	Reestablish key.Binding // Only does something in the tunnels panel
+	Sockets     key.Binding
+	Peer        key.Binding
+	Reveal      key.Binding // Only does something in the detail pane for a process
+	Renice      key.Binding
+	Stop        key.Binding
+	Continue    key.Binding
+	Close       key.Binding // Closes the selected connection, rather than its process
+	DismissTips key.Binding // Only enabled while tips are shown
+	Sort        key.Binding // Cycles what the table's sorted by, with the help saying what it is now
+	Top         key.Binding
+
+	// Only enabled while replaying a recording
+	PreviousFrame key.Binding
+	NextFrame     key.Binding
+	FirstFrame    key.Binding
+	LastFrame     key.Binding
+
+	Actions []key.Binding // Keys for the actions in the config file
 }
 
-var keys = keyMap{
-	Up: key.NewBinding(
-		key.WithKeys("up", "k"),
-		key.WithHelp("↑/k", "move up"),
-	),
-	Down: key.NewBinding(
-		key.WithKeys("down", "j"),
-		key.WithHelp("↓/j", "move down"),
-	),
-	Terminate: key.NewBinding(
-		key.WithKeys("t"),
-		key.WithHelp("t", "terminate selected process"),
-	),
-	Refresh: key.NewBinding(
-		key.WithKeys("r"),
-		key.WithHelp("r", "refresh the list of processes"),
-	),
-	Escape: key.NewBinding(
-		key.WithKeys("esc"),
-		key.WithHelp("esc", "close the search bar"),
-	),
-	Search: key.NewBinding(
-		key.WithKeys("/"),
-		key.WithHelp("/", "toggle the search bar"),
-	),
-	Help: key.NewBinding(
-		key.WithKeys("?"),
-		key.WithHelp("?", "toggle help"),
-	),
-	Quit: key.NewBinding(
-		key.WithKeys("q", "ctrl+c"),
-		key.WithHelp("q", "quit"),
-	),
+// newKeyMap() creates the keymap, with the help text in the current language
+func newKeyMap() keyMap {
+	return keyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑/k", tr("help.up")),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓/j", tr("help.down")),
+		),
+		Terminate: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", tr("help.terminate")),
+		),
+		Refresh: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", tr("help.refresh")),
+		),
+		Escape: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", tr("help.escape")),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", tr("help.search")),
+		),
+		Help: key.NewBinding(
+			key.WithKeys("?"),
+			key.WithHelp("?", tr("help.help")),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("q", "ctrl+c"),
+			key.WithHelp("q", tr("help.quit")),
+		),
+		ProbeTLS: key.NewBinding(
+			key.WithKeys("T"),
+			key.WithHelp("T", tr("help.probe-tls")),
+		),
+		ProbeHTTP: key.NewBinding(
+			key.WithKeys("H"),
+			key.WithHelp("H", tr("help.probe-http")),
+		),
+		Open: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", tr("help.open")),
+		),
+		Latency: key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", tr("help.latency")),
+		),
+		OpenDir: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", tr("help.open-directory")),
+		),
+		Protocols: key.NewBinding(
+			key.WithKeys("P"),
+			key.WithHelp("P", tr("help.protocols", "TCP+UDP")), // Updated once the settings are known
+		),
+		Filter: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", tr("help.filter")),
+		),
+		Unfilter: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", tr("help.unfilter")),
+		),
+		Toggle: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", tr("help.toggle")),
+		),
+		Collapse: key.NewBinding(
+			key.WithKeys("left"),
+			key.WithHelp("←", tr("help.collapse")),
+		),
+		Expand: key.NewBinding(
+			key.WithKeys("right"),
+			key.WithHelp("→", tr("help.expand")),
+		),
+		Info: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", tr("help.info")),
+		),
+		Children: key.NewBinding(
+			key.WithKeys("C"),
+			key.WithHelp("C", tr("help.children")),
+		),
+		Peer: key.NewBinding(
+			key.WithKeys("J"),
+			key.WithHelp("J", tr("help.peer")),
+		),
+		Reveal: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", tr("help.reveal")),
+		),
+		Renice: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", tr("help.renice")),
+		),
+		Stop: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", tr("help.stop")),
+		),
+		Continue: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", tr("help.continue")),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("ctrl+k"),
+			key.WithHelp("ctrl+k", tr("help.close-connection")),
+		),
+		Capture: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", tr("help.capture")),
+		),
+		Trace: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", tr("help.trace")),
+		),
+		Record: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithDisabled(),
+		),
+		Multicast: key.NewBinding(
+			key.WithKeys("M"),
+			key.WithHelp("M", tr("help.multicast")),
+		),
+		Dashboard: key.NewBinding(
+			key.WithKeys("D"),
+			key.WithHelp("D", tr("help.dashboard")),
+		),
+		NetTop: key.NewBinding(
+			key.WithKeys("B"),
+			key.WithHelp("B", tr("help.net-top")),
+		),
+		Events: key.NewBinding(
+			key.WithKeys("A"),
+			key.WithHelp("A", tr("help.events")),
+		),
+		Waits: key.NewBinding(
+			key.WithKeys("W"),
+			key.WithHelp("W", tr("help.waits")),
+		),
+		Forwards: key.NewBinding(
+			key.WithKeys("L"),
+			key.WithHelp("L", tr("help.forwards")),
+		),
+		Tunnels: key.NewBinding(
+			key.WithKeys("U"),
+			key.WithHelp("U", tr("help.tunnels")),
+		),
+		Reestablish: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", tr("help.reestablish")),
+		),
+		Sockets: key.NewBinding(
+			key.WithKeys("Y"),
+			key.WithHelp("Y", tr("help.sockets")),
+		),
+		Self: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", selfLabel(true)), // Updated once the settings are known
+		),
+		PreviousFrame: key.NewBinding(
+			key.WithKeys("["),
+			key.WithHelp("[", tr("help.previous-frame")),
+			key.WithDisabled(),
+		),
+		NextFrame: key.NewBinding(
+			key.WithKeys("]"),
+			key.WithHelp("]", tr("help.next-frame")),
+			key.WithDisabled(),
+		),
+		FirstFrame: key.NewBinding(
+			key.WithKeys("{"),
+			key.WithHelp("{", tr("help.first-frame")),
+			key.WithDisabled(),
+		),
+		LastFrame: key.NewBinding(
+			key.WithKeys("}"),
+			key.WithHelp("}", tr("help.last-frame")),
+			key.WithDisabled(),
+		),
+		DescribePod: key.NewBinding(
+			key.WithKeys("K"),
+			key.WithHelp("K", tr("help.describe-pod")),
+			key.WithDisabled(),
+		),
+		Sort: key.NewBinding(
+			key.WithKeys("O"),
+			key.WithHelp("O", tr("help.sort", sortLabel(""))), // Updated once the settings are known
+		),
+		Top: key.NewBinding(
+			key.WithKeys("%"),
+			key.WithHelp("%", tr("help.top")),
+		),
+		DismissTips: key.NewBinding(
+			key.WithKeys("X"),
+			key.WithHelp("X", tr("help.dismiss-tips")),
+			key.WithDisabled(),
+		),
+	}
 }
 
+// The keymap used, created once the language is known
+var keys keyMap
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 // Help functions. Used in creating the help menu
@@ -192,8 +587,21 @@ func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down},
 		{k.Refresh, k.Help},
-		{k.Terminate, k.Search},
-		{k.Quit},
+		{k.Terminate, k.Close, k.Search},
+		{k.Filter, k.Unfilter},
+		{k.Renice, k.Stop, k.Continue},
+		{k.ProbeTLS, k.ProbeHTTP},
+		{k.Open, k.Latency, k.OpenDir},
+		{k.Capture, k.Trace},
+		{k.Protocols, k.Multicast, k.Dashboard, k.NetTop, k.Events, k.Waits, k.Self},
+		{k.Forwards, k.Tunnels, k.Reestablish, k.Sockets},
+		{k.Sort, k.Top},
+		{k.Toggle, k.Collapse, k.Expand},
+		{k.Info, k.Reveal, k.Children, k.Peer},
+		{k.DescribePod, k.DismissTips, k.Quit},
+		{k.PreviousFrame, k.NextFrame},
+		{k.FirstFrame, k.LastFrame},
+		k.Actions,
 	}
 }
 
@@ -204,548 +612,484 @@ var baseStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
-// ---------------------------------------------------------------------------------------------------------------------
-
-// Variable containing hashmap for port numbers to service names:
-var serviceNames = map[string]string{
-	"20":    "ftp-data",
-	"21":    "ftp",
-	"22":    "ssh",
-	"23":    "telnet",
-	"25":    "smtp",
-	"69":    "tftp",
-	"80":    "http",
-	"88":    "kerberos-auth",
-	"106":   "macos-password",
-	"109":   "pop2",
-	"110":   "pop3",
-	"115":   "sftp",
-	"118":   "sql",
-	"123":   "ntp",
-	"137":   "netBIOS-ns",
-	"138":   "netBIOS-data",
-	"139":   "netBIOS-ssn",
-	"143":   "imap",
-	"194":   "irc",
-	"220":   "imap3",
-	"389":   "ldap",
-	"443":   "https",
-	"445":   "microsoft-ds",
-	"464":   "kerberos-passwd",
-	"543":   "kerberos-login",
-	"544":   "kerberos-remote",
-	"587":   "smtp-msa",
-	"593":   "ms-dcom",
-	"636":   "ldap-ssl",
-	"691":   "ms-exchange",
-	"749":   "kerberos-admin",
-	"902":   "vmware-server",
-	"989":   "ftp-data-ssl",
-	"990":   "ftp-ssl",
-	"992":   "telnet-ssl",
-	"995":   "pop3-ssl",
-	"1025":  "ms-rpc",
-	"1194":  "openvpn",
-	"1337":  "waste",
-	"1433":  "mssql-server",
-	"1434":  "mssql-monitor",
-	"1589":  "cisco-vqp",
-	"1725":  "steam",
-	"1883":  "mqtt",
-	"2049":  "nfs",
-	"2082":  "cpanel",
-	"2083":  "cpanel-ssl, radsec",
-	"2483":  "oracle-db",
-	"2484":  "oracle-db",
-	"2967":  "symantec-av",
-	"3074":  "xbox-live",
-	"3306":  "mysql",
-	"3389":  "rdp",
-	"5432":  "postgres-sql",
-	"6665":  "irc",
-	"6669":  "irc",
-	"6881":  "bittorrent",
-	"6999":  "bittorrent",
-	"6970":  "quicktime",
-	"8086":  "kaspersky-av",
-	"8087":  "kaspersky-av",
-	"8222":  "vmware-server",
-	"9100":  "pdl",
-	"10000": "backup-exec",
-	"12345": "netbus",
-	"27374": "sub7",
-	"18006": "back-orifice",
-	"25565": "minecraft-server",
-}
+// The style used for notices about what the backend can't do
+var noticeStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("240"))
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-// LSOF Processing
-// All the functions and Cmds relating to getting the processes with ports open on macOS and Linux
+// Process Collection
+// All the functions and Cmds relating to getting the processes with ports open, using whichever backend was selected
 
 // checkProcesses() is the primary function that returns a bubbletea message. It handles all the other functions,
 // processes their outputs, then passes those outputs to other functions.
 // It takes an input of the render and parsing settings, so that all the parsing and conversion from process structs to
-// strings is done inside a goroutine.
-func checkProcesses(settingsInfo settings) tea.Cmd {
+// strings is done inside a goroutine. The row cache from the previous refresh is used so that only processes which
+// have changed get their rows rebuilt.
+// The context is used to kill the backend's command if it takes too long or pvw quits. cancel is called once
+// everything has been collected (the Windows side and other namespaces are collected after the backend).
+// If partials isn't nil, what the backend has found so far is sent to it while it's still collecting, and it's closed
+// once the collection is done (see progressive.go).
+func checkProcesses(ctx context.Context, cancel context.CancelFunc, settingsInfo settings, previous rowCache, partials chan<- processesMsg) tea.Cmd {
 	return func() tea.Msg {
+		defer cancel()
 
-		out, err := getLsof()
+		var partial func([]process)
+		if partials != nil {
+			defer close(partials)
+			partial = partialResults(settingsInfo, partials)
+		}
 
-		if err != nil {
-			if !(err.Error() == "1") {
+		// Get every process with a socket open from the backend
+		started := time.Now()
+		// Transient failures are tried again before giving up (see failures.go)
+		all, warnings, err := collectWithRetries(ctx, func() ([]process, []parseWarning, error) {
+			return collectProgressively(ctx, settingsInfo.backend, partial)
+		})
+		collectedAt := time.Now()
+
+		// pvw's children change from one collection to the next, so they're looked up again (see self.go)
+		settingsInfo.self = selfProcesses()
+		debugf("%s backend took %s, found %d processes with %d warnings", settingsInfo.backend.name(), time.Since(started), len(all), len(warnings))
+
+		for _, warning := range warnings {
+			debugf("skipped a record from %s: %s", settingsInfo.backend.name(), warning)
+		}
+
+		// We have the PIDs, so we can use them to get the CWDs.
+		if err == nil && settingsInfo.getCwd {
+			started = time.Now()
+			err = addDirectories(all)
+			debugf("getting working directories took %s", time.Since(started))
+		}
+
+		// Paused processes get a badge. A recording already says which processes were paused when it was made.
+		if _, isReplay := settingsInfo.backend.(*replayBackend); err == nil && !isReplay {
+			markStopped(all)
+			settingsInfo.onTerminal = onTerminals(all, settingsInfo.terminals)
+		}
+
+		// Under WSL, ports can be taken by Windows programs too. If netstat.exe fails, we can still show the Linux side.
+		if err == nil && settingsInfo.windows {
+			started = time.Now()
+			listeners, windowsWarnings, windowsErr := windowsListeners(ctx)
+			debugf("netstat.exe took %s, found %d Windows processes listening (error: %v)", time.Since(started), len(listeners), windowsErr)
+
+			all = append(all, listeners...)
+			warnings = append(warnings, windowsWarnings...)
+		}
 
-				return processesMsg{nil, nil, nil, out} // No processes, so return empty process slice
+		// Containers' sockets are in their own network namespaces, which the backends can't see
+		if err == nil && settingsInfo.allNamespaces {
+			started = time.Now()
+			namespaced, namespaceWarnings, namespaceErr := pvw.Namespaces().Collect(ctx)
+			debugf("other network namespaces took %s, found %d processes (error: %v)", time.Since(started), len(namespaced), namespaceErr)
+
+			if settingsInfo.getCwd {
+				addDirectories(namespaced)
 			}
-			// Error if we fail, rather than running extra code
-			return errMsg{err}
+			all = append(all, namespaced...)
+			warnings = append(warnings, namespaceWarnings...)
+		}
+
+		if err == nil && settingsInfo.recorder != nil {
+			settingsInfo.recorder.record(all)
 		}
 
-		// We have a string that represents the `lsof` output. Parse that
-		// into a slice of process structs with the parseLsof() function
-		parsed, err := parseLsof(out, settingsInfo)
+		// Remember who was listening where, for `pvw who-had`. A recording isn't what's happening here, so it's left out.
+		if _, isReplay := settingsInfo.backend.(*replayBackend); err == nil && settingsInfo.recordHistory && !isReplay {
+			rememberListeners(all)
+		}
 
 		if err != nil {
-			return errMsg{err}
+			debugf("collection failed: %v", err)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// The backend hung, so it was killed
+				return collectErrMsg{errors.New(tr("error.backend-timeout", settingsInfo.backend.name(), settingsInfo.timeout))}
+			}
+			// Error if we fail, rather than running extra code
+			return collectErrMsg{explainFailure(err, settingsInfo.backend.name())}
+		}
+
+		// Connections are aged before filtering, so changing the filters doesn't make them new again (see age.go)
+		settingsInfo.ages = observeConnections(all, settingsInfo.currentTime())
+		// Peers are too, so a connection's peer is still named when the filters hide it (see peers.go), and so are the
+		// sockets sharing a port (see binds.go)
+		settingsInfo.peers = findPeers(all)
+		previouslyShared := len(settingsInfo.sharedPorts) > 0 || len(settingsInfo.reusedPorts) > 0
+		settingsInfo.sharedPorts = findSharedPorts(all)
+		settingsInfo.reusedPorts = findReusedPorts(all)
+
+		// We have a slice of every process, so filter it down to what we want to show
+		filtered := filterProcesses(all, settingsInfo)
+		if settingsInfo.showResources || sortNeedsResources(settingsInfo.sortBy) {
+			settingsInfo.resources = collectResources(filtered)
 		}
 
 		//fmt.Println(len(parsed))
 		//d1 := string(len(parsed))
 		//_ = os.WriteFile("/tmp/log2", []byte(d1), 0644)
 
-		formatted, ends, err := formatLsof(parsed, settingsInfo)
+		// In watch mode, processes that have exited stay in the table for a bit (see exited.go)
+		filtered, exited := keepExited(filtered, previous, settingsInfo)
+		sortProcesses(filtered, settingsInfo)
+		groupReusedPorts(filtered, settingsInfo)
 
-		return processesMsg{parsed, formatted, ends, out}
+		formatted, ends, cache, unchanged := formatLsofIncremental(filtered, previous, settingsInfo)
+		if len(exited) > 0 {
+			markExited(formatted, ends, filtered, cache, exited, settingsInfo)
+			unchanged = false
+		}
+		// The badges aren't in the row cache, so if there were any last time, the rows could be different
+		if markSharedPorts(formatted, ends, filtered, settingsInfo) || previouslyShared {
+			unchanged = false
+		}
+		debugf("%d processes left after filtering, formatted into %d rows (unchanged: %t)", len(filtered), len(formatted), unchanged)
+
+		return processesMsg{
+			all:        all,
+			processes:  filtered,
+			rows:       formatted,
+			ends:       ends,
+			cache:      cache,
+			unchanged:  unchanged,
+			collected:  true,
+			generation: settingsInfo.generation,
+			warnings:   warnings,
+
+			collectedAt: collectedAt,
+			self:        settingsInfo.self,
+			onTerminal:  settingsInfo.onTerminal,
+			resources:   settingsInfo.resources,
+			ages:        settingsInfo.ages,
+			peers:       settingsInfo.peers,
+			sharedPorts: settingsInfo.sharedPorts,
+			reusedPorts: settingsInfo.reusedPorts,
+		}
 
 	}
 }
 
-func rerenderProcesses(mostRecent string, settingsInfo settings) tea.Cmd {
+// rerenderProcesses() re-filters and re-formats the most recent collection, for when the settings have changed (e.g.
+// the search term) but we don't need to ask the backend again.
+func rerenderProcesses(mostRecent []process, settingsInfo settings, previous rowCache) tea.Cmd {
 	return func() tea.Msg {
-		// We have a string that represents the `lsof` output. Parse that
-		// into a slice of process structs with the parseLsof() function
-		parsed, err := parseLsof(mostRecent, settingsInfo)
-
-		if err != nil {
-			return errMsg{err}
+		filtered := filterProcesses(mostRecent, settingsInfo)
+		sortProcesses(filtered, settingsInfo)
+		groupReusedPorts(filtered, settingsInfo)
+
+		// Only the filters can change, and a process' rows only depend on which of its connections made it through them,
+		// so any process that's the same after filtering can keep its rows (and skip running plugin column commands)
+		formatted, ends, cache, _ := formatLsofIncremental(filtered, previous, settingsInfo)
+		markSharedPorts(formatted, ends, filtered, settingsInfo)
+
+		return processesMsg{
+			all:        mostRecent,
+			processes:  filtered,
+			rows:       formatted,
+			ends:       ends,
+			cache:      cache,
+			generation: settingsInfo.generation,
 		}
 
-		formatted, ends, err := formatLsof(parsed, settingsInfo)
-
-		return processesMsg{parsed, formatted, ends, mostRecent}
-
 	}
 
 }
 
-// getLsof() runs the desired command and returns the output as a raw string or an error
-func getLsof() (string, error) {
-	// Set the command to use and get the output of that command (as well as any error codes we may encounter)
-	// Command is `lsof -i -Pn -F cPnpLTt`
-	cmd := exec.Command("lsof", "-i", "-Pn", "-F", "cPnpLTt")
-	out, err := cmd.Output()
+// rerender() re-filters the most recent collection once the settings have changed. Their generation goes up first, so a
+// collection (or rerender) that was already running with the old settings can't replace the table when it finishes.
+func (m *model) rerender() tea.Cmd {
+	m.settings.generation++
+	return catchPanics(rerenderProcesses(m.snapshot, m.settings, m.rowCache))
+}
 
-	if err != nil {
-		// There's an error, return an empty string & the error. We'll parse error code 1 (no processes found) later on.
-		return "", err
-	}
+// ---------------------------------------------------------------------------------------------------------------------
 
-	// We have valid data, so return it! The out variable is a byte array, so convert it to a string first.
-	return string(out), err
+// Working Directories
+// Backends don't find the working directory of each process, as it takes a command per process, so it's added
+// afterwards if the Directory column is shown
+
+// canGetCwd() checks if getCwd() can find working directories on this system
+func canGetCwd() bool {
+	return runtime.GOOS == "linux" || commandExists("lsof")
 }
 
-// getCwd() gets the working directory of a process from a PID
+// getCwd() gets the working directory of a process from a PID. On Linux it's where /proc/<pid>/cwd points, and
+// elsewhere lsof finds it. A process we aren't allowed to look inside of doesn't have one, rather than failing the
+// whole collection.
 func getCwd(pid int) (string, error) {
 	pidString := strconv.Itoa(pid)
-
-	// Gets the process' open files, including current working directory.
-	// Command is `ps -oexe= PID`
-	cmd := exec.Command("ps", "-oexe=", pidString)
-	out, err := cmd.Output()
-
-	if err != nil {
-		return "", err
+	if runtime.GOOS == "linux" {
+		cwd, _ := os.Readlink(filepath.Join("/proc", pidString, "cwd"))
+		return cwd, nil
 	}
 
-	if len(string(out)) < 1 {
-		// No cwd, return empty and nil
+	if !commandExists("lsof") {
 		return "", nil
 	}
 
-	return string(out), nil
+	// Command is `lsof -a -p PID -d cwd -Fn`, which prints p<pid>, then f<fd> and n<path> for the cwd
+	out, err := exec.Command("lsof", "-a", "-p", pidString, "-d", "cwd", "-Fn").Output()
+	if err != nil {
+		// lsof exits with 1 when it can't see the process, which just means there's no cwd to show
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
 
+	return parseLsofCwd(string(out)), nil
 }
 
-// parseLsof() takes the raw string output of lsof and converts it to a slice of process structs based on the parsing
-// criteria given to it in a settings struct
-func parseLsof(raw string, options settings) ([]process, error) {
-
-	// Input will be a string. Processes are separated by \np (newline, then 'p' character)
-	separated := strings.Split(raw, "\np")
-
-	// Create a new slice of processes
-	allProcesses := make([]process, 0)
-
-	// For each process
-	for processIndex, processString := range separated {
-		// Start by splitting process info by \np (newline, then 'p' character) to get each port open as a separate item
-		// in a slice, as well as the PID, command, and user in the 1st item in the array
-
-		connectionSplit := strings.Split(processString, "\nt")
-
-		// there will always be a 1st (index 0) element, which should contain the following lines:
-		// 88917 (process ID - no initial character as we split using that character earlier EXCEPT in process
-		// with index 0, as no newline before it)
-		// cProcessName
-		// LUserName
-
-		// So get that element, and split by newlines. Check if index is 0, and if so, handle first line to include the
-		// 'p' character
-
-		processInfo := strings.Split(connectionSplit[0], "\n")
+// parseLsofCwd() reads the working directory from lsof -Fn's output, which is the line starting with n
+func parseLsofCwd(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "n") {
+			return strings.TrimSuffix(line[1:], "\r")
+		}
+	}
+	return ""
+}
 
-		// Patch to prevent a crash when searching with no processes listed
-		if len(processInfo[0]) == 0 {
+// addDirectories() fills in the working directory of every process
+func addDirectories(processes []process) error {
+	for i := range processes {
+		if processes[i].Windows {
+			// Windows processes' directories can't be looked up from this side
 			continue
 		}
 
-		if processIndex == 0 {
-			// remove first character from the first line in processInfo if we're on index 0
-			processInfo[0] = processInfo[0][1:]
-		}
-		pid, err := strconv.Atoi(processInfo[0])
+		cwd, err := getCwd(processes[i].ID)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		processes[i].Directory = cwd
+	}
+	return nil
+}
 
-		finalCwd := ""
-		// We have the pid, so we can use that to get the CWD.
-		if options.getCwd {
-			cwd, err := getCwd(pid)
-			if err != nil {
-				return nil, err
-			}
-			finalCwd = cwd
+// portMatches() checks if a port is in a port filter, which can have ranges of ports (e.g. 9000-9100) and patterns
+// (e.g. 80*, see patterns.go) as well as single ports
+func portMatches(filter []string, port string) bool {
+	if port == "" {
+		return false
+	}
+	if slices.Contains(filter, port) {
+		return true
+	}
+	for _, spec := range filter {
+		if isPattern(spec) && patternMatches(spec, port, false) {
+			return true
 		}
+	}
 
-		cmd := processInfo[1][1:]
-
-		// Logic to check if filtering is matched
-		if
-		// If neither search nor nameFilter are enabled
-		(!(len(options.nameFilter) > 0) && (options.searchTerm == "")) ||
-			// If we have a valid name filter but no search term
-			((options.searchTerm == "") && slices.Contains(options.nameFilter, cmd)) ||
-			// if we have a valid search term and no filter
-			(!(len(options.nameFilter) > 0) && strings.Contains(cmd, options.searchTerm)) ||
-			// If we have a filter and search term and both are matched
-			(((len(options.nameFilter) > 0) && (options.searchTerm != "")) && strings.Contains(cmd, options.searchTerm) && slices.Contains(options.nameFilter, cmd)) {
-
-			user := processInfo[2][1:]
-
-			// Now onto handling ports and addresses. Looping through each one to parse it.
-			allConnections := make([]connection, 0)
-
-			// Ignore first element in array, as we've already parsed it
-			for _, connectionString := range connectionSplit[1:] {
-				valid := true // Store if the connection is valid based on the parsing settings
-
-				tmpConnection := connection{}
-
-				connectionInfo := strings.Split(connectionString, "\n")
-				// A port string will consist of a file descriptor (unused), a connection type (TCP or UDP), the
-				// information on the connection (localAddress:localPort->remoteAddress:remotePort), the connection status
-				// (established, listening, closed, or an empty field), and size of the read/send buffers (unused).
-
-				// Loop through each property in the connection info
-
-				// First property is always the IP Version
-				tmpConnection.ipv6 = connectionInfo[0] == "IPv6"
-				if !options.showIPv6 && tmpConnection.ipv6 {
-					valid = false
-					continue
-				}
-
-				if !options.showIPv4 && !tmpConnection.ipv6 {
-					valid = false
-					continue
-				}
-
-				for _, connectionProperty := range connectionInfo[1:] {
-					if len(connectionProperty) > 0 {
-
-						switch string(connectionProperty[0]) {
-						// Switch-case for each identifier (with an additional nested switch-case for the "T**= options)
-
-						case "n":
-							// n: Local and remote addresses and ports.
-							if connectionProperty[1:] == "*:*" {
-								// *:* usually indicates some unimportant connection, so we just make that connection invalid
-								// This might be wrong! If you want to submit an issue about this, then feel free!
-								valid = false
-
-							} else {
-
-								splitLocalAndRemote := strings.Split(connectionProperty[1:], "->")
-								// If there is a ->, then there is a clear local and remote connection
-
-								if len(splitLocalAndRemote) > 1 {
-									var splitLocalAddressAndPort []string
-									var splitRemoteAddressAndPort []string
-
-									if tmpConnection.ipv6 {
-										// IPv6 parsing algorithm
-										// Should only be 2 elements in that array: local and remote addr:port pairs
-										splitLocalAddressAndPort = strings.Split(splitLocalAndRemote[0][1:], "]:")
-										splitRemoteAddressAndPort = strings.Split(splitLocalAndRemote[1][1:], "]:")
-										if len(splitLocalAddressAndPort) < 2 {
-											splitLocalAddressAndPort = strings.Split(splitLocalAndRemote[0], ":")
-										} else {
-											splitLocalAddressAndPort[0] = "[" + splitLocalAddressAndPort[0] + "]"
-										}
-
-										if len(splitRemoteAddressAndPort) < 2 {
-											splitRemoteAddressAndPort = strings.Split(splitLocalAndRemote[1], ":")
-										} else {
-											splitRemoteAddressAndPort[0] = "[" + splitRemoteAddressAndPort[0] + "]"
-										}
-
-									} else {
-										// IPv4 parsing algorithm
-										// Should only be 2 elements in that array: local and remote addr:port pairs
-										splitLocalAddressAndPort = strings.Split(splitLocalAndRemote[0], ":")
-										splitRemoteAddressAndPort = strings.Split(splitLocalAndRemote[1], ":")
-									}
-
-									// Set the struct's data to the parsed output
-									tmpConnection.localAddress = splitLocalAddressAndPort[0]
-									tmpConnection.localPort = splitLocalAddressAndPort[1]
-									tmpConnection.remoteAddress = splitRemoteAddressAndPort[0]
-									tmpConnection.remotePort = splitRemoteAddressAndPort[1]
-
-									// outbound connection, so use remote port for friendly name
-									if _, exists := serviceNames[tmpConnection.remotePort]; exists {
-										tmpConnection.remoteName = serviceNames[tmpConnection.remotePort]
-									}
-
-								} else {
-									// if not, then assume we're looking at a local port and address
-									// Note here, that might be an incorrect assumption, please correct me if I'm wrong :)
-									var splitLocalAddressAndPort []string
-									if tmpConnection.ipv6 {
-										// IPv6 algorithm
-										splitLocalAddressAndPort = strings.Split(splitLocalAndRemote[0][1:], "]:")
-										if len(splitLocalAddressAndPort) < 2 {
-											splitLocalAddressAndPort = strings.Split(splitLocalAndRemote[0], ":")
-										} else {
-											splitLocalAddressAndPort[0] = "[" + splitLocalAddressAndPort[0] + "]"
-										}
-
-									} else {
-										// IPv4 algorithm
-										splitLocalAddressAndPort = strings.Split(splitLocalAndRemote[0], ":")
-									}
-									tmpConnection.localAddress = splitLocalAddressAndPort[0]
-
-									// As localPort is a string, we can handle ports like '*' without conversions.
-									tmpConnection.localPort = splitLocalAddressAndPort[1]
-
-									// friendly port name is local port as process is listening on this port
-									if _, exists := serviceNames[tmpConnection.localPort]; exists {
-										tmpConnection.localName = serviceNames[tmpConnection.localPort]
-									}
-
-								}
-							}
-
-							// Check validity with ports
-							// If we have ports to filter by
-							if len(options.portFilter) > 0 {
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	for _, spec := range filter {
+		if from, to, valid := parsePortRange(spec); valid && from <= number && number <= to {
+			return true
+		}
+	}
+	return false
+}
 
-								// If neither remote nor local ports are in the filter then it's invalid
-								if !(slices.Contains(options.portFilter, tmpConnection.localPort) ||
-									slices.Contains(options.portFilter, tmpConnection.remotePort)) {
-									valid = false
-								}
-							}
+// filterProcesses() takes every process from the backend and returns only the processes and connections that match
+// the filtering criteria given to it in a settings struct. Processes without any matching connections are removed.
+func filterProcesses(processes []process, options settings) []process {
+	filtered := make([]process, 0, len(processes))
 
-							break
+	for _, proc := range processes {
+		// Logic to check if filtering is matched. If there's a name filter, the name has to match one of it the way
+		// --match says (see patterns.go), and if there's a search term, the name has to contain it.
+		if len(options.nameFilter) > 0 && !nameMatches(options.nameFilter, proc.Name, options.match) {
+			continue
+		}
+		if options.searchTerm != "" && !strings.Contains(proc.Name, options.searchTerm) {
+			continue
+		}
+		if options.childrenOf != "" && !slices.Contains(options.children, proc.ID) {
+			continue
+		}
+		if options.hideSelf && options.self[proc.ID] {
+			continue
+		}
 
-						case "T":
-							if connectionProperty[0:4] == "TST=" {
-								// TST= : Connection status
-								tmpConnection.status = strings.ToTitle(connectionProperty[4:])
+		connections := make([]connection, 0, len(proc.Connections))
 
-								// If the port isn't closed OR we have enabled closed ports
-								if connectionProperty[4:] == "CLOSED" && options.showClosed {
-									valid = false
-								}
-								if options.listenOnly && connectionProperty[4:] != "LISTEN" {
-									valid = false
-								}
-							}
-							break
+		for _, conn := range proc.Connections {
+			if !options.showIPv6 && conn.IPv6 {
+				continue
+			}
+			if !options.showIPv4 && !conn.IPv6 {
+				continue
+			}
+			if (!options.showTCP && conn.Protocol == "TCP") || (!options.showUDP && conn.Protocol == "UDP") {
+				continue
+			}
 
-						case "P":
-							// Get protocol
-							tmpConnection.protocol = connectionProperty[1:]
-							break
-						}
-					}
+			// If we have ports to filter by, and neither remote nor local ports are in the filter, then skip it
+			if len(options.portFilter) > 0 &&
+				!(portMatches(options.portFilter, conn.LocalPort) || portMatches(options.portFilter, conn.RemotePort)) {
+				continue
+			}
 
-				}
+			// Sockets bound to every interface can be reached on any of them, so they're kept
+			if len(options.interfaceFilter) > 0 && !onInterface(conn, options.interfaceFilter) {
+				continue
+			}
+			if len(options.hostFilter) > 0 && !atHost(conn, options.hostFilter) {
+				continue
+			}
 
-				// That connection has been parsed! Time to add it to the slice.
-				if valid {
-					allConnections = append(allConnections, tmpConnection) // Add the connection to the slice
-				}
+			if len(options.filters) > 0 && !matchesFilters(options.filters, proc, conn) {
+				continue
+			}
+			if excluded(proc, conn, options) {
+				continue
 			}
 
-			// All elements of a process have now been parsed, so create a new process struct with that information and
-			// append it to the allProcesses slice.
-
-			// If the process still has a valid connection in it.
-			if len(allConnections) > 0 {
-				// Then add it to the slice
-				allProcesses = append(allProcesses, process{
-					id:          pid,
-					name:        cmd,
-					username:    user,
-					directory:   finalCwd,
-					connections: allConnections,
-				})
+			// Skip the port if it's closed, unless we have enabled closed ports
+			if conn.Status == "CLOSED" && !options.showClosed {
+				continue
+			}
+			if options.listenOnly && !isListening(conn) {
+				continue
 			}
 
+			connections = append(connections, conn)
+		}
+
+		// If the process still has a valid connection in it, then add it to the slice
+		if len(connections) > 0 {
+			proc.Connections = connections
+			filtered = append(filtered, proc)
 		}
 	}
 
-	// Gone through all processes, so now return the final slice of process structs
-	return allProcesses, nil
+	return filtered
 }
 
-// formatLsof() takes the slice of process structs given and converts to the table rows that get rendered
-func formatLsof(processes []process, options settings) ([]table.Row, []int, error) {
-	// Loop through each process, and create a row based on the columns we have, then add that to a row slice
-	var rows []table.Row
-	var rowStarts []int
-
-	for _, proc := range processes {
-		rowStarts = append(rowStarts, len(rows))
-
-		for connIndex, conn := range proc.connections {
-
-			row := make(table.Row, len(options.columns))
-
-			// Loop through each column in options.columns and use a switch-case on its title to get the value to set at its index
-			for columnIndex, column := range options.columns {
-
-				value := ""
-
-				switch column.Title {
-
-				case "PID":
-					if connIndex == 0 {
-						value = strconv.Itoa(proc.id)
-					}
-					break
+// protocolsLabel() says which protocols are being shown, e.g. TCP+UDP
+func protocolsLabel(options settings) string {
+	switch {
+	case options.showTCP && !options.showUDP:
+		return "TCP"
+	case options.showUDP && !options.showTCP:
+		return "UDP"
+	}
+	return "TCP+UDP"
+}
 
-				case "Name":
-					if connIndex == 0 {
-						value = proc.name
-					}
-					break
+// cycleProtocols() switches between showing both protocols, only TCP, and only UDP
+func (m *model) cycleProtocols() {
+	switch protocolsLabel(m.settings) {
+	case "TCP+UDP":
+		m.settings.showTCP, m.settings.showUDP = true, false
+	case "TCP":
+		m.settings.showTCP, m.settings.showUDP = false, true
+	default:
+		m.settings.showTCP, m.settings.showUDP = true, true
+	}
 
-				case "Directory":
-					if connIndex == 0 {
-						value = proc.directory
-					}
-					break
+	label := protocolsLabel(m.settings)
+	m.keys.Protocols.SetHelp("P", tr("help.protocols", label))
+	m.announce(tr("plain.protocols", label))
+}
 
-				case "Owner":
-					if connIndex == 0 {
-						value = proc.username
-					}
-					break
-
-				case "Protocol":
-					value = conn.protocol
-					break
-
-				case "Address":
-					// If there is a remote address, use that
-					if conn.remoteAddress != "" {
-						value = conn.remoteAddress
-					} else {
-						// If not, then use local address
-						value = conn.localAddress
-					}
-					break
-
-				case "Port":
-					if conn.remoteAddress != "" {
-						if options.serviceNames && conn.remoteName != "" {
-							value = conn.remoteName
-						} else {
-							value = conn.remotePort
-						}
-					} else {
-						// If not, then use local address
-						if options.serviceNames && conn.localName != "" {
-							value = conn.localName
-						} else {
-							value = conn.localPort
-						}
-					}
-					break
-
-				case "Local Address":
-					value = conn.localAddress
-					break
-				case "Local Port":
-					if options.serviceNames && conn.localName != "" {
-						value = conn.localName
-					} else {
-						value = conn.localPort
-					}
-					break
-				case "Remote Address":
-					value = conn.remoteAddress
-					break
-				case "Remote Port":
-					if options.serviceNames && conn.remoteName != "" {
-						value = conn.remoteName
-					} else {
-						value = conn.remotePort
-					}
-					break
+// formatLsofIncremental() works like lsof.Format(), but reuses the rows from the previous refresh for any process that
+// hasn't changed since then. It returns the new row cache, and whether the rows are identical to the previous refresh.
+func formatLsofIncremental(processes []process, previous rowCache, options settings) ([]table.Row, []int, rowCache, bool) {
+	var rows []table.Row
+	var rowStarts []int
+	cache := make(rowCache, len(processes))
 
-				case "Status":
-					value = strings.ToTitle(conn.status)
-					break
+	// If the number of processes is different, then something must have changed
+	unchanged := previous != nil && len(previous) == len(processes)
+	now := options.currentTime()
 
-				}
-				row[columnIndex] = value
+	for i, proc := range processes {
+		rowStarts = append(rowStarts, len(rows))
 
+		cached, exists := previous[proc.ID]
+		ages := connectionAges(proc, options, now)
+		peers := connectionPeers(proc, options)
+		if exists && cached.proc.Equal(proc) {
+			// The same processes in a different order still need the table updating, or its rows and m.processes would
+			// disagree about which process each row is
+			if cached.index != i {
+				cached.index = i
+				unchanged = false
+			}
+			// Nothing has changed for this process, so reuse the rows we built last time. Its CPU, memory, ages and
+			// peers change far more often than its sockets, so they're filled into a copy rather than starting again.
+			usage := options.resources[proc.ID]
+			if (options.showResources && usage != cached.usage) || !slices.Equal(ages, cached.ages) ||
+				!slices.Equal(peers, cached.peers) {
+				cached.rows = withLiveColumns(cached.rows, proc, ages, peers, options)
+				cached.usage, cached.ages, cached.peers = usage, ages, peers
+				unchanged = false
 			}
-			rows = append(rows, row)
+			rows = append(rows, collapseRows(cached.rows, proc, options)...)
+			cache[proc.ID] = cached
+			continue
+		}
+
+		// New or changed process, so format it from scratch
+		unchanged = false
+		procRows, _ := lsof.Format([]process{proc}, options.columns, options.serviceNames)
+		fillPluginColumns(procRows, proc, options)
+		fillKubernetesColumns(procRows, proc, options)
+		fillExposedColumn(procRows, proc, options)
+		fillPrivilegeColumn(procRows, proc, options)
+		fillGuessColumn(procRows, proc, options)
+		fillInterfaceColumn(procRows, proc, options)
+		fillHostNames(procRows, proc, options)
+		fillAddressDisplay(procRows, proc, options)
+		fillNetNamespaceColumn(procRows, proc, options)
+		fillServiceSocketNames(procRows, proc, options)
+		fillNiceColumn(procRows, proc, options)
+		fillProjectColumn(procRows, proc, options)
+		fillFrameworkColumn(procRows, proc, options)
+		fillResourceColumns(procRows, proc, options)
+		fillAgeColumn(procRows, ages, options)
+		fillPeerColumn(procRows, peers, options)
+		fillProcessColumns(procRows, options)
+		fillStoppedBadge(procRows, proc, options)
+		fillTerminalBadge(procRows, proc, options)
+		fillHighlightBadges(procRows, proc, options)
+		rows = append(rows, collapseRows(procRows, proc, options)...)
+		cache[proc.ID] = cachedProcess{
+			proc: proc, rows: procRows, usage: options.resources[proc.ID], ages: ages, peers: peers, index: i,
 		}
 	}
-	return rows, rowStarts, nil
+
+	return rows, rowStarts, cache, unchanged
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-// Func to create a command that will terminate a given process ID
-func terminateProcess(id int) tea.Cmd {
+// Func to create a command that will terminate a given process. Windows processes (under WSL) have to be terminated
+// from the Windows side, as their PIDs aren't Linux PIDs.
+func terminateProcess(proc process, collectedAt time.Time) tea.Cmd {
 	return func() tea.Msg {
-		pid := id
-		cmd := exec.Command("kill", strconv.Itoa(pid))
+		// Make sure the PID hasn't been taken by something else since the process was collected
+		if err := checkSameProcess(proc, collectedAt); err != nil {
+			return errMsg{err}
+		}
 
-		// Terminate the process with that ID. Don't care about the output, so just ignore it
-		err := cmd.Run()
+		// Terminate the process with that ID
+		var err error
+		if proc.Windows {
+			err = pvw.KillWindows(proc.ID)
+		} else {
+			err = pvw.Kill(proc.ID)
+		}
 
 		if err != nil {
-			return errMsg{err}
+			return exitedError(proc, err)
 		}
 		return terminateMsg{}
 	}
@@ -757,7 +1101,70 @@ func terminateProcess(id int) tea.Cmd {
 
 func (m model) Init() tea.Cmd {
 	// When we first run, we want to get all the processes currently running
-	return checkProcesses(m.settings)
+	cmds := []tea.Cmd{requestRefresh}
+	if m.settings.interval > 0 {
+		// Also start ticking if we're in watch mode
+		cmds = append(cmds, tick(m.settings.interval))
+	}
+	if m.settings.checkUpdates {
+		cmds = append(cmds, checkForUpdate)
+	}
+	if m.tracer != nil {
+		cmds = append(cmds, waitForEvent(m.tracer.events))
+	}
+	return tea.Batch(cmds...)
+}
+
+// requestRefresh is a command that asks Update to refresh the processes. Init can't change the model, so it uses this
+// to make sure the first collection is tracked like any other.
+func requestRefresh() tea.Msg {
+	return refreshMsg{}
+}
+
+// refresh() starts collecting the processes again, unless a collection is already running. In that case, another
+// refresh is queued up to run once it finishes, so that hammering refresh (or a short interval) can't stack up lsof
+// processes.
+func (m *model) refresh() tea.Cmd {
+	if m.collecting {
+		m.refreshQueued = true
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.settings.timeout)
+	m.collecting = true
+	m.cancelCollect = cancel
+
+	// The first collection fills the table in as it goes, if the backend can. Plain mode would print every partial
+	// result, so it waits for the whole thing.
+	if _, isProgressive := progressiveCollector(m.settings.backend); isProgressive && m.snapshot == nil && m.output == nil {
+		m.partials = make(chan processesMsg, 1)
+		return tea.Batch(catchPanics(checkProcesses(ctx, cancel, m.settings, m.rowCache, m.partials)), waitForPartial(m.partials))
+	}
+	return catchPanics(checkProcesses(ctx, cancel, m.settings, m.rowCache, nil))
+}
+
+// collectionDone() marks the running collection as finished, and starts the queued refresh if there is one
+func (m *model) collectionDone() tea.Cmd {
+	m.collecting = false
+	m.cancelCollect = nil
+	m.partials = nil
+
+	if m.refreshQueued {
+		m.refreshQueued = false
+		return m.refresh()
+	}
+	return nil
+}
+
+// after() returns a command that sends a message once a delay has passed. It's tea.Tick, but the tests swap it for one
+// that never sends anything, as they don't wait around for timers.
+var after = tea.Tick
+
+// tick() returns a command that sends a tickMsg after the refresh interval has passed
+func tick(interval time.Duration) tea.Cmd {
+	return after(interval, func(time.Time) tea.Msg {
+		return tickMsg{}
+	})
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -769,26 +1176,289 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case processesMsg:
+		if msg.partial {
+			// A partial result can turn up after the whole collection has, in which case it's out of date
+			if m.partials == nil {
+				return m, nil
+			}
+			cmd = waitForPartial(m.partials)
+		}
+		// A rerender with old settings has already been replaced by a newer one, and so has a partial result
+		if msg.generation != m.settings.generation && !msg.collected {
+			return m, cmd
+		}
+
 		// We have processes, lets update the model to use the new processes
+		m.snapshot = msg.all
+		m.rowCache = msg.cache
+		if !msg.collectedAt.IsZero() {
+			m.collectedAt = msg.collectedAt
+		}
+
+		if msg.collected {
+			m.settings.self = msg.self
+			m.settings.onTerminal = msg.onTerminal
+			m.settings.resources = msg.resources
+			m.settings.ages = msg.ages
+			m.settings.peers = msg.peers
+			m.settings.sharedPorts = msg.sharedPorts
+			m.settings.reusedPorts = msg.reusedPorts
+			m.warnings = msg.warnings
+			running := withoutExited(msg.processes, msg.cache)
+			m.recordConnectionCount(running)
+			m.logChanges(running)
+			bell := m.checkAlerts(running)
+			m.fitTable()
+			cmd = tea.Batch(m.collectionDone(), bell)
+			if m.settings.title {
+				cmd = tea.Batch(cmd, updateTitle(running))
+			}
+		}
+
+		if msg.generation != m.settings.generation {
+			// The settings changed while this was being collected, so its rows were picked by the old ones. What it
+			// collected is still the latest, so it's filtered again with the new ones instead.
+			return m, tea.Batch(cmd, catchPanics(rerenderProcesses(m.snapshot, m.settings, m.rowCache)))
+		}
+
+		if msg.unchanged {
+			// Nothing's different, so leave the table (and the cursor) alone to prevent flickering
+			m.processes = msg.processes
+			m.rowStarts = msg.ends
+			m.refreshDashboard()
+			m.refreshWaits()
+			m.refreshForwards()
+			m.refreshTunnels()
+			m.refreshEvents()
+			return m, cmd
+		}
+
+		// Remember which connection was selected, so we can select it again once the rows have moved around
+		selected, hasSelection := m.selectedKey()
+
 		m.table.SetRows(msg.rows) // Convert the array of process structs to text for use in rendering
-		m.rowStarts = msg.ends    // The starts of each process's set of rows
+		m.rows = msg.rows
+		m.rowStarts = msg.ends // The starts of each process's set of rows
 		m.processes = msg.processes
-		m.lsofOut = msg.raw
+		m.trackTop()
+		m.fitColumns()
+		m.truncated = anyTruncated(m.columnWidths(), m.rows)
+		m.fitTable()
+
+		if hasSelection {
+			if row, exists := m.findRow(selected); exists {
+				m.moveCursor(row)
+			}
+		}
+
+		m.announceRows()
+		m.refreshDashboard()
+		m.refreshWaits()
+		m.refreshForwards()
+		m.refreshTunnels()
+		m.refreshEvents()
+		return m, cmd
+
+	case refreshMsg:
+		return m, m.refresh()
+
+	case updateMsg:
+		// There's a newer release (see update.go)
+		notice := tr("notice.update", msg.latest.Tag)
+		m.notices = append(m.notices, notice)
+		m.announce(notice)
+		m.fitTable()
 		return m, nil
 
+	case tickMsg:
+		// Time for the next refresh in watch mode. Queue up the next tick too. When replaying, this plays the recording.
+		if _, isReplay := m.replaying(); isReplay {
+			return m, tea.Batch(m.stepReplay(1), tick(m.settings.interval))
+		}
+		if m.socketsUnchanged() {
+			// No sockets have opened or closed, so there's nothing new for the backend to find
+			return m, tick(m.settings.interval)
+		}
+		return m, tea.Batch(m.refresh(), tick(m.settings.interval))
+
 	case terminateMsg:
 		// terminate process worked, so rerender processes table
-		return m, checkProcesses(m.settings)
+		return m, m.refresh()
+
+	case stopMsg:
+		// The process' state is checked on every refresh, so the badge changes once it's refreshed
+		return m, m.refresh()
+
+	case closeConnectionMsg:
+		// The connection's gone, so refresh to take its row away
+		return m, m.refresh()
+
+	case reniceMsg:
+		// The process' sockets haven't changed, so its rows have to be forgotten for the new nice value to show up
+		m.forgetRows(msg.pid)
+		return m, m.refresh()
+
+	case detailMsg:
+		m.openDetail(msg)
+		return m, nil
+
+	case tunnelsMsg:
+		m.openTunnels(msg.tunnels)
+		return m, nil
+
+	case tunnelMsg:
+		// The new ssh has been started, so refresh to show its ports
+		return m, m.refresh()
+
+	case serviceSocketsMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			m.announce(tr("plain.error", m.err))
+			m.fitTable()
+			return m, nil
+		}
+		m.openServiceSockets(msg.sockets)
+		return m, nil
+
+	case netTopMsg:
+		if msg.err != nil && msg.generation == m.netTopGeneration {
+			m.err = msg.err
+			m.announce(tr("plain.error", m.err))
+			m.fitTable()
+			return m, nil
+		}
+		return m, m.updateNetTop(msg)
+
+	case connectionEventMsg:
+		m.logEvents(msg.event)
+		m.refreshEvents()
+		m.announce(formatEvent(msg.event))
+		return m, waitForEvent(m.tracer.events)
+
+	case tracerStoppedMsg:
+		// bpftrace is only asked to stop once pvw has quit, so it's stopped by itself, and the log carries on without it
+		m.tracer = nil
+		if msg.err != nil {
+			m.err = msg.err
+			m.announce(tr("plain.error", m.err))
+			m.fitTable()
+		}
+		m.refreshEvents()
+		return m, nil
+
+	case serviceSocketMsg:
+		// The unit's been started or stopped, so its port has come or gone, and its state has changed
+		return m, tea.Batch(m.refresh(), showServiceSockets(m.snapshot))
 
 	case errMsg:
 		m.err = msg.err
+		m.announce(tr("plain.error", m.err))
+		m.fitTable()
 		return m, nil
 
+	case exitedMsg:
+		// The process went before the action could run, which isn't worth a raw error
+		m.err = errors.New(tr("error.exited", processLabel(msg.proc)))
+		m.announce(tr("plain.error", m.err))
+		m.fitTable()
+		return m, m.refresh()
+
+	case collectErrMsg:
+		m.err = msg.err
+		m.announce(tr("plain.error", m.err))
+		m.fitTable()
+		return m, m.collectionDone()
+
+	case panicMsg:
+		// Something went badly wrong, so quit and let main() print what happened once the terminal is restored
+		m.crash = &msg
+		if m.cancelCollect != nil {
+			m.cancelCollect()
+		}
+		return m, tea.Quit
+
 	case tea.WindowSizeMsg:
 		m.help.Width = msg.Width
+		m.windowHeight = msg.Height
+		m.fitTable()
 
 	case tea.KeyMsg:
-		if m.settings.displaySearch {
+		if m.promptDone != nil {
+			// A prompt is open, so keys go to it
+			return m.updatePrompt(msg)
+
+		} else if m.showFilter {
+			// The filter bar is open, so keys go to it
+			return m.updateFilterBar(msg)
+
+		} else if m.showKeyHelp {
+			// The help overlay is open, so keys search it
+			return m.updateKeyHelp(msg)
+
+		} else if m.showDetail {
+			// The detail pane is open, so keys scroll it rather than move around the table
+			switch {
+			case key.Matches(msg, keys.Escape):
+				m.closeDetail()
+				return m, nil
+
+			case key.Matches(msg, keys.Reveal) && m.detailProcess != nil:
+				// Show or hide the secrets in the process' environment
+				return m, catchPanics(showDetails(*m.detailProcess, m.detailConnection, m.settings, !m.revealSecrets))
+
+			case key.Matches(msg, keys.Children) && m.detailProcess != nil:
+				// Filter the table down to the process' children
+				return m, m.showChildren(*m.detailProcess)
+
+			case m.tunnelsOpen() && key.Matches(msg, keys.Up):
+				m.moveTunnelCursor(-1)
+				return m, nil
+
+			case m.tunnelsOpen() && key.Matches(msg, keys.Down):
+				m.moveTunnelCursor(1)
+				return m, nil
+
+			case m.tunnelsOpen() && key.Matches(msg, keys.Terminate):
+				// Close the selected tunnel
+				return m, m.closeTunnel()
+
+			case m.tunnelsOpen() && key.Matches(msg, keys.Reestablish):
+				// Start the selected tunnel again
+				if tunnel, exists := m.selectedTunnel(); exists {
+					return m, reestablishTunnel(tunnel, m.collectedAt)
+				}
+				return m, nil
+
+			case m.serviceSocketsOpen() && key.Matches(msg, keys.Up):
+				m.moveSocketCursor(-1)
+				return m, nil
+
+			case m.serviceSocketsOpen() && key.Matches(msg, keys.Down):
+				m.moveSocketCursor(1)
+				return m, nil
+
+			case m.serviceSocketsOpen() && key.Matches(msg, keys.Stop):
+				// Stop the selected socket unit, freeing its port
+				return m, m.stopServiceSocket()
+
+			case m.serviceSocketsOpen() && key.Matches(msg, keys.Continue):
+				// Start the selected socket unit again
+				if socket, exists := m.selectedServiceSocket(); exists && !m.settings.readOnly {
+					return m, controlServiceSocket(socket, "start")
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Quit):
+				if m.cancelCollect != nil {
+					m.cancelCollect()
+				}
+				return m, tea.Quit
+
+			default:
+				return m.updateDetail(msg)
+			}
+
+		} else if m.settings.displaySearch {
 			// Ignore other keys if in search mode
 			switch {
 			case key.Matches(msg, keys.Search):
@@ -797,7 +1467,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				m.settings.displaySearch = !m.settings.displaySearch
 
-				return m, rerenderProcesses(m.lsofOut, m.settings)
+				return m, m.rerender()
 
 			case key.Matches(msg, keys.Escape):
 				m.textInput.Blur()
@@ -805,19 +1475,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				m.settings.displaySearch = false
 
-				return m, rerenderProcesses(m.lsofOut, m.settings)
+				return m, m.rerender()
 
 			default:
 				m.textInput, cmd = m.textInput.Update(msg)
 				m.settings.searchTerm = m.textInput.Value()
-				return m, rerenderProcesses(m.lsofOut, m.settings)
+				return m, m.rerender()
 
 			}
 
 		} else {
+			m.learnTip(msg)
+
 			switch {
 			case key.Matches(msg, m.keys.Refresh):
-				return m, checkProcesses(m.settings)
+				return m, m.refresh()
 
 			case key.Matches(msg, keys.Terminate):
 				// If the read-only option is not enabled
@@ -825,17 +1497,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// If there are any processes left:
 					if len(m.processes) > 0 {
 						// Get the id of the currently highlighted process and terminate that process
-						cursor := m.table.Cursor()
-
 						// Use the start of each process' set of rows to get the PID to kill.
-						i := 0
-						for i < (len(m.processes)) {
-							if i >= cursor {
-								// We now have the index of the process in m.processes that we need the PID from stored in variable 'i'
-								return m, terminateProcess(m.processes[i].id)
+						if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+							if m.processes[processIndex].ID == 0 {
+								// We don't know which process this is, so there's nothing to terminate
+								m.err = errors.New(tr("error.unknown-process"))
+								return m, nil
 							}
-
-							i += 1
+							proc := m.processes[processIndex]
+							if reason := protectedReason(proc, m.settings); reason != "" {
+								return m, m.confirmProtected(proc, reason)
+							}
+							if m.settings.confirmTerminate || m.settings.onTerminal[proc.ID] {
+								label := tr("prompt.terminate", proc.Name, proc.ID)
+								if m.settings.onTerminal[proc.ID] {
+									// It's in our terminal, so terminating it might take more than it looks like it will
+									label = tr("prompt.terminal", proc.Name, proc.ID)
+								}
+								m.openPrompt(label, "", func(answer string) tea.Cmd {
+									if !strings.HasPrefix(strings.ToLower(answer), "y") {
+										return nil
+									}
+									return terminateProcess(proc, m.collectedAt)
+								})
+								return m, textinput.Blink
+							}
+							return m, catchPanics(terminateProcess(proc, m.collectedAt))
 						}
 						// If it breaks, do nothing
 						return m, nil
@@ -845,18 +1532,251 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
+			case key.Matches(msg, keys.Close):
+				// Close the selected connection, leaving its process running
+				if m.settings.readOnly {
+					return m, nil
+				}
+				return m, m.closeSelectedConnection()
+
 			case key.Matches(msg, keys.Help):
-				m.help.ShowAll = !m.help.ShowAll
-				return m, nil
+				// Plain mode doesn't draw the overlay, so the keys are written out instead
+				if m.output != nil {
+					m.announceHelp()
+					return m, nil
+				}
+				m.openKeyHelp()
+				return m, textinput.Blink
+
+			case key.Matches(msg, keys.Filter):
+				m.openFilterBar()
+				return m, textinput.Blink
+
+			case key.Matches(msg, keys.Unfilter):
+				return m, m.removeLastFilter()
 
 			case key.Matches(msg, keys.Search):
 				m.textInput.Focus()
 				m.table.Blur()
 				m.settings.displaySearch = true
+				m.announce(tr("plain.search"))
+
+				return m, nil
+
+			case key.Matches(msg, keys.DescribePod):
+				// Show `kubectl describe pod` for the pod the selected process is in
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					return m, catchPanics(describePod(m.processes[processIndex].ID))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.PreviousFrame):
+				return m, m.stepReplay(-1)
+
+			case key.Matches(msg, keys.NextFrame):
+				return m, m.stepReplay(1)
+
+			case key.Matches(msg, keys.FirstFrame):
+				return m, m.stepReplay(math.MinInt)
+
+			case key.Matches(msg, keys.LastFrame):
+				return m, m.stepReplay(math.MaxInt)
+
+			case key.Matches(msg, keys.Toggle), key.Matches(msg, keys.Collapse), key.Matches(msg, keys.Expand):
+				// Collapse the selected process to a single row, or expand it back out
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					pid := m.processes[processIndex].ID
+					collapse := key.Matches(msg, keys.Collapse) ||
+						(key.Matches(msg, keys.Toggle) && !m.settings.collapsed[pid])
+					m.setCollapsed(pid, collapse)
+					return m, m.rerender()
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Children):
+				// Filter the table down to the selected process' children
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					return m, m.showChildren(m.processes[processIndex])
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Escape) && m.settings.childrenOf != "":
+				// Go back to showing every process
+				return m, m.showAllProcesses()
+
+			case key.Matches(msg, keys.Info):
+				// Show everything about the selected process, including its environment, and how the selected
+				// connection is doing
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					proc := m.processes[processIndex]
+					var conn *connection
+					// A collapsed process' row doesn't belong to any connection in particular
+					if !m.settings.collapsed[proc.ID] || len(proc.Connections) == 1 {
+						conn = &proc.Connections[connectionIndex]
+					}
+					return m, catchPanics(showDetails(proc, conn, m.settings, false))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Renice):
+				// Ask for a new nice value for the selected process
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					return m, m.askRenice(m.processes[processIndex])
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Stop), key.Matches(msg, keys.Continue):
+				// Pause or resume the selected process
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					return m, catchPanics(stopProcess(m.processes[processIndex], key.Matches(msg, keys.Stop), m.collectedAt))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Multicast):
+				// Show the multicast groups each interface has joined, and the sockets taking part in discovery
+				return m, catchPanics(showMulticast(m.processes))
+
+			case key.Matches(msg, keys.Dashboard):
+				// Summarise the connections by state, process and remote host
+				return m, catchPanics(showDashboard(m.processes, m.connectionCounts))
 
+			case key.Matches(msg, keys.NetTop):
+				// Rank the processes by how fast their connections are going
+				measure := m.openNetTop()
+				return m, catchPanics(measure)
+
+			case key.Matches(msg, keys.Events):
+				// Show the connections that have opened and closed
+				m.showEvents()
+				return m, nil
+
+			case key.Matches(msg, keys.Waits):
+				// Explain the connections stuck in CLOSE_WAIT or TIME_WAIT
+				return m, catchPanics(showWaits(m.processes, m.settings.ages, m.settings.currentTime()))
+
+			case key.Matches(msg, keys.Forwards):
+				// List where each port forwarder's ports go
+				return m, catchPanics(showForwards(m.processes))
+
+			case key.Matches(msg, keys.Tunnels):
+				// List the ssh tunnels, whether or not they're in the table
+				return m, catchPanics(showTunnels(m.snapshot))
+
+			case key.Matches(msg, keys.Sockets):
+				// List the sockets the service manager is holding for its services
+				return m, catchPanics(showServiceSockets(m.snapshot))
+
+			case key.Matches(msg, keys.Capture):
+				// Watch the selected connection's packets
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					if connections := m.processes[processIndex].Connections; len(connections) > 0 {
+						return m, handOff(captureCommand(connections[connectionIndex]))
+					}
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Record):
+				// Show the raw record behind the selected row
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					if proc := m.processes[processIndex]; len(proc.Connections) > 0 {
+						return m, catchPanics(showRecord(proc, proc.Connections[connectionIndex]))
+					}
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Peer):
+				// Go to the other end of the selected connection, if it's a local process
+				return m, m.jumpToPeer()
+
+			case key.Matches(msg, keys.Trace):
+				// Watch the selected process' network system calls
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					return m, traceProcess(m.processes[processIndex])
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.ProbeTLS):
+				// Connect to the selected port and show its certificate
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					proc := m.processes[processIndex]
+					return m, catchPanics(ifRunning(proc, probeTLS(proc.Connections[connectionIndex])))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.ProbeHTTP):
+				// Send a request to the selected port and show the response
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					proc := m.processes[processIndex]
+					return m, catchPanics(ifRunning(proc, probeHTTP(proc.Connections[connectionIndex])))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Open):
+				// Open the selected port in the browser, if it speaks HTTP
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					proc := m.processes[processIndex]
+					return m, catchPanics(ifRunning(proc, openInBrowser(proc.Connections[connectionIndex])))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.OpenDir):
+				// Ask where to open the selected process' working directory
+				if processIndex, _, exists := m.rowLocation(m.table.Cursor()); exists {
+					return m, m.askOpenDirectory(m.processes[processIndex])
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Latency):
+				// Time how long it takes to reach the other end of the selected connection
+				if processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor()); exists {
+					proc := m.processes[processIndex]
+					return m, catchPanics(ifRunning(proc, probeLatency(proc.Connections[connectionIndex])))
+				}
+				return m, nil
+
+			case key.Matches(msg, keys.Protocols):
+				m.cycleProtocols()
+				return m, m.rerender()
+
+			case key.Matches(msg, keys.Self):
+				m.toggleSelf()
+				return m, m.rerender()
+
+			case key.Matches(msg, keys.Sort), key.Matches(msg, keys.Top):
+				if key.Matches(msg, keys.Top) {
+					m.setSort("cpu")
+					m.table.GotoTop()
+				} else {
+					m.cycleSort()
+				}
+				// Resources are only collected when they're needed, so the first sort has to collect them
+				if m.settings.resources == nil && sortNeedsResources(m.settings.sortBy) {
+					return m, m.refresh()
+				}
+				return m, m.rerender()
+
+			case key.Matches(msg, m.keys.DismissTips):
+				return m, m.dismissTips()
+
+			case key.Matches(msg, m.keys.Actions...):
+				// Run the action for the connection under the cursor
+				processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor())
+				if !exists {
+					return m, nil
+				}
+				for i, binding := range m.keys.Actions {
+					if key.Matches(msg, binding) {
+						proc := m.processes[processIndex]
+						return m, runAction(m.settings.actions[i], proc, proc.Connections[connectionIndex])
+					}
+				}
 				return m, nil
 
 			case key.Matches(msg, keys.Quit):
+				// Don't leave lsof running after we've gone
+				if m.cancelCollect != nil {
+					m.cancelCollect()
+				}
 				return m, tea.Quit
 
 			}
@@ -864,61 +1784,378 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	}
 
+	cursor := m.table.Cursor()
 	m.table, cmd = m.table.Update(msg)
+	m.trackTop()
+
+	if m.table.Cursor() != cursor {
+		m.announceSelection()
+	}
 	return m, cmd
 }
 
 func (m model) View() string {
+	// Nothing is drawn in plain mode, as everything gets written out by announce() instead
+	if m.output != nil {
+		return ""
+	}
+
+	if m.showKeyHelp {
+		return m.keyHelpView()
+	}
 
 	var final string
-	final += baseStyle.Render(m.table.View()) + "\n"
+	if m.showDetail {
+		final += m.settings.frameStyle().Render(m.detailView()) + "\n"
+	} else if empty := m.emptyLines(); empty != nil {
+		final += m.settings.frameStyle().Render(m.emptyView(empty)) + "\n"
+	} else {
+		final += m.settings.frameStyle().Render(m.highlightRows(m.dimExitedRows(m.tableView()))) + "\n"
+	}
+
+	for _, line := range m.alertLines() {
+		final += line + "\n"
+	}
+
+	for _, notice := range m.shownNotices() {
+		final += noticeStyle.Render(notice) + "\n"
+	}
+
+	if len(m.warnings) > 0 {
+		final += noticeStyle.Render(tr("notice.skipped-records", len(m.warnings), m.settings.backend.name())) + "\n"
+	}
 
 	if m.err != nil {
 		final += m.err.Error() + "\n"
 	}
 
-	final += m.textInput.View()
+	if line, shown := m.truncatedLine(); shown {
+		final += line + "\n"
+	}
+
+	if chips := m.filterChips(); chips != "" {
+		final += chips + "\n"
+	}
+
+	if tip := m.currentTip(); tip != "" {
+		final += noticeStyle.Render(tip) + "\n"
+	}
+
+	if m.promptDone != nil {
+		final += m.prompt.View()
+	} else if m.showFilter {
+		final += m.filterInput.View()
+	} else {
+		final += m.textInput.View()
+		if spark := m.sparklineView(); spark != "" {
+			final += "   " + spark
+		}
+	}
 
+	// Pad out the gap above the help, so the help stays in the same place as the notices, errors and help change
 	helpView := m.help.View(m.keys)
-	height := 17 - strings.Count(final, "\n") - strings.Count(helpView, "\n")
+	height := m.chromeHeight() + m.table.Height() - 2 - strings.Count(final, "\n") - strings.Count(helpView, "\n")
+	if height < 0 {
+		height = 0
+	}
 
 	return "\n" + final + strings.Repeat("\n", height) + helpView
 
 }
 
 func main() {
+	// The flag descriptions are translated, so work out the language first. --lang takes priority over $LANG.
+	language := languageFromArgs(os.Args[1:])
+	if language == "" {
+		language = languageFromEnvironment()
+	}
+	if err := setLanguage(language); err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		os.Exit(exitError)
+	}
+	keys = newKeyMap()
+
+	// Subcommands don't start the TUI
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout))
+		case "free":
+			os.Exit(runFree(os.Args[2:], os.Stdout))
+		case "multicast":
+			os.Exit(runMulticast(os.Args[2:], os.Stdout))
+		case "listen":
+			os.Exit(runListen(os.Args[2:], os.Stdout))
+		case "snapshot":
+			os.Exit(runSnapshot(os.Args[2:], os.Stdout))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:], os.Stdout))
+		case "who-had":
+			os.Exit(runWhoHad(os.Args[2:], os.Stdout))
+		case "setup":
+			os.Exit(runSetup(os.Args[2:], os.Stdout))
+		case "schema":
+			os.Exit(runSchema(os.Args[2:], os.Stdout))
+		case "version":
+			os.Exit(runVersion(os.Args[2:], os.Stdout))
+		case "self-update":
+			os.Exit(runSelfUpdate(os.Args[2:], os.Stdout))
+		}
+	}
+
 	// Start by handling the CLI switches/flags
 	// Columns to enable (always enable the port column)
-	flagConnStatus := pflag.BoolP("show-status", "s", true, "Show the status of connections")
-	flagProtocol := pflag.BoolP("show-protocol", "P", false, "Show the protocol used in a connection")
-	flagShowAddresses := pflag.BoolP("show-addresses", "a", false, "Show IP addresses in a connection")
-	flagFullConnection := pflag.BoolP("show-full-connection", "C", false, "Show full connection information")
-	flagOwner := pflag.BoolP("show-owner", "o", false, "Show the owner of processes")
-	flagName := pflag.BoolP("show-process-name", "n", false, "Show the name of processes")
-	flagPID := pflag.BoolP("show-process-id", "i", true, "Show the process ID")
-	flagDirectory := pflag.BoolP("show-cwd", "d", false, "Show the process' current working directory")
-	flagAll := pflag.BoolP("show-all", "A", false, "Show all information (equivalent to -PCond flags)")
-
-	// Process and connection filtering options (used in parseLsof())
-	flagListeningOnly := pflag.BoolP("listen-only", "l", false, "Only show listening ports")
-	flagShowClosed := pflag.BoolP("show-closed", "c", false, "Show closed ports")
-	flagShowProtocolNames := pflag.BoolP("show-proto-names", "N", false, "Show protocol names instead of ports where applicable")
-
-	flagShowIPv6 := pflag.BoolP("ipv6", "6", true, "Show IPv6 connections")
-	flagShowIPv4 := pflag.BoolP("ipv4", "4", true, "Show IPv4 connections")
+	flagConnStatus := pflag.BoolP("show-status", "s", true, tr("flag.show-status"))
+	flagProtocol := pflag.BoolP("show-protocol", "P", false, tr("flag.show-protocol"))
+	flagShowAddresses := pflag.BoolP("show-addresses", "a", false, tr("flag.show-addresses"))
+	flagFullConnection := pflag.BoolP("show-full-connection", "C", false, tr("flag.show-full-connection"))
+	flagOwner := pflag.BoolP("show-owner", "o", false, tr("flag.show-owner"))
+	flagName := pflag.BoolP("show-process-name", "n", false, tr("flag.show-process-name"))
+	flagPID := pflag.BoolP("show-process-id", "i", true, tr("flag.show-process-id"))
+	flagDirectory := pflag.BoolP("show-cwd", "d", false, tr("flag.show-cwd"))
+	flagProject := pflag.Bool("show-project", false, tr("flag.show-project"))
+	flagFramework := pflag.Bool("show-framework", false, tr("flag.show-framework"))
+	flagNice := pflag.Bool("show-nice", false, tr("flag.show-nice"))
+	flagResources := pflag.Bool("show-resources", false, tr("flag.show-resources"))
+	flagSort := pflag.String("sort", "", tr("flag.sort"))
+	flagAge := pflag.Bool("show-age", false, tr("flag.show-age"))
+	flagPeers := pflag.Bool("show-peers", false, tr("flag.show-peers"))
+	flagExposed := pflag.BoolP("show-exposed", "E", false, tr("flag.show-exposed"))
+	flagPrivileges := pflag.Bool("show-privileges", false, tr("flag.show-privileges"))
+	flagQueues := pflag.Bool("show-queues", false, tr("flag.show-queues"))
+	flagTCPInfo := pflag.Bool("show-tcp-info", false, tr("flag.show-tcp-info"))
+	flagShowInterface := pflag.BoolP("show-interface", "I", false, tr("flag.show-interface"))
+	flagHostNames := pflag.Bool("host-names", false, tr("flag.host-names"))
+	flagAddresses := pflag.StringSlice("addresses", nil, tr("flag.addresses"))
+	flagGuess := pflag.BoolP("guess-protocols", "g", false, tr("flag.guess-protocols"))
+	flagAll := pflag.BoolP("show-all", "A", false, tr("flag.show-all"))
+
+	// Process and connection filtering options (used in filterProcesses())
+	flagListeningOnly := pflag.BoolP("listen-only", "l", false, tr("flag.listen-only"))
+	flagHideSelf := pflag.Bool("hide-self", true, tr("flag.hide-self"))
+	flagShowClosed := pflag.BoolP("show-closed", "c", false, tr("flag.show-closed"))
+	flagShowProtocolNames := pflag.BoolP("show-proto-names", "N", false, tr("flag.show-proto-names"))
+
+	flagShowIPv6 := pflag.BoolP("ipv6", "6", true, tr("flag.ipv6"))
+	flagShowIPv4 := pflag.BoolP("ipv4", "4", true, tr("flag.ipv4"))
+
+	// Only show one protocol. Passing neither (or both) shows TCP and UDP.
+	flagTCP := pflag.BoolP("tcp", "t", false, tr("flag.tcp"))
+	flagUDP := pflag.BoolP("udp", "u", false, tr("flag.udp"))
+
+	// Watch mode - refresh automatically every interval
+	flagInterval := pflag.DurationP("interval", "w", 0, tr("flag.interval"))
+	flagReactive := pflag.Bool("reactive", true, tr("flag.reactive"))
+	flagEBPF := pflag.Bool("ebpf", false, tr("flag.ebpf"))
+	flagTimeout := pflag.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+
+	// The backend to find processes with (auto-detected by default)
+	flagBackend := pflag.String("backend", "auto", tr("flag.backend"))
+
+	// Debug logging, for bug reports. The TUI uses stdout, so it's written to a file
+	flagDebug := pflag.String("debug", "", tr("flag.debug"))
+	pflag.Lookup("debug").NoOptDefVal = "pvw-debug.log"
+
+	// The language to use. This has already been handled above, but pflag needs to know it exists
+	pflag.String("lang", "", tr("flag.lang"))
+
+	// Whether to set the terminal's title to a summary
+	flagTitle := pflag.Bool("title", false, tr("flag.title"))
+
+	// How process names given as arguments are matched
+	flagMatch := pflag.String("match", matchExact, tr("flag.match"))
+
+	// How tightly to pack the table
+	flagDensity := pflag.String("density", densityComfortable, tr("flag.density"))
+
+	// Whether to keep the columns at their usual widths, rather than fitting them to what's in them
+	flagFixedWidths := pflag.Bool("fixed-widths", false, tr("flag.fixed-widths"))
+
+	// Whether to take over the whole terminal, or render inline underneath the shell prompt
+	flagAltScreen := pflag.Bool("alt-screen", false, tr("flag.alt-screen"))
+	flagHeight := pflag.Int("height", 10, tr("flag.height"))
+
+	// Kubernetes pods, for running on a node
+	flagKubernetes := pflag.BoolP("kubernetes", "K", false, tr("flag.kubernetes"))
+
+	// Windows listeners, when running under WSL
+	flagWindows := pflag.Bool("windows", pvw.InWSL(), tr("flag.windows"))
+
+	// Network namespaces (e.g. containers)
+	flagAllNamespaces := pflag.Bool("all-namespaces", false, tr("flag.all-namespaces"))
+
+	// Recording and replaying
+	flagRecord := pflag.String("record", "", tr("flag.record"))
+	flagReplay := pflag.String("replay", "", tr("flag.replay"))
+
+	// Whether to remember filters and the like for next time
+	flagNoPersist := pflag.Bool("no-persist", false, tr("flag.no-persist"))
+
+	// Where the config file is
+	flagConfig := pflag.String("config", defaultConfigPath(), tr("flag.config"))
+
+	// Plain mode, for screen readers and dumb terminals. --accessible does the same thing, as that's what some people
+	// will look for
+	flagPlain := pflag.Bool("plain", false, tr("flag.plain"))
+	flagAccessible := pflag.Bool("accessible", false, tr("flag.plain"))
 
 	// Read-only mode (prevents process termination, passed to model)
-	flagReadOnly := pflag.BoolP("read-only", "r", false, "Read-only mode - prevents processes from being terminated in the TUI")
+	flagReadOnly := pflag.BoolP("read-only", "r", false, tr("flag.read-only"))
 
 	// A flag to set a comma separated list of ports to filter by
-	flagPortFilter := pflag.StringSlice("ports", nil, "Port filter - only shows the selected ports. Accepts a list of port numbers, separated by commas.")
+	flagPortFilter := pflag.StringSlice("ports", nil, tr("flag.ports"))
+
+	// Batch actions, instead of starting the TUI
+	flagExec := pflag.String("exec", "", tr("flag.exec"))
+	flagDryRun := pflag.Bool("dry-run", false, tr("flag.dry-run"))
+	flagConfirm := pflag.Bool("confirm", false, tr("flag.confirm"))
+	flagInterfaceFilter := pflag.StringSlice("interface", nil, tr("flag.interface"))
+	flagHostFilter := pflag.StringSlice("host", nil, tr("flag.host"))
+
+	// What to hide, even if it matches the filters
+	flagExcludeNames := pflag.StringSlice("exclude-name", nil, tr("flag.exclude-name"))
+	flagExcludePorts := pflag.StringSlice("exclude-ports", nil, tr("flag.exclude-ports"))
+	flagExcludeUsers := pflag.StringSlice("exclude-user", nil, tr("flag.exclude-user"))
+
+	// Check what's listening against a policy file, instead of starting the TUI
+	flagCheckPolicy := pflag.String("check-policy", "", tr("flag.check-policy"))
+	flagQuiet := pflag.BoolP("quiet", "q", false, tr("flag.quiet"))
+
+	// Print what the table would show as JSON, instead of starting the TUI
+	flagJSON := pflag.Bool("json", false, tr("flag.json"))
+
+	// --help groups the flags, rather than pflag's long list (see help.go)
+	pflag.Usage = func() {
+		printUsage(os.Stderr, pflag.CommandLine)
+	}
+
+	// The man page and completion scripts are made from the flags, so they can only be written once they're defined
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "man":
+			writeManPage(os.Stdout, pflag.CommandLine)
+			os.Exit(exitOK)
+		case "completion":
+			os.Exit(runCompletion(os.Args[2:], os.Stdout, pflag.CommandLine))
+		}
+	}
+
+	// Help command should be built-in, and populates based in usage field in pflag.TypeP(). Mistakes in the flags exit
+	// with the same code as any other error (see exit.go), rather than pflag's 2.
+	pflag.CommandLine.Init(os.Args[0], pflag.ContinueOnError)
+	if err := pflag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		os.Exit(exitError)
+	}
+
+	plain := *flagPlain || *flagAccessible
+	if plain {
+		// Nothing gets drawn in plain mode, so don't send the terminal escape codes asking for its background colour
+		lipgloss.SetHasDarkBackground(true)
+	}
+
+	if !validMatch(*flagMatch) {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.match", *flagMatch)))
+		os.Exit(exitError)
+	}
+
+	if err := checkAddressDisplays(*flagAddresses); err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		os.Exit(exitError)
+	}
 
-	// Help command should be built-in, and populates based in usage field in pflag.TypeP()
-	pflag.Parse()
+	if !validDensity(*flagDensity) {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.density", *flagDensity)))
+		os.Exit(exitError)
+	}
+	if !validSort(*flagSort) {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.sort", *flagSort)))
+		os.Exit(exitError)
+	}
 
 	if !*flagShowIPv6 && !*flagShowIPv4 {
-		fmt.Println("Error running pvw: Neither IPv4 or IPv6 connections have been allowed. Please enable at least one." + "")
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.no-ip-version")))
+		os.Exit(exitError)
+	}
+
+	// pvw doesn't work on Windows (yet)
+	if runtime.GOOS == "windows" {
+		fmt.Fprintln(os.Stderr, tr("error.windows"))
+		os.Exit(exitError)
+	}
+
+	if *flagDebug != "" {
+		logFile, err := startDebugLog(*flagDebug)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("error.running", tr("error.debug-log", err)))
+			os.Exit(exitError)
+		}
+		defer logFile.Close()
+		pvw.SetLogger(log.Default())
+		pvw.KeepRecords(true) // So ctrl+r can show the record behind a row (see records.go)
+
+		debugf("pvw started on %s/%s with arguments %q", runtime.GOOS, runtime.GOARCH, os.Args[1:])
+	}
+
+	// Find a backend we can use (or check the one that was asked for is installed). A recording being replayed takes
+	// the backend's place.
+	var selectedBackend backend
+	var err error
+	if *flagReplay != "" {
+		selectedBackend, err = loadReplay(*flagReplay)
+		*flagWindows, *flagAllNamespaces = false, false
+	} else {
+		selectedBackend, err = selectBackend(*flagBackend)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		os.Exit(exitError)
+
+	}
+
+	// Work out what the backend can do with our privileges, so we can hide anything it can't
+	caps := selectedBackend.capabilities()
+	notices := append(caps.notices(selectedBackend.name()), namespaceNotice(*flagAllNamespaces)...)
+	debugf("using the %s backend with capabilities %+v", selectedBackend.name(), caps)
+
+	if !caps.kill && !*flagReadOnly {
+		*flagReadOnly = true
+	}
+
+	// The first time pvw is run, offer to make a config file (see setup.go)
+	if *flagExec == "" && *flagCheckPolicy == "" && !*flagJSON && shouldOfferSetup(*flagConfig) {
+		offerSetup(*flagConfig, os.Stdin, os.Stdout)
+	}
+
+	cfg, err := loadConfig(*flagConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.config", *flagConfig, err)))
+		os.Exit(exitError)
+	}
+
+	// The config file's defaults, for anything the flags don't say
+	for _, name := range cfg.Show {
+		if flag := pflag.Lookup("show-" + name); flag != nil && !flag.Changed {
+			_ = flag.Value.Set("true")
+		}
+	}
+	if cfg.Interval != "" && !pflag.CommandLine.Changed("interval") {
+		*flagInterval, _ = time.ParseDuration(cfg.Interval)
+	}
+	keys.Actions = actionBindings(cfg.Actions)
+
+	if *flagHeight < 1 {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.height")))
+		os.Exit(exitError)
+	}
+
+	if *flagTimeout <= 0 {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.timeout")))
+		os.Exit(exitError)
 	}
 
 	if *flagAll {
@@ -931,8 +2168,16 @@ func main() {
 		*flagConnStatus = true
 	}
 
-	// All other args act as a process name filter
+	// All other args act as a process name filter, and can be globs or regular expressions like the ports
 	cmdArgs := pflag.Args()
+	patterns := append(append([]string{}, cmdArgs...), *flagPortFilter...)
+	patterns = append(append(patterns, *flagExcludeNames...), *flagExcludePorts...)
+	for _, pattern := range patterns {
+		if err := checkPattern(pattern); err != nil {
+			fmt.Fprintln(os.Stderr, tr("error.running", err))
+			os.Exit(exitError)
+		}
+	}
 
 	// Create a settings map with columns and bool values. Note that pflag makes the variables pointers,
 	// hence the need for *variable
@@ -947,7 +2192,12 @@ func main() {
 		table.Column{Title: "PID", Width: 5}:        *flagPID,
 		table.Column{Title: "Name", Width: 10}:      *flagName,
 		table.Column{Title: "Directory", Width: 16}: *flagDirectory,
+		table.Column{Title: "Project", Width: 12}:   *flagProject,
+		table.Column{Title: "Framework", Width: 9}:  *flagFramework,
 		table.Column{Title: "Owner", Width: 8}:      *flagOwner,
+		table.Column{Title: "Nice", Width: 4}:       *flagNice,
+		table.Column{Title: "CPU", Width: 5}:        *flagResources,
+		table.Column{Title: "Memory", Width: 6}:     *flagResources,
 
 		// Connection information
 		table.Column{Title: "Protocol", Width: 3}:                 *flagProtocol, // Used when not viewing full connection
@@ -959,14 +2209,38 @@ func main() {
 		table.Column{Title: "Remote Address", Width: addressColumnWidth}: *flagFullConnection,
 		table.Column{Title: "Remote Port", Width: 5}:                     *flagFullConnection,
 
-		table.Column{Title: "Status", Width: 11}: *flagConnStatus,
+		table.Column{Title: "Status", Width: 11}:   *flagConnStatus,
+		table.Column{Title: "Age", Width: 4}:       *flagAge,
+		table.Column{Title: "Peer", Width: 12}:     *flagPeers,
+		table.Column{Title: "Interface", Width: 9}: *flagShowInterface,
+		table.Column{Title: "Exposed", Width: 7}:   *flagExposed,
+		table.Column{Title: "Privilege", Width: 9}: *flagPrivileges,
+		table.Column{Title: "Recv-Q", Width: 6}:    *flagQueues,
+		table.Column{Title: "Send-Q", Width: 6}:    *flagQueues,
+		table.Column{Title: "Backlog", Width: 7}:   *flagQueues,
+		table.Column{Title: "RTT", Width: 6}:       *flagTCPInfo,
+		table.Column{Title: "Retrans", Width: 7}:   *flagTCPInfo,
+		table.Column{Title: "Cwnd", Width: 4}:      *flagTCPInfo,
+
+		table.Column{Title: "Protocol Guess", Width: 8}: *flagGuess,
+
+		// Kubernetes information
+		table.Column{Title: "Pod", Width: 20}:       *flagKubernetes,
+		table.Column{Title: "Namespace", Width: 12}: *flagKubernetes,
+
+		table.Column{Title: "Net NS", Width: 12}: *flagAllNamespaces,
 	}
 
 	columnIndexes := []table.Column{
 		{Title: "PID", Width: 5},
 		{Title: "Name", Width: 10},
 		{Title: "Directory", Width: 16},
+		{Title: "Project", Width: 12},
+		{Title: "Framework", Width: 9},
 		{Title: "Owner", Width: 8},
+		{Title: "Nice", Width: 4},
+		{Title: "CPU", Width: 5},
+		{Title: "Memory", Width: 6},
 
 		// Connection information
 		{Title: "Protocol", Width: 3},
@@ -981,6 +2255,23 @@ func main() {
 		{Title: "Remote Port", Width: 5},
 
 		{Title: "Status", Width: 11},
+		{Title: "Age", Width: 4},
+		{Title: "Peer", Width: 12},
+		{Title: "Interface", Width: 9},
+		{Title: "Exposed", Width: 7},
+		{Title: "Privilege", Width: 9},
+		{Title: "Recv-Q", Width: 6},
+		{Title: "Send-Q", Width: 6},
+		{Title: "Backlog", Width: 7},
+		{Title: "RTT", Width: 6},
+		{Title: "Retrans", Width: 7},
+		{Title: "Cwnd", Width: 4},
+		{Title: "Protocol Guess", Width: 8},
+
+		{Title: "Pod", Width: 20},
+		{Title: "Namespace", Width: 12},
+
+		{Title: "Net NS", Width: 12},
 	}
 
 	// Configure columns to use by looping through columnSettings
@@ -988,11 +2279,20 @@ func main() {
 
 	for i := 0; i < len(columnSettings); i++ {
 		colName := columnIndexes[i]
-		enableColumn := columnSettings[colName]
+		enableColumn := columnSettings[colName] && caps.supportsColumn(colName.Title)
 
 		if enableColumn {
-			columns = append(columns, colName) // If the settings allow it, enable the column.
+			columns = append(columns, colName) // If the settings allow it (and the backend can fill it), enable the column.
+		}
+	}
+
+	// Plugin columns from the config file go after pvw's own columns
+	for _, plugin := range cfg.Columns {
+		width := plugin.Width
+		if width <= 0 {
+			width = 10
 		}
+		columns = append(columns, table.Column{Title: plugin.Title, Width: width})
 	}
 
 	// Set to empty, then let commands etc. fill the rows out
@@ -1000,50 +2300,158 @@ func main() {
 
 	// Create a new table with the selected columns
 	t := table.New(
-		table.WithColumns(columns),
+		table.WithColumns(translateColumns(columns)),
 		table.WithRows(rows),
 		table.WithFocused(true),
-		table.WithHeight(10),
+		table.WithHeight(*flagHeight),
 	)
 
 	// Change the default styles of the table
-	s := table.DefaultStyles()
+	t.SetStyles(tableStyles(*flagDensity, cfg.Theme))
 
-	s.Header = s.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBottom(true).
-		Bold(true)
+	// Create settings struct for parsing settings and render columns
+	parseAndRenderSettings := settings{
+		readOnly:         *flagReadOnly,
+		showClosed:       *flagShowClosed,
+		listenOnly:       *flagListeningOnly,
+		hideSelf:         *flagHideSelf,
+		getCwd:           (*flagDirectory || *flagProject) && caps.directories,
+		columns:          columns,
+		nameFilter:       cmdArgs,
+		match:            *flagMatch,
+		excludeNames:     *flagExcludeNames,
+		excludePorts:     *flagExcludePorts,
+		excludeUsers:     *flagExcludeUsers,
+		portFilter:       *flagPortFilter,
+		searchTerm:       "",
+		displaySearch:    false,
+		serviceNames:     *flagShowProtocolNames,
+		showIPv6:         *flagShowIPv6,
+		showIPv4:         *flagShowIPv4,
+		showTCP:          *flagTCP || !*flagUDP,
+		showUDP:          *flagUDP || !*flagTCP,
+		interval:         *flagInterval,
+		reactive:         *flagReactive,
+		ebpf:             *flagEBPF,
+		timeout:          *flagTimeout,
+		backend:          selectedBackend,
+		pluginColumns:    cfg.Columns,
+		actions:          cfg.Actions,
+		highlights:       cfg.Highlights,
+		alerts:           cfg.Alerts,
+		confirmTerminate: cfg.ConfirmTerminate,
+		checkUpdates:     cfg.CheckUpdates,
+		tips:             !plain && *flagExec == "" && *flagCheckPolicy == "" && !*flagJSON && startTips(cfg.HideTips),
+		protected:        cfg.protectedNames(),
+		ancestors:        ancestors(),
+		terminals:        ownTerminals(),
+		altScreen:        *flagAltScreen,
+		height:           *flagHeight,
+		kubernetes:       *flagKubernetes && caps.processNames,
+		windows:          *flagWindows && pvw.Windows().Available(),
+		exposed:          *flagExposed,
+
+		guessProtocols:  *flagGuess,
+		showInterface:   *flagShowInterface,
+		interfaceFilter: *flagInterfaceFilter,
+		hostNames:       *flagHostNames,
+		addressDisplays: *flagAddresses,
+		hostFilter:      *flagHostFilter,
+		title:           *flagTitle && !plain,
+		density:         *flagDensity,
+		theme:           cfg.Theme,
+		fixedWidths:     *flagFixedWidths,
+		allNamespaces:   *flagAllNamespaces && pvw.Namespaces().Available(),
+		showNice:        *flagNice,
+		showResources:   *flagResources && caps.processNames,
+		sortBy:          *flagSort,
+		showAge:         *flagAge,
+		showPeers:       *flagPeers,
+		showProject:     *flagProject && caps.directories,
+		showPrivileges:  *flagPrivileges && caps.processNames,
+		showFramework:   *flagFramework && caps.processNames,
+	}
 
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color("7")).
-		Background(lipgloss.Color("#33a989")).
-		Bold(false)
+	// Put back what was changed last time (see prefs.go). Modes that don't start the TUI don't, as nothing would show
+	// that they're being filtered.
+	persist := !*flagNoPersist && preferencesPath() != "" && *flagExec == "" && *flagCheckPolicy == "" && !*flagJSON
+	if prefs, saved := loadPreferences(); persist && saved {
+		applyPreferences(prefs, &parseAndRenderSettings, pflag.CommandLine)
+		notices = append(notices, tr("notice.preferences"))
+	}
 
-	t.SetStyles(s)
+	// Say which protocols are shown in the help
+	keys.Protocols.SetHelp("P", tr("help.protocols", protocolsLabel(parseAndRenderSettings)))
+	keys.Self.SetHelp("S", selfLabel(parseAndRenderSettings.hideSelf))
+	keys.Sort.SetHelp("O", tr("help.sort", sortLabel(parseAndRenderSettings.sortBy)))
+
+	// Hide the terminate key from the help menu if it won't do anything
+	keys.Terminate.SetEnabled(!parseAndRenderSettings.readOnly)
+	keys.Renice.SetEnabled(!parseAndRenderSettings.readOnly && commandExists("renice"))
+	keys.Stop.SetEnabled(!parseAndRenderSettings.readOnly && runtime.GOOS != "windows")
+	keys.Continue.SetEnabled(!parseAndRenderSettings.readOnly && runtime.GOOS != "windows")
+	keys.Reestablish.SetEnabled(!parseAndRenderSettings.readOnly && runtime.GOOS != "windows" && commandExists("ssh"))
+	keys.Sockets.SetEnabled(runtime.GOOS == "linux" && commandExists("systemctl") ||
+		runtime.GOOS == "darwin" && commandExists("launchctl"))
+	keys.Close.SetEnabled(!parseAndRenderSettings.readOnly && canCloseConnections())
+	keys.Capture.SetEnabled(canCapture())
+	keys.Trace.SetEnabled(traceTool() != "")
+	keys.Record.SetEnabled(debugEnabled)
+	keys.DescribePod.SetEnabled(parseAndRenderSettings.kubernetes && commandExists("kubectl"))
+	keys.DismissTips.SetEnabled(parseAndRenderSettings.tips)
+
+	// The keys for moving through a recording only do something while replaying one
+	_, replaying := selectedBackend.(*replayBackend)
+	// The net top view measures the live connections, which a recording's aren't
+	keys.NetTop.SetEnabled(runtime.GOOS == "linux" && !replaying)
+	// Connections only open and close in watch mode
+	keys.Events.SetEnabled(parseAndRenderSettings.interval > 0 || parseAndRenderSettings.ebpf)
+	for _, binding := range []*key.Binding{&keys.PreviousFrame, &keys.NextFrame, &keys.FirstFrame, &keys.LastFrame} {
+		binding.SetEnabled(replaying)
+	}
 
-	// Create settings struct for parsing settings and render columns
-	parseAndRenderSettings := settings{
-		readOnly:      *flagReadOnly,
-		showClosed:    *flagShowClosed,
-		listenOnly:    *flagListeningOnly,
-		getCwd:        *flagDirectory,
-		columns:       columns,
-		nameFilter:    cmdArgs,
-		portFilter:    *flagPortFilter,
-		searchTerm:    "",
-		displaySearch: false,
-		serviceNames:  *flagShowProtocolNames,
-		showIPv6:      *flagShowIPv6,
-		showIPv4:      *flagShowIPv4,
+	if *flagRecord != "" {
+		parseAndRenderSettings.recorder, err = newRecorder(*flagRecord)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("error.running", err))
+			os.Exit(exitError)
+		}
+	}
+
+	// With --exec, run the command for each matching process rather than starting the TUI
+	if *flagExec != "" {
+		output := quietOutput(os.Stdout, *flagQuiet)
+		os.Exit(runExec(*flagExec, parseAndRenderSettings, *flagDryRun, *flagConfirm, output))
+	}
+
+	// With --json, print the processes rather than starting the TUI
+	if *flagJSON {
+		os.Exit(runJSON(parseAndRenderSettings, os.Stdout))
+	}
+
+	// With --check-policy, list what doesn't match the policy rather than starting the TUI
+	if *flagCheckPolicy != "" {
+		os.Exit(runPolicy(*flagCheckPolicy, parseAndRenderSettings, quietOutput(os.Stdout, *flagQuiet)))
+	}
+
+	// The checks above only look, so it's only the TUI that remembers who was listening where
+	parseAndRenderSettings.recordHistory = true
+
+	// With --ebpf, have the kernel say when connections open and close, carrying on without it if it can't
+	var tracer *eventTracer
+	if parseAndRenderSettings.ebpf {
+		if tracer, err = startTracer(); err != nil {
+			notices = append(notices, err.Error())
+		}
 	}
 
 	// Create text input area
 	ti := textinput.New()
-	ti.Placeholder = "type to search"
+	ti.Placeholder = tr("search.placeholder")
 	ti.Blur()
 	ti.CharLimit = 64
 	ti.Width = 16
+	ti.SetValue(parseAndRenderSettings.searchTerm)
 
 	// Create final model struct
 	m := model{
@@ -1057,25 +2465,52 @@ func main() {
 		keys:       keys,
 		help:       help.New(),
 		inputStyle: baseStyle,
+
+		notices: notices,
+		tracer:  tracer,
 	}
 
-	// Run it! (except if we're running on Windows)
-	if runtime.GOOS == "windows" {
-		fmt.Println("Sorry, pvw is UNIX only right now.")
-	} else {
-		// Check if lsof is installed
-		cmd := exec.Command("/bin/sh", "-c", "command -v lsof")
-		err := cmd.Run()
+	var programOptions []tea.ProgramOption
+	if *flagAltScreen && !plain {
+		// Take over the whole terminal, and put back whatever was there before when pvw quits
+		programOptions = append(programOptions, tea.WithAltScreen())
+	}
 
-		if err != nil {
-			fmt.Println("Error running pvw: lsof command does not exist. Please install lsof with your package manager.")
-			os.Exit(1)
+	// In plain mode nothing is drawn, and changes are written out line by line instead
+	if plain {
+		m.output = os.Stdout
+		// bubbletea asks the terminal for its colours if its output is a terminal, which sends escape codes. Hiding
+		// stdout behind another writer stops it, and nothing is written there without a renderer anyway.
+		programOptions = append(programOptions, tea.WithoutRenderer(), tea.WithOutput(struct{ io.Writer }{os.Stdout}))
+		m.announce(notices...)
+	}
 
-		}
+	if parseAndRenderSettings.title {
+		saveTitle()
+	}
+
+	// Run it!
+	finalModel, err := tea.NewProgram(m, programOptions...).Run()
+	if tracer != nil {
+		tracer.stop()
+	}
+	if parseAndRenderSettings.title {
+		restoreTitle()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		os.Exit(exitError)
+	}
+
+	// The terminal has been restored by now, so it's safe to print out a crash
+	if final, isModel := finalModel.(model); isModel && final.crash != nil {
+		printCrash(os.Stderr, *final.crash, selectedBackend.name())
+		os.Exit(exitError)
+	}
 
-		if _, err := tea.NewProgram(m).Run(); err != nil {
-			fmt.Println("Error running pvw: ", err)
-			os.Exit(1)
+	if final, isModel := finalModel.(model); isModel && persist {
+		if err := savePreferences(final.settings); err != nil {
+			debugf("couldn't save the preferences: %v", err)
 		}
 	}
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestGetCwd checks the working directory is where a process is running, not where its executable is
+func TestGetCwd(t *testing.T) {
+	if !canGetCwd() {
+		t.Skip("can't find working directories here")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+
+	directory, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := exec.Command("sleep", "30")
+	child.Dir = directory
+	if err := child.Start(); err != nil {
+		t.Skip("can't start sleep:", err)
+	}
+	defer func() {
+		child.Process.Kill()
+		child.Wait()
+	}()
+
+	if cwd, err := getCwd(child.Process.Pid); err != nil || cwd != directory {
+		t.Errorf("expected sleep's working directory to be %s, got %q (%v)", directory, cwd, err)
+	}
+
+	// PIDs above the kernel's highest possible PID never exist
+	if cwd, err := getCwd(5000001); err != nil || cwd != "" {
+		t.Errorf("expected no working directory and no error for a process that doesn't exist, got %q (%v)", cwd, err)
+	}
+}
+
+func TestParseLsofCwd(t *testing.T) {
+	tests := []struct {
+		out  string
+		want string
+	}{
+		{"p4100\nfcwd\nn/home/sam/app\n", "/home/sam/app"},
+		{"p4100\nfcwd\nn/Users/sam/My Projects/app \n", "/Users/sam/My Projects/app "},
+		{"p4100\r\nfcwd\r\nn/home/sam/app\r\n", "/home/sam/app"},
+		{"p4100\n", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := parseLsofCwd(test.out); got != test.want {
+			t.Errorf("parseLsofCwd(%q) = %q, want %q", test.out, got, test.want)
+		}
+	}
+}
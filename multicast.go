@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/pflag"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Multicast
+// Discovery protocols (mDNS, SSDP, LLMNR...) work by joining multicast groups and listening on a well known UDP port,
+// which the normal view doesn't show much of: a socket on *:5353 looks like any other. `pvw multicast` (or M in the
+// TUI) lists the groups each interface has joined, and the sockets that look like they're taking part in discovery.
+//
+// On Linux, the groups come from /proc/net/igmp and /proc/net/igmp6. The kernel doesn't say which socket joined a group
+// (memberships are set with a socket option that can only be read by the process itself), so sockets are matched up by
+// what they're bound to instead: either the group's address, or a port that a discovery protocol uses. On macOS,
+// `netstat -g` is shown as is.
+
+// The IGMP and MLD tables
+const (
+	igmpPath  = "/proc/net/igmp"
+	igmp6Path = "/proc/net/igmp6"
+)
+
+// A multicast group an interface has joined
+type membership struct {
+	device string
+	group  string
+	users  int // The number of sockets on this interface that joined the group
+}
+
+// A well known multicast group or discovery port
+type discoveryProtocol struct {
+	name   string
+	port   string
+	groups []string
+}
+
+// discoveryProtocols are the protocols we know the groups and ports of. Groups without a port (like all-hosts) are
+// joined by the kernel itself.
+var discoveryProtocols = []discoveryProtocol{
+	{"mDNS", "5353", []string{"224.0.0.251", "ff02::fb"}},
+	{"SSDP", "1900", []string{"239.255.255.250", "ff02::c", "ff05::c"}},
+	{"WS-Discovery", "3702", []string{"239.255.255.250", "ff02::c"}},
+	{"LLMNR", "5355", []string{"224.0.0.252", "ff02::1:3"}},
+	{"DHCP", "67", nil},
+	{"DHCP", "68", nil},
+	{"DHCPv6", "546", []string{"ff02::1:2"}},
+	{"DHCPv6", "547", []string{"ff02::1:2"}},
+	{"NetBIOS", "137", nil},
+	{"NetBIOS", "138", nil},
+	{"all hosts", "", []string{"224.0.0.1", "ff01::1", "ff02::1"}},
+	{"all routers", "", []string{"224.0.0.2", "ff02::2"}},
+	{"IGMPv3", "", []string{"224.0.0.22"}},
+	{"MLDv2", "", []string{"ff02::16"}},
+}
+
+// runMulticast() runs multicast mode with the arguments after `multicast`, and returns the exit code
+func runMulticast(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("multicast", pflag.ContinueOnError)
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("multicast.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	w = quietOutput(w, *flagQuiet)
+
+	all, err := collectListeners(*flagBackend, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	fmt.Fprint(w, multicastReport(all))
+	return exitOK
+}
+
+// showMulticast() shows the multicast report in the detail pane, using the processes already in the table
+func showMulticast(processes []process) tea.Cmd {
+	return func() tea.Msg {
+		return detailMsg{title: tr("multicast.title"), body: multicastReport(processes)}
+	}
+}
+
+// multicastReport() describes the groups each interface has joined and the sockets taking part in discovery
+func multicastReport(processes []process) string {
+	var b strings.Builder
+
+	fmt.Fprintln(&b, tr("multicast.groups"))
+	memberships, err := readMemberships()
+	switch {
+	case err == nil:
+		device := ""
+		for _, m := range memberships {
+			if m.device != device {
+				device = m.device
+				fmt.Fprintf(&b, "  %s\n", device)
+			}
+			fmt.Fprintf(&b, "    %-28s %-14s %s\n", m.group, groupName(m.group), tr("multicast.users", m.users))
+		}
+		if len(memberships) == 0 {
+			fmt.Fprintln(&b, "  "+tr("multicast.none"))
+		}
+
+	case commandExists("netstat"):
+		// Command is `netstat -g`, which lists memberships on macOS and the BSDs
+		out, _ := exec.Command("netstat", "-g").CombinedOutput()
+		b.Write(out)
+
+	default:
+		fmt.Fprintln(&b, "  "+tr("multicast.unavailable", err))
+	}
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, tr("multicast.sockets"))
+	found := false
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			name, taking := discoverySocket(conn)
+			if !taking {
+				continue
+			}
+			found = true
+			fmt.Fprintf(&b, "  %-28s %-6s %-14s %s\n", net.JoinHostPort(strings.Trim(conn.LocalAddress, "[]"),
+				conn.LocalPort), conn.Protocol, name, processLabel(proc))
+		}
+	}
+	if !found {
+		fmt.Fprintln(&b, "  "+tr("multicast.none"))
+	}
+
+	return b.String()
+}
+
+// discoverySocket() checks if a socket looks like it's taking part in multicast or broadcast discovery, and returns the
+// name of the protocol if we know it
+func discoverySocket(conn connection) (string, bool) {
+	if conn.Protocol != "UDP" || conn.RemotePort != "" {
+		return "", false
+	}
+
+	address := strings.Trim(conn.LocalAddress, "[]")
+	for _, protocol := range discoveryProtocols {
+		if protocol.port != "" && protocol.port == conn.LocalPort {
+			return protocol.name, true
+		}
+	}
+
+	if lsof.Multicast(conn.LocalAddress) {
+		return groupName(address), true
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.Equal(net.IPv4bcast) {
+		return "broadcast", true
+	}
+
+	return "", false
+}
+
+// groupName() returns the name of a well known multicast group, or an empty string if we don't know it
+func groupName(group string) string {
+	// Every IPv6 address has a solicited-node group (ff02::1:ffXX:XXXX), used for neighbour discovery
+	if strings.HasPrefix(group, "ff02::1:ff") {
+		return "solicited-node"
+	}
+
+	for _, protocol := range discoveryProtocols {
+		for _, g := range protocol.groups {
+			if g == group {
+				return protocol.name
+			}
+		}
+	}
+	return ""
+}
+
+// readMemberships() reads the multicast groups each interface has joined from /proc/net/igmp and /proc/net/igmp6. It
+// only fails if neither can be read (i.e. we're not on Linux).
+func readMemberships() ([]membership, error) {
+	igmp, err := os.Open(igmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer igmp.Close()
+	memberships := parseIGMP(igmp)
+
+	if igmp6, err := os.Open(igmp6Path); err == nil {
+		defer igmp6.Close()
+		memberships = append(memberships, parseIGMP6(igmp6)...)
+	}
+
+	// Keep each interface's IPv4 and IPv6 groups together
+	sort.SliceStable(memberships, func(i, j int) bool { return memberships[i].device < memberships[j].device })
+
+	return memberships, nil
+}
+
+// parseIGMP() parses /proc/net/igmp, which has a line for each interface followed by an indented line for each group:
+//
+//	Idx	Device    : Count Querier	Group    Users Timer	Reporter
+//	1	lo        :     1      V3
+//					010000E0     1 0:00000000		0
+//
+// Groups are hex in the kernel's byte order, the same as the addresses in /proc/net/tcp.
+func parseIGMP(r io.Reader) []membership {
+	var memberships []membership
+	device := ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Skip the header
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "\t") {
+			device = fields[1]
+			continue
+		}
+
+		group, _ := decodeGroup(fields[0])
+		users, _ := strconv.Atoi(fields[1])
+		if group != "" {
+			memberships = append(memberships, membership{device: device, group: group, users: users})
+		}
+	}
+
+	return memberships
+}
+
+// parseIGMP6() parses /proc/net/igmp6, which has a line for each group an interface has joined:
+//
+//	1    lo              ff020000000000000000000000000001     1 0000000C 0
+//
+// Unlike /proc/net/igmp, the groups are in network order.
+func parseIGMP6(r io.Reader) []membership {
+	var memberships []membership
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		users, _ := strconv.Atoi(fields[3])
+		memberships = append(memberships, membership{device: fields[1], group: net.IP(raw).String(), users: users})
+	}
+
+	return memberships
+}
+
+// decodeGroup() converts a group from /proc/net/igmp like 010000E0 to 224.0.0.1. Like decodeProcAddress() in the proc
+// collector, this assumes a little-endian machine.
+func decodeGroup(raw string) (string, error) {
+	b, err := hex.DecodeString(raw)
+	if err != nil || len(b) != net.IPv4len {
+		return "", errors.New("invalid group " + raw)
+	}
+	return net.IPv4(b[3], b[2], b[1], b[0]).String(), nil
+}
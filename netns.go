@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/table"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Network Namespaces
+// The backends only see sockets in pvw's own network namespace, so a container's listeners are missing from the table.
+// With --all-namespaces, the sockets in every other namespace are added too, and the Net NS column shows which one each
+// process is in (its `ip netns` name, or its inode number). Without it, pvw says when there are other namespaces, so
+// it's clear why a container's ports aren't there.
+
+// fillNetNamespaceColumn() fills in the Net NS column for every row of a process. Processes in our own namespace are
+// left empty.
+func fillNetNamespaceColumn(rows []table.Row, proc process, options settings) {
+	if !options.allNamespaces || proc.NetNamespace == "" {
+		return
+	}
+
+	column := columnIndex(options, "Net NS")
+	if column == -1 || len(rows) == 0 {
+		return
+	}
+
+	for i := range rows {
+		rows[i][column] = proc.NetNamespace
+	}
+}
+
+// namespaceNotice() says how many other network namespaces there are when their sockets aren't being shown
+func namespaceNotice(allNamespaces bool) []string {
+	if allNamespaces || !pvw.Namespaces().Available() {
+		return nil
+	}
+
+	if others := pvw.OtherNetNamespaces(); len(others) > 0 {
+		return []string{tr("notice.other-namespaces", len(others))}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Net Top
+// The table says who's connected to what, but not who's busy. B ranks the processes in the table by how fast their
+// connections are going, the way top does for CPU, in the detail pane:
+//
+//	4242 node        ↓  12.3M/s  ↑   210K/s  ████████████████████████████████████████
+//	 880 postgres    ↓   1.1M/s  ↑   3.2M/s  ███████████████
+//
+// followed by how fast each network interface is going. It measures every netTopInterval for as long as it's open,
+// from the bytes the kernel says each TCP connection has sent and received (over netlink, like the diag backend, so it
+// works whichever backend found the connections), and the interfaces' totals from /proc/net/dev. A connection between
+// two local processes counts for both of them.
+//
+// UDP sockets don't keep count, so the interfaces can add up to more than the processes. eBPF could count them too,
+// but needs root, so it isn't used. Only Linux keeps the counts, and only for pvw's own network namespace. Like the
+// dashboard, filters and searches apply, and esc goes back to the table.
+
+// How often the net top view measures
+const netTopInterval = time.Second
+
+// How many processes the net top view lists
+const netTopRows = 15
+
+// traffic is how many bytes something has received and sent
+type traffic struct {
+	received uint64
+	sent     uint64
+}
+
+// trafficSample is how much every TCP connection and network interface had received and sent at a moment
+type trafficSample struct {
+	at         time.Time
+	sockets    map[string]traffic // By trafficKey()
+	interfaces map[string]traffic // By name
+}
+
+// netTopMsg is a measurement for the net top view. The view is reopened with the same generation for as long as it's
+// open, so measurements from before it was last opened are ignored.
+type netTopMsg struct {
+	sample     trafficSample
+	generation int
+	err        error
+}
+
+// trafficKey() identifies a connection by its addresses and ports, which is all the kernel's counts have
+func trafficKey(conn connection) string {
+	return conn.LocalAddress + ":" + conn.LocalPort + "->" + conn.RemoteAddress + ":" + conn.RemotePort
+}
+
+// parseNetDev() reads how much each interface has received and sent from /proc/net/dev, which has two lines of
+// headings and then a line for each interface: its name and a colon, then 8 received fields starting with the bytes,
+// then 8 sent fields starting with the bytes
+func parseNetDev(contents string) map[string]traffic {
+	interfaces := make(map[string]traffic)
+	for _, line := range strings.Split(contents, "\n") {
+		name, counts, found := strings.Cut(line, ":")
+		fields := strings.Fields(counts)
+		if !found || len(fields) < 9 {
+			continue
+		}
+		received, receivedErr := strconv.ParseUint(fields[0], 10, 64)
+		sent, sentErr := strconv.ParseUint(fields[8], 10, 64)
+		if receivedErr == nil && sentErr == nil {
+			interfaces[strings.TrimSpace(name)] = traffic{received, sent}
+		}
+	}
+	return interfaces
+}
+
+// sampleTraffic() measures how much every TCP connection and network interface has received and sent so far
+func sampleTraffic() (trafficSample, error) {
+	if runtime.GOOS != "linux" {
+		return trafficSample{}, errors.New(tr("error.net-top-linux"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tcpInfoTimeout)
+	defer cancel()
+	connections, err := pvw.TCPTraffic(ctx)
+	if err != nil {
+		return trafficSample{}, errors.New(tr("error.net-top", err))
+	}
+
+	sample := trafficSample{at: time.Now(), sockets: make(map[string]traffic, len(connections))}
+	for _, c := range connections {
+		sample.sockets[trafficKey(c.Connection)] = traffic{c.Received, c.Sent}
+	}
+	if contents, err := os.ReadFile("/proc/net/dev"); err == nil {
+		sample.interfaces = parseNetDev(string(contents))
+	}
+	return sample, nil
+}
+
+// measureNetTop() measures for the net top view after a delay
+func measureNetTop(generation int, delay time.Duration) tea.Cmd {
+	measure := func(time.Time) tea.Msg {
+		sample, err := sampleTraffic()
+		return netTopMsg{sample: sample, generation: generation, err: err}
+	}
+	if delay == 0 {
+		return func() tea.Msg { return measure(time.Now()) }
+	}
+	return after(delay, measure)
+}
+
+// since() returns how much was received and sent between two counts. A count that went down belongs to a new
+// connection with the same addresses, so all of it counts.
+func (t traffic) since(earlier traffic) traffic {
+	if t.received < earlier.received || t.sent < earlier.sent {
+		return t
+	}
+	return traffic{t.received - earlier.received, t.sent - earlier.sent}
+}
+
+// processTraffic is a line of the net top view: a process, and how fast its connections are receiving and sending
+type processTraffic struct {
+	label          string
+	received, sent float64 // Bytes per second
+	total          float64
+}
+
+// formatRate() writes a speed in bytes per second the way the Memory column writes sizes, e.g. 12.3M/s
+func formatRate(bytesPerSecond float64) string {
+	if bytesPerSecond < 1 {
+		return "-"
+	}
+	return formatMemory(int64(bytesPerSecond)) + "/s"
+}
+
+// netTopReport() ranks the processes by how fast their TCP connections received and sent between two samples, and
+// lists how fast each interface went
+func netTopReport(processes []process, earlier, later trafficSample) string {
+	seconds := later.at.Sub(earlier.at).Seconds()
+	if seconds <= 0 {
+		return tr("nettop.measuring")
+	}
+
+	var ranked []processTraffic
+	for _, proc := range processes {
+		var total traffic
+		for _, conn := range proc.Connections {
+			count, found := later.sockets[trafficKey(conn)]
+			if !found || conn.Protocol != "TCP" {
+				continue
+			}
+			// A connection that's new since the last sample has sent and received all of it since then
+			difference := count.since(earlier.sockets[trafficKey(conn)])
+			total.received += difference.received
+			total.sent += difference.sent
+		}
+		if total.received+total.sent == 0 {
+			continue
+		}
+		received, sent := float64(total.received)/seconds, float64(total.sent)/seconds
+		ranked = append(ranked, processTraffic{processLabel(proc), received, sent, received + sent})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].total > ranked[j].total })
+	if len(ranked) > netTopRows {
+		ranked = ranked[:netTopRows]
+	}
+
+	var b strings.Builder
+	fmt.Fprintln(&b, tr("nettop.processes"))
+	if len(ranked) == 0 {
+		fmt.Fprintln(&b, "  "+tr("nettop.idle"))
+	}
+	labelWidth := 0
+	for _, p := range ranked {
+		if width := lipgloss.Width(p.label); width > labelWidth {
+			labelWidth = width
+		}
+	}
+	for _, p := range ranked {
+		bar := int(p.total * dashboardBarWidth / ranked[0].total)
+		if bar < 1 {
+			bar = 1
+		}
+		padding := strings.Repeat(" ", labelWidth-lipgloss.Width(p.label))
+		fmt.Fprintf(&b, "  %s%s  ↓ %8s  ↑ %8s  %s\n", p.label, padding, formatRate(p.received), formatRate(p.sent),
+			dashboardBarStyle.Render(strings.Repeat("█", bar)))
+	}
+
+	var names []string
+	for name, count := range later.interfaces {
+		if previous, found := earlier.interfaces[name]; found && count.since(previous) != (traffic{}) {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, tr("nettop.interfaces"))
+		nameWidth := 0
+		for _, name := range names {
+			if width := lipgloss.Width(name); width > nameWidth {
+				nameWidth = width
+			}
+		}
+		for _, name := range names {
+			difference := later.interfaces[name].since(earlier.interfaces[name])
+			fmt.Fprintf(&b, "  %s%s  ↓ %8s  ↑ %8s\n", name, strings.Repeat(" ", nameWidth-lipgloss.Width(name)),
+				formatRate(float64(difference.received)/seconds), formatRate(float64(difference.sent)/seconds))
+		}
+	}
+	return b.String()
+}
+
+// netTopOpen() checks if the net top view is what's in the detail pane
+func (m model) netTopOpen() bool {
+	return m.showDetail && m.detailTitle == tr("nettop.title")
+}
+
+// openNetTop() opens the net top view, which shows that it's measuring until there are two samples to compare
+func (m *model) openNetTop() tea.Cmd {
+	m.netTopGeneration++
+	m.netTop = nil
+	return measureNetTop(m.netTopGeneration, 0)
+}
+
+// updateNetTop() shows a new measurement in the net top view and schedules the next, unless the view's been closed
+// since. In plain mode, where the pane would be written out again every time, it stops after the first comparison.
+func (m *model) updateNetTop(msg netTopMsg) tea.Cmd {
+	if msg.generation != m.netTopGeneration || (m.netTop != nil && !m.netTopOpen()) {
+		return nil
+	}
+
+	body := tr("nettop.measuring")
+	first := m.netTop == nil
+	if !first {
+		body = netTopReport(m.processes, *m.netTop, msg.sample)
+	}
+	m.netTop = &msg.sample
+	m.openDetail(detailMsg{title: tr("nettop.title"), body: body})
+
+	if m.output != nil && !first {
+		return nil
+	}
+	return measureNetTop(m.netTopGeneration, netTopInterval)
+}
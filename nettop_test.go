@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseNetDev checks each interface's received and sent bytes are read from /proc/net/dev
+func TestParseNetDev(t *testing.T) {
+	contents := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1234567    8000    0    0    0     0          0         0  1234567    8000    0    0    0     0       0          0
+  eth0:98765432   70000    0    0    0     0          0         0 12345678   40000    0    0    0     0       0          0
+`
+	interfaces := parseNetDev(contents)
+	if len(interfaces) != 2 {
+		t.Fatalf("expected 2 interfaces, got %v", interfaces)
+	}
+	if eth0 := interfaces["eth0"]; eth0.received != 98765432 || eth0.sent != 12345678 {
+		t.Errorf("expected eth0 to have received 98765432 and sent 12345678, got %+v", eth0)
+	}
+}
+
+// TestNetTopReport checks processes are ranked by how fast their connections went between two samples, with new
+// connections counting from nothing and idle processes left out
+func TestNetTopReport(t *testing.T) {
+	api := listeningProcess(100, "api", "8080")
+	api.Connections = append(api.Connections, connection{Protocol: "TCP", Status: "ESTABLISHED",
+		LocalAddress: "10.0.0.2", LocalPort: "8080", RemoteAddress: "10.0.0.9", RemotePort: "50000"})
+	db := listeningProcess(200, "db", "5432")
+	db.Connections = append(db.Connections, connection{Protocol: "TCP", Status: "ESTABLISHED",
+		LocalAddress: "10.0.0.2", LocalPort: "5432", RemoteAddress: "10.0.0.3", RemotePort: "40000"})
+	idle := listeningProcess(300, "idle", "9000")
+
+	at := time.Now()
+	earlier := trafficSample{at: at, sockets: map[string]traffic{
+		trafficKey(api.Connections[1]): {received: 1000, sent: 1000},
+	}}
+	later := trafficSample{at: at.Add(2 * time.Second), sockets: map[string]traffic{
+		trafficKey(api.Connections[1]): {received: 1000 + 4<<20, sent: 1000 + 2<<20},
+		trafficKey(db.Connections[1]):  {received: 2048, sent: 0},
+	}}
+
+	report := netTopReport([]process{db, idle, api}, earlier, later)
+	apiLine, dbLine := strings.Index(report, "api"), strings.Index(report, "db")
+	if apiLine == -1 || dbLine == -1 || apiLine > dbLine {
+		t.Errorf("expected api to be ranked above db:\n%s", report)
+	}
+	if !strings.Contains(report, "2.0M/s") || !strings.Contains(report, "1.0M/s") || !strings.Contains(report, "1K/s") {
+		t.Errorf("expected api at 2.0M/s down and 1.0M/s up, and db at 1K/s down:\n%s", report)
+	}
+	if strings.Contains(report, "idle") {
+		t.Errorf("expected the idle process to be left out:\n%s", report)
+	}
+}
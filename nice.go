@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Nice
+// A process holding a port isn't always broken - sometimes it's just hogging the CPU. Rather than terminating it, n
+// asks for a new nice value (from -20, the highest priority, to 19, the lowest) and renices it with `renice`, and
+// --show-nice adds a Nice column with each process' current value. Only root can lower a nice value.
+
+// reniceMsg says a process was reniced, so its rows need to be rebuilt to show the new value
+type reniceMsg struct{ pid int }
+
+// The range of nice values
+const (
+	minNice = -20
+	maxNice = 19
+)
+
+// processNice() gets a process' nice value using `ps -o nice=`, which works on both Linux and macOS
+func processNice(pid int) string {
+	if !commandExists("ps") {
+		return ""
+	}
+	// Command is `ps -o nice= -p PID`
+	out, err := exec.Command("ps", "-o", "nice=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// fillNiceColumn() fills in the Nice column on the first row of a process, the same as the other process columns
+func fillNiceColumn(rows []table.Row, proc process, options settings) {
+	if !options.showNice || len(rows) == 0 || proc.ID == 0 || proc.Windows {
+		return
+	}
+
+	if column := columnIndex(options, "Nice"); column != -1 {
+		rows[0][column] = processNice(proc.ID)
+	}
+}
+
+// askRenice() asks for the selected process' new nice value, starting with its current one
+func (m *model) askRenice(proc process) tea.Cmd {
+	if proc.ID == 0 || proc.Windows {
+		m.err = errors.New(tr("error.unknown-process"))
+		return nil
+	}
+
+	m.openPrompt(tr("prompt.nice", proc.Name, proc.ID), processNice(proc.ID), func(value string) tea.Cmd {
+		return reniceProcess(proc.ID, value)
+	})
+	return nil
+}
+
+// reniceProcess() changes the nice value of a process with `renice`
+func reniceProcess(pid int, value string) tea.Cmd {
+	return func() tea.Msg {
+		nice, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || nice < minNice || nice > maxNice {
+			return errMsg{errors.New(tr("error.invalid-nice", value, minNice, maxNice))}
+		}
+
+		// Command is `renice -n NICE -p PID`
+		out, err := exec.Command("renice", "-n", strconv.Itoa(nice), "-p", strconv.Itoa(pid)).CombinedOutput()
+		if err != nil {
+			if message := strings.TrimSpace(string(out)); message != "" {
+				return errMsg{errors.New(message)}
+			}
+			return errMsg{err}
+		}
+		debugf("reniced %d to %d", pid, nice)
+
+		return reniceMsg{pid}
+	}
+}
+
+// forgetRows() drops a process' cached rows, so they're rebuilt on the next refresh even if its sockets haven't
+// changed. The cache may still be in use by a collection, so a copy is made rather than changing it.
+func (m *model) forgetRows(pid int) {
+	cache := make(rowCache, len(m.rowCache))
+	for cachedPID, cached := range m.rowCache {
+		if cachedPID != pid {
+			cache[cachedPID] = cached
+		}
+	}
+	m.rowCache = cache
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Patterns
+// Process names aren't always the ones you'd expect: lsof cuts them off (com.docker.backend shows up as com.docker.b),
+// and the same server can run as node, nodejs or node20. So the names given on the command line, the values of
+// --ports and the values in the filter bar can be patterns as well as exact values:
+//
+//	node*      A glob - * matches anything, ? matches one character and [...] matches one of a set
+//	/^java/    A regular expression, between slashes
+//
+// Anything else has to match exactly by default. Regular expressions only have to match part of the value, so /^java/
+// matches java and javaw, while globs have to match the whole value.
+//
+// Names on the command line are often a guess at what the process is called, so --match loosens how names that aren't
+// patterns are matched:
+//
+//	exact      The name has to be the same (the default)
+//	substring  The name only has to contain it, ignoring case, so chrome matches Google Chrome H
+//	fuzzy      The name only has to have its letters in order, ignoring case and spaces, so gch matches Google Chrome
+//
+// Patterns ignore case too with substring or fuzzy.
+
+// Regular expressions are compiled the first time they're used. Filtering happens in several goroutines, so the
+// compiled ones are behind a mutex.
+var (
+	patternMutex    sync.Mutex
+	patternCompiled = make(map[string]*regexp.Regexp)
+)
+
+// isRegexPattern() checks if a value is a regular expression, i.e. it's between slashes
+func isRegexPattern(value string) bool {
+	return len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/")
+}
+
+// isPattern() checks if a value is a glob or a regular expression, rather than something to match exactly
+func isPattern(value string) bool {
+	return isRegexPattern(value) || strings.ContainsAny(value, "*?[")
+}
+
+// compilePattern() returns the compiled regular expression for a value between slashes
+func compilePattern(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	expression := pattern[1 : len(pattern)-1]
+	if ignoreCase {
+		expression = "(?i)" + expression
+	}
+
+	patternMutex.Lock()
+	defer patternMutex.Unlock()
+
+	if compiled, ok := patternCompiled[expression]; ok {
+		return compiled, nil
+	}
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	patternCompiled[expression] = compiled
+	return compiled, nil
+}
+
+// checkPattern() returns an error if a value is a pattern that can't be used
+func checkPattern(pattern string) error {
+	var err error
+	if isRegexPattern(pattern) {
+		_, err = compilePattern(pattern, false)
+	} else if isPattern(pattern) {
+		_, err = path.Match(pattern, "")
+	}
+	if err != nil {
+		return fmt.Errorf(tr("error.pattern"), pattern, err)
+	}
+	return nil
+}
+
+// patternMatches() checks if a value matches a pattern, or is the same as it if it isn't a pattern. Patterns that
+// can't be used don't match anything.
+func patternMatches(pattern string, value string, ignoreCase bool) bool {
+	switch {
+	case isRegexPattern(pattern):
+		compiled, err := compilePattern(pattern, ignoreCase)
+		return err == nil && compiled.MatchString(value)
+
+	case isPattern(pattern):
+		if ignoreCase {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+		matched, err := path.Match(pattern, value)
+		return err == nil && matched
+
+	case ignoreCase:
+		return strings.EqualFold(pattern, value)
+	}
+	return pattern == value
+}
+
+// The ways --match accepts to match names
+const (
+	matchExact     = "exact"
+	matchSubstring = "substring"
+	matchFuzzy     = "fuzzy"
+)
+
+// validMatch() checks if a way of matching names is one --match accepts
+func validMatch(match string) bool {
+	return match == matchExact || match == matchSubstring || match == matchFuzzy
+}
+
+// nameMatches() checks if a process name matches any of the names (or patterns) given on the command line, the way
+// --match says to
+func nameMatches(filter []string, name string, match string) bool {
+	for _, pattern := range filter {
+		switch {
+		case isPattern(pattern):
+			if patternMatches(pattern, name, match != matchExact) {
+				return true
+			}
+		case match == matchSubstring:
+			if strings.Contains(strings.ToLower(name), strings.ToLower(pattern)) {
+				return true
+			}
+		case match == matchFuzzy:
+			if fuzzyMatches(pattern, name) {
+				return true
+			}
+		case pattern == name:
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyMatches() checks if a name has every letter of a search in the same order, ignoring case and spaces
+func fuzzyMatches(search string, name string) bool {
+	remaining := []rune(strings.ToLower(strings.Join(strings.Fields(search), "")))
+	for _, r := range strings.ToLower(name) {
+		if len(remaining) == 0 {
+			break
+		}
+		if r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
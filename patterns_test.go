@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		ignoreCase     bool
+		want           bool
+	}{
+		{"node", "node", false, true},
+		{"node", "nodejs", false, false},
+		{"node", "Node", false, false},
+		{"node", "Node", true, true},
+		{"node*", "node20", false, true},
+		{"node*", "xnode", false, false},
+		{"node?", "node2", false, true},
+		{"node?", "node20", false, false},
+		{"[jk]ava", "kava", false, true},
+		{"com.docker.*", "com.docker.b", false, true},
+		{"NODE*", "node20", true, true},
+		{"NODE*", "node20", false, false},
+		{"/^java/", "javaw", false, true},
+		{"/^java/", "openjava", false, false},
+		{"/sql/", "postgresql", false, true},
+		{"/^JAVA$/", "java", true, true},
+		{"80*", "8080", false, true},
+		{"/(/", "(", false, false},
+		{"[", "[", false, false},
+	}
+
+	for _, test := range tests {
+		if got := patternMatches(test.pattern, test.value, test.ignoreCase); got != test.want {
+			t.Errorf("patternMatches(%q, %q, %t) = %t, want %t",
+				test.pattern, test.value, test.ignoreCase, got, test.want)
+		}
+	}
+}
+
+func TestCheckPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"node", true},
+		{"node*", true},
+		{"/^java/", true},
+		{"/", true},
+		{"/(/", false},
+		{"[", false},
+	}
+
+	for _, test := range tests {
+		if err := checkPattern(test.pattern); (err == nil) != test.valid {
+			t.Errorf("checkPattern(%q) = %v, want valid to be %t", test.pattern, err, test.valid)
+		}
+	}
+}
+
+func TestNameMatches(t *testing.T) {
+	tests := []struct {
+		filter []string
+		name   string
+		match  string
+		want   bool
+	}{
+		{[]string{"node"}, "node", matchExact, true},
+		{[]string{"chrome"}, "Google Chrome H", matchExact, false},
+		{[]string{"postgres", "node"}, "node", matchExact, true},
+		{[]string{"node*"}, "Node20", matchExact, false},
+		{[]string{"chrome"}, "Google Chrome H", matchSubstring, true},
+		{[]string{"chrome"}, "chromium", matchSubstring, false},
+		{[]string{"node*"}, "Node20", matchSubstring, true},
+		{[]string{"gch"}, "Google Chrome", matchFuzzy, true},
+		{[]string{"g c h"}, "Google Chrome", matchFuzzy, true},
+		{[]string{"hcg"}, "Google Chrome", matchFuzzy, false},
+		{[]string{"/^java/"}, "Javaw", matchFuzzy, true},
+		{nil, "node", matchSubstring, false},
+	}
+
+	for _, test := range tests {
+		if got := nameMatches(test.filter, test.name, test.match); got != test.want {
+			t.Errorf("nameMatches(%q, %q, %s) = %t, want %t", test.filter, test.name, test.match, got, test.want)
+		}
+	}
+}
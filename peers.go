@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Local Peers
+// A connection over loopback has both of its ends on this machine, so both show up in the table: the client's socket
+// from 127.0.0.1:51000 to 127.0.0.1:6379, and redis' from 127.0.0.1:6379 to 127.0.0.1:51000. With a handful of local
+// services talking to each other, working out which row goes with which gets tedious, so pvw pairs them up.
+//
+// --show-peers adds a Peer column with the process at the other end of each connection ("4242 redis-server"), the
+// detail pane (i) lists them too, and J jumps to the other end of the selected connection. A connection's peer is
+// whichever socket's local end is its remote end and the other way around, so it works for connections to any of the
+// machine's addresses, not only loopback. Peers are found before filtering, so the Peer column still says who's at the
+// other end when the filters hide them.
+
+// peer is the process at the other end of a connection, and its side of it
+type peer struct {
+	pid  int
+	name string
+	conn connection
+}
+
+// label() describes a peer the way the Peer column shows it
+func (p peer) label() string {
+	if p.pid == 0 {
+		return p.name
+	}
+	return strconv.Itoa(p.pid) + " " + p.name
+}
+
+// endpoint is one end of a connection
+type endpoint struct {
+	protocol string
+	address  string
+	port     string
+}
+
+// findPeers() pairs up the connections between processes on this machine, by the connection at each end
+func findPeers(processes []process) map[rowKey]peer {
+	// Index every connected socket by its local end
+	byLocal := make(map[endpoint][]peer)
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			if conn.RemotePort == "" {
+				continue
+			}
+			local := endpoint{conn.Protocol, conn.LocalAddress, conn.LocalPort}
+			byLocal[local] = append(byLocal[local], peer{pid: proc.ID, name: proc.Name, conn: conn})
+		}
+	}
+
+	// Then look up each socket's remote end, which is the peer's local end
+	peers := make(map[rowKey]peer)
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			if conn.RemotePort == "" {
+				continue
+			}
+			for _, candidate := range byLocal[endpoint{conn.Protocol, conn.RemoteAddress, conn.RemotePort}] {
+				if candidate.conn.RemoteAddress == conn.LocalAddress && candidate.conn.RemotePort == conn.LocalPort {
+					peers[connectionKey(proc.ID, conn)] = candidate
+					break
+				}
+			}
+		}
+	}
+	return peers
+}
+
+// connectionPeers() labels the peer of each of a process' connections, or returns nil if the Peer column isn't shown
+func connectionPeers(proc process, options settings) []string {
+	if !options.showPeers {
+		return nil
+	}
+	labels := make([]string, len(proc.Connections))
+	for i, conn := range proc.Connections {
+		if p, found := options.peers[connectionKey(proc.ID, conn)]; found {
+			labels[i] = p.label()
+		}
+	}
+	return labels
+}
+
+// fillPeerColumn() fills in the Peer column of each of a process' rows
+func fillPeerColumn(rows []table.Row, peers []string, options settings) {
+	if len(peers) == 0 {
+		return
+	}
+
+	column := columnIndex(options, "Peer")
+	if column == -1 {
+		return
+	}
+	for i, label := range peers {
+		if i < len(rows) {
+			rows[i][column] = label
+		}
+	}
+}
+
+// processPeers() lists the processes at the other end of a process' connections, once each, in the order of its rows
+func processPeers(proc process, peers map[rowKey]peer) []string {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, conn := range proc.Connections {
+		p, found := peers[connectionKey(proc.ID, conn)]
+		if !found {
+			continue
+		}
+		line := tr("details.peer", conn.LocalPort, p.label())
+		if !seen[line] {
+			seen[line] = true
+			labels = append(labels, line)
+		}
+	}
+	return labels
+}
+
+// jumpToPeer() moves the cursor to the row for the other end of the selected connection
+func (m *model) jumpToPeer() tea.Cmd {
+	processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor())
+	if !exists {
+		return nil
+	}
+	proc := m.processes[processIndex]
+	p, found := m.settings.peers[connectionKey(proc.ID, proc.Connections[connectionIndex])]
+	if !found {
+		return func() tea.Msg { return errMsg{errors.New(tr("error.no-peer"))} }
+	}
+
+	// findRow() picks the process' only row if it's collapsed
+	if row, shown := m.findRow(connectionKey(p.pid, p.conn)); shown {
+		m.moveCursor(row)
+		return nil
+	}
+	return func() tea.Msg { return errMsg{errors.New(tr("error.peer-hidden", p.label()))} }
+}
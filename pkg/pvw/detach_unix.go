@@ -0,0 +1,13 @@
+//go:build !windows
+
+package pvw
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Detach makes a command run in its own session, so it keeps running after the terminal it was started from closes
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
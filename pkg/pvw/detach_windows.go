@@ -0,0 +1,9 @@
+//go:build windows
+
+package pvw
+
+import "os/exec"
+
+// Detach makes a command keep running after the terminal it was started from closes. Windows has no sessions to start
+// it in, so it does nothing there.
+func Detach(cmd *exec.Cmd) {}
@@ -0,0 +1,249 @@
+package pvw
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Netlink Processing
+// All the functions relating to getting the processes with ports open by asking the kernel over netlink (sock_diag) on
+// Linux, which is what ss uses. It doesn't need any commands to be installed either, and unlike /proc, the kernel also
+// hands over each TCP connection's tcp_info: its round trip time, how many segments it's had to retransmit, and its
+// congestion window. Like /proc, sockets are matched up to processes by their inodes, so only the sockets of processes
+// we're allowed to look inside of can be found. Containers often block netlink, in which case /proc is used instead.
+//
+// The messages are read in the machine's byte order, like /proc's addresses, apart from the ports and addresses, which
+// are in network order.
+
+type diagCollector struct{}
+
+// Diag returns a Collector that asks the kernel over netlink on Linux, which doesn't need any commands to be installed
+// and knows how each TCP connection is doing. Only the sockets of processes we're allowed to look inside of can be
+// found.
+func Diag() Collector { return diagCollector{} }
+
+func (diagCollector) Name() string { return "diag" }
+
+func (diagCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	owners, err := socketOwners(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	debugf("found the owners of %d sockets in /proc", len(owners))
+
+	processes := make(map[int]*Process)
+	usernames := make(map[string]string)
+	var warnings []Warning
+	for _, dump := range []struct {
+		protocol string
+		ipv6     bool
+	}{
+		{"TCP", false},
+		{"TCP", true},
+		{"UDP", false},
+		{"UDP", true},
+	} {
+		messages, err := dumpDiag(ctx, dump.protocol, dump.ipv6)
+		if err != nil && dump.ipv6 {
+			// IPv6 might be disabled, so it isn't an error if it can't be dumped
+			debugf("skipping %s over IPv6: %v", dump.protocol, err)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i, message := range messages {
+			socket, err := parseDiagMessage(message, dump.protocol)
+			if err != nil {
+				record := hex.EncodeToString(message)
+				warnings = append(warnings, Warning{Line: i + 1, Record: record, Reason: err.Error()})
+				continue
+			}
+			addOwnedSocket(processes, socket, owners, usernames, "")
+		}
+	}
+	return sortedProcesses(processes), warnings, nil
+}
+
+// The sizes of the kernel's inet_diag_req_v2 and inet_diag_msg, and the attribute with a socket's tcp_info
+const (
+	diagRequestSize = 56
+	diagMessageSize = 72
+	diagInfo        = 2 // INET_DIAG_INFO
+)
+
+// The offsets in tcp_info of tcpi_rtt (in microseconds), tcpi_snd_cwnd (in segments), tcpi_total_retrans, and
+// tcpi_bytes_acked and tcpi_bytes_received, which kernels before 4.2 don't have
+const (
+	tcpInfoRTT           = 68
+	tcpInfoCwnd          = 80
+	tcpInfoRetransmits   = 100
+	tcpInfoBytesAcked    = 120
+	tcpInfoBytesReceived = 128
+)
+
+// diagRequest() builds the body of a netlink request to dump every socket of a protocol and IP version, with their
+// tcp_info
+func diagRequest(protocol string, ipv6 bool) []byte {
+	request := make([]byte, diagRequestSize)
+	request[0] = 2 // AF_INET
+	if ipv6 {
+		request[0] = 10 // AF_INET6
+	}
+	request[1] = 6 // IPPROTO_TCP
+	if protocol == "UDP" {
+		request[1] = 17 // IPPROTO_UDP
+	}
+	request[2] = 1 << (diagInfo - 1)
+	// Every state
+	binary.LittleEndian.PutUint32(request[4:8], 0xffffffff)
+	return request
+}
+
+// parseDiagMessage() parses an inet_diag_msg, and the tcp_info after it if there is one. How many bytes the socket's
+// sent and received go in the procSocket rather than the connection, as they change all the time.
+func parseDiagMessage(message []byte, protocol string) (procSocket, error) {
+	if len(message) < diagMessageSize {
+		return procSocket{}, errors.New("message too short")
+	}
+
+	ipv6 := message[0] == 10
+	localIP, remoteIP := net.IP(message[8:12]), net.IP(message[24:28])
+	if ipv6 {
+		localIP, remoteIP = net.IP(message[8:24]), net.IP(message[24:40])
+	}
+	conn := Connection{
+		Protocol:     protocol,
+		LocalAddress: formatIP(localIP, ipv6),
+		LocalPort:    strconv.Itoa(int(binary.BigEndian.Uint16(message[4:6]))),
+		IPv6:         ipv6,
+	}
+
+	// UDP doesn't really have states, and lsof doesn't show them, so only use them for TCP
+	if protocol == "TCP" {
+		conn.Status = procStates[fmt.Sprintf("%02X", message[1])]
+	}
+
+	// For a listening socket, the read queue is the connections waiting to be accepted and the write queue is the
+	// backlog
+	recvQueue := int(binary.LittleEndian.Uint32(message[56:60]))
+	writeQueue := int(binary.LittleEndian.Uint32(message[60:64]))
+	conn.RecvQueue = recvQueue
+	if conn.Status == "LISTEN" {
+		conn.Backlog = writeQueue
+	} else {
+		conn.SendQueue = writeQueue
+	}
+
+	// A remote port of 0 means there isn't a remote end
+	if remotePort := binary.BigEndian.Uint16(message[6:8]); remotePort != 0 {
+		conn.RemoteAddress = formatIP(remoteIP, ipv6)
+		conn.RemotePort = strconv.Itoa(int(remotePort))
+	}
+
+	var sent, received uint64
+	// The attributes follow, each with its length and type, padded to 4 bytes
+	for attributes := message[diagMessageSize:]; len(attributes) >= 4; {
+		length := int(binary.LittleEndian.Uint16(attributes[0:2]))
+		kind := binary.LittleEndian.Uint16(attributes[2:4])
+		if length < 4 || length > len(attributes) {
+			return procSocket{}, errors.New("invalid attribute")
+		}
+		info := attributes[4:length]
+		if kind == diagInfo && protocol == "TCP" && conn.Status != "LISTEN" && len(info) >= tcpInfoRetransmits+4 {
+			conn.RTT = time.Duration(binary.LittleEndian.Uint32(info[tcpInfoRTT:])) * time.Microsecond
+			conn.CongestionWindow = int(binary.LittleEndian.Uint32(info[tcpInfoCwnd:]))
+			conn.Retransmits = int(binary.LittleEndian.Uint32(info[tcpInfoRetransmits:]))
+		}
+		if kind == diagInfo && protocol == "TCP" && len(info) >= tcpInfoBytesReceived+8 {
+			sent = binary.LittleEndian.Uint64(info[tcpInfoBytesAcked:])
+			received = binary.LittleEndian.Uint64(info[tcpInfoBytesReceived:])
+		}
+		padded := (length + 3) &^ 3
+		if padded > len(attributes) {
+			break
+		}
+		attributes = attributes[padded:]
+	}
+
+	conn.SetServiceNames()
+	if lsof.KeepRecords {
+		conn.Record = hex.EncodeToString(message)
+	}
+
+	uid := strconv.FormatUint(uint64(binary.LittleEndian.Uint32(message[64:68])), 10)
+	inode := strconv.FormatUint(uint64(binary.LittleEndian.Uint32(message[68:72])), 10)
+	return procSocket{conn: conn, uid: uid, inode: inode, sent: sent, received: received}, nil
+}
+
+// ErrNoTCPInfo is returned by LookupTCPInfo if the kernel doesn't have the connection, which usually means it's closed
+// or in another network namespace
+var ErrNoTCPInfo = errors.New("the kernel doesn't have that connection")
+
+// LookupTCPInfo asks the kernel over netlink for a TCP connection's round trip time, retransmits and congestion
+// window, and returns it with them filled in. It's for connections found by a collector that doesn't know them, and
+// only works on Linux, for connections in our own network namespace.
+func LookupTCPInfo(ctx context.Context, conn Connection) (Connection, error) {
+	messages, err := dumpDiag(ctx, "TCP", conn.IPv6)
+	if err != nil {
+		return conn, err
+	}
+
+	for _, message := range messages {
+		socket, err := parseDiagMessage(message, "TCP")
+		if err != nil {
+			continue
+		}
+		found := socket.conn
+		if found.LocalAddress == conn.LocalAddress && found.LocalPort == conn.LocalPort &&
+			found.RemoteAddress == conn.RemoteAddress && found.RemotePort == conn.RemotePort {
+			conn.RTT, conn.Retransmits, conn.CongestionWindow = found.RTT, found.Retransmits, found.CongestionWindow
+			return conn, nil
+		}
+	}
+	return conn, ErrNoTCPInfo
+}
+
+// Traffic is how much a TCP connection has sent and received since it was opened
+type Traffic struct {
+	Connection Connection
+	Sent       uint64 // The bytes the other end has acknowledged
+	Received   uint64
+}
+
+// TCPTraffic asks the kernel over netlink how much every TCP connection has sent and received, for working out how
+// fast they're going from two calls a moment apart. Only the connections' addresses and ports are filled in. It only
+// works on Linux 4.2 or later, for connections in our own network namespace.
+func TCPTraffic(ctx context.Context) ([]Traffic, error) {
+	var traffic []Traffic
+	for _, ipv6 := range []bool{false, true} {
+		messages, err := dumpDiag(ctx, "TCP", ipv6)
+		if err != nil && ipv6 {
+			// IPv6 might be disabled
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range messages {
+			socket, err := parseDiagMessage(message, "TCP")
+			if err != nil || socket.conn.RemotePort == "" {
+				continue
+			}
+			traffic = append(traffic, Traffic{Connection: socket.conn, Sent: socket.sent, Received: socket.received})
+		}
+	}
+	return traffic, nil
+}
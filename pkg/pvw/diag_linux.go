@@ -0,0 +1,86 @@
+//go:build linux
+
+package pvw
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"syscall"
+)
+
+// The netlink family and message type for sock_diag, which the syscall package doesn't have
+const (
+	netlinkInetDiag  = 4  // NETLINK_INET_DIAG
+	sockDiagByFamily = 20 // SOCK_DIAG_BY_FAMILY
+)
+
+func (diagCollector) Available() bool {
+	socket, err := openDiag()
+	if err != nil {
+		return false
+	}
+	syscall.Close(socket)
+	// The sockets still have to be matched up to their processes through /proc
+	_, err = os.Stat("/proc/net/tcp")
+	return err == nil
+}
+
+// openDiag() opens a netlink socket to ask the kernel about its sockets
+func openDiag() (int, error) {
+	return syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkInetDiag)
+}
+
+// dumpDiag() asks the kernel for every socket of a protocol and IP version, and returns the inet_diag_msg for each
+func dumpDiag(ctx context.Context, protocol string, ipv6 bool) ([][]byte, error) {
+	socket, err := openDiag()
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(socket)
+
+	request := diagRequest(protocol, ipv6)
+	header := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(request))
+	binary.LittleEndian.PutUint32(header[0:4], uint32(syscall.NLMSG_HDRLEN+len(request)))
+	binary.LittleEndian.PutUint16(header[4:6], sockDiagByFamily)
+	binary.LittleEndian.PutUint16(header[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.LittleEndian.PutUint32(header[8:12], 1)
+	kernel := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Sendto(socket, append(header, request...), 0, kernel); err != nil {
+		return nil, os.NewSyscallError("sendto", err)
+	}
+
+	var messages [][]byte
+	buffer := make([]byte, os.Getpagesize()*8)
+	for {
+		// A dump can be big on a busy host, so stop if we've timed out
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n, _, err := syscall.Recvfrom(socket, buffer, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		replies, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			switch reply.Header.Type {
+			case syscall.NLMSG_DONE:
+				return messages, nil
+			case syscall.NLMSG_ERROR:
+				// The error is a negated errno, followed by the request that caused it
+				if len(reply.Data) >= 4 {
+					if errno := -int32(binary.LittleEndian.Uint32(reply.Data[0:4])); errno != 0 {
+						return nil, os.NewSyscallError("sock_diag", syscall.Errno(errno))
+					}
+				}
+				return messages, nil
+			case sockDiagByFamily:
+				messages = append(messages, append([]byte(nil), reply.Data...))
+			}
+		}
+	}
+}
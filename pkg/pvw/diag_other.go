@@ -0,0 +1,15 @@
+//go:build !linux
+
+package pvw
+
+import (
+	"context"
+	"errors"
+)
+
+func (diagCollector) Available() bool { return false }
+
+// dumpDiag() asks the kernel for every socket of a protocol and IP version. Only Linux has sock_diag.
+func dumpDiag(ctx context.Context, protocol string, ipv6 bool) ([][]byte, error) {
+	return nil, errors.New("netlink is only on Linux")
+}
@@ -0,0 +1,172 @@
+package pvw
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// diagMessage() builds an inet_diag_msg the way the kernel sends it, with a tcp_info after it if rtt isn't 0
+func diagMessage(ipv6 bool, state byte, local string, localPort uint16, remote string, remotePort uint16,
+	queues [2]uint32, rtt time.Duration, cwnd, retransmits uint32) []byte {
+	message := make([]byte, diagMessageSize)
+	message[0] = 2
+	if ipv6 {
+		message[0] = 10
+	}
+	message[1] = state
+	binary.BigEndian.PutUint16(message[4:6], localPort)
+	binary.BigEndian.PutUint16(message[6:8], remotePort)
+	for offset, address := range map[int]string{8: local, 24: remote} {
+		ip := net.ParseIP(address)
+		if !ipv6 {
+			ip = ip.To4()
+		}
+		copy(message[offset:], ip)
+	}
+	binary.LittleEndian.PutUint32(message[56:60], queues[0])
+	binary.LittleEndian.PutUint32(message[60:64], queues[1])
+	binary.LittleEndian.PutUint32(message[64:68], 1000)
+	binary.LittleEndian.PutUint32(message[68:72], 4242)
+
+	if rtt != 0 {
+		info := make([]byte, 4+tcpInfoRetransmits+4)
+		binary.LittleEndian.PutUint16(info[0:2], uint16(len(info)))
+		binary.LittleEndian.PutUint16(info[2:4], diagInfo)
+		binary.LittleEndian.PutUint32(info[4+tcpInfoRTT:], uint32(rtt/time.Microsecond))
+		binary.LittleEndian.PutUint32(info[4+tcpInfoCwnd:], cwnd)
+		binary.LittleEndian.PutUint32(info[4+tcpInfoRetransmits:], retransmits)
+		message = append(message, info...)
+	}
+	return message
+}
+
+func TestParseDiagMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  []byte
+		protocol string
+		want     Connection
+	}{
+		{
+			name:     "listening with a backlog",
+			message:  diagMessage(false, 0x0A, "0.0.0.0", 8080, "0.0.0.0", 0, [2]uint32{3, 128}, 0, 0, 0),
+			protocol: "TCP",
+			want: Connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "8080", Status: "LISTEN", RecvQueue: 3,
+				Backlog: 128},
+		},
+		{
+			name: "established with tcp_info",
+			message: diagMessage(false, 0x01, "127.0.0.1", 8080, "127.0.0.1", 51000, [2]uint32{0, 512},
+				1500*time.Microsecond, 10, 2),
+			protocol: "TCP",
+			want: Connection{Protocol: "TCP", LocalAddress: "127.0.0.1", LocalPort: "8080", RemoteAddress: "127.0.0.1",
+				RemotePort: "51000", Status: "ESTABLISHED", SendQueue: 512, RTT: 1500 * time.Microsecond,
+				CongestionWindow: 10, Retransmits: 2},
+		},
+		{
+			name:     "IPv6",
+			message:  diagMessage(true, 0x0A, "::1", 5432, "::", 0, [2]uint32{}, 0, 0, 0),
+			protocol: "TCP",
+			want: Connection{Protocol: "TCP", LocalAddress: "[::1]", LocalPort: "5432", Status: "LISTEN",
+				IPv6: true},
+		},
+		{
+			name:     "UDP doesn't have states",
+			message:  diagMessage(false, 0x07, "0.0.0.0", 53, "0.0.0.0", 0, [2]uint32{}, 0, 0, 0),
+			protocol: "UDP",
+			want:     Connection{Protocol: "UDP", LocalAddress: "*", LocalPort: "53"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			socket, err := parseDiagMessage(test.message, test.protocol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := socket.conn
+			got.LocalName, got.RemoteName = "", ""
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if socket.uid != "1000" || socket.inode != "4242" {
+				t.Errorf("got uid %s and inode %s, want 1000 and 4242", socket.uid, socket.inode)
+			}
+		})
+	}
+
+	t.Run("too short", func(t *testing.T) {
+		if _, err := parseDiagMessage(make([]byte, 40), "TCP"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+// TestParseDiagTraffic checks how much a connection has sent and received is read from newer kernels' tcp_info
+func TestParseDiagTraffic(t *testing.T) {
+	message := diagMessage(false, 0x01, "10.0.0.2", 443, "10.0.0.9", 50000, [2]uint32{}, 0, 0, 0)
+	info := make([]byte, 4+tcpInfoBytesReceived+8)
+	binary.LittleEndian.PutUint16(info[0:2], uint16(len(info)))
+	binary.LittleEndian.PutUint16(info[2:4], diagInfo)
+	binary.LittleEndian.PutUint32(info[4+tcpInfoCwnd:], 10)
+	binary.LittleEndian.PutUint64(info[4+tcpInfoBytesAcked:], 1<<33)
+	binary.LittleEndian.PutUint64(info[4+tcpInfoBytesReceived:], 4096)
+
+	socket, err := parseDiagMessage(append(message, info...), "TCP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if socket.sent != 1<<33 || socket.received != 4096 {
+		t.Errorf("got %d sent and %d received, want %d and 4096", socket.sent, socket.received, uint64(1<<33))
+	}
+}
+
+func TestDiagRequest(t *testing.T) {
+	request := diagRequest("UDP", true)
+	if len(request) != diagRequestSize || request[0] != 10 || request[1] != 17 || request[2] != 1<<(diagInfo-1) {
+		t.Errorf("unexpected request %v", request)
+	}
+}
+
+// TestLookupTCPInfo asks the kernel about a real connection, where netlink is allowed
+func TestLookupTCPInfo(t *testing.T) {
+	if !Diag().Available() {
+		t.Skip("netlink's sock_diag isn't available")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	local, remote := client.LocalAddr().(*net.TCPAddr), client.RemoteAddr().(*net.TCPAddr)
+	conn := Connection{
+		Protocol:      "TCP",
+		LocalAddress:  "127.0.0.1",
+		LocalPort:     strconv.Itoa(local.Port),
+		RemoteAddress: "127.0.0.1",
+		RemotePort:    strconv.Itoa(remote.Port),
+	}
+	found, err := LookupTCPInfo(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.CongestionWindow == 0 {
+		t.Errorf("expected a congestion window, got %+v", found)
+	}
+
+	conn.RemotePort = "1"
+	if _, err := LookupTCPInfo(context.Background(), conn); err != ErrNoTCPInfo {
+		t.Errorf("expected ErrNoTCPInfo for a connection that doesn't exist, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package pvw_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// Find out what's listening on port 8080, and ask it to stop
+func ExampleFindPort() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collector, err := pvw.Auto()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	processes, err := pvw.FindPort(ctx, collector, 8080)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, proc := range processes {
+		fmt.Printf("%s (%d) has port 8080 open\n", proc.Name, proc.ID)
+
+		if err := pvw.Kill(proc.ID); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
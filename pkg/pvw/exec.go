@@ -0,0 +1,6 @@
+//go:build !pure
+
+package pvw
+
+// Pure is true in builds made with the pure build tag, which never run other programs (see pure.go)
+const Pure = false
@@ -0,0 +1,160 @@
+package pvw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run `go test ./pkg/pvw -update` to rewrite the golden files after changing a parser on purpose
+var update = flag.Bool("update", false, "update the golden files")
+
+// The fixtures in testdata, by the parser they're for: ss -tunap output, netstat -tunap (or -tuna) output, and the
+// socket tables in /proc/net, named after the table so the protocol and IP version are known
+var goldenParsers = []struct {
+	prefix string
+	parse  func(name string, raw string) string
+}{
+	{"ss", func(_ string, raw string) string { return dumpProcesses(parseSs(raw)) }},
+	{"netstat", func(_ string, raw string) string { return dumpProcesses(parseNetstat(raw)) }},
+	{"proc", func(name string, raw string) string {
+		table := strings.TrimPrefix(name, "proc_")
+		protocol := strings.ToUpper(strings.TrimSuffix(table, "6"))
+		return dumpSockets(parseProcNet(raw, protocol, strings.HasSuffix(table, "6")))
+	}},
+}
+
+// dumpConnection() writes out a connection in a stable, readable format, the same way internal/lsof's tests do
+func dumpConnection(b *strings.Builder, conn Connection) {
+	fmt.Fprintf(b, "\t%s ipv6=%t local=%s:%s (%s) remote=%s:%s (%s) status=%q queues=%d/%d backlog=%d\n",
+		conn.Protocol, conn.IPv6,
+		conn.LocalAddress, conn.LocalPort, conn.LocalName,
+		conn.RemoteAddress, conn.RemotePort, conn.RemoteName,
+		conn.Status, conn.RecvQueue, conn.SendQueue, conn.Backlog)
+}
+
+// dumpProcesses() writes out parsed processes and warnings. Usernames come from /proc on the machine running the tests,
+// so they're left out.
+func dumpProcesses(processes []Process, warnings []Warning) string {
+	var b strings.Builder
+	for _, proc := range processes {
+		fmt.Fprintf(&b, "process %d name=%q\n", proc.ID, proc.Name)
+		for _, conn := range proc.Connections {
+			dumpConnection(&b, conn)
+		}
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(&b, "warning %s\n", warning)
+	}
+	return b.String()
+}
+
+// dumpSockets() writes out the sockets parsed from a /proc/net table, and its warnings
+func dumpSockets(sockets []procSocket, warnings []Warning) string {
+	var b strings.Builder
+	for _, socket := range sockets {
+		fmt.Fprintf(&b, "socket inode=%s uid=%s\n", socket.inode, socket.uid)
+		dumpConnection(&b, socket.conn)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(&b, "warning %s\n", warning)
+	}
+	return b.String()
+}
+
+// checkGolden() compares got with the golden file, or rewrites the golden file if -update was passed
+func checkGolden(t *testing.T, goldenPath string, got string) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run with -update to create it)", err)
+	}
+
+	if got != string(want) {
+		t.Errorf("output doesn't match %s\n--- got ---\n%s\n--- want ---\n%s", goldenPath, got, want)
+	}
+}
+
+// TestParseGolden parses every captured ss, netstat and /proc/net output in testdata and compares the result with its
+// golden file
+func TestParseGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		name := strings.TrimSuffix(filepath.Base(fixture), ".txt")
+
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, parser := range goldenParsers {
+				if name == parser.prefix || strings.HasPrefix(name, parser.prefix+"_") {
+					checkGolden(t, strings.TrimSuffix(fixture, ".txt")+".golden", parser.parse(name, string(raw)))
+					return
+				}
+			}
+			t.Fatalf("no parser for %s", fixture)
+		})
+	}
+}
+
+// TestReadProcNet checks sockets from /proc/net are only kept for the processes that own them, and go to every one
+// that does
+func TestReadProcNet(t *testing.T) {
+	directory := t.TempDir()
+	for _, table := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		raw, err := os.ReadFile(filepath.Join("testdata", "proc_"+table+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(directory, table), raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// PIDs above the kernel's highest possible PID, so no real process' name is read
+	owners := map[string][]int{
+		"31337": {5000001, 5000002}, // Shared by a forking server
+		"60001": {5000003},
+		"22002": {5000004},
+	}
+	processes, warnings := readProcNet(directory, owners, "netns")
+	if len(warnings) != 2 {
+		t.Errorf("expected the 2 broken lines in proc_tcp.txt to be warned about, got %v", warnings)
+	}
+
+	want := map[int]string{5000001: "*:80", 5000002: "*:80", 5000003: "[::1]:3000", 5000004: "*:5353"}
+	if len(processes) != len(want) {
+		t.Fatalf("expected %d processes, got %+v", len(want), processes)
+	}
+	for _, proc := range processes {
+		if len(proc.Connections) != 1 || proc.NetNamespace != "netns" {
+			t.Errorf("expected process %d to have one socket in netns, got %+v", proc.ID, proc)
+			continue
+		}
+		conn := proc.Connections[0]
+		if got := conn.LocalAddress + ":" + conn.LocalPort; got != want[proc.ID] {
+			t.Errorf("expected process %d to have %s, got %s", proc.ID, want[proc.ID], got)
+		}
+	}
+}
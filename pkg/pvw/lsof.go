@@ -0,0 +1,135 @@
+package pvw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// LSOF Processing
+// All the functions relating to getting the processes with ports open from lsof on macOS and Linux. Some lsof builds
+// don't support the T field (TCP/TPI information, which has the state), and refuse to run at all if it's asked for, so
+// the first time lsof runs we check whether it's supported and leave it out if not. Sockets are still found without
+// it, just without their states.
+
+type lsofCollector struct{}
+
+// Lsof returns a Collector that uses lsof. It works on both macOS and Linux, and is the only collector available on
+// macOS.
+func Lsof() Collector { return lsofCollector{} }
+
+func (lsofCollector) Name() string { return "lsof" }
+
+func (lsofCollector) Available() bool { return commandExists("lsof") }
+
+func (c lsofCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	return c.CollectProgressively(ctx, nil)
+}
+
+// CollectProgressively parses lsof's output as it arrives rather than waiting for lsof to finish, which can take a
+// while on a big host, so the processes lsof lists first can be shown straight away
+func (lsofCollector) CollectProgressively(ctx context.Context, partial func([]Process)) ([]Process, []Warning, error) {
+	processes, warnings, err := streamLsof(ctx, partial)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, nil, err
+		}
+		// lsof exits with 1 when it doesn't find anything, which isn't a failure. Anything else is.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return processes, warnings, nil
+}
+
+// streamLsof() runs lsof and parses its output line by line from its stdout with lsof.ParseProgressively(), as it's
+// written. lsof is killed if the context is cancelled or times out before it finishes.
+func streamLsof(ctx context.Context, partial func([]Process)) ([]Process, []Warning, error) {
+	// Command is `lsof -i -Pn -F cPnpLTt`, or `lsof -i -Pn -F cPnpLt` if lsof doesn't support the T field
+	cmd := exec.CommandContext(ctx, "lsof", "-i", "-Pn", "-F", lsofFields())
+	// stderr is kept so a failure can say why, like cmd.Output() does for the other backends
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		debugf("%s failed: %v", cmd.String(), err)
+		return nil, nil, err
+	}
+
+	// The raw output is only kept if it's going to be logged
+	var output io.Reader = stdout
+	var raw strings.Builder
+	if logger != nil {
+		output = io.TeeReader(stdout, &raw)
+	}
+
+	processes, warnings, parseErr := lsof.ParseProgressively(output, partial)
+	if parseErr != nil {
+		// Nothing's reading lsof's output any more, so make sure it doesn't get stuck writing it
+		io.Copy(io.Discard, stdout)
+	}
+
+	// Wait() closes stdout, so it has to come after everything's been read
+	err = cmd.Wait()
+	debugRaw(cmd.String(), raw.String())
+
+	if err != nil {
+		debugf("%s failed: %v", cmd.String(), err)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = stderr.Bytes()
+		}
+		// There's an error, so throw away what was parsed. We'll parse error code 1 (no processes found) later on.
+		return nil, nil, err
+	}
+	return processes, warnings, parseErr
+}
+
+// The fields to ask lsof for, worked out the first time lsof runs
+var (
+	lsofFieldsOnce sync.Once
+	lsofFieldSet   string
+)
+
+// lsofFields() returns the fields to ask lsof for, leaving out T if this lsof doesn't support it
+func lsofFields() string {
+	lsofFieldsOnce.Do(func() {
+		lsofFieldSet = "cPnpLTt"
+		if !lsofSupportsField(lsofFieldSet) {
+			debugf("lsof doesn't support the T field, so states won't be shown")
+			lsofFieldSet = "cPnpLt"
+		}
+	})
+	return lsofFieldSet
+}
+
+// lsofSupportsField() checks if lsof accepts a set of fields, by asking for them for our own process' sockets (which
+// is quick, even if we don't have any). lsof complains about unknown fields on stderr.
+func lsofSupportsField(fields string) bool {
+	var stderr bytes.Buffer
+
+	// Command is `lsof -a -i -Pn -F FIELDS -p PID`
+	cmd := exec.Command("lsof", "-a", "-i", "-Pn", "-F", fields, "-p", strconv.Itoa(os.Getpid()))
+	cmd.Stderr = &stderr
+	cmd.Run()
+
+	complaint := strings.ToLower(stderr.String())
+	return !strings.Contains(complaint, "unknown field") && !strings.Contains(complaint, "unsupported")
+}
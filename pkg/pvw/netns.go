@@ -0,0 +1,142 @@
+package pvw
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Network Namespace Processing
+// Containers usually get their own network namespace, and every collector only sees the sockets in the namespace it's
+// running in, so a container's listeners don't show up at all. Each process' view of the network is in
+// /proc/<pid>/net though, so reading the socket tables of one process in each namespace finds every socket in it,
+// without having to switch namespaces. Like the proc collector, we need to be able to look inside the processes (i.e.
+// be root) to find them.
+
+// NetNamespace is a network namespace other than our own
+type NetNamespace struct {
+	ID   string // The namespace's inode number, e.g. 4026532281
+	Name string // The name given to it by `ip netns`, or its ID if it doesn't have one
+	PID  int    // A process in the namespace, whose /proc/<pid>/net has its sockets
+}
+
+// Where `ip netns add` puts the namespaces it names
+const namedNetnsDirectory = "/run/netns"
+
+type namespaceCollector struct{}
+
+// Namespaces returns a Collector that finds processes with sockets open in every network namespace apart from ours.
+// Every Process it returns has NetNamespace set. It isn't one of the Collectors, as it doesn't find anything in our own
+// namespace (which the other collectors do).
+func Namespaces() Collector { return namespaceCollector{} }
+
+func (namespaceCollector) Name() string { return "netns" }
+
+func (namespaceCollector) Available() bool {
+	_, err := os.Stat("/proc/self/ns/net")
+	return isLinux() && err == nil
+}
+
+func (namespaceCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	namespaces := OtherNetNamespaces()
+	if len(namespaces) == 0 {
+		return nil, nil, nil
+	}
+
+	owners, err := socketOwners(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var processes []Process
+	var warnings []Warning
+	for _, namespace := range namespaces {
+		found, namespaceWarnings := readProcNet(filepath.Join("/proc", strconv.Itoa(namespace.PID), "net"), owners,
+			namespace.Name)
+		debugf("found %d processes in network namespace %s", len(found), namespace.Name)
+
+		processes = append(processes, found...)
+		warnings = append(warnings, namespaceWarnings...)
+	}
+
+	return processes, warnings, nil
+}
+
+// OtherNetNamespaces finds the network namespaces that processes we can look inside of are in, apart from our own
+func OtherNetNamespaces() []NetNamespace {
+	own := netNamespaceID("self")
+	if own == "" {
+		return nil
+	}
+
+	names := namedNetNamespaces()
+	found := make(map[string]NetNamespace)
+
+	nsFiles, _ := filepath.Glob("/proc/[0-9]*/ns/net")
+	for _, nsFile := range nsFiles {
+		pidDirectory := filepath.Dir(filepath.Dir(nsFile))
+		pid, err := strconv.Atoi(filepath.Base(pidDirectory))
+		if err != nil {
+			continue
+		}
+
+		id := netNamespaceID(strconv.Itoa(pid))
+		if id == "" || id == own {
+			continue
+		}
+		if _, exists := found[id]; exists {
+			continue
+		}
+
+		namespace := NetNamespace{ID: id, Name: id, PID: pid}
+		if info, err := os.Stat(nsFile); err == nil {
+			for name, named := range names {
+				if os.SameFile(info, named) {
+					namespace.Name = name
+				}
+			}
+		}
+		found[id] = namespace
+	}
+
+	namespaces := make([]NetNamespace, 0, len(found))
+	for _, namespace := range found {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+
+	return namespaces
+}
+
+// netNamespaceID() returns the inode number of a process' network namespace, from its /proc/<pid>/ns/net link (which
+// looks like net:[4026531840]). It's empty if we're not allowed to look.
+func netNamespaceID(pid string) string {
+	link, err := os.Readlink(filepath.Join("/proc", pid, "ns", "net"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(link, "net:["), "]")
+}
+
+// namedNetNamespaces() returns the namespaces named with `ip netns add`, so they can be matched up with processes'
+// namespaces using os.SameFile
+func namedNetNamespaces() map[string]os.FileInfo {
+	named := make(map[string]os.FileInfo)
+
+	entries, err := os.ReadDir(namedNetnsDirectory)
+	if err != nil {
+		return named
+	}
+	for _, entry := range entries {
+		if info, err := os.Stat(filepath.Join(namedNetnsDirectory, entry.Name())); err == nil {
+			named[entry.Name()] = info
+		}
+	}
+
+	return named
+}
@@ -0,0 +1,171 @@
+package pvw
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Netstat Processing
+// All the functions relating to getting the processes with ports open from netstat on Linux. This is a fallback for
+// minimal systems (like Alpine containers) that don't have lsof or ss. Some netstat builds (and non-root users) can't
+// see which process owns a socket, so those sockets are grouped under an unknown process rather than being hidden.
+
+type netstatCollector struct{}
+
+// Netstat returns a Collector that uses netstat on Linux. Some netstat builds can't tell which process owns a
+// socket, in which case those sockets are put in a Process with an ID of 0 and no name.
+func Netstat() Collector { return netstatCollector{} }
+
+func (netstatCollector) Name() string { return "netstat" }
+
+func (netstatCollector) Available() bool { return isLinux() && commandExists("netstat") }
+
+func (netstatCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	out, err := getNetstat(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	processes, warnings := parseNetstat(out)
+	return processes, warnings, nil
+}
+
+// getNetstat() runs netstat and returns the output as a raw string or an error.
+func getNetstat(ctx context.Context) (string, error) {
+	// Command is `netstat -tunap`: TCP and UDP sockets, numeric ports, all states, and the processes using them
+	out, err := exec.CommandContext(ctx, "netstat", "-tunap").Output()
+
+	if err != nil && ctx.Err() == nil {
+		// BusyBox's netstat can be built without -p, so try again without process information
+		debugf("netstat -tunap failed (%v), trying again without -p", err)
+		out, err = exec.CommandContext(ctx, "netstat", "-tuna").Output()
+	}
+	debugRaw("netstat", string(out))
+
+	if err != nil {
+		debugf("netstat failed: %v", err)
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// Matches the PID/Program name column, e.g. 1234/nginx
+var netstatProgramRegex = regexp.MustCompile(`^(\d+)/(.*)$`)
+
+// netstat mostly uses the same state names as lsof, except for closed sockets
+var netstatStates = map[string]string{
+	"ESTABLISHED": "ESTABLISHED",
+	"SYN_SENT":    "SYN_SENT",
+	"SYN_RECV":    "SYN_RECV",
+	"FIN_WAIT1":   "FIN_WAIT1",
+	"FIN_WAIT2":   "FIN_WAIT2",
+	"TIME_WAIT":   "TIME_WAIT",
+	"CLOSE":       "CLOSED",
+	"CLOSE_WAIT":  "CLOSE_WAIT",
+	"LAST_ACK":    "LAST_ACK",
+	"LISTEN":      "LISTEN",
+	"CLOSING":     "CLOSING",
+}
+
+// parseNetstat() takes the raw string output of netstat and converts it to a slice of process structs. Sockets
+// without a known process are put in a process with a PID of 0 and no name.
+func parseNetstat(raw string) ([]Process, []Warning) {
+	processes := make(map[int]*Process)
+	usernames := make(map[string]string)
+	var warnings []Warning
+
+	// Keep track of the record being parsed, so it can be shown if something panics
+	var current Warning
+	defer lsof.AnnotatePanic(&current)
+
+	for lineIndex, line := range strings.Split(raw, "\n") {
+		current = Warning{Line: lineIndex + 1, Record: line}
+
+		// Each line is: Proto Recv-Q Send-Q Local Address Foreign Address [State] [PID/Program name]
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !(strings.HasPrefix(fields[0], "tcp") || strings.HasPrefix(fields[0], "udp")) {
+			// Header, or a blank line
+			continue
+		}
+
+		if len(fields) < 5 {
+			warnings = append(warnings, Warning{Line: lineIndex + 1, Record: line, Reason: "missing addresses"})
+			continue
+		}
+
+		ipv6 := strings.HasSuffix(fields[0], "6")
+		local, localPort := splitNetstatAddress(fields[3], ipv6)
+		remote, remotePort := splitNetstatAddress(fields[4], ipv6)
+
+		conn := Connection{
+			Protocol:     strings.ToUpper(strings.TrimSuffix(fields[0], "6")),
+			LocalAddress: local,
+			LocalPort:    localPort,
+			IPv6:         ipv6,
+		}
+
+		conn.RecvQueue, _ = strconv.Atoi(fields[1])
+		conn.SendQueue, _ = strconv.Atoi(fields[2])
+
+		// A foreign port of * means there isn't a remote end
+		if remotePort != "*" {
+			conn.RemoteAddress = remote
+			conn.RemotePort = remotePort
+		}
+
+		conn.SetServiceNames()
+		if lsof.KeepRecords {
+			conn.Record = line
+		}
+
+		// UDP sockets usually don't have a state, so the rest of the columns can be the state, the program, or both
+		pid := 0
+		name := ""
+		for i, field := range fields[5:] {
+			if state, exists := netstatStates[field]; exists {
+				conn.Status = state
+			} else if match := netstatProgramRegex.FindStringSubmatch(field); match != nil {
+				pid, _ = strconv.Atoi(match[1])
+				// Program names can have spaces in them, so use the rest of the line
+				name = strings.Join(append([]string{match[2]}, fields[5+i+1:]...), " ")
+				break
+			}
+		}
+
+		proc, exists := processes[pid]
+		if !exists {
+			proc = &Process{ID: pid, Name: name}
+			if pid != 0 {
+				proc.Username = processOwner(pid, usernames)
+			}
+			processes[pid] = proc
+		}
+		proc.Connections = append(proc.Connections, conn)
+	}
+
+	return sortedProcesses(processes), warnings
+}
+
+// splitNetstatAddress() splits a netstat address like 127.0.0.1:80 or ::1:80 into the address and the port. IPv6
+// addresses are put in brackets, the same as lsof.
+func splitNetstatAddress(address string, ipv6 bool) (string, string) {
+	i := strings.LastIndex(address, ":")
+	if i == -1 {
+		return normaliseAddress(address), ""
+	}
+
+	host := address[:i]
+	if ipv6 {
+		host = "[" + host + "]"
+	}
+
+	return normaliseAddress(host), address[i+1:]
+}
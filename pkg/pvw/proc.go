@@ -0,0 +1,300 @@
+package pvw
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// /proc Processing
+// All the functions relating to getting the processes with ports open by reading /proc on Linux. This doesn't need any
+// commands to be installed, but can only find the processes of sockets whose owners we're allowed to look inside of.
+
+type procCollector struct{}
+
+// Proc returns a Collector that uses /proc on Linux, which doesn't need any commands to be installed.
+// Only the sockets of processes we're allowed to look inside of (all of them if we're root) can be found.
+func Proc() Collector { return procCollector{} }
+
+func (procCollector) Name() string { return "proc" }
+
+func (procCollector) Available() bool {
+	_, err := os.Stat("/proc/net/tcp")
+	return isLinux() && err == nil
+}
+
+func (procCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	// Find which process owns each socket, so the sockets in /proc/net can be matched up to them
+	owners, err := socketOwners(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	debugf("found the owners of %d sockets in /proc", len(owners))
+
+	processes, warnings := readProcNet("/proc/net", owners, "")
+	return processes, warnings, nil
+}
+
+// readProcNet() reads the socket tables in a /proc/net directory, and matches each socket up to the processes that own
+// it. Every process found gets the network namespace given (empty for our own).
+func readProcNet(netDirectory string, owners map[string][]int, namespace string) ([]Process, []Warning) {
+	processes := make(map[int]*Process)
+	usernames := make(map[string]string)
+	var warnings []Warning
+
+	for _, table := range []struct {
+		file     string
+		protocol string
+		ipv6     bool
+	}{
+		{"tcp", "TCP", false},
+		{"tcp6", "TCP", true},
+		{"udp", "UDP", false},
+		{"udp6", "UDP", true},
+	} {
+		file := filepath.Join(netDirectory, table.file)
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			// IPv6 might be disabled, so a missing table isn't an error
+			debugf("skipping %s: %v", file, err)
+			continue
+		}
+		debugRaw(file, string(raw))
+
+		sockets, tableWarnings := parseProcNet(string(raw), table.protocol, table.ipv6)
+		warnings = append(warnings, tableWarnings...)
+
+		for _, socket := range sockets {
+			addOwnedSocket(processes, socket, owners, usernames, namespace)
+		}
+	}
+
+	return sortedProcesses(processes), warnings
+}
+
+// addOwnedSocket() adds a socket to each of the processes that own it, adding the processes if they're new. Every
+// process added gets the network namespace given.
+func addOwnedSocket(processes map[int]*Process, socket procSocket, owners map[string][]int,
+	usernames map[string]string, namespace string) {
+	for _, pid := range owners[socket.inode] {
+		proc, exists := processes[pid]
+		if !exists {
+			proc = &Process{
+				ID:           pid,
+				Name:         processName(pid),
+				Username:     lookupUsername(socket.uid, usernames),
+				NetNamespace: namespace,
+			}
+			processes[pid] = proc
+		}
+		proc.Connections = append(proc.Connections, socket.conn)
+	}
+}
+
+// A socket from one of the tables in /proc/net, along with the inode used to find its process and the UID of its owner.
+// Sockets from netlink also have how many bytes they've sent and received.
+type procSocket struct {
+	conn     Connection
+	inode    string
+	uid      string
+	sent     uint64
+	received uint64
+}
+
+// The socket states used in /proc/net/tcp, which are the kernel's TCP_* constants in hex
+var procStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSED",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// parseProcNet() parses one of the socket tables in /proc/net (tcp, tcp6, udp or udp6)
+func parseProcNet(raw string, protocol string, ipv6 bool) ([]procSocket, []Warning) {
+	var sockets []procSocket
+	var warnings []Warning
+
+	lines := strings.Split(raw, "\n")
+	// Skip the header line
+	// Keep track of the record being parsed, so it can be shown if something panics
+	var current Warning
+	defer lsof.AnnotatePanic(&current)
+
+	for lineIndex, line := range lines[1:] {
+		current = Warning{Line: lineIndex + 2, Record: line}
+
+		warn := func(reason string) {
+			warnings = append(warnings, Warning{Line: lineIndex + 2, Record: line, Reason: reason})
+		}
+
+		// Each line is: sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 10 {
+			warn("missing fields")
+			continue
+		}
+
+		local, localPort := decodeProcAddress(fields[1], ipv6)
+		remote, remotePort := decodeProcAddress(fields[2], ipv6)
+		if localPort == "" || remotePort == "" {
+			warn("invalid address")
+			continue
+		}
+
+		conn := Connection{
+			Protocol:     protocol,
+			LocalAddress: local,
+			LocalPort:    localPort,
+			IPv6:         ipv6,
+		}
+
+		// UDP doesn't really have states, and lsof doesn't show them, so only use them for TCP
+		if protocol == "TCP" {
+			conn.Status = procStates[fields[3]]
+		}
+
+		// The queues are hex, e.g. 00000000:00000000. For a listening socket, rx_queue is the connections waiting to be
+		// accepted.
+		if tx, rx, found := strings.Cut(fields[4], ":"); found {
+			sendQueue, _ := strconv.ParseInt(tx, 16, 64)
+			recvQueue, _ := strconv.ParseInt(rx, 16, 64)
+			conn.SendQueue, conn.RecvQueue = int(sendQueue), int(recvQueue)
+		}
+
+		// A remote port of 0 means there isn't a remote end
+		if remotePort != "0" {
+			conn.RemoteAddress = remote
+			conn.RemotePort = remotePort
+		}
+
+		conn.SetServiceNames()
+		if lsof.KeepRecords {
+			conn.Record = line
+		}
+
+		sockets = append(sockets, procSocket{conn: conn, uid: fields[7], inode: fields[9]})
+	}
+
+	return sockets, warnings
+}
+
+// decodeProcAddress() converts an address from /proc/net like 0100007F:1F90 to 127.0.0.1 and 8080. The address is
+// stored as hex in the kernel's byte order, 4 bytes at a time, and the port is big-endian hex.
+func decodeProcAddress(raw string, ipv6 bool) (string, string) {
+	parts := strings.Split(raw, ":")
+	if len(parts) != 2 {
+		return "", ""
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return "", ""
+	}
+
+	addressBytes, err := hex.DecodeString(parts[0])
+	if err != nil || len(addressBytes)%4 != 0 {
+		return "", ""
+	}
+
+	// Reverse each group of 4 bytes to get the address in network order (this assumes a little-endian machine,
+	// which covers everything pvw is realistically run on)
+	for i := 0; i < len(addressBytes); i += 4 {
+		addressBytes[i], addressBytes[i+3] = addressBytes[i+3], addressBytes[i]
+		addressBytes[i+1], addressBytes[i+2] = addressBytes[i+2], addressBytes[i+1]
+	}
+
+	return formatIP(net.IP(addressBytes), ipv6), strconv.FormatUint(port, 10)
+}
+
+// formatIP() converts an IP address in network order to the format lsof uses
+func formatIP(ip net.IP, ipv6 bool) string {
+	address := ip.String()
+	if ipv6 {
+		if ip.To4() != nil && !ip.IsUnspecified() {
+			// IPv4 mapped IPv6 addresses get printed as IPv4 addresses, but lsof shows them as IPv6
+			address = "::ffff:" + address
+		}
+		address = "[" + address + "]"
+	}
+	return normaliseAddress(address)
+}
+
+// socketOwners() looks through the open files of every process we're allowed to see, and returns a map of socket
+// inodes to the PIDs that have that socket open.
+func socketOwners(ctx context.Context) (map[string][]int, error) {
+	owners := make(map[string][]int)
+
+	fdDirs, err := filepath.Glob("/proc/[0-9]*/fd")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fdDir := range fdDirs {
+		// Reading every process' files can take a while, so stop if we've timed out
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(fdDir)))
+		if err != nil {
+			continue
+		}
+
+		// This fails for other users' processes unless we're root, so just skip them
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if !containsInt(owners[inode], pid) {
+				owners[inode] = append(owners[inode], pid)
+			}
+		}
+	}
+
+	return owners, nil
+}
+
+// processName() gets the command name of a process from /proc
+func processName(pid int) string {
+	comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// containsInt() checks if a slice of ints contains a value
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+//go:build pure
+
+package pvw
+
+// Pure is true in builds made with the pure build tag, which never run other programs. Only the diag and proc
+// collectors can be used, and everything else that would run a command acts as if it isn't installed.
+const Pure = true
@@ -0,0 +1,308 @@
+// Package pvw finds the processes that have network ports open, so other Go tools can answer "what's on port X"
+// without scraping the pvw TUI. It's the same code the TUI uses.
+//
+// A Collector finds every process with a socket open using a particular tool (lsof, ss, netstat or /proc). Auto picks
+// the best one available on this system:
+//
+//	collector, err := pvw.Auto()
+//	if err != nil {
+//		return err
+//	}
+//
+//	processes, err := pvw.FindPort(ctx, collector, 8080)
+//	if err != nil {
+//		return err
+//	}
+//
+//	for _, proc := range processes {
+//		fmt.Println(proc.ID, proc.Name)
+//	}
+//
+// This package follows semantic versioning along with the rest of the module, so anything exported here won't change
+// in an incompatible way without a new major version. Nothing under internal/ makes that promise.
+package pvw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Important Structs
+
+// Process is a process with at least one socket open. Contains a PID, the name of the executable responsible for that
+// process, the username of the user that owns it, and the connections it has open. A Process with an ID of 0 holds
+// sockets whose owner the Collector couldn't find out. Directory is left empty by every Collector.
+type Process = lsof.Process
+
+// Connection is an open socket. Contains a protocol type (TCP or UDP), connection status, local address and port,
+// remote address and port if there is a remote end, friendly names for the ports where they're well known, and the
+// sizes of its queues. Only SS and Diag fill in Backlog, and only Diag fills in RTT, Retransmits and
+// CongestionWindow.
+type Connection = lsof.Connection
+
+// Warning is a record in a Collector's output that couldn't be parsed, and was skipped
+type Warning = lsof.Warning
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Collectors
+
+// Collector finds every process with a socket open
+type Collector interface {
+	// Name returns a short name for the collector, e.g. "lsof"
+	Name() string
+
+	// Available checks if the collector can run on this system
+	Available() bool
+
+	// Collect gets every process with a socket open. Records that can't be parsed are skipped and returned as
+	// warnings, rather than failing the whole collection. Any commands run are killed if ctx is cancelled.
+	Collect(ctx context.Context) ([]Process, []Warning, error)
+}
+
+// ProgressiveCollector is a Collector that can hand over what it's found so far while it's still collecting, so the
+// results from something slow to finish can be shown as they arrive
+type ProgressiveCollector interface {
+	Collector
+
+	// CollectProgressively is Collect, but calls partial with every process found so far each time it finds another.
+	// The slice partial is given is only valid until it returns, but the processes in it won't change, so they can be
+	// copied out of it and kept. partial is called from the goroutine CollectProgressively was called from.
+	CollectProgressively(ctx context.Context, partial func([]Process)) ([]Process, []Warning, error)
+}
+
+// ErrNoCollector is returned by Auto if none of the collectors can run on this system
+var ErrNoCollector = errors.New("no supported collector found, please install lsof")
+
+// Collectors returns every collector, in order of preference. ss is the fastest, then lsof, then netstat (which might
+// not know the processes), then netlink and /proc as a last resort as they can only see the sockets of processes we're
+// allowed to look inside of. Netlink comes before /proc as it knows more, but containers often block it.
+func Collectors() []Collector {
+	return []Collector{SS(), Lsof(), Netstat(), Diag(), Proc()}
+}
+
+// Auto returns the first collector from Collectors that's available on this system
+func Auto() (Collector, error) {
+	for _, c := range Collectors() {
+		if c.Available() {
+			return c, nil
+		}
+	}
+	return nil, ErrNoCollector
+}
+
+// FindPort returns the processes that have a socket open with the given local port, with only those connections in
+// each process. Warnings from the collector are ignored.
+func FindPort(ctx context.Context, c Collector, port int) ([]Process, error) {
+	processes, _, err := c.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	portString := strconv.Itoa(port)
+	var found []Process
+
+	for _, proc := range processes {
+		var connections []Connection
+		for _, conn := range proc.Connections {
+			if conn.LocalPort == portString {
+				connections = append(connections, conn)
+			}
+		}
+
+		if len(connections) > 0 {
+			proc.Connections = connections
+			found = append(found, proc)
+		}
+	}
+
+	return found, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Killing Processes
+
+// ErrUnknownProcess is returned when trying to signal a Process with an ID of 0, whose owner wasn't found
+var ErrUnknownProcess = errors.New("the process is unknown, so it can't be signalled")
+
+// Kill asks a process to terminate, by sending it SIGTERM
+func Kill(pid int) error {
+	return Signal(pid, syscall.SIGTERM)
+}
+
+// Signal sends a signal to a process
+func Signal(pid int, sig syscall.Signal) error {
+	if pid <= 0 {
+		return ErrUnknownProcess
+	}
+
+	debugf("sending %s to %d", sig, pid)
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.Signal(sig); err != nil {
+		debugf("sending %s to %d failed: %v", sig, pid, err)
+		return fmt.Errorf("couldn't signal process %d: %w", pid, err)
+	}
+	return nil
+}
+
+// Exists checks if a process is still running. It can't tell on Windows, where it assumes the process is running if
+// it can be opened.
+func Exists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		proc.Release()
+		return true
+	}
+
+	// Signal 0 isn't sent, but still fails if there's no process to send it to. Other users' processes can't be
+	// signalled, but they're still there.
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Exited checks if an error from Signal (or Kill) means the process had already exited
+func Exited(err error) bool {
+	return errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Debug Logging
+
+// Logger is anything that can write debug logs, like a *log.Logger
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// The logger set with SetLogger, or nil if logging is disabled
+var logger Logger
+
+// SetLogger turns on debug logging. The raw output of every command a collector runs is logged, along with the
+// commands themselves and any signals sent. Pass nil to turn it off again.
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// KeepRecords makes the collectors keep the raw output each connection was parsed from in its Record, so a connection
+// that looks wrong can be checked against what the collector saw. It's off by default, as it's slow on big hosts.
+func KeepRecords(keep bool) {
+	lsof.KeepRecords = keep
+}
+
+// debugf() writes a line to the debug log, if there is one
+func debugf(format string, args ...any) {
+	if logger != nil {
+		logger.Printf(format, args...)
+	}
+}
+
+// debugRaw() writes the raw output of a command or file to the debug log, indented so it's easy to tell apart from the
+// log lines around it
+func debugRaw(source string, raw string) {
+	if logger == nil {
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(raw, "\n"), "\n")
+	logger.Printf("raw output of %s (%d lines, %d bytes):\n\t%s", source, len(lines), len(raw), strings.Join(lines, "\n\t"))
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// commandExists() checks if a command is installed and on the $PATH. Pure builds never run commands, so to them nothing
+// is installed.
+func commandExists(command string) bool {
+	if Pure {
+		return false
+	}
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+// isLinux() checks if we're running on Linux, as the ss, netstat and proc collectors are Linux only
+func isLinux() bool {
+	return runtime.GOOS == "linux"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Helpers shared between the ss, netstat and proc collectors, which need to fill in some information themselves that
+// lsof gives us for free.
+
+// normaliseAddress() converts an address to the format lsof uses, so that each collector's output looks the same.
+// Wildcard addresses become '*', and interface names (e.g. %lo) are removed.
+func normaliseAddress(address string) string {
+	if i := strings.Index(address, "%"); i != -1 {
+		address = address[:i]
+		if strings.HasPrefix(address, "[") {
+			address += "]"
+		}
+	}
+
+	switch address {
+	case "0.0.0.0", "[::]", "::", "*":
+		return "*"
+	}
+	return address
+}
+
+// lookupUsername() converts a UID to a username, falling back to the UID if there's no user with it. Usernames are
+// cached, as lots of sockets usually belong to the same few users.
+func lookupUsername(uid string, cache map[string]string) string {
+	if username, exists := cache[uid]; exists {
+		return username
+	}
+
+	username := uid
+	if u, err := user.LookupId(uid); err == nil {
+		username = u.Username
+	}
+
+	cache[uid] = username
+	return username
+}
+
+// processOwner() gets the username of the user that owns a process from /proc
+func processOwner(pid int, cache map[string]string) string {
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(status), "\n") {
+		// The line looks like "Uid:	1000	1000	1000	1000", and we want the real UID (the first one)
+		if strings.HasPrefix(line, "Uid:") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				return lookupUsername(fields[1], cache)
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,220 @@
+package pvw
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// SS Processing
+// All the functions relating to getting the processes with ports open from ss on Linux. ss asks the kernel directly
+// rather than looking through every process' open files, so it's much faster than lsof on busy hosts.
+
+type ssCollector struct{}
+
+// SS returns a Collector that uses ss on Linux. It's much faster than lsof on busy hosts.
+func SS() Collector { return ssCollector{} }
+
+func (ssCollector) Name() string { return "ss" }
+
+func (ssCollector) Available() bool { return isLinux() && commandExists("ss") }
+
+func (ssCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	out, err := getSs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	processes, warnings := parseSs(out)
+	return processes, warnings, nil
+}
+
+// getSs() runs ss and returns the output as a raw string or an error.
+func getSs(ctx context.Context) (string, error) {
+	// Command is `ss -tunap`: TCP and UDP sockets, numeric ports, all states, and the processes using them
+	cmd := exec.CommandContext(ctx, "ss", "-tunap")
+	out, err := cmd.Output()
+	debugRaw(cmd.String(), string(out))
+
+	if err != nil {
+		debugf("%s failed: %v", cmd.String(), err)
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// Matches each process in the process column, which looks like users:(("nginx",pid=1234,fd=6),("nginx",pid=1235,fd=6))
+var ssUserRegex = regexp.MustCompile(`\("((?:[^"\\]|\\.)*)",pid=(\d+),fd=\d+\)`)
+
+// ss uses slightly different names for states than lsof, so convert them
+var ssStates = map[string]string{
+	"ESTAB":      "ESTABLISHED",
+	"SYN-SENT":   "SYN_SENT",
+	"SYN-RECV":   "SYN_RECV",
+	"FIN-WAIT-1": "FIN_WAIT1",
+	"FIN-WAIT-2": "FIN_WAIT2",
+	"TIME-WAIT":  "TIME_WAIT",
+	"UNCONN":     "", // lsof doesn't give unconnected UDP sockets a state
+	"CLOSE-WAIT": "CLOSE_WAIT",
+	"LAST-ACK":   "LAST_ACK",
+	"LISTEN":     "LISTEN",
+	"CLOSING":    "CLOSING",
+	"CLOSED":     "CLOSED",
+}
+
+// parseSs() takes the raw string output of ss and converts it to a slice of process structs. Sockets that ss can't
+// find a process for (usually because they belong to another user) are skipped, the same as lsof does.
+func parseSs(raw string) ([]Process, []Warning) {
+	processes := make(map[int]*Process)
+	usernames := make(map[string]string)
+	var warnings []Warning
+
+	// Keep track of the record being parsed, so it can be shown if something panics
+	var current Warning
+	defer lsof.AnnotatePanic(&current)
+
+	for lineIndex, line := range strings.Split(raw, "\n") {
+		current = Warning{Line: lineIndex + 1, Record: line}
+
+		// Each line is: Netid State Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+		fields := strings.Fields(line)
+		usersIndex := strings.Index(line, "users:")
+		if len(fields) < 7 || usersIndex == -1 {
+			// Header, or a socket without a process
+			continue
+		}
+
+		local, localPort := splitSsAddress(fields[4])
+		remote, remotePort := splitSsAddress(fields[5])
+
+		conn := Connection{
+			Protocol:     strings.ToUpper(fields[0]),
+			Status:       ssStates[fields[1]],
+			LocalAddress: local,
+			LocalPort:    localPort,
+			// ss shows dual-stack IPv6 sockets bound to [::] as *
+			IPv6: strings.HasPrefix(fields[4], "[") || strings.HasPrefix(fields[4], "*"),
+		}
+
+		// For listening sockets, Recv-Q is the connections waiting to be accepted and Send-Q is the backlog
+		recvQueue, _ := strconv.Atoi(fields[2])
+		sendQueue, _ := strconv.Atoi(fields[3])
+		conn.RecvQueue = recvQueue
+		if conn.Status == "LISTEN" {
+			conn.Backlog = sendQueue
+		} else {
+			conn.SendQueue = sendQueue
+		}
+
+		// A peer of *:* means there isn't one
+		if remotePort != "*" {
+			conn.RemoteAddress = remote
+			conn.RemotePort = remotePort
+		}
+
+		conn.SetServiceNames()
+		if lsof.KeepRecords {
+			conn.Record = line
+		}
+
+		// Process names can have spaces in them, so use everything after the peer address rather than the fields
+		matches := ssUserRegex.FindAllStringSubmatch(line[usersIndex:], -1)
+		if len(matches) == 0 {
+			warnings = append(warnings, Warning{Line: lineIndex + 1, Record: line, Reason: "invalid process list"})
+			continue
+		}
+
+		for _, match := range matches {
+			pid, err := strconv.Atoi(match[2])
+			if err != nil {
+				continue
+			}
+
+			// Several processes can share a socket (e.g. a forking server), and lsof lists it under each of them
+			proc, exists := processes[pid]
+			if !exists {
+				proc = &Process{
+					ID:       pid,
+					Name:     strings.ReplaceAll(match[1], `\"`, `"`),
+					Username: processOwner(pid, usernames),
+				}
+				processes[pid] = proc
+			}
+			proc.Connections = append(proc.Connections, conn)
+		}
+	}
+
+	return sortedProcesses(processes), warnings
+}
+
+// splitSsAddress() splits an ss address like 127.0.0.1:80 or [::1]:80 into the address and the port
+func splitSsAddress(address string) (string, string) {
+	i := strings.LastIndex(address, ":")
+	if i == -1 {
+		return normaliseAddress(address), ""
+	}
+
+	return normaliseAddress(address[:i]), address[i+1:]
+}
+
+// sortedProcesses() converts a map of processes to a slice ordered by PID, which is the order lsof lists them in
+func sortedProcesses(processes map[int]*Process) []Process {
+	sorted := make([]Process, 0, len(processes))
+	for _, proc := range processes {
+		sorted = append(sorted, *proc)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return sorted
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Closing Connections
+// ss can also close a single TCP connection without touching the process that has it open, by asking the kernel to
+// destroy the socket (SOCK_DESTROY). The process sees the connection reset, the same as if the other end had gone.
+
+// ErrConnectionNotClosed is returned by CloseConnection when ss didn't close anything. ss doesn't say why, but it's
+// almost always missing privileges (it needs root, or CAP_NET_ADMIN) or a kernel built without
+// CONFIG_INET_DIAG_DESTROY. The connection might also have closed on its own.
+var ErrConnectionNotClosed = errors.New("ss didn't close the connection")
+
+// ErrCantCloseConnection is returned by CloseConnection for connections ss can't close: anything other than a TCP
+// connection with both ends known, or anything at all when ss isn't installed
+var ErrCantCloseConnection = errors.New("only TCP connections can be closed, on Linux with ss installed")
+
+// CloseConnection closes a single TCP connection, leaving the process that has it open running
+func CloseConnection(ctx context.Context, conn Connection) error {
+	if !isLinux() || !commandExists("ss") || conn.Protocol != "TCP" || conn.RemotePort == "" ||
+		conn.LocalAddress == "*" || conn.RemoteAddress == "*" {
+		return ErrCantCloseConnection
+	}
+
+	// Command is `ss -K -tn src ADDRESS:PORT dst ADDRESS:PORT`, with IPv6 addresses in brackets as pvw has them. ss
+	// prints the sockets it destroyed after its header, so a header on its own means nothing was closed.
+	cmd := exec.CommandContext(ctx, "ss", "-K", "-tn",
+		"src", conn.LocalAddress+":"+conn.LocalPort, "dst", conn.RemoteAddress+":"+conn.RemotePort)
+	out, err := cmd.Output()
+	debugRaw(cmd.String(), string(out))
+	if err != nil {
+		debugf("%s failed: %v", cmd.String(), err)
+		return err
+	}
+
+	if len(strings.Split(strings.TrimSpace(string(out)), "\n")) < 2 {
+		return ErrConnectionNotClosed
+	}
+	return nil
+}
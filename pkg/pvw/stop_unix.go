@@ -0,0 +1,16 @@
+//go:build !windows
+
+package pvw
+
+import "syscall"
+
+// Stop pauses a process, by sending it SIGSTOP. It keeps its sockets open (so nothing else can take its ports) until
+// it's terminated or resumed with Continue.
+func Stop(pid int) error {
+	return Signal(pid, syscall.SIGSTOP)
+}
+
+// Continue resumes a process paused with Stop, by sending it SIGCONT
+func Continue(pid int) error {
+	return Signal(pid, syscall.SIGCONT)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package pvw
+
+import "errors"
+
+// ErrNoStop is returned by Stop and Continue on Windows, which doesn't have SIGSTOP and SIGCONT
+var ErrNoStop = errors.New("processes can't be paused on Windows")
+
+// Stop pauses a process. Windows doesn't have SIGSTOP, so it always fails.
+func Stop(pid int) error { return ErrNoStop }
+
+// Continue resumes a paused process. Windows doesn't have SIGCONT, so it always fails.
+func Continue(pid int) error { return ErrNoStop }
@@ -0,0 +1,185 @@
+package pvw
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/allyring/pvw/internal/lsof"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// WSL Processing
+// Under WSL2, Linux and Windows share the same localhost ports (Windows forwards them to Linux), so a Windows program
+// listening on a port stops a Linux one from using it - and none of the Linux collectors can see it, as it's not a
+// Linux process. WSL lets us run Windows programs, so the Windows collector asks netstat.exe instead.
+
+type windowsCollector struct{}
+
+// Windows returns a Collector that finds Windows processes with sockets open, when running under WSL. Every Process
+// it returns has Windows set, and their IDs are Windows PIDs, so they can't be signalled with Kill or Signal (use
+// KillWindows instead). It isn't one of the Collectors, as it doesn't find any Linux processes.
+func Windows() Collector { return windowsCollector{} }
+
+func (windowsCollector) Name() string { return "netstat.exe" }
+
+func (windowsCollector) Available() bool { return InWSL() && commandExists("netstat.exe") }
+
+func (windowsCollector) Collect(ctx context.Context) ([]Process, []Warning, error) {
+	// Command is `netstat.exe -ano`: all sockets, numeric addresses, and the PID that owns them
+	out, err := exec.CommandContext(ctx, "netstat.exe", "-ano").Output()
+	debugRaw("netstat.exe", string(out))
+	if err != nil {
+		debugf("netstat.exe failed: %v", err)
+		return nil, nil, err
+	}
+
+	processes, warnings := parseWindowsNetstat(string(out), windowsProcessNames(ctx))
+	return processes, warnings, nil
+}
+
+// InWSL checks if we're running under the Windows Subsystem for Linux
+func InWSL() bool {
+	if !isLinux() {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+
+	// WSL's kernels have "microsoft" in their release, e.g. 5.15.90.1-microsoft-standard-WSL2
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// KillWindows asks a Windows process to terminate from inside WSL, using taskkill.exe
+func KillWindows(pid int) error {
+	if pid <= 0 {
+		return ErrUnknownProcess
+	}
+
+	// Command is `taskkill.exe /PID PID`
+	debugf("running taskkill.exe for Windows process %d", pid)
+	return exec.Command("taskkill.exe", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// windowsProcessNames() gets the name of every Windows process from tasklist.exe, by PID. If tasklist.exe fails, the
+// processes are left without names.
+func windowsProcessNames(ctx context.Context) map[int]string {
+	names := make(map[int]string)
+
+	// Command is `tasklist.exe /fo csv /nh`, which prints "name","PID","session name","session #","memory" per process
+	out, err := exec.CommandContext(ctx, "tasklist.exe", "/fo", "csv", "/nh").Output()
+	if err != nil {
+		debugf("tasklist.exe failed: %v", err)
+		return names
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(out)))
+	reader.FieldsPerRecord = -1
+	records, _ := reader.ReadAll()
+
+	for _, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		if pid, err := strconv.Atoi(record[1]); err == nil {
+			names[pid] = record[0]
+		}
+	}
+
+	return names
+}
+
+// netstat.exe uses slightly different state names to lsof
+var windowsStates = map[string]string{
+	"LISTENING":    "LISTEN",
+	"ESTABLISHED":  "ESTABLISHED",
+	"SYN_SENT":     "SYN_SENT",
+	"SYN_RECEIVED": "SYN_RECV",
+	"FIN_WAIT_1":   "FIN_WAIT1",
+	"FIN_WAIT_2":   "FIN_WAIT2",
+	"TIME_WAIT":    "TIME_WAIT",
+	"CLOSED":       "CLOSED",
+	"CLOSE_WAIT":   "CLOSE_WAIT",
+	"LAST_ACK":     "LAST_ACK",
+	"CLOSING":      "CLOSING",
+}
+
+// parseWindowsNetstat() takes the raw output of netstat.exe -ano and converts it to a slice of process structs, using
+// names to fill in the name of each process
+func parseWindowsNetstat(raw string, names map[int]string) ([]Process, []Warning) {
+	processes := make(map[int]*Process)
+	var warnings []Warning
+
+	// Keep track of the record being parsed, so it can be shown if something panics
+	var current Warning
+	defer lsof.AnnotatePanic(&current)
+
+	for lineIndex, line := range strings.Split(raw, "\n") {
+		// Windows programs end their lines with \r\n
+		line = strings.TrimRight(line, "\r")
+		current = Warning{Line: lineIndex + 1, Record: line}
+
+		// Each line is: Proto Local Address Foreign Address [State] PID. UDP sockets don't have a state.
+		fields := strings.Fields(line)
+		if len(fields) == 0 || (fields[0] != "TCP" && fields[0] != "UDP") {
+			// Header, or a blank line
+			continue
+		}
+
+		if len(fields) < 4 {
+			warnings = append(warnings, Warning{Line: lineIndex + 1, Record: line, Reason: "missing fields"})
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			warnings = append(warnings, Warning{Line: lineIndex + 1, Record: line, Reason: "invalid PID"})
+			continue
+		}
+
+		// IPv6 addresses are in brackets, e.g. [::]:135
+		ipv6 := strings.HasPrefix(fields[1], "[")
+		local, localPort := splitNetstatAddress(fields[1], false)
+		remote, remotePort := splitNetstatAddress(fields[2], false)
+
+		conn := Connection{
+			Protocol:     fields[0],
+			LocalAddress: local,
+			LocalPort:    localPort,
+			IPv6:         ipv6,
+		}
+
+		// A foreign address of *:* or port 0 means there isn't a remote end
+		if remotePort != "*" && remotePort != "0" {
+			conn.RemoteAddress = remote
+			conn.RemotePort = remotePort
+		}
+
+		if len(fields) == 5 {
+			conn.Status = fields[3]
+			if state, exists := windowsStates[fields[3]]; exists {
+				conn.Status = state
+			}
+		}
+
+		conn.SetServiceNames()
+		if lsof.KeepRecords {
+			conn.Record = line
+		}
+
+		proc, exists := processes[pid]
+		if !exists {
+			proc = &Process{ID: pid, Name: names[pid], Windows: true}
+			processes[pid] = proc
+		}
+		proc.Connections = append(proc.Connections, conn)
+	}
+
+	return sortedProcesses(processes), warnings
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Plain Mode
+// With --plain (or --accessible), pvw doesn't draw the table at all. bubbletea's renderer is turned off, and instead
+// each change is written out as a line of plain text with labels, no colours, and no box drawing. Screen readers read
+// out new lines as they're written, so the list is announced when it changes and the selected connection is announced
+// when the cursor moves. It also means pvw works in dumb terminals that can't handle redrawing the screen.
+
+// announce() writes lines to the output in plain mode. The terminal is still in raw mode so keys can be read, so every
+// line needs a carriage return as well as a newline.
+func (m model) announce(lines ...string) {
+	if m.output == nil || len(lines) == 0 {
+		return
+	}
+	fmt.Fprint(m.output, strings.Join(lines, "\r\n")+"\r\n")
+}
+
+// describeRow() describes a row in the table as a list of labelled values, e.g. "PID: 123, Name: nginx, Port: 80".
+// Only the first row of a process has the process' details in the table, so the rest of its rows borrow them from it.
+func (m model) describeRow(row int) string {
+	if row < 0 || row >= len(m.rows) {
+		return ""
+	}
+
+	values := m.rows[row]
+	var first table.Row
+	if processIndex, _, exists := m.rowLocation(row); exists {
+		first = m.rows[m.rowStarts[processIndex]]
+	}
+
+	var labelled []string
+	for i, column := range m.settings.columns {
+		if i >= len(values) {
+			break
+		}
+
+		value := values[i]
+		if value == "" && first != nil {
+			value = first[i]
+		}
+		if value == "" {
+			continue
+		}
+
+		labelled = append(labelled, columnTitle(column.Title)+": "+value)
+	}
+
+	return strings.Join(labelled, ", ")
+}
+
+// announceRows() writes out every row, marking the selected one
+func (m model) announceRows() {
+	if len(m.rows) == 0 {
+		lines := []string{tr("plain.no-connections")}
+		if reasons := m.emptyReasons(); len(reasons) > 0 {
+			lines = append(lines, tr("empty.only"))
+			for _, reason := range reasons {
+				lines = append(lines, "  "+reason)
+			}
+		}
+		m.announce(lines...)
+		return
+	}
+
+	lines := []string{tr("plain.connections", len(m.rows))}
+	for row := range m.rows {
+		marker := "  "
+		if row == m.table.Cursor() {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%d. %s", marker, row+1, m.describeRow(row)))
+	}
+
+	m.announce(lines...)
+}
+
+// announceSelection() writes out the connection under the cursor
+func (m model) announceSelection() {
+	if len(m.rows) == 0 {
+		return
+	}
+	cursor := m.table.Cursor()
+	m.announce(tr("plain.selected", cursor+1, len(m.rows), m.describeRow(cursor)))
+}
+
+// announceHelp() writes out every key that can be pressed, as the help overlay isn't drawn in plain mode (see keyhelp.go)
+func (m model) announceHelp() {
+	lines := []string{tr("plain.keys")}
+	for _, category := range m.keyCategories() {
+		lines = append(lines, tr(category.title)+":")
+		for _, binding := range category.bindings {
+			if binding.Enabled() && binding.Help().Key != "" {
+				lines = append(lines, "  "+binding.Help().Key+": "+binding.Help().Desc)
+			}
+		}
+	}
+	m.announce(lines...)
+}
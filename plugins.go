@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/exp/slices"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Plugins
+// Extra columns and actions can be added in the config file, without changing pvw. Both run a shell command, with
+// information about the process (and for actions, the connection) passed in environment variables:
+//
+//	PVW_PID, PVW_NAME, PVW_OWNER, PVW_DIRECTORY
+//	PVW_PROTOCOL, PVW_STATUS, PVW_LOCAL_ADDRESS, PVW_LOCAL_PORT, PVW_REMOTE_ADDRESS, PVW_REMOTE_PORT (actions only)
+//
+// PVW_DIRECTORY is the same as the Directory column. For example, a column showing how many threads a process has, and
+// a key to open its open files in a pager:
+//
+//	{
+//		"columns": [{"title": "Threads", "width": 7, "command": "ps -o nlwp= -p $PVW_PID"}],
+//		"actions": [{"key": "L", "description": "list open files", "command": "lsof -p $PVW_PID | less"}]
+//	}
+//
+// A column's command is run when a process first shows up (or its connections change), and the first line it prints
+// is used as the value. An action's command takes over the terminal until it finishes, so it can be interactive.
+
+// pluginColumn is an extra column, declared in the config file
+type pluginColumn struct {
+	Title   string `json:"title"`
+	Width   int    `json:"width"`
+	Command string `json:"command"`
+}
+
+// pluginAction is an extra key, declared in the config file
+type pluginAction struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+// How long a column's command can run for before it's killed and the value is left empty
+const pluginColumnTimeout = 2 * time.Second
+
+// The titles of pvw's own columns, which plugin columns can't reuse
+var builtInColumnTitles = []string{
+	"PID", "Name", "Directory", "Project", "Framework", "Owner", "Nice", "CPU", "Memory", "Protocol", "Address", "Port",
+	"Local Address", "Local Port", "Remote Address", "Remote Port", "Status", "Age", "Peer", "Interface", "Exposed",
+	"Privilege", "Recv-Q", "Send-Q", "Backlog", "RTT", "Retrans", "Cwnd", "Protocol Guess", "Pod", "Namespace",
+	"Net NS",
+}
+
+// builtInColumn() checks if a title belongs to one of pvw's own columns
+func builtInColumn(title string) bool {
+	return slices.Contains(builtInColumnTitles, title)
+}
+
+// builtInKey() checks if a key is already used by pvw or the table, so an action can't take it over
+func builtInKey(k string) bool {
+	tableKeys := table.DefaultKeyMap()
+	bindings := []key.Binding{
+		keys.Up, keys.Down, keys.Terminate, keys.Refresh, keys.Search, keys.Escape, keys.Help, keys.Quit,
+		keys.ProbeTLS, keys.ProbeHTTP, keys.Open, keys.OpenDir, keys.Latency, keys.Capture, keys.Trace, keys.Peer, keys.Record,
+		keys.Protocols, keys.Multicast, keys.Dashboard, keys.NetTop, keys.Events, keys.Waits, keys.Forwards, keys.Tunnels, keys.Reestablish, keys.Sockets, keys.Self, keys.Filter, keys.Unfilter, keys.Toggle, keys.Collapse, keys.Expand, keys.Info, keys.Reveal, keys.Children, keys.Renice, keys.Stop, keys.Continue, keys.Close, keys.DescribePod,
+		keys.DismissTips, keys.Sort, keys.Top,
+		keys.PreviousFrame, keys.NextFrame, keys.FirstFrame, keys.LastFrame,
+		tableKeys.PageUp, tableKeys.PageDown, tableKeys.HalfPageUp, tableKeys.HalfPageDown,
+		tableKeys.GotoTop, tableKeys.GotoBottom,
+	}
+
+	for _, binding := range bindings {
+		if slices.Contains(binding.Keys(), k) {
+			return true
+		}
+	}
+	return false
+}
+
+// processEnvironment() returns the environment variables describing a process, added on to pvw's own environment
+func processEnvironment(proc process) []string {
+	directory := proc.Directory
+	if directory == "" && proc.ID != 0 {
+		directory, _ = getCwd(proc.ID)
+	}
+
+	return append(os.Environ(),
+		"PVW_PID="+strconv.Itoa(proc.ID),
+		"PVW_NAME="+proc.Name,
+		"PVW_OWNER="+proc.Username,
+		"PVW_DIRECTORY="+directory,
+	)
+}
+
+// connectionEnvironment() returns the environment variables describing a connection and its process
+func connectionEnvironment(proc process, conn connection) []string {
+	return append(processEnvironment(proc),
+		"PVW_PROTOCOL="+conn.Protocol,
+		"PVW_STATUS="+conn.Status,
+		"PVW_LOCAL_ADDRESS="+conn.LocalAddress,
+		"PVW_LOCAL_PORT="+conn.LocalPort,
+		"PVW_REMOTE_ADDRESS="+conn.RemoteAddress,
+		"PVW_REMOTE_PORT="+conn.RemotePort,
+	)
+}
+
+// pluginValue() runs a column's command for a process, and returns the first line of its output
+func pluginValue(column pluginColumn, proc process) string {
+	if pvw.Pure {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginColumnTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", column.Command)
+	cmd.Env = processEnvironment(proc)
+
+	out, err := cmd.Output()
+	if err != nil {
+		debugf("column %q failed for %d: %v", column.Title, proc.ID, err)
+	}
+
+	value, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return value
+}
+
+// fillPluginColumns() fills in the plugin columns on the first row of a process, the same as the other process
+// columns
+func fillPluginColumns(rows []table.Row, proc process, options settings) {
+	if len(rows) == 0 || len(options.pluginColumns) == 0 {
+		return
+	}
+
+	for i, column := range options.columns {
+		for _, plugin := range options.pluginColumns {
+			if plugin.Title == column.Title {
+				rows[0][i] = pluginValue(plugin, proc)
+			}
+		}
+	}
+}
+
+// actionBindings() creates a key binding for each action, so they show up in the help
+func actionBindings(actions []pluginAction) []key.Binding {
+	bindings := make([]key.Binding, len(actions))
+	for i, action := range actions {
+		bindings[i] = key.NewBinding(
+			key.WithKeys(action.Key),
+			key.WithHelp(action.Key, action.Description),
+		)
+	}
+	return bindings
+}
+
+// runAction() hands the terminal over to an action's command for the selected connection. Once it's finished, the
+// processes are refreshed in case the action changed them.
+func runAction(action pluginAction, proc process, conn connection) tea.Cmd {
+	if pvw.Pure {
+		return func() tea.Msg { return errMsg{errors.New(tr("error.pure-command", "sh"))} }
+	}
+
+	cmd := exec.Command("sh", "-c", action.Command)
+	cmd.Env = connectionEnvironment(proc, conn)
+	debugf("running action %q for %d", action.Command, proc.ID)
+
+	return tea.Exec(actionCommand{cmd}, func(err error) tea.Msg {
+		if err != nil {
+			return errMsg{err}
+		}
+		return refreshMsg{}
+	})
+}
+
+// actionCommand runs an action's command with bubbletea's tea.Exec. In plain mode bubbletea doesn't know where the
+// terminal is (see main()), and doesn't give the command a stdout, so stdout is used instead.
+type actionCommand struct{ *exec.Cmd }
+
+func (c actionCommand) SetStdin(r io.Reader) { c.Stdin = r }
+
+func (c actionCommand) SetStdout(w io.Writer) {
+	if f, isFile := w.(*os.File); w == nil || (isFile && f == nil) {
+		w = os.Stdout
+	}
+	c.Stdout = w
+}
+
+func (c actionCommand) SetStderr(w io.Writer) { c.Stderr = w }
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Policy Checks
+// --check-policy FILE compares what's listening against a policy file declaring what should be, instead of starting
+// the TUI. Anything listening that the policy doesn't allow, and anything the policy expects that isn't listening, is
+// listed, so it can be used as a hardening check on a server:
+//
+//	listeners:
+//	  - process: sshd
+//	    port: 22
+//	    scope: all
+//	  - process: postgres
+//	    port: 5432
+//	    scope: local
+//	  - process: node
+//	    port: 9000-9100
+//	    optional: true
+//
+// Each listener can have:
+//
+//	process   The process name - any process if left out
+//	port      The port, or a range of ports (e.g. 9000-9100) - any port if left out
+//	protocol  TCP or UDP - either if left out
+//	scope     all, local or an interface name, the same as `pvw listen` shows - any scope if left out
+//	optional  If true, it's allowed to listen but doesn't have to
+//
+// The file can be JSON (with the same fields) or YAML, though only the simple YAML above (a list of listeners with one
+// field per line) is understood. The filters (--ports, --tcp...) apply first, so a policy can be checked for only part
+// of the machine. The exit code is 0 if everything matches the policy, 1 if something went wrong, or 3 if something
+// doesn't match (see exit.go).
+
+// policy is a policy file, declaring what should be listening
+type policy struct {
+	Listeners []policyListener `json:"listeners"`
+}
+
+// policyListener is a listener a policy allows or expects
+type policyListener struct {
+	Process  string `json:"process"`
+	Port     string `json:"port"`
+	Protocol string `json:"protocol"`
+	Scope    string `json:"scope"`
+	Optional bool   `json:"optional"`
+}
+
+// UnmarshalJSON() lets ports be given as numbers as well as strings, as most are numbers but ranges have to be strings
+func (l *policyListener) UnmarshalJSON(raw []byte) error {
+	type plain policyListener
+	var fields struct {
+		plain
+		Port json.RawMessage `json:"port"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+
+	*l = policyListener(fields.plain)
+	if len(fields.Port) > 0 {
+		l.Port = strings.Trim(string(fields.Port), `"`)
+	}
+	return nil
+}
+
+// runPolicy() checks what's listening against a policy file, and returns the exit code
+func runPolicy(path string, options settings, w io.Writer) int {
+	rules, err := loadPolicy(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.timeout)
+
+	// Collect the same way the TUI does, so every filter applies
+	var processes []process
+	switch msg := checkProcesses(ctx, cancel, options, nil, nil)().(type) {
+	case processesMsg:
+		processes = msg.processes
+	case collectErrMsg:
+		fmt.Fprintln(os.Stderr, tr("error.running", msg.err))
+		return exitError
+	}
+
+	unexpected, missing := checkPolicy(rules, findListeners(processes, options.showTCP, options.showUDP))
+	for _, l := range unexpected {
+		fmt.Fprintln(w, tr("policy.unexpected", l.protocol, l.port, l.scope, processLabel(l.proc)))
+	}
+	for _, rule := range missing {
+		fmt.Fprintln(w, tr("policy.missing", describeRule(rule)))
+	}
+
+	if len(unexpected) > 0 || len(missing) > 0 {
+		return exitConflict
+	}
+	fmt.Fprintln(w, tr("policy.ok", len(rules.Listeners)))
+	return exitOK
+}
+
+// checkPolicy() returns the listeners the policy doesn't allow, and the listeners it expects that weren't found
+func checkPolicy(rules policy, listeners []listener) ([]listener, []policyListener) {
+	var unexpected []listener
+	found := make([]bool, len(rules.Listeners))
+
+	for _, l := range listeners {
+		allowed := false
+		for i, rule := range rules.Listeners {
+			if ruleMatches(rule, l) {
+				allowed, found[i] = true, true
+			}
+		}
+		if !allowed {
+			unexpected = append(unexpected, l)
+		}
+	}
+
+	var missing []policyListener
+	for i, rule := range rules.Listeners {
+		if !found[i] && !rule.Optional {
+			missing = append(missing, rule)
+		}
+	}
+
+	return unexpected, missing
+}
+
+// ruleMatches() checks if a listener is one a policy's rule allows
+func ruleMatches(rule policyListener, l listener) bool {
+	if rule.Process != "" && rule.Process != l.proc.Name {
+		return false
+	}
+	if rule.Port != "" && !portMatches([]string{rule.Port}, strconv.Itoa(l.port)) {
+		return false
+	}
+	if rule.Protocol != "" && !strings.EqualFold(rule.Protocol, l.protocol) {
+		return false
+	}
+	return rule.Scope == "" || rule.Scope == l.scope
+}
+
+// describeRule() describes a rule the way listeners are described, e.g. "TCP 443 all nginx", with * for anything it
+// leaves out
+func describeRule(rule policyListener) string {
+	parts := []string{rule.Protocol, rule.Port, rule.Scope, rule.Process}
+	for i, part := range parts {
+		if part == "" {
+			parts[i] = "*"
+		}
+	}
+	return strings.ToUpper(parts[0]) + " " + strings.Join(parts[1:], " ")
+}
+
+// loadPolicy() reads a policy file, as JSON or (simple) YAML
+func loadPolicy(path string) (policy, error) {
+	var rules policy
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "{") {
+		err = json.Unmarshal(raw, &rules)
+	} else {
+		rules, err = parsePolicyYAML(string(raw))
+	}
+	if err != nil {
+		return rules, fmt.Errorf(tr("error.policy"), path, err)
+	}
+
+	for _, rule := range rules.Listeners {
+		if _, _, valid := parsePortRange(rule.Port); rule.Port != "" && !valid {
+			return rules, fmt.Errorf(tr("error.policy"), path, fmt.Errorf(tr("error.policy-port"), rule.Port))
+		}
+	}
+	return rules, nil
+}
+
+// parsePolicyYAML() reads the simple YAML policy files are usually written in: a listeners key, then a list of
+// listeners with one `field: value` on each line. Anything fancier (flow style, anchors, multi-line values) isn't
+// understood, and should be written as JSON instead.
+func parsePolicyYAML(text string) (policy, error) {
+	var rules policy
+	var current *policyListener
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if hash := strings.Index(line, "#"); hash != -1 {
+			line = line[:hash]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "" || line == "---":
+			continue
+
+		case line == "listeners:":
+			continue
+
+		case strings.HasPrefix(line, "-"):
+			// The start of a new listener, which can have its first field on the same line
+			rules.Listeners = append(rules.Listeners, policyListener{})
+			current = &rules.Listeners[len(rules.Listeners)-1]
+			line = strings.TrimSpace(strings.TrimPrefix(line, "-"))
+			if line == "" {
+				continue
+			}
+		}
+
+		if current == nil {
+			return rules, fmt.Errorf(tr("error.policy-line"), number, line)
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return rules, fmt.Errorf(tr("error.policy-line"), number, line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch strings.TrimSpace(name) {
+		case "process":
+			current.Process = value
+		case "port":
+			current.Port = value
+		case "protocol":
+			current.Protocol = value
+		case "scope":
+			current.Scope = value
+		case "optional":
+			optional, err := strconv.ParseBool(value)
+			if err != nil {
+				return rules, fmt.Errorf(tr("error.policy-line"), number, line)
+			}
+			current.Optional = optional
+		default:
+			return rules, fmt.Errorf(tr("error.policy-line"), number, line)
+		}
+	}
+
+	if len(rules.Listeners) == 0 {
+		return rules, errors.New(tr("error.policy-empty"))
+	}
+	return rules, nil
+}
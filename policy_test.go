@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePolicyYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		want  []policyListener
+		valid bool
+	}{
+		{
+			name: "one field per line",
+			text: `listeners:
+  - process: sshd
+    port: 22
+    scope: all
+  - process: node
+    port: 9000-9100
+    optional: true
+`,
+			want: []policyListener{
+				{Process: "sshd", Port: "22", Scope: "all"},
+				{Process: "node", Port: "9000-9100", Optional: true},
+			},
+			valid: true,
+		},
+		{
+			name: "quotes, comments and a bare dash",
+			text: `---
+# Only the database
+listeners:
+  -
+    process: "postgres"  # Not pgbouncer
+    port: '5432'
+    protocol: tcp
+`,
+			want:  []policyListener{{Process: "postgres", Port: "5432", Protocol: "tcp"}},
+			valid: true,
+		},
+		{name: "no listeners", text: "listeners:\n"},
+		{name: "a field outside a listener", text: "port: 22\n"},
+		{name: "no colon", text: "listeners:\n  - port 22\n"},
+		{name: "an unknown field", text: "listeners:\n  - user: root\n"},
+		{name: "optional isn't true or false", text: "listeners:\n  - port: 22\n    optional: maybe\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parsePolicyYAML(test.text)
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid to be %t", err, test.valid)
+			}
+			if test.valid && !reflect.DeepEqual(got.Listeners, test.want) {
+				t.Errorf("got %+v, want %+v", got.Listeners, test.want)
+			}
+		})
+	}
+}
+
+// TestPolicyJSONPorts checks ports in JSON policies can be numbers or strings
+func TestPolicyJSONPorts(t *testing.T) {
+	var rules policy
+	raw := `{"listeners": [
+		{"process": "sshd", "port": 22},
+		{"port": "9000-9100", "optional": true},
+		{"scope": "local"}
+	]}`
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []policyListener{
+		{Process: "sshd", Port: "22"},
+		{Port: "9000-9100", Optional: true},
+		{Scope: "local"},
+	}
+	if !reflect.DeepEqual(rules.Listeners, want) {
+		t.Errorf("got %+v, want %+v", rules.Listeners, want)
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	sshd := listener{port: 22, protocol: "TCP", scope: "all", proc: process{ID: 1, Name: "sshd"}}
+	postgres := listener{port: 5432, protocol: "TCP", scope: "local", proc: process{ID: 2, Name: "postgres"}}
+	node := listener{port: 9050, protocol: "TCP", scope: "all", proc: process{ID: 3, Name: "node"}}
+	dns := listener{port: 53, protocol: "UDP", scope: "eth0", proc: process{ID: 4, Name: "dnsmasq"}}
+
+	rules := policy{Listeners: []policyListener{
+		{Process: "sshd", Port: "22", Scope: "all"},
+		{Process: "postgres", Port: "5432", Scope: "local"},
+		{Process: "node", Port: "9000-9100", Optional: true},
+		{Protocol: "udp", Port: "53"},
+	}}
+
+	tests := []struct {
+		name       string
+		listeners  []listener
+		unexpected []listener
+		missing    []policyListener
+	}{
+		{"everything expected", []listener{sshd, postgres, node, dns}, nil, nil},
+		{"optional ones can be missing", []listener{sshd, postgres, dns}, nil, nil},
+		{"a required one is missing", []listener{sshd, dns}, nil, []policyListener{rules.Listeners[1]}},
+		{
+			"listening on the wrong scope",
+			[]listener{sshd, {port: 5432, protocol: "TCP", scope: "all", proc: postgres.proc}, dns},
+			[]listener{{port: 5432, protocol: "TCP", scope: "all", proc: postgres.proc}},
+			[]policyListener{rules.Listeners[1]},
+		},
+		{
+			"something unexpected",
+			[]listener{sshd, postgres, dns, {port: 8080, protocol: "TCP", scope: "all", proc: node.proc}},
+			[]listener{{port: 8080, protocol: "TCP", scope: "all", proc: node.proc}},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unexpected, missing := checkPolicy(rules, test.listeners)
+			if !reflect.DeepEqual(unexpected, test.unexpected) {
+				t.Errorf("got unexpected %+v, want %+v", unexpected, test.unexpected)
+			}
+			if !reflect.DeepEqual(missing, test.missing) {
+				t.Errorf("got missing %+v, want %+v", missing, test.missing)
+			}
+		})
+	}
+}
+
+func TestDescribeRule(t *testing.T) {
+	tests := []struct {
+		rule policyListener
+		want string
+	}{
+		{policyListener{Process: "nginx", Port: "443", Protocol: "tcp", Scope: "all"}, "TCP 443 all nginx"},
+		{policyListener{Port: "9000-9100"}, "* 9000-9100 * *"},
+	}
+
+	for _, test := range tests {
+		if got := describeRule(test.rule); got != test.want {
+			t.Errorf("describeRule(%+v) = %q, want %q", test.rule, got, test.want)
+		}
+	}
+}
+
+// TestPolicyLeavesHistory checks checking a policy only looks at what's listening, without adding it to who-had's
+// history the way the TUI does
+func TestPolicyLeavesHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	historyMutex.Lock()
+	history = nil
+	historyMutex.Unlock()
+
+	rules := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(rules, []byte("listeners:\n  - process: sshd\n    port: 22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backend := &fakeBackend{snapshots: [][]process{{listeningProcess(100, "sshd", "22")}}}
+	options := settings{
+		match: matchExact, showTCP: true, showUDP: true, showIPv4: true, showIPv6: true, timeout: time.Second,
+		backend: backend,
+	}
+
+	if code := runPolicy(rules, options, io.Discard); code != exitOK {
+		t.Fatalf("expected the policy to be met, got exit code %d", code)
+	}
+	if _, err := os.Stat(historyPath()); !os.IsNotExist(err) {
+		t.Errorf("expected no history to be saved, got %v", err)
+	}
+}
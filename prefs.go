@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Preferences
+// How the table was set up while pvw was open is kept for next time: the filters from the filter bar, the search term,
+// which protocols P switched to, and the order O sorted by (each order has its own direction, biggest or oldest first,
+// so there's no direction to keep). They're saved to the state directory ($XDG_STATE_HOME/pvw/preferences.json,
+// usually ~/.local/state) when pvw quits, and put back when it starts. Flags win over saved preferences, so --tcp or
+// --udp still pick the protocols and --sort the order, and --no-persist ignores the file entirely (neither reading nor
+// saving it).
+//
+// The rest of what can change while pvw is open is about what's on screen at the time (the processes collapsed, the
+// children C is showing, whether S hides pvw's own processes) and starts afresh. The columns, the refresh interval and
+// so on can only be set with flags or the config file.
+
+// preferences are what's kept from one run of pvw to the next
+type preferences struct {
+	Filters   string `json:"filters,omitempty"`   // As they're typed in the filter bar
+	Search    string `json:"search,omitempty"`    // The search term
+	Protocols string `json:"protocols,omitempty"` // TCP or UDP if only one is shown, or "" for both
+	Sort      string `json:"sort,omitempty"`      // What the table's sorted by, as --sort takes it
+}
+
+// preferencesPath() returns where the preferences are kept
+func preferencesPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "preferences.json")
+}
+
+// loadPreferences() reads the preferences saved last time, if there are any
+func loadPreferences() (preferences, bool) {
+	var prefs preferences
+
+	raw, err := os.ReadFile(preferencesPath())
+	if err != nil {
+		return prefs, false
+	}
+	if err := json.Unmarshal(raw, &prefs); err != nil {
+		debugf("ignoring the preferences in %s: %v", preferencesPath(), err)
+		return prefs, false
+	}
+	return prefs, prefs != preferences{}
+}
+
+// applyPreferences() puts saved preferences back into the settings, unless a flag says otherwise
+func applyPreferences(prefs preferences, options *settings, flags *pflag.FlagSet) {
+	options.filters = parseFilters(prefs.Filters)
+	options.searchTerm = prefs.Search
+
+	if !flags.Changed("tcp") && !flags.Changed("udp") {
+		switch prefs.Protocols {
+		case "TCP":
+			options.showTCP, options.showUDP = true, false
+		case "UDP":
+			options.showTCP, options.showUDP = false, true
+		}
+	}
+
+	// An order from a newer pvw might not be one this one knows
+	if !flags.Changed("sort") && validSort(prefs.Sort) {
+		options.sortBy = prefs.Sort
+	}
+}
+
+// savePreferences() writes the preferences out from the settings pvw finished with
+func savePreferences(options settings) error {
+	prefs := preferences{Filters: formatFilters(options.filters), Search: options.searchTerm, Sort: options.sortBy}
+	if options.showTCP != options.showUDP {
+		prefs.Protocols = "UDP"
+		if options.showTCP {
+			prefs.Protocols = "TCP"
+		}
+	}
+
+	raw, err := json.MarshalIndent(prefs, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	path := preferencesPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	temporary := path + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(temporary, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// TestSortPreference checks the order the table was sorted by is saved and put back, unless --sort says otherwise or
+// it's an order this pvw doesn't know
+func TestSortPreference(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved string
+		args  []string
+		want  string
+	}{
+		{"put back", "memory", nil, "memory"},
+		{"--sort wins", "memory", []string{"--sort=cpu"}, "cpu"},
+		{"unknown order", "bandwidth", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("pvw", pflag.ContinueOnError)
+			flagSort := flags.String("sort", "", "")
+			if err := flags.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			options := settings{sortBy: *flagSort}
+			applyPreferences(preferences{Sort: test.saved}, &options, flags)
+			if options.sortBy != test.want {
+				t.Errorf("got %q, want %q", options.sortBy, test.want)
+			}
+		})
+	}
+}
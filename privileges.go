@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Privileged Ports
+// Ports below 1024 can normally only be bound by root, so a process that isn't root listening on port 80 raises the
+// question of how. --show-privileges adds a Privilege column to the listeners on those ports, saying which of these
+// let it:
+//
+//   - root: it runs as root (its effective user ID is 0)
+//   - capability: it has CAP_NET_BIND_SERVICE in its effective capabilities, from setcap or its service's
+//     AmbientCapabilities
+//   - authbind: it runs under authbind, which binds the port for it (it's in its LD_PRELOAD)
+//   - socket activation: its service manager bound the socket and handed it over (LISTEN_FDS is set)
+//   - sysctl: net.ipv4.ip_unprivileged_port_start is at or below the port, so anything can bind it
+//
+// If none of them are true, the column shows a ?, which usually means it was handed the socket by a parent that was
+// root. The detail pane (i) explains each. Everything but root comes from /proc, so elsewhere it's root or ?.
+
+// The ports below this are privileged, unless the sysctl says otherwise
+const privilegedPorts = 1024
+
+// The bit for CAP_NET_BIND_SERVICE in a process' capability sets
+const capNetBindService = 1 << 10
+
+// isPrivilegedPort() checks if a socket is bound to a port below 1024
+func isPrivilegedPort(conn connection) bool {
+	port, err := strconv.Atoi(conn.LocalPort)
+	return err == nil && port > 0 && port < privilegedPorts && isBound(conn)
+}
+
+// unprivilegedPortStart() reads the lowest port anything can bind, from net.ipv4.ip_unprivileged_port_start
+func unprivilegedPortStart() int {
+	contents, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if err != nil {
+		return privilegedPorts
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return privilegedPorts
+	}
+	return start
+}
+
+// privileges is what lets a process bind privileged ports, from /proc/<pid>/status and its environment
+type privileges struct {
+	root       bool
+	capability bool
+	authbind   bool
+	activated  bool
+	start      int // The lowest port anything can bind
+}
+
+// parsePrivileges() works out what lets a process bind privileged ports, from the contents of its /proc/<pid>/status
+// (or "" if it couldn't be read), its environment, and whether its owner is root
+func parsePrivileges(pid int, status string, environment []string, root bool) privileges {
+	p := privileges{root: root, start: privilegedPorts}
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "Uid:":
+			// Real, effective, saved and filesystem, and it's the effective one that counts
+			p.root = fields[2] == "0"
+		case len(fields) >= 2 && fields[0] == "CapEff:":
+			capabilities, err := strconv.ParseUint(fields[1], 16, 64)
+			p.capability = err == nil && capabilities&capNetBindService != 0
+		}
+	}
+
+	listenPID := ""
+	for _, variable := range environment {
+		name, value, _ := strings.Cut(variable, "=")
+		switch name {
+		case "LD_PRELOAD":
+			p.authbind = p.authbind || strings.Contains(value, "authbind")
+		case "LISTEN_FDS":
+			p.activated = true
+		case "LISTEN_PID":
+			listenPID = value
+		}
+	}
+	// systemd sets LISTEN_PID to the process it started, so a child that inherited the environment doesn't count
+	if listenPID != "" && listenPID != strconv.Itoa(pid) {
+		p.activated = false
+	}
+	return p
+}
+
+// kind() names what lets a process bind a port, most likely first, or returns "unknown"
+func (p privileges) kind(port string) string {
+	number, _ := strconv.Atoi(port)
+	switch {
+	case p.root:
+		return "root"
+	case p.activated:
+		return "socket"
+	case p.authbind:
+		return "authbind"
+	case p.capability:
+		return "capability"
+	case number >= p.start:
+		return "sysctl"
+	}
+	return "unknown"
+}
+
+// hasPrivilegedPorts() checks if a process has any sockets bound to ports below 1024
+func hasPrivilegedPorts(proc process) bool {
+	return !proc.Windows && proc.ID != 0 && slices.IndexFunc(proc.Connections, isPrivilegedPort) != -1
+}
+
+// processPrivileges() works out what lets a process bind privileged ports
+func processPrivileges(proc process) privileges {
+	status, _ := os.ReadFile(filepath.Join("/proc", strconv.Itoa(proc.ID), "status"))
+	environment, _ := processEnvironmentVariables(proc.ID)
+	p := parsePrivileges(proc.ID, string(status), environment, proc.Username == "root")
+	if len(status) > 0 {
+		p.start = unprivilegedPortStart()
+	}
+	return p
+}
+
+// privilegeLines() explains what lets a process bind each of its privileged ports for the detail pane, with the
+// ports that are there for the same reason on one line
+func privilegeLines(proc process) []string {
+	if !hasPrivilegedPorts(proc) {
+		return nil
+	}
+
+	p := processPrivileges(proc)
+	var kinds []string
+	ports := make(map[string][]string)
+	for _, conn := range proc.Connections {
+		if !isPrivilegedPort(conn) {
+			continue
+		}
+		kind := p.kind(conn.LocalPort)
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+		if !slices.Contains(ports[kind], conn.LocalPort) {
+			ports[kind] = append(ports[kind], conn.LocalPort)
+		}
+	}
+
+	var lines []string
+	for _, kind := range kinds {
+		explanation := tr("privilege.explain-" + kind)
+		if kind == "sysctl" {
+			explanation = tr("privilege.explain-sysctl", p.start)
+		}
+		lines = append(lines, strings.Join(ports[kind], ", ")+": "+explanation)
+	}
+	return lines
+}
+
+// fillPrivilegeColumn() fills in the Privilege column of the rows of a process' privileged ports
+func fillPrivilegeColumn(rows []table.Row, proc process, options settings) {
+	if !options.showPrivileges || !hasPrivilegedPorts(proc) {
+		return
+	}
+
+	column := columnIndex(options, "Privilege")
+	if column == -1 {
+		return
+	}
+
+	p := processPrivileges(proc)
+	for i, conn := range proc.Connections {
+		if i < len(rows) && isPrivilegedPort(conn) {
+			rows[i][column] = tr("privilege." + p.kind(conn.LocalPort))
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// TestPrivileges checks what lets a process bind a privileged port is read from its status and environment, with root
+// taking precedence
+func TestPrivileges(t *testing.T) {
+	const unprivileged = "Uid:\t1000\t1000\t1000\t1000\nCapEff:\t0000000000000000\n"
+
+	for _, test := range []struct {
+		name        string
+		status      string
+		environment []string
+		owner       bool
+		start       int
+		port        string
+		expected    string
+	}{
+		{"root", "Uid:\t1000\t0\t0\t0\nCapEff:\t000001ffffffffff\n", nil, false, 1024, "80", "root"},
+		{"owned by root elsewhere", "", nil, true, 1024, "80", "root"},
+		{"capability", "Uid:\t33\t33\t33\t33\nCapEff:\t0000000000000400\n", nil, false, 1024, "80", "capability"},
+		{"authbind", unprivileged, []string{"LD_PRELOAD=/usr/lib/authbind/libauthbind.so.1"}, false, 1024, "80",
+			"authbind"},
+		{"socket activation", unprivileged, []string{"LISTEN_FDS=1", "LISTEN_PID=4200"}, false, 1024, "80", "socket"},
+		{"inherited LISTEN_FDS", unprivileged, []string{"LISTEN_FDS=1", "LISTEN_PID=1"}, false, 1024, "80", "unknown"},
+		{"sysctl", unprivileged, nil, false, 80, "443", "sysctl"},
+		{"below the sysctl", unprivileged, nil, false, 443, "80", "unknown"},
+	} {
+		p := parsePrivileges(4200, test.status, test.environment, test.owner)
+		p.start = test.start
+		if kind := p.kind(test.port); kind != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, kind)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Probes
+// When several services are listening, the port number doesn't always say which is which. Probes connect to the
+// selected listening port and show what answers in the detail pane.
+//
+// The TLS probe does a TLS handshake and shows the certificate the service sent, and the protocol it picked with ALPN.
+// The certificate isn't checked before the handshake (otherwise self-signed dev certificates would fail), but whether
+// it would have passed is shown too.
+//
+// The HTTP probe sends a HEAD request (or a GET, if HEAD isn't allowed), over HTTPS if the port does TLS, and shows the
+// status, a few of the headers, and how long the response took. Redirects aren't followed, so it's clear what the port
+// itself answered with.
+//
+// Opening a port in the browser does a quick HTTP probe first, so it only opens ports that actually speak HTTP.
+//
+// For connections to somewhere else, the latency probe times a few TCP connections to the remote address and port
+// (and pings it, if ping is installed), to help tell whether it's the remote end or the app that's slow.
+
+// How long a probe waits for the service to answer
+const probeTimeout = 5 * time.Second
+
+// probeAddress() works out where to connect to for a listening connection. Sockets listening on every address are
+// reached through loopback.
+func probeAddress(conn connection) (string, error) {
+	if !isListening(conn) || conn.Protocol != "TCP" {
+		return "", errors.New(tr("error.not-listening"))
+	}
+
+	host := strings.Trim(conn.LocalAddress, "[]")
+	if host == "*" || host == "" {
+		host = "127.0.0.1"
+		if conn.IPv6 {
+			host = "::1"
+		}
+	}
+
+	return net.JoinHostPort(host, conn.LocalPort), nil
+}
+
+// probeTLS() does a TLS handshake with a listening port, and shows the certificate in the detail pane
+func probeTLS(conn connection) tea.Cmd {
+	return func() tea.Msg {
+		address, err := probeAddress(conn)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		dialer := &net.Dialer{Timeout: probeTimeout}
+		config := &tls.Config{
+			InsecureSkipVerify: true, // Checked afterwards, so the certificate can be shown whether it's valid or not
+			NextProtos:         []string{"h2", "http/1.1"},
+		}
+
+		debugf("probing %s with TLS", address)
+		tlsConn, err := tls.DialWithDialer(dialer, "tcp", address, config)
+		if err != nil {
+			return errMsg{errors.New(tr("error.tls-probe", address, err))}
+		}
+		defer tlsConn.Close()
+
+		return detailMsg{
+			title: tr("tls.title", address),
+			body:  describeTLS(tlsConn.ConnectionState()),
+		}
+	}
+}
+
+// describeTLS() writes out the interesting parts of a TLS connection for the detail pane
+func describeTLS(state tls.ConnectionState) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.version"), tls.VersionName(state.Version))
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.cipher"), tls.CipherSuiteName(state.CipherSuite))
+
+	protocol := state.NegotiatedProtocol
+	if protocol == "" {
+		protocol = tr("tls.none")
+	}
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.alpn"), protocol)
+
+	if len(state.PeerCertificates) == 0 {
+		return b.String()
+	}
+
+	cert := state.PeerCertificates[0]
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.subject"), cert.Subject)
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.issuer"), cert.Issuer)
+	if len(cert.DNSNames) > 0 {
+		fmt.Fprintf(&b, "%s: %s\n", tr("tls.names"), strings.Join(cert.DNSNames, ", "))
+	}
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.not-before"), cert.NotBefore.Local().Format(time.RFC1123))
+	fmt.Fprintf(&b, "%s: %s\n", tr("tls.not-after"), cert.NotAfter.Local().Format(time.RFC1123))
+
+	if time.Now().After(cert.NotAfter) {
+		fmt.Fprintf(&b, "%s\n", tr("tls.expired"))
+	} else {
+		fmt.Fprintf(&b, "%s\n", tr("tls.expires-in", int(time.Until(cert.NotAfter).Hours()/24)))
+	}
+
+	// Check the chain against the system's roots, without a hostname as we connected through an IP address
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+		fmt.Fprintf(&b, "%s: %s\n", tr("tls.untrusted"), err)
+	} else {
+		fmt.Fprintf(&b, "%s\n", tr("tls.trusted"))
+	}
+
+	return b.String()
+}
+
+// speaksTLS() checks if a port answers a TLS handshake within the timeout
+func speaksTLS(address string, timeout time.Duration) bool {
+	dialer := &net.Dialer{Timeout: timeout, Deadline: time.Now().Add(timeout)}
+	tlsConn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return false
+	}
+	tlsConn.Close()
+	return true
+}
+
+// probeURL() works out the URL to send requests to for a listening connection, using HTTPS if it does TLS
+func probeURL(conn connection) (string, error) {
+	address, err := probeAddress(conn)
+	if err != nil {
+		return "", err
+	}
+
+	if speaksTLS(address, probeTimeout) {
+		return "https://" + address + "/", nil
+	}
+	return "http://" + address + "/", nil
+}
+
+// probeHTTP() sends a request to a listening port, and shows the response in the detail pane
+func probeHTTP(conn connection) tea.Cmd {
+	return func() tea.Msg {
+		url, err := probeURL(conn)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		client := &http.Client{
+			Timeout: probeTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // Dev servers usually have self-signed certificates
+			},
+			// Show the redirect itself, rather than wherever it goes
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		defer client.CloseIdleConnections()
+
+		debugf("probing %s with HTTP", url)
+		started := time.Now()
+		resp, err := client.Head(url)
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			// Some servers only answer GET
+			resp.Body.Close()
+			started = time.Now()
+			resp, err = client.Get(url)
+		}
+		elapsed := time.Since(started)
+
+		if err != nil {
+			return errMsg{errors.New(tr("error.http-probe", url, err))}
+		}
+		resp.Body.Close()
+
+		return detailMsg{
+			title: tr("http.title", resp.Request.Method, url),
+			body:  describeHTTP(resp, elapsed),
+		}
+	}
+}
+
+// describeHTTP() writes out the interesting parts of a response for the detail pane
+func describeHTTP(resp *http.Response, elapsed time.Duration) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: %s %s\n", tr("http.status"), resp.Proto, resp.Status)
+	fmt.Fprintf(&b, "%s: %s\n", tr("http.time"), elapsed.Round(100*time.Microsecond))
+
+	for _, header := range []string{"Server", "Content-Type", "Content-Length", "Location", "X-Powered-By"} {
+		if value := resp.Header.Get(header); value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", header, value)
+		}
+	}
+
+	return b.String()
+}
+
+// How long to wait for a port to answer an HTTP request before deciding it doesn't speak HTTP
+const browserProbeTimeout = time.Second
+
+// openInBrowser() opens a listening port in the default browser, if it answers HTTP requests
+func openInBrowser(conn connection) tea.Cmd {
+	return func() tea.Msg {
+		url, err := probeURL(conn)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		// Anything that answers with an HTTP response counts, whatever the status
+		ctx, cancel := context.WithTimeout(context.Background(), browserProbeTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		defer client.CloseIdleConnections()
+
+		resp, err := client.Do(req)
+		if err != nil {
+			debugf("%s didn't answer HTTP: %v", url, err)
+			return errMsg{errors.New(tr("error.not-http", conn.LocalPort))}
+		}
+		resp.Body.Close()
+
+		// Use localhost rather than 127.0.0.1, as that's what dev servers usually print (and some check the Host header)
+		url = strings.Replace(url, "127.0.0.1", "localhost", 1)
+		url = strings.Replace(url, "[::1]", "localhost", 1)
+
+		if err := openURL(url); err != nil {
+			return errMsg{errors.New(tr("error.open-browser", url, err))}
+		}
+		return nil
+	}
+}
+
+// openURL() opens a URL with the system's default browser, without waiting for it to close
+func openURL(url string) error {
+	if pvw.Pure {
+		return errors.New(tr("error.pure-command", "xdg-open"))
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("open", url)
+	case commandExists("wslview"):
+		// Under WSL, xdg-open usually isn't set up, but wslu opens the browser on the Windows side
+		cmd = exec.Command("wslview", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	debugf("opening %s with %s", url, cmd.Path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Don't leave a zombie process behind once it's finished
+	go cmd.Wait()
+	return nil
+}
+
+// How many times the latency probe connects to the remote end
+const latencyAttempts = 3
+
+// probeLatency() measures how long it takes to reach the remote end of a connection, and shows it in the detail pane
+func probeLatency(conn connection) tea.Cmd {
+	return func() tea.Msg {
+		if conn.RemoteAddress == "" || conn.RemotePort == "" || conn.RemoteAddress == "*" {
+			return errMsg{errors.New(tr("error.no-remote"))}
+		}
+
+		host := strings.Trim(conn.RemoteAddress, "[]")
+		address := net.JoinHostPort(host, conn.RemotePort)
+		debugf("probing latency to %s", address)
+
+		var b strings.Builder
+		if conn.Protocol == "TCP" {
+			var times []time.Duration
+			for i := 0; i < latencyAttempts; i++ {
+				started := time.Now()
+				c, err := net.DialTimeout("tcp", address, probeTimeout)
+				if err != nil {
+					fmt.Fprintf(&b, "%s: %v\n", tr("latency.connect"), err)
+					continue
+				}
+				times = append(times, time.Since(started))
+				c.Close()
+			}
+
+			if len(times) > 0 {
+				fmt.Fprintf(&b, "%s: %s\n", tr("latency.connect"), summariseLatency(times))
+			}
+		}
+
+		// ping needs to be installed (and sending ICMP from Go needs privileges, so we use it rather than doing it
+		// ourselves)
+		if commandExists("ping") {
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout*2)
+			defer cancel()
+
+			// Command is `ping -c 3 ADDRESS`
+			out, err := exec.CommandContext(ctx, "ping", "-c", fmt.Sprint(latencyAttempts), host).CombinedOutput()
+			if err != nil && len(out) == 0 {
+				fmt.Fprintf(&b, "%s: %v\n", tr("latency.ping"), err)
+			} else {
+				// The last line is the summary, e.g. rtt min/avg/max/mdev = 0.035/0.042/0.051/0.006 ms
+				lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+				fmt.Fprintf(&b, "%s: %s\n", tr("latency.ping"), lines[len(lines)-1])
+			}
+		}
+
+		return detailMsg{title: tr("latency.title", address), body: b.String()}
+	}
+}
+
+// summariseLatency() describes a set of times as the minimum, average and maximum
+func summariseLatency(times []time.Duration) string {
+	least, most, total := times[0], times[0], time.Duration(0)
+	for _, t := range times {
+		if t < least {
+			least = t
+		}
+		if t > most {
+			most = t
+		}
+		total += t
+	}
+
+	average := total / time.Duration(len(times))
+	round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+	return tr("latency.summary", round(least), round(average), round(most))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Progressive Loading
+// On a host with tens of thousands of sockets, lsof can take seconds to finish, and the table used to stay empty until
+// it had. Backends that can (see pvw.ProgressiveCollector) parse the output as it's written instead, and during the
+// first collection, what they've found so far is sent to the table every partialInterval so there's something to look
+// at straight away. Later refreshes don't bother, as the table already has the last collection in it, and swapping it
+// for half a collection would only make rows vanish and come back.
+
+// How often the table is updated with what the first collection has found so far. Collections quicker than this never
+// show anything partial, so they don't flicker.
+const partialInterval = 100 * time.Millisecond
+
+// progressiveCollector() returns the backend's collector, if it can show what it's found while it's still collecting
+func progressiveCollector(b backend) (pvw.ProgressiveCollector, bool) {
+	wrapped, isCollector := b.(collectorBackend)
+	if !isCollector {
+		return nil, false
+	}
+	collector, isProgressive := wrapped.collector.(pvw.ProgressiveCollector)
+	return collector, isProgressive
+}
+
+// collectProgressively() collects every process with a socket open from the backend, calling partial with what's been
+// found so far along the way if the backend can. Otherwise, it's the same as collect().
+func collectProgressively(ctx context.Context, b backend, partial func([]process)) ([]process, []parseWarning, error) {
+	if collector, isProgressive := progressiveCollector(b); isProgressive && partial != nil {
+		return collector.CollectProgressively(ctx, partial)
+	}
+	return b.collect(ctx)
+}
+
+// partialResults() returns a function that filters and formats the processes found so far, and sends them to partials
+// at most once every partialInterval. If the table hasn't caught up with the last ones yet, they're skipped rather
+// than holding up the collection.
+func partialResults(settingsInfo settings, partials chan<- processesMsg) func([]process) {
+	last := time.Now()
+	var cache rowCache
+
+	return func(found []process) {
+		if time.Since(last) < partialInterval {
+			return
+		}
+		last = time.Now()
+
+		// The backend carries on once this returns, so the processes are copied out first
+		found = append([]process(nil), found...)
+		filtered := filterProcesses(found, settingsInfo)
+
+		var formatted []table.Row
+		var ends []int
+		formatted, ends, cache, _ = formatLsofIncremental(filtered, cache, settingsInfo)
+
+		msg := processesMsg{
+			all: found, processes: filtered, rows: formatted, ends: ends, partial: true, collectedAt: last,
+			generation: settingsInfo.generation,
+		}
+		select {
+		case partials <- msg:
+		default:
+		}
+	}
+}
+
+// waitForPartial() returns a command that waits for the next partial result from the first collection, or returns
+// nothing once it's finished
+func waitForPartial(partials <-chan processesMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, open := <-partials
+		if !open {
+			return nil
+		}
+		return msg
+	}
+}
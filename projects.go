@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Projects
+// "node (PID 41321)" doesn't say much when there are six of them. What people actually want to know is which project
+// each one belongs to, so --show-project adds a Project column, named after the project the process' working directory
+// is in ("my-shop-frontend"). It needs the working directories, so it turns --show-cwd's collection on too.
+//
+// The project is the nearest directory at or above the working directory with something that names it, which is, in
+// order of preference, package.json's name, Cargo.toml's or pyproject.toml's name, the last part of go.mod's module,
+// or failing all of those, the name of the git repository's directory. A directory's name is looked up once and
+// remembered, as working directories rarely change.
+
+// The files that name a project, in the order they're preferred when a directory has more than one
+var projectFiles = []string{"package.json", "Cargo.toml", "pyproject.toml", "go.mod", ".git"}
+
+// Each directory's project name, or "" if it isn't in one, as they've been looked up. Rows are formatted in their own
+// goroutines, so it's behind a mutex.
+var (
+	projectMutex sync.Mutex
+	projectNames = make(map[string]string)
+)
+
+// projectName() returns the name of the project a directory is in, or "" if it isn't in one
+func projectName(directory string) string {
+	if directory == "" || !filepath.IsAbs(directory) {
+		return ""
+	}
+
+	projectMutex.Lock()
+	defer projectMutex.Unlock()
+	if name, found := projectNames[directory]; found {
+		return name
+	}
+
+	// The home directory is often a git repository of dotfiles, which isn't what anything running in it is part of, so
+	// the search stops short of it
+	home, _ := os.UserHomeDir()
+	name := ""
+	for current := filepath.Clean(directory); current != home; current = filepath.Dir(current) {
+		if name = directoryProject(current); name != "" {
+			break
+		}
+		// The root (or a volume on Windows) is its own parent
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+	projectNames[directory] = name
+	return name
+}
+
+// directoryProject() returns the name of the project rooted in a directory, or "" if there isn't one there
+func directoryProject(directory string) string {
+	for _, file := range projectFiles {
+		filename := filepath.Join(directory, file)
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+
+		var name string
+		switch file {
+		case "package.json":
+			var manifest struct {
+				Name string `json:"name"`
+			}
+			if contents, err := os.ReadFile(filename); err == nil && json.Unmarshal(contents, &manifest) == nil {
+				name = manifest.Name
+			}
+		case "Cargo.toml", "pyproject.toml":
+			name = tomlName(filename)
+		case "go.mod":
+			name = path.Base(goModule(filename))
+		case ".git":
+			name = filepath.Base(directory)
+		}
+
+		if name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	return ""
+}
+
+// tomlName() reads the name out of a Cargo.toml's [package] or a pyproject.toml's [project] (or [tool.poetry]) table.
+// It only understands name = "..." on a line of its own, which is how they're always written.
+func tomlName(filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		if section != "package" && section != "project" && section != "tool.poetry" {
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); found && strings.TrimSpace(key) == "name" {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// goModule() reads the module path out of a go.mod
+func goModule(filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// fillProjectColumn() fills in the Project column of a process' first row
+func fillProjectColumn(rows []table.Row, proc process, options settings) {
+	if !options.showProject || len(rows) == 0 {
+		return
+	}
+
+	if column := columnIndex(options, "Project"); column != -1 {
+		rows[0][column] = projectName(proc.Directory)
+	}
+}
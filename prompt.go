@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Prompts
+// Some actions need a value before they can run (e.g. renice needs the new nice value). They ask for it in the search
+// bar's place: enter runs the action with whatever was typed, and esc cancels it.
+
+// openPrompt() asks for a value, starting with the given one, and runs done with it once enter is pressed
+func (m *model) openPrompt(label string, value string, done func(string) tea.Cmd) {
+	m.prompt = textinput.New()
+	m.prompt.Prompt = label + " "
+	m.prompt.SetValue(value)
+	m.prompt.CharLimit = 16
+	m.prompt.Width = 16
+	m.prompt.Focus()
+
+	m.promptDone = done
+	m.table.Blur()
+	m.announce(label)
+}
+
+// closePrompt() goes back to the table
+func (m *model) closePrompt() {
+	m.prompt.Blur()
+	m.promptDone = nil
+	m.table.Focus()
+}
+
+// updatePrompt() handles a key press while a prompt is open
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEnter:
+		done, value := m.promptDone, m.prompt.Value()
+		m.closePrompt()
+		if cmd := done(value); cmd != nil {
+			return m, catchPanics(cmd)
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.Escape):
+		m.closePrompt()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.prompt, cmd = m.prompt.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Protected Processes
+// Some processes are a bad idea to terminate by accident: sshd (on a remote machine, you'd be locked out), init and
+// systemd, and anything pvw is running under - your shell, your terminal, tmux. Pressing t on one of these asks for its
+// PID to be typed in, rather than just y, so a slip of the cursor can't take them down.
+//
+// Which names are protected can be changed with "protected" in the config file, which replaces the list below and
+// takes globs and /regular expressions/ like the name filter. PID 1 and pvw's own parents are always protected.
+
+// The names protected if the config file doesn't say otherwise
+var defaultProtected = []string{"sshd", "init", "systemd", "launchd", "login", "tmux*", "screen"}
+
+// protectedReason() says why a process is protected, or returns "" if it isn't
+func protectedReason(proc process, options settings) string {
+	switch {
+	case proc.ID == 0 || proc.Windows:
+		return ""
+	case proc.ID == 1:
+		return tr("protected.init")
+	case slices.Contains(options.ancestors, proc.ID):
+		return tr("protected.ancestor")
+	case nameMatches(options.protected, proc.Name, matchExact):
+		return tr("protected.name")
+	}
+	return ""
+}
+
+// confirmProtected() asks for a protected process' PID to be typed in before terminating it
+func (m *model) confirmProtected(proc process, reason string) tea.Cmd {
+	pid := strconv.Itoa(proc.ID)
+	collectedAt := m.collectedAt
+	m.openPrompt(tr("prompt.protected", processLabel(proc), reason), "", func(answer string) tea.Cmd {
+		if strings.TrimSpace(answer) != pid {
+			return func() tea.Msg { return errMsg{errors.New(tr("error.protected", processLabel(proc)))} }
+		}
+		return terminateProcess(proc, collectedAt)
+	})
+	return textinput.Blink
+}
+
+// ancestors() lists the PIDs of the processes pvw is running under: its parent, its parent's parent, and so on up to
+// (but not including) PID 1
+func ancestors() []int {
+	var pids []int
+	for pid := os.Getppid(); pid > 1 && len(pids) < 64; {
+		pids = append(pids, pid)
+
+		parent, found := parentProcess(pid)
+		if !found || parent == pid {
+			break
+		}
+		pid = parent
+	}
+	return pids
+}
+
+// parentProcess() finds a process' parent, from /proc on Linux or ps elsewhere. Windows doesn't keep track of
+// parents in a way that's worth following, so only pvw's own parent is known there.
+func parentProcess(pid int) (int, bool) {
+	switch {
+	case runtime.GOOS == "linux":
+		// The parent comes straight after the state
+		fields, found := procStatFields(pid)
+		if !found || len(fields) < 2 {
+			return 0, false
+		}
+		parent, err := strconv.Atoi(fields[1])
+		return parent, err == nil
+
+	case runtime.GOOS == "windows" || !commandExists("ps"):
+		return 0, false
+	}
+
+	// Command is `ps -o ppid= -p PID`
+	out, err := exec.Command("ps", "-o", "ppid=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, false
+	}
+	parent, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	return parent, err == nil
+}
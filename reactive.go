@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Reactive Refresh
+// With a short --interval, most of pvw's CPU goes on collections that find exactly what the last one did, as lsof has
+// to look through every process' files to find its sockets. On Linux, the kernel's socket tables are much cheaper to
+// read: /proc/net/tcp, tcp6, udp and udp6 list every socket in the network namespace in one go. So in watch mode, each
+// tick reads them first, and only collects if they've changed since the last collection.
+//
+// Neither of the cheaper signals works here: files in /proc don't have meaningful modification times, and can't be
+// watched with inotify, and the sock_diag events for sockets closing need CAP_NET_ADMIN and say nothing about sockets
+// opening. Only the parts of each line pvw shows are compared (the addresses, state, owner and inode, plus the queues
+// if they're shown), as the timers and counters change all the time.
+//
+// A collection still happens every reactiveMaxSkip however quiet the tables are, as processes can change without their
+// sockets changing (a socket handed from one process to another, say). Anything that changes with time alone turns it
+// off: --show-age, --show-resources, --show-tcp-info and sorting by CPU, memory or age, as do --windows and
+// --all-namespaces, whose sockets aren't in the tables. --reactive=false turns it off too.
+
+// The longest pvw goes between collections in watch mode, however quiet the socket tables are
+const reactiveMaxSkip = 10 * time.Second
+
+// The kernel's socket tables, for the network namespace pvw's in
+var socketTables = []string{"/proc/net/tcp", "/proc/net/tcp6", "/proc/net/udp", "/proc/net/udp6"}
+
+// reactiveRefresh() checks if watch mode can skip collecting when the socket tables haven't changed
+func reactiveRefresh(options settings) bool {
+	tcpInfo := columnIndex(options, "RTT") != -1
+	return options.reactive && runtime.GOOS == "linux" && !options.windows && !options.allNamespaces &&
+		!options.showAge && !options.showResources && !tcpInfo && options.sortBy == ""
+}
+
+// digestSocketTable() hashes the parts of a socket table pvw shows, a line at a time. The first line is the headings.
+func digestSocketTable(w io.Writer, contents io.Reader, queues bool) {
+	scanner := bufio.NewScanner(contents)
+	scanner.Scan()
+	for scanner.Scan() {
+		// Fields are sl, local_address, rem_address, st, tx_queue:rx_queue, tr:tm->when, retrnsmt, uid, timeout, inode...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		line := fields[1] + " " + fields[2] + " " + fields[3] + " " + fields[7] + " " + fields[9]
+		if queues {
+			line += " " + fields[4]
+		}
+		io.WriteString(w, line+"\n")
+	}
+}
+
+// digestSocketTables() hashes every socket table. It returns false if they can't be read.
+func digestSocketTables(queues bool) (uint64, bool) {
+	hash := fnv.New64a()
+	for _, path := range socketTables {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// IPv6 can be turned off
+			continue
+		}
+		if err != nil {
+			return 0, false
+		}
+		digestSocketTable(hash, file, queues)
+		file.Close()
+	}
+	return hash.Sum64(), true
+}
+
+// socketsUnchanged() checks if the socket tables are the same as they were at the last collection in watch mode, so
+// this tick's collection can be skipped. If they aren't, it remembers them for next time.
+func (m *model) socketsUnchanged() bool {
+	if !reactiveRefresh(m.settings) {
+		return false
+	}
+	queues := columnIndex(m.settings, "Recv-Q") != -1
+	digest, readable := digestSocketTables(queues)
+	if !readable {
+		return false
+	}
+
+	now := time.Now()
+	if digest == m.socketDigest && now.Sub(m.digestedAt) < reactiveMaxSkip {
+		return true
+	}
+	m.socketDigest, m.digestedAt = digest, now
+	return false
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"hash/fnv"
+	"strings"
+	"testing"
+)
+
+// TestDigestSocketTable checks only the parts of a socket table pvw shows change its digest
+func TestDigestSocketTable(t *testing.T) {
+	const heading = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	digest := func(line string, queues bool) uint64 {
+		hash := fnv.New64a()
+		digestSocketTable(hash, strings.NewReader(heading+line), queues)
+		return hash.Sum64()
+	}
+
+	listening := "   0: 00000000:0BB8 00000000:0000 0A 00000000:00000001 00:00000000 00000000  1000        0 41234 1\n"
+	for _, test := range []struct {
+		name    string
+		line    string
+		queues  bool
+		changed bool
+	}{
+		{"timer", strings.Replace(listening, "00:00000000", "02:000001F4", 1), false, false},
+		{"retransmits", strings.Replace(listening, " 00000000  1000", " 00000003  1000", 1), false, false},
+		{"state", strings.Replace(listening, " 0A ", " 01 ", 1), false, true},
+		{"inode", strings.Replace(listening, "41234", "41235", 1), false, true},
+		{"queue hidden", strings.Replace(listening, "00000000:00000001", "00000000:00000002", 1), false, false},
+		{"queue shown", strings.Replace(listening, "00000000:00000001", "00000000:00000002", 1), true, true},
+	} {
+		if changed := digest(test.line, test.queues) != digest(listening, test.queues); changed != test.changed {
+			t.Errorf("%s: expected changed to be %t", test.name, test.changed)
+		}
+	}
+}
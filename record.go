@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Record and Replay
+// Bugs in parsing or the UI usually depend on exactly what was open on someone's machine, which is hard to reproduce.
+// --record FILE saves every collection to a file as it happens, and --replay FILE plays a recording back in the TUI
+// instead of collecting, so the bug can be seen without access to the machine. While replaying:
+//
+//	[ and ]  Step back and forward one collection
+//	{ and }  Jump to the first and last collection
+//
+// With --interval, the recording plays itself, moving forward one collection each time. Recordings are one snapshot
+// (the same format `pvw snapshot` saves) per line. The processes in a recording aren't running here, so nothing can
+// be terminated while replaying.
+
+// recorder appends every collection to a recording. Collections happen in their own goroutines, so it's behind a mutex.
+type recorder struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// newRecorder() starts a new recording, replacing the file if it already exists
+func newRecorder(path string) (*recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{encoder: json.NewEncoder(file)}, nil
+}
+
+// record() adds a collection to the recording. Each one is written straight to the file, so a recording is still
+// usable if pvw crashes.
+func (r *recorder) record(processes []process) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.encoder.Encode(snapshot{Taken: time.Now(), Processes: processes}); err != nil {
+		debugf("couldn't record a collection: %v", err)
+	}
+}
+
+// replayBackend is a backend that returns the collections in a recording rather than collecting. The current position
+// is shared between the model and the collections, so it's behind a mutex.
+type replayBackend struct {
+	frames []snapshot
+
+	mutex    sync.Mutex
+	position int
+}
+
+// loadReplay() reads a recording
+func loadReplay(path string) (*replayBackend, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	replay := &replayBackend{}
+	decoder := json.NewDecoder(file)
+	for {
+		var frame snapshot
+		if err := decoder.Decode(&frame); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			// A recording that was cut off part way through a line can still be replayed up to there
+			debugf("stopped reading %s after %d collections: %v", path, len(replay.frames), err)
+			break
+		}
+		replay.frames = append(replay.frames, frame)
+	}
+
+	if len(replay.frames) == 0 {
+		return nil, errors.New(tr("error.empty-recording", path))
+	}
+	return replay, nil
+}
+
+func (r *replayBackend) name() string { return "replay" }
+
+func (r *replayBackend) available() bool { return true }
+
+// capabilities() says everything that was recorded can be shown, but nothing can be terminated or looked up, as the
+// processes aren't running here
+func (r *replayBackend) capabilities() capabilities {
+	return capabilities{processNames: true, owners: true, otherUsers: true}
+}
+
+func (r *replayBackend) collect(ctx context.Context) ([]process, []parseWarning, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Filtering changes the processes' connections, so return a copy rather than the recording itself
+	frame := r.frames[r.position].Processes
+	processes := make([]process, len(frame))
+	copy(processes, frame)
+	return processes, nil, nil
+}
+
+// step() moves forward (or backward, if negative) through the recording, stopping at either end. It returns whether
+// the position changed.
+func (r *replayBackend) step(n int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Jumps to either end are bigger than the recording, so cap them first to avoid overflowing
+	if n > len(r.frames) {
+		n = len(r.frames)
+	} else if n < -len(r.frames) {
+		n = -len(r.frames)
+	}
+
+	position := r.position + n
+	if position < 0 {
+		position = 0
+	}
+	if position >= len(r.frames) {
+		position = len(r.frames) - 1
+	}
+
+	moved := position != r.position
+	r.position = position
+	return moved
+}
+
+// status() says where in the recording we are, to be shown under the table
+func (r *replayBackend) status() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	taken := r.frames[r.position].Taken.Format("2006-01-02 15:04:05")
+	return tr("notice.replay", r.position+1, len(r.frames), taken)
+}
+
+// replaying() returns the replay backend, if a recording is being replayed
+func (m model) replaying() (*replayBackend, bool) {
+	replay, isReplay := m.settings.backend.(*replayBackend)
+	return replay, isReplay
+}
+
+// stepReplay() moves through the recording and shows the collection it's now at
+func (m *model) stepReplay(n int) tea.Cmd {
+	replay, isReplay := m.replaying()
+	if !isReplay || !replay.step(n) {
+		return nil
+	}
+
+	m.announce(replay.status())
+	return m.refresh()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Raw Records
+// The backends' output differs between systems and versions, so now and then a row comes out wrong. With --debug,
+// ctrl+r shows the raw record the selected row was parsed from in the detail pane (lsof's lines for its process and
+// file, or ss', netstat's or /proc's line), under what pvw made of it, so it can be pasted into a bug report as it is.
+// It's written to the debug log too.
+//
+// The key is left out of the help, and does nothing without --debug, as keeping every record is slow on big hosts.
+// Recordings made with --debug keep the records, so they can be looked at while replaying.
+
+// showRecord() shows the raw record behind a connection in the detail pane
+func showRecord(proc process, conn connection) tea.Cmd {
+	return func() tea.Msg {
+		debugf("record for %s %s:%s:\n\t%s", processLabel(proc), conn.LocalAddress, conn.LocalPort,
+			strings.ReplaceAll(conn.Record, "\n", "\n\t"))
+		return detailMsg{title: tr("record.title", processLabel(proc)), body: recordReport(proc, conn)}
+	}
+}
+
+// recordReport() lists what pvw parsed a connection as, then the record it was parsed from
+func recordReport(proc process, conn connection) string {
+	var b strings.Builder
+
+	remote := ""
+	if conn.RemotePort != "" {
+		remote = conn.RemoteAddress + ":" + conn.RemotePort
+	}
+
+	fmt.Fprintln(&b, tr("record.parsed"))
+	writeFields(&b, [][2]string{
+		{"  PID", strconv.Itoa(proc.ID)},
+		{"  Name", proc.Name},
+		{"  Owner", proc.Username},
+		{"  Protocol", conn.Protocol},
+		{"  IPv6", strconv.FormatBool(conn.IPv6)},
+		{"  Local", conn.LocalAddress + ":" + conn.LocalPort},
+		{"  Remote", remote},
+		{"  Status", conn.Status},
+		{"  Recv-Q/Send-Q", strconv.Itoa(conn.RecvQueue) + "/" + strconv.Itoa(conn.SendQueue)},
+	})
+	fmt.Fprintln(&b)
+
+	if conn.Record == "" {
+		fmt.Fprintln(&b, tr("record.none"))
+		return b.String()
+	}
+	fmt.Fprintln(&b, tr("record.raw"))
+	for _, line := range strings.Split(conn.Record, "\n") {
+		fmt.Fprintln(&b, "  "+line)
+	}
+	return b.String()
+}
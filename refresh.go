@@ -0,0 +1,128 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Incremental refresh
+// Instead of rebuilding every row and resetting the table on each refresh, the previous refresh is kept around so only
+// processes that have changed get re-formatted, and the selected connection stays selected even if rows move about.
+
+// rowCache maps a PID to the process and the rows it produced in the previous refresh
+type rowCache map[int]cachedProcess
+
+type cachedProcess struct {
+	proc   process
+	rows   []table.Row
+	exited time.Time     // When the process exited, if it's only still in the table because of that (see exited.go)
+	usage  resourceUsage // The CPU and memory in its rows (see resources.go)
+	ages   []string      // The connection ages in its rows (see age.go)
+	peers  []string      // The peers in its rows (see peers.go)
+	index  int           // Where it was in the table, as the rows can't be reused if the processes were reordered
+}
+
+// withLiveColumns() copies a process' cached rows with its CPU, memory, connection ages and peers filled in again, as
+// they change far more often than its sockets do. The cache's rows are left alone, as the table may still be showing
+// them.
+func withLiveColumns(rows []table.Row, proc process, ages, peers []string, options settings) []table.Row {
+	copied := make([]table.Row, len(rows))
+	for i, row := range rows {
+		copied[i] = append(table.Row{}, row...)
+	}
+	fillResourceColumns(copied, proc, options)
+	fillAgeColumn(copied, ages, options)
+	fillPeerColumn(copied, peers, options)
+	return copied
+}
+
+// rowKey identifies a single connection across refreshes. The status isn't included, as a connection that goes from
+// ESTABLISHED to CLOSE_WAIT is still the same connection and should stay selected.
+type rowKey struct {
+	pid      int
+	protocol string
+	local    string
+	remote   string
+}
+
+// connectionKey() returns the rowKey for a connection belonging to the process with the given PID
+func connectionKey(pid int, c connection) rowKey {
+	return rowKey{
+		pid:      pid,
+		protocol: c.Protocol,
+		local:    c.LocalAddress + ":" + c.LocalPort,
+		remote:   c.RemoteAddress + ":" + c.RemotePort,
+	}
+}
+
+// rowLocation() converts a row index in the table to the index of the process in m.processes and the index of the
+// connection within that process, using the start of each process' set of rows.
+func (m model) rowLocation(row int) (int, int, bool) {
+	if row < 0 || len(m.rowStarts) == 0 || len(m.processes) != len(m.rowStarts) {
+		return 0, 0, false
+	}
+
+	// Find the last process that starts on or before this row
+	processIndex := 0
+	for i, start := range m.rowStarts {
+		if start > row {
+			break
+		}
+		processIndex = i
+	}
+
+	connectionIndex := row - m.rowStarts[processIndex]
+	if connectionIndex >= len(m.processes[processIndex].Connections) {
+		return 0, 0, false
+	}
+
+	return processIndex, connectionIndex, true
+}
+
+// selectedKey() returns the key of the connection under the cursor, if there is one
+func (m model) selectedKey() (rowKey, bool) {
+	processIndex, connectionIndex, exists := m.rowLocation(m.table.Cursor())
+	if !exists {
+		return rowKey{}, false
+	}
+
+	proc := m.processes[processIndex]
+	return connectionKey(proc.ID, proc.Connections[connectionIndex]), true
+}
+
+// findRow() returns the row index of the connection with the given key
+func (m model) findRow(key rowKey) (int, bool) {
+	for processIndex, proc := range m.processes {
+		if proc.ID != key.pid || processIndex >= len(m.rowStarts) {
+			continue
+		}
+
+		for connectionIndex, conn := range proc.Connections {
+			if connectionKey(proc.ID, conn) == key {
+				// A collapsed process only has one row, for all of its connections
+				if m.settings.collapsed[proc.ID] {
+					return m.rowStarts[processIndex], true
+				}
+				return m.rowStarts[processIndex] + connectionIndex, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// moveCursor() moves the table's cursor to the given row. MoveUp and MoveDown are used rather than SetCursor so that
+// the table scrolls to keep the row visible.
+func (m *model) moveCursor(row int) {
+	cursor := m.table.Cursor()
+
+	if row > cursor {
+		m.table.MoveDown(row - cursor)
+	} else if row < cursor {
+		m.table.MoveUp(cursor - row)
+	}
+	m.trackTop()
+}
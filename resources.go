@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Resources
+// Sometimes the question isn't what's on a port, but what's eating the machine - and whether it's the thing on the
+// port. --show-resources adds CPU and Memory columns, and O sorts the table by CPU, then memory, then connection age
+// (see age.go), then back to the order the backend found things in. % is the quick way to ask: it sorts by CPU and
+// jumps to the top, like top(1) but only for processes with sockets open. --sort cpu|memory|age starts sorted.
+//
+// On Linux both come from /proc. The CPU is how much of one core the process used since the last refresh (or, on the
+// first one, on average since it started), and the memory is its resident set. Elsewhere they come from a single
+// `ps -o pid= -o %cpu= -o rss=` for every process in the table, and ps decides what the CPU covers.
+
+// resourceUsage is how much of the machine a process is using
+type resourceUsage struct {
+	cpu    float64 // Percent of one core, rounded to a tenth
+	memory int64   // Resident memory, in bytes
+}
+
+// The orders the table can be sorted in, as --sort takes them. "" is the order the backend found things in.
+var sortOrders = []string{"", "cpu", "memory", "age"}
+
+// cpuSample is a process' CPU time at a moment, so the next refresh can work out how much it used in between
+type cpuSample struct {
+	ticks uint64
+	at    time.Time
+}
+
+// The CPU samples from the last collection. Collections happen in their own goroutines, so they're behind a mutex.
+var (
+	cpuSamplesMutex sync.Mutex
+	cpuSamples      = make(map[int]cpuSample)
+)
+
+// collectResources() finds how much CPU and memory each process is using
+func collectResources(processes []process) map[int]resourceUsage {
+	var pids []int
+	for _, proc := range processes {
+		if proc.ID != 0 && !proc.Windows {
+			pids = append(pids, proc.ID)
+		}
+	}
+
+	switch {
+	case len(pids) == 0:
+		return nil
+	case runtime.GOOS == "linux":
+		return procResources(pids)
+	case runtime.GOOS != "windows" && commandExists("ps"):
+		return psResources(pids)
+	}
+	return nil
+}
+
+// procResources() reads each process' CPU time from /proc/<pid>/stat, and its resident memory from /proc/<pid>/statm.
+// CPU times are in clock ticks, which are almost always 100 a second.
+func procResources(pids []int) map[int]resourceUsage {
+	usage := make(map[int]resourceUsage, len(pids))
+	boot, booted := bootTime()
+	now := time.Now()
+
+	cpuSamplesMutex.Lock()
+	defer cpuSamplesMutex.Unlock()
+	samples := make(map[int]cpuSample, len(pids))
+
+	for _, pid := range pids {
+		// utime and stime are fields 14 and 15, and the start time field 22, counting the PID as 1 and the name as 2
+		fields, found := procStatFields(pid)
+		if !found || len(fields) < 20 {
+			continue
+		}
+		user, userErr := strconv.ParseUint(fields[11], 10, 64)
+		system, systemErr := strconv.ParseUint(fields[12], 10, 64)
+		if userErr != nil || systemErr != nil {
+			continue
+		}
+		sample := cpuSample{ticks: user + system, at: now}
+		samples[pid] = sample
+
+		var u resourceUsage
+		if previous, seen := cpuSamples[pid]; seen && now.After(previous.at) && sample.ticks >= previous.ticks {
+			u.cpu = cpuPercent(sample.ticks-previous.ticks, now.Sub(previous.at))
+		} else if started, err := strconv.ParseUint(fields[19], 10, 64); err == nil && booted {
+			u.cpu = cpuPercent(sample.ticks, now.Sub(boot.Add(time.Duration(started)*time.Second/100)))
+		}
+
+		// statm is in pages, and the resident set is the second number
+		if statm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "statm")); err == nil {
+			if numbers := strings.Fields(string(statm)); len(numbers) > 1 {
+				pages, _ := strconv.ParseInt(numbers[1], 10, 64)
+				u.memory = pages * int64(os.Getpagesize())
+			}
+		}
+		usage[pid] = u
+	}
+
+	cpuSamples = samples
+	return usage
+}
+
+// cpuPercent() works out how much of a core some clock ticks were over a length of time
+func cpuPercent(ticks uint64, over time.Duration) float64 {
+	if over <= 0 {
+		return 0
+	}
+	percent := float64(ticks) / 100 / over.Seconds() * 100
+	return float64(int64(percent*10+0.5)) / 10
+}
+
+// psResources() asks ps for the CPU and memory of every process at once. rss is in kilobytes.
+func psResources(pids []int) map[int]resourceUsage {
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = strconv.Itoa(pid)
+	}
+
+	// Command is `ps -o pid= -o %cpu= -o rss= -p PID,PID...`
+	out, err := exec.Command("ps", "-o", "pid=", "-o", "%cpu=", "-o", "rss=", "-p", strings.Join(ids, ",")).Output()
+	if err != nil && len(out) == 0 {
+		debugf("ps failed to get resources: %v", err)
+		return nil
+	}
+
+	usage := make(map[int]resourceUsage, len(pids))
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		pid, pidErr := strconv.Atoi(fields[0])
+		cpu, cpuErr := strconv.ParseFloat(strings.Replace(fields[1], ",", ".", 1), 64)
+		rss, rssErr := strconv.ParseInt(fields[2], 10, 64)
+		if pidErr != nil || cpuErr != nil || rssErr != nil {
+			continue
+		}
+		usage[pid] = resourceUsage{cpu: cpu, memory: rss * 1024}
+	}
+	return usage
+}
+
+// formatMemory() writes an amount of memory the way top does, e.g. 512K, 12.3M or 1.2G
+func formatMemory(bytes int64) string {
+	units := []string{"K", "M", "G", "T"}
+	value := float64(bytes) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 || value >= 100 {
+		return fmt.Sprintf("%.0f%s", value, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
+
+// fillResourceColumns() fills in the CPU and Memory columns on the first row of a process (or every row when compact,
+// like the other process columns). Processes without any usage, like those on the Windows side, are left empty.
+func fillResourceColumns(rows []table.Row, proc process, options settings) {
+	usage, found := options.resources[proc.ID]
+	if !options.showResources || !found || len(rows) == 0 {
+		return
+	}
+
+	filled := rows[:1]
+	if options.density == densityCompact {
+		filled = rows
+	}
+	for i, c := range options.columns {
+		for _, row := range filled {
+			switch c.Title {
+			case "CPU":
+				row[i] = strconv.FormatFloat(usage.cpu, 'f', 1, 64)
+			case "Memory":
+				row[i] = formatMemory(usage.memory)
+			}
+		}
+	}
+}
+
+// sortProcesses() puts the processes using the most CPU or memory first, or those with the oldest connections (see
+// age.go), if the table's sorted by any of them. The sort is stable, so processes that are level stay in the order the
+// backend found them in.
+func sortProcesses(processes []process, options settings) {
+	if options.sortBy == "" {
+		return
+	}
+	sort.SliceStable(processes, func(i, j int) bool {
+		if options.sortBy == "age" {
+			a, foundA := oldestConnection(processes[i], options)
+			b, foundB := oldestConnection(processes[j], options)
+			return foundA && (!foundB || a.since.Before(b.since))
+		}
+
+		a, b := options.resources[processes[i].ID], options.resources[processes[j].ID]
+		if options.sortBy == "memory" {
+			return a.memory > b.memory
+		}
+		return a.cpu > b.cpu
+	})
+}
+
+// sortNeedsResources() checks if an order needs each process' CPU and memory, which are only collected when needed
+func sortNeedsResources(order string) bool {
+	return order == "cpu" || order == "memory"
+}
+
+// validSort() checks if an order is one --sort accepts
+func validSort(order string) bool {
+	return slices.Contains(sortOrders, order)
+}
+
+// sortLabel() names an order, for the help and plain mode
+func sortLabel(order string) string {
+	if order == "" {
+		return tr("sort.default")
+	}
+	return tr("sort." + order)
+}
+
+// cycleSort() moves on to the next order the table can be sorted in
+func (m *model) cycleSort() {
+	next := (slices.Index(sortOrders, m.settings.sortBy) + 1) % len(sortOrders)
+	m.setSort(sortOrders[next])
+}
+
+// setSort() sorts the table, and says how it's sorted
+func (m *model) setSort(order string) {
+	m.settings.sortBy = order
+	m.keys.Sort.SetHelp("O", tr("help.sort", sortLabel(order)))
+	m.announce(tr("plain.sort", sortLabel(order)))
+}
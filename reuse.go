@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// PID Reuse Protection
+// PIDs get reused. If the process in the table exits and something else starts with the same PID before you press t,
+// terminating "it" would terminate the wrong process. So before a process is signalled, what's running with its PID
+// now is checked against what was collected: it has to have the same name, and have started before the collection.
+// If either doesn't match, nothing is signalled.
+//
+// On Linux both come from /proc, and elsewhere from ps. If they can't be found out (the process has gone, or there's no
+// ps), the signal is sent anyway, and fails or succeeds on its own.
+
+// How much later than the collection a process can seem to have started before it's taken to be a different one.
+// Start times aren't exact (ps only gives them to the second), so anything closer than this is given the benefit of
+// the doubt.
+const startSlack = time.Second
+
+// checkSameProcess() checks the process running with proc's PID now is the one that was collected at collectedAt
+func checkSameProcess(proc process, collectedAt time.Time) error {
+	if proc.ID == 0 || proc.Windows || collectedAt.IsZero() || runtime.GOOS == "windows" {
+		return nil
+	}
+
+	name, started, found := currentProcess(proc.ID)
+	if !found {
+		return nil
+	}
+
+	// Backends cut long names short by different amounts (lsof at 9 characters, /proc at 15), so one only has to
+	// start with the other
+	sameName := proc.Name == "" || strings.HasPrefix(name, proc.Name) || strings.HasPrefix(proc.Name, name)
+	if !sameName || started.After(collectedAt.Add(startSlack)) {
+		debugf("PID %d was %q when collected at %s, but is now %q started at %s", proc.ID, proc.Name,
+			collectedAt.Format(time.RFC3339), name, started.Format(time.RFC3339))
+		return errors.New(tr("error.pid-reused", proc.ID, processLabel(proc)))
+	}
+	return nil
+}
+
+// currentProcess() finds the name and start time of the process running with a PID
+func currentProcess(pid int) (string, time.Time, bool) {
+	if runtime.GOOS == "linux" {
+		return procIdentity(pid)
+	}
+	return psIdentity(pid)
+}
+
+// procIdentity() reads a process' name and start time from /proc. The start time in /proc/<pid>/stat is in clock
+// ticks since boot, which is almost always 100 a second, and the boot time is in /proc/stat.
+func procIdentity(pid int) (string, time.Time, bool) {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	// The name is in brackets, and can have spaces and brackets in it, so it runs up to the last )
+	start, end := strings.IndexByte(string(stat), '('), strings.LastIndexByte(string(stat), ')')
+	if start == -1 || end < start {
+		return "", time.Time{}, false
+	}
+	name := string(stat[start+1 : end])
+
+	// The start time is field 22, counting the PID as 1 and the name as 2
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return "", time.Time{}, false
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	boot, found := bootTime()
+	if !found {
+		return "", time.Time{}, false
+	}
+	return name, boot.Add(time.Duration(ticks) * time.Second / 100), true
+}
+
+// bootTime() reads when the system booted from /proc/stat
+func bootTime() (time.Time, bool) {
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return time.Unix(seconds, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// psIdentity() asks ps for a process' name and start time. lstart is in the C locale's date format, so ps is run in
+// it, and comes before the name as it has a fixed number of spaces in it while the name might not.
+func psIdentity(pid int) (string, time.Time, bool) {
+	if !commandExists("ps") {
+		return "", time.Time{}, false
+	}
+
+	// Command is `ps -o lstart= -o comm= -p PID`
+	cmd := exec.Command("ps", "-o", "lstart=", "-o", "comm=", "-p", strconv.Itoa(pid))
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	// e.g. "Thu Oct 16 09:41:07 2026 /usr/sbin/sshd"
+	fields := strings.Fields(string(out))
+	if len(fields) < 6 {
+		return "", time.Time{}, false
+	}
+	started, err := time.ParseInLocation("Mon Jan 2 15:04:05 2006", strings.Join(fields[:5], " "), time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	// macOS gives the whole path of the executable
+	return filepath.Base(strings.Join(fields[5:], " ")), started, true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Hiding pvw
+// pvw makes connections of its own (probing ports, guessing protocols, talking to Kubernetes...), and so can the
+// commands it runs. They aren't what anyone's looking for, and terminating them from inside pvw only confuses it. So
+// --hide-self, which is on by default, leaves pvw and its children out of the table, and S shows or hides them.
+//
+// Which processes are pvw's children is worked out again after every collection, as they come and go.
+
+// selfProcesses() returns pvw's PID and the PIDs of its children. On Linux the children come from /proc, and elsewhere
+// from ps. If neither works, only pvw itself is returned.
+func selfProcesses() map[int]bool {
+	self := os.Getpid()
+	pids := map[int]bool{self: true}
+
+	var children []int
+	if runtime.GOOS == "linux" {
+		children = procChildren(self)
+	} else if runtime.GOOS != "windows" && commandExists("ps") {
+		children, _ = childProcesses(self)
+	}
+
+	for _, child := range children {
+		pids[child] = true
+	}
+	return pids
+}
+
+// procChildren() reads a process' children from /proc/<pid>/task/<tid>/children, which lists the children started by
+// each of its threads
+func procChildren(pid int) []int {
+	files, _ := filepath.Glob(filepath.Join("/proc", strconv.Itoa(pid), "task", "*", "children"))
+
+	var children []int
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, field := range strings.Fields(string(raw)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+// selfLabel() is the help for S, which says what pressing it will do
+func selfLabel(hidden bool) string {
+	if hidden {
+		return tr("help.show-self")
+	}
+	return tr("help.hide-self")
+}
+
+// toggleSelf() shows pvw's own processes if they're hidden, or hides them if they're shown
+func (m *model) toggleSelf() {
+	m.settings.hideSelf = !m.settings.hideSelf
+	m.keys.Self.SetHelp("S", selfLabel(m.settings.hideSelf))
+	if m.settings.hideSelf {
+		m.announce(tr("plain.self-hidden"))
+	} else {
+		m.announce(tr("plain.self-shown"))
+	}
+}
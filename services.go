@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Service Manager Sockets
+// systemd can hold a port open for a service that hasn't started yet (socket activation): it listens on the port
+// itself, and starts the service when the first connection comes in. In the table, that's systemd (PID 1) holding the
+// port, which doesn't say much, and terminating it isn't an option. pvw names those rows after the service the socket
+// unit starts ("systemd socket → cups.service"), and Y lists every socket unit listening on a port, the system's and
+// the user's, whether or not the filters hide them:
+//
+//	> cups.socket (active) → cups.service
+//	    TCP 0.0.0.0:631
+//	  docker-api.socket (inactive) → docker.service
+//	    TCP 127.0.0.1:2375
+//
+// ↑ and ↓ pick a unit, s stops it (asking first, like terminating does), and c starts it again, through systemctl.
+// Stopping the socket only frees the port if the service hasn't started yet, as a service that has has the socket too.
+// Starting or stopping the system's units needs root, or whatever polkit allows, as there's nowhere for systemctl to
+// ask for a password.
+//
+// On macOS, the panel lists launchd's jobs instead (see launchd.go).
+
+// serviceSocket is a socket unit: a socket the service manager listens on, and the service it starts. For launchd,
+// it's a job that listens on ports, whether or not launchd listens on them for it.
+type serviceSocket struct {
+	manager string // systemd or launchd
+	unit    string // e.g. cups.socket, or the job's label for launchd
+	service string // e.g. cups.service, or "" if systemd didn't say
+	plist   string // The job's property list for launchd, or "" if it wasn't found
+	user    bool   // Whether it's the user's service manager's, rather than the system's
+	state   string // e.g. active, or "" if it isn't known
+	listens []socketListen
+}
+
+// socketListen is an address a socket unit listens on
+type socketListen struct{ protocol, address, port string }
+
+// serviceSocketsMsg opens the service sockets panel with the socket units found
+type serviceSocketsMsg struct {
+	sockets []serviceSocket
+	err     error
+}
+
+// serviceSocketMsg is sent once a socket unit has been started or stopped
+type serviceSocketMsg struct{}
+
+// target() names what a socket unit is for: the service it starts, or the unit itself if that isn't known
+func (s serviceSocket) target() string {
+	if s.service != "" {
+		return s.service
+	}
+	return s.unit
+}
+
+// label() describes a socket unit for the panel, e.g. "cups.socket (active) → cups.service"
+func (s serviceSocket) label() string {
+	label := s.unit
+	if s.state != "" {
+		label += " (" + s.state + ")"
+	}
+	if s.service != "" {
+		label += " → " + s.service
+	}
+	return label
+}
+
+// parseListen() splits a socket unit's listen address into its address and port. One that's only a port listens on
+// every address. It returns false for sockets that aren't on a port (files, netlink, vsock...).
+func parseListen(listen string) (string, string, bool) {
+	if _, err := strconv.Atoi(listen); err == nil {
+		return "*", listen, true
+	}
+	colon := strings.LastIndex(listen, ":")
+	if colon == -1 || strings.HasPrefix(listen, "/") || strings.HasPrefix(listen, "@") ||
+		strings.HasPrefix(listen, "vsock:") {
+		return "", "", false
+	}
+	if _, err := strconv.Atoi(listen[colon+1:]); err != nil {
+		return "", "", false
+	}
+	return listen[:colon], listen[colon+1:], true
+}
+
+// parseListSockets() parses the output of `systemctl list-sockets --show-types --no-legend --full`, which has a line
+// for each address a unit listens on:
+//
+//	0.0.0.0:631 Stream cups.socket cups.service
+//
+// Units that don't listen on any ports are left out.
+func parseListSockets(output string, user bool) []serviceSocket {
+	socket := serviceSocket{manager: "systemd", user: user}
+	var sockets []serviceSocket
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		address, port, onPort := parseListen(fields[0])
+		protocol := map[string]string{"Stream": "TCP", "Datagram": "UDP"}[fields[1]]
+		if !onPort || protocol == "" {
+			continue
+		}
+
+		i := slices.IndexFunc(sockets, func(s serviceSocket) bool { return s.unit == fields[2] })
+		if i == -1 {
+			socket.unit, socket.service = fields[2], strings.Join(fields[3:], " ")
+			sockets = append(sockets, socket)
+			i = len(sockets) - 1
+		}
+		sockets[i].listens = append(sockets[i].listens, socketListen{protocol, address, port})
+	}
+	return sockets
+}
+
+// parseUnitStates() parses the output of `systemctl list-units --plain --no-legend --full` into each unit's state
+func parseUnitStates(output string) map[string]string {
+	states := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 3 {
+			states[fields[0]] = fields[2]
+		}
+	}
+	return states
+}
+
+// systemdSockets() lists the system's or the user's socket units that listen on ports
+func systemdSockets(user bool) ([]serviceSocket, error) {
+	if runtime.GOOS != "linux" || !commandExists("systemctl") {
+		return nil, errors.New(tr("error.no-service-manager"))
+	}
+
+	options := []string{"--all", "--no-legend", "--no-pager", "--full"}
+	if user {
+		options = append(options, "--user")
+	}
+	out, err := exec.Command("systemctl", append([]string{"list-sockets", "--show-types"}, options...)...).Output()
+	if err != nil {
+		return nil, explainFailure(err, "systemctl")
+	}
+	sockets := parseListSockets(string(out), user)
+
+	// The states are only for the panel, so it isn't worth failing over them
+	list := append([]string{"list-units", "--type=socket", "--plain"}, options...)
+	if out, err := exec.Command("systemctl", list...).Output(); err == nil {
+		states := parseUnitStates(string(out))
+		for i := range sockets {
+			sockets[i].state = states[sockets[i].unit]
+		}
+	}
+	return sockets, nil
+}
+
+// findServiceSockets() lists the system's socket units, then the user's, or launchd's jobs on macOS
+func findServiceSockets(processes []process) ([]serviceSocket, error) {
+	if runtime.GOOS == "darwin" {
+		return launchdSockets(processes)
+	}
+	sockets, err := systemdSockets(false)
+	if err != nil {
+		return nil, err
+	}
+	// There isn't always a user service manager (in containers, or over some ssh setups)
+	users, _ := systemdSockets(true)
+	return append(sockets, users...), nil
+}
+
+// socketFor() finds the socket unit listening on a connection's port
+func socketFor(conn connection, sockets []serviceSocket) (serviceSocket, bool) {
+	for _, socket := range sockets {
+		for _, listen := range socket.listens {
+			if listen.port == conn.LocalPort && listen.protocol == conn.Protocol {
+				return socket, true
+			}
+		}
+	}
+	return serviceSocket{}, false
+}
+
+// isServiceManager() checks if a process is a systemd or launchd holding sockets for its units. Any systemd but PID 1
+// is a user's.
+func isServiceManager(proc process) bool {
+	return !proc.Windows &&
+		(proc.Name == "systemd" && runtime.GOOS == "linux" || proc.Name == "launchd" && runtime.GOOS == "darwin")
+}
+
+// managerSockets() lists the sockets a systemd or launchd holds for its units
+func managerSockets(proc process) ([]serviceSocket, error) {
+	if proc.Name == "launchd" {
+		// Only the jobs with Sockets in their property lists, as they're the ones launchd listens on for
+		return launchdSockets(nil)
+	}
+	return systemdSockets(proc.ID != 1)
+}
+
+// fillServiceSocketNames() names the rows of the sockets systemd or launchd holds after the services they're for
+func fillServiceSocketNames(rows []table.Row, proc process, options settings) {
+	if !isServiceManager(proc) {
+		return
+	}
+	column := columnIndex(options, "Name")
+	if column == -1 {
+		return
+	}
+
+	sockets, err := managerSockets(proc)
+	if err != nil {
+		debugf("couldn't list %s's sockets: %v", proc.Name, err)
+		return
+	}
+	for i, conn := range proc.Connections {
+		if socket, found := socketFor(conn, sockets); found && i < len(rows) && isBound(conn) {
+			rows[i][column] = tr("services.socket", socket.manager, socket.target())
+		}
+	}
+}
+
+// serviceSocketLines() describes the socket units a systemd or launchd is holding sockets for, for the detail pane
+func serviceSocketLines(proc process) []string {
+	if !isServiceManager(proc) {
+		return nil
+	}
+	sockets, err := managerSockets(proc)
+	if err != nil {
+		return nil
+	}
+
+	var lines []string
+	for _, conn := range proc.Connections {
+		if socket, found := socketFor(conn, sockets); found && isBound(conn) {
+			lines = append(lines, fmt.Sprintf("%s %s:%s → %s", conn.Protocol, conn.LocalAddress, conn.LocalPort,
+				socket.label()))
+		}
+	}
+	return lines
+}
+
+// showServiceSockets() opens the service sockets panel
+func showServiceSockets(processes []process) tea.Cmd {
+	return func() tea.Msg {
+		sockets, err := findServiceSockets(processes)
+		return serviceSocketsMsg{sockets: sockets, err: err}
+	}
+}
+
+// serviceSocketsOpen() checks if the detail pane is showing the service sockets panel
+func (m model) serviceSocketsOpen() bool {
+	return m.showDetail && m.detailTitle == tr("services.title")
+}
+
+// openServiceSockets() shows the socket units in the detail pane, keeping the same one selected if it's still there
+func (m *model) openServiceSockets(sockets []serviceSocket) {
+	var selected serviceSocket
+	if m.socketCursor < len(m.serviceSockets) {
+		selected = m.serviceSockets[m.socketCursor]
+	}
+	m.serviceSockets = sockets
+
+	m.socketCursor = 0
+	for i, socket := range sockets {
+		if socket.unit == selected.unit && socket.user == selected.user {
+			m.socketCursor = i
+		}
+	}
+	m.openDetail(detailMsg{title: tr("services.title"), body: m.serviceSocketsReport()})
+}
+
+// serviceSocketsReport() lists the socket units and what they listen on, with the selected one marked
+func (m model) serviceSocketsReport() string {
+	if len(m.serviceSockets) == 0 {
+		return tr("services.none") + "\n"
+	}
+
+	var b strings.Builder
+	for i, socket := range m.serviceSockets {
+		marker := "  "
+		if i == m.socketCursor {
+			marker = "> "
+		}
+		label := socket.label()
+		if socket.user {
+			label += " " + tr("services.user")
+		}
+		fmt.Fprintln(&b, marker+label)
+		for _, listen := range socket.listens {
+			fmt.Fprintf(&b, "    %s %s:%s\n", listen.protocol, listen.address, listen.port)
+		}
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, tr("services.keys"))
+	return b.String()
+}
+
+// moveSocketCursor() selects the next or previous socket unit
+func (m *model) moveSocketCursor(by int) {
+	if cursor := m.socketCursor + by; cursor >= 0 && cursor < len(m.serviceSockets) {
+		m.socketCursor = cursor
+		m.openDetail(detailMsg{title: tr("services.title"), body: m.serviceSocketsReport()})
+	}
+}
+
+// selectedServiceSocket() returns the selected socket unit, if there are any
+func (m model) selectedServiceSocket() (serviceSocket, bool) {
+	if m.socketCursor >= len(m.serviceSockets) {
+		return serviceSocket{}, false
+	}
+	return m.serviceSockets[m.socketCursor], true
+}
+
+// stopServiceSocket() stops the selected socket unit, asking first if terminating would
+func (m *model) stopServiceSocket() tea.Cmd {
+	socket, exists := m.selectedServiceSocket()
+	if !exists || m.settings.readOnly {
+		return nil
+	}
+	if m.settings.confirmTerminate {
+		m.openPrompt(tr("prompt.stop-socket", socket.unit), "", func(answer string) tea.Cmd {
+			if !strings.HasPrefix(strings.ToLower(answer), "y") {
+				return nil
+			}
+			return controlServiceSocket(socket, "stop")
+		})
+		return textinput.Blink
+	}
+	return controlServiceSocket(socket, "stop")
+}
+
+// controlServiceSocket() starts or stops a socket unit, or loads or unloads a launchd job
+func controlServiceSocket(socket serviceSocket, verb string) tea.Cmd {
+	return func() tea.Msg {
+		// Command is `systemctl [--user] --no-ask-password start|stop UNIT`, or `launchctl load|unload PLIST`
+		command, args := "systemctl", []string{"--no-ask-password", verb, socket.unit}
+		if socket.user {
+			args = append([]string{"--user"}, args...)
+		}
+		if socket.manager == "launchd" {
+			if socket.plist == "" {
+				return errMsg{errors.New(tr("error.no-plist", socket.unit))}
+			}
+			command, args = "launchctl", []string{map[string]string{"start": "load", "stop": "unload"}[verb], socket.plist}
+		}
+
+		debugf("running %s %s", command, strings.Join(args, " "))
+		if out, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+			said, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+			if said == "" {
+				said = err.Error()
+			}
+			return errMsg{errors.New(tr("error.socket-unit", command, socket.unit, said))}
+		}
+		return serviceSocketMsg{}
+	}
+}
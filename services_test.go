@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// TestParseListSockets checks socket units are read from systemctl list-sockets, with the ones that aren't on a port
+// left out
+func TestParseListSockets(t *testing.T) {
+	output := `/run/dbus/system_bus_socket Stream      dbus.socket       dbus.service
+0.0.0.0:631                 Stream      cups.socket       cups.service
+[::]:631                    Stream      cups.socket       cups.service
+22                          Stream      sshd.socket       sshd@.service
+127.0.0.1:5353              Datagram    resolver.socket
+route 1361                  Netlink     systemd-networkd.socket systemd-networkd.service
+vsock::22                   Stream      sshd-vsock.socket
+`
+	sockets := parseListSockets(output, false)
+
+	expected := map[string][]socketListen{
+		"cups.socket":     {{"TCP", "0.0.0.0", "631"}, {"TCP", "[::]", "631"}},
+		"sshd.socket":     {{"TCP", "*", "22"}},
+		"resolver.socket": {{"UDP", "127.0.0.1", "5353"}},
+	}
+	if len(sockets) != len(expected) {
+		t.Fatalf("expected %d units, got %+v", len(expected), sockets)
+	}
+	for _, socket := range sockets {
+		listens := expected[socket.unit]
+		if len(listens) != len(socket.listens) {
+			t.Errorf("%s: expected %v, got %v", socket.unit, listens, socket.listens)
+			continue
+		}
+		for i := range listens {
+			if listens[i] != socket.listens[i] {
+				t.Errorf("%s: expected %v, got %v", socket.unit, listens[i], socket.listens[i])
+			}
+		}
+	}
+
+	conn := connection{Protocol: "TCP", LocalAddress: "*", LocalPort: "631", Status: "LISTEN"}
+	if socket, found := socketFor(conn, sockets); !found || socket.target() != "cups.service" {
+		t.Errorf("expected port 631 to be cups.service's, got %+v", socket)
+	}
+}
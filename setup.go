@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Setup Wizard
+// The first time pvw starts without a config file (in a terminal), it offers to make one by asking four questions:
+// the colours to use, which columns to show, how often to refresh, and whether t should ask before terminating
+// anything. `pvw setup` asks them again at any time, keeping anything else already in the config file. Saying no to
+// the offer is remembered in the state directory, so it's only made once.
+
+// The themes the table can be drawn with (see tableStyles())
+var themes = []string{"teal", "blue", "mono"}
+
+// The columns the wizard offers, by the name of their --show- flag
+var setupColumns = []string{
+	"process-name", "owner", "cwd", "project", "framework", "protocol", "addresses", "full-connection", "queues",
+	"tcp-info", "exposed", "privileges", "interface", "nice", "resources", "age", "peers",
+}
+
+// setupSkippedPath() returns the file that remembers the wizard was turned down
+func setupSkippedPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "setup-skipped")
+}
+
+// isTerminal() checks if a file is a terminal, so there's someone there to answer questions
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// shouldOfferSetup() checks if the wizard should be offered: the config file is the usual one and doesn't exist yet,
+// someone's at a terminal to answer it, and it hasn't been turned down before
+func shouldOfferSetup(path string) bool {
+	if path == "" || path != defaultConfigPath() || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return false
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	_, err := os.Stat(setupSkippedPath())
+	return setupSkippedPath() != "" && errors.Is(err, fs.ErrNotExist)
+}
+
+// offerSetup() asks whether to run the wizard, and runs it if so. Saying no is remembered.
+func offerSetup(path string, in io.Reader, w io.Writer) {
+	answers := bufio.NewReader(in)
+	fmt.Fprint(w, tr("setup.offer"))
+	answer, _ := answers.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	if answer != "" && !strings.HasPrefix(answer, "y") {
+		if err := os.MkdirAll(filepath.Dir(setupSkippedPath()), 0o755); err == nil {
+			err = os.WriteFile(setupSkippedPath(), nil, 0o644)
+			if err != nil {
+				debugf("couldn't remember the setup was skipped: %v", err)
+			}
+		}
+		fmt.Fprintln(w, tr("setup.skipped"))
+		return
+	}
+
+	if err := runSetupWizard(path, answers, w); err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+	}
+}
+
+// runSetupWizard() asks the wizard's questions and writes the answers to the config file, keeping whatever else is
+// already in it
+func runSetupWizard(path string, answers *bufio.Reader, w io.Writer) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return fmt.Errorf(tr("error.config"), path, err)
+	}
+
+	ask := func(question string, valid func(string) bool) string {
+		for {
+			fmt.Fprint(w, question)
+			answer, err := answers.ReadString('\n')
+			answer = strings.TrimSpace(answer)
+			if valid(answer) || err != nil {
+				return answer
+			}
+			fmt.Fprintln(w, tr("setup.invalid"))
+		}
+	}
+
+	cfg.Theme = ask(tr("setup.theme", strings.Join(themes, ", ")), func(answer string) bool {
+		return answer == "" || slices.Contains(themes, answer)
+	})
+
+	columns := ask(tr("setup.columns", strings.Join(setupColumns, ", ")), func(answer string) bool {
+		for _, column := range splitList(answer) {
+			if !slices.Contains(setupColumns, column) {
+				return false
+			}
+		}
+		return true
+	})
+	cfg.Show = splitList(columns)
+
+	cfg.Interval = ask(tr("setup.interval"), func(answer string) bool {
+		_, err := time.ParseDuration(answer)
+		return answer == "" || err == nil
+	})
+
+	confirm := ask(tr("setup.confirm"), func(string) bool { return true })
+	cfg.ConfirmTerminate = strings.HasPrefix(strings.ToLower(confirm), "y")
+
+	raw, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, tr("setup.saved", path))
+	return nil
+}
+
+// splitList() splits a comma or space separated answer into its items
+func splitList(answer string) []string {
+	return strings.Fields(strings.ReplaceAll(answer, ",", " "))
+}
+
+// runSetup() runs setup mode with the arguments after `setup`, and returns the exit code
+func runSetup(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("setup", pflag.ContinueOnError)
+	flagConfig := flags.String("config", defaultConfigPath(), tr("flag.config"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("setup.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	if *flagConfig == "" {
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.no-config-path")))
+		return exitError
+	}
+
+	if err := runSetupWizard(*flagConfig, bufio.NewReader(os.Stdin), w); err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Snapshots
+// `pvw snapshot` saves every socket and the process it belongs to as JSON, and `pvw diff` compares two of them (or one
+// with what's open now), listing the listeners and connections that appeared or disappeared. That makes it easy to see
+// what a deployment changed:
+//
+//	pvw snapshot && ./deploy.sh && pvw diff --since 0s
+//
+// Snapshots go in the snapshot directory (~/.cache/pvw/snapshots on Linux) unless --output says otherwise, named after
+// when they were taken. --since finds the newest one there that's at least that old. Only the newest maxSnapshots are
+// kept, so running `pvw snapshot` from cron doesn't fill the disk.
+//
+// `pvw diff` exits with 0 if nothing changed, 1 if something went wrong, or 3 if something changed (see exit.go).
+
+// A snapshot, as saved to a file
+type snapshot struct {
+	Taken     time.Time `json:"taken"`
+	Processes []process `json:"processes"`
+}
+
+// How many snapshots to keep in the snapshot directory
+const maxSnapshots = 100
+
+// The format of snapshot file names, which sorts in the order they were taken
+const snapshotNameFormat = "20060102-150405.000"
+
+// snapshotDirectory() returns where snapshots are kept by default
+func snapshotDirectory() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pvw", "snapshots")
+}
+
+// runSnapshot() runs snapshot mode with the arguments after `snapshot`, and returns the exit code
+func runSnapshot(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("snapshot", pflag.ContinueOnError)
+	flagOutput := flags.StringP("output", "o", "", tr("flag.snapshot-output"))
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("snapshot.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	w = quietOutput(w, *flagQuiet)
+
+	current, err := takeSnapshot(*flagBackend, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	path := *flagOutput
+	if path == "" {
+		dir := snapshotDirectory()
+		if dir == "" {
+			fmt.Fprintln(os.Stderr, tr("error.running", tr("error.no-snapshot-directory")))
+			return exitError
+		}
+		path = filepath.Join(dir, current.Taken.Format(snapshotNameFormat)+".json")
+	}
+
+	if err := saveSnapshot(path, current); err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+	if *flagOutput == "" {
+		pruneSnapshots(filepath.Dir(path))
+	}
+
+	fmt.Fprintln(w, path)
+	return exitOK
+}
+
+// runDiff() runs diff mode with the arguments after `diff`, and returns the exit code
+func runDiff(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("diff", pflag.ContinueOnError)
+	flagSince := flags.Duration("since", 0, tr("flag.since"))
+	flagBackend := flags.String("backend", "auto", tr("flag.backend"))
+	flagTimeout := flags.Duration("timeout", 10*time.Second, tr("flag.timeout"))
+	flagQuiet := flags.BoolP("quiet", "q", false, tr("flag.quiet"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("diff.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+	w = quietOutput(w, *flagQuiet)
+
+	// Work out what to compare: two files, a file and now, or an old snapshot and now
+	var before, after snapshot
+	var err error
+	files := flags.Args()
+	switch {
+	case len(files) == 2:
+		before, err = loadSnapshot(files[0])
+		if err == nil {
+			after, err = loadSnapshot(files[1])
+		}
+
+	case len(files) == 1:
+		before, err = loadSnapshot(files[0])
+		if err == nil {
+			after, err = takeSnapshot(*flagBackend, *flagTimeout)
+		}
+
+	case len(files) == 0 && flags.Changed("since"):
+		before, err = snapshotSince(snapshotDirectory(), *flagSince)
+		if err == nil {
+			after, err = takeSnapshot(*flagBackend, *flagTimeout)
+		}
+
+	default:
+		flags.Usage()
+		return exitError
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	changes := diffSnapshots(before, after)
+	fmt.Fprintln(w, tr("diff.comparing", before.Taken.Format(time.RFC3339), after.Taken.Format(time.RFC3339)))
+	if len(changes) == 0 {
+		fmt.Fprintln(w, tr("diff.unchanged"))
+		return exitOK
+	}
+	for _, change := range changes {
+		fmt.Fprintln(w, change)
+	}
+	return exitConflict
+}
+
+// takeSnapshot() gets every process with a socket open from the named backend
+func takeSnapshot(backendName string, timeout time.Duration) (snapshot, error) {
+	processes, err := collectListeners(backendName, timeout)
+	if err != nil {
+		return snapshot{}, err
+	}
+	return snapshot{Taken: time.Now(), Processes: processes}, nil
+}
+
+// saveSnapshot() writes a snapshot to a file, creating its directory if needed
+func saveSnapshot(path string, s snapshot) error {
+	raw, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
+
+// loadSnapshot() reads a snapshot from a file
+func loadSnapshot(path string) (snapshot, error) {
+	var s snapshot
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return s, fmt.Errorf(tr("error.snapshot"), path, err)
+	}
+	return s, nil
+}
+
+// savedSnapshots() lists the snapshots in a directory, oldest first
+func savedSnapshots(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(paths)
+	return paths
+}
+
+// snapshotSince() loads the newest snapshot in a directory that was taken at least a duration ago
+func snapshotSince(dir string, since time.Duration) (snapshot, error) {
+	cutoff := time.Now().Add(-since)
+
+	paths := savedSnapshots(dir)
+	for i := len(paths) - 1; i >= 0; i-- {
+		s, err := loadSnapshot(paths[i])
+		if err != nil {
+			debugf("skipping snapshot %s: %v", paths[i], err)
+			continue
+		}
+		if !s.Taken.After(cutoff) {
+			return s, nil
+		}
+	}
+
+	return snapshot{}, errors.New(tr("error.no-snapshot", since, dir))
+}
+
+// pruneSnapshots() deletes all but the newest maxSnapshots snapshots in a directory
+func pruneSnapshots(dir string) {
+	paths := savedSnapshots(dir)
+	for len(paths) > maxSnapshots {
+		if err := os.Remove(paths[0]); err != nil {
+			debugf("couldn't remove old snapshot %s: %v", paths[0], err)
+		}
+		paths = paths[1:]
+	}
+}
+
+// diffSnapshots() lists the sockets that disappeared (starting with -) and appeared (starting with +) between two
+// snapshots, with listeners first
+func diffSnapshots(before snapshot, after snapshot) []string {
+	old, current := snapshotSockets(before), snapshotSockets(after)
+
+	var listeners, connections []string
+	add := func(line string, conn connection) {
+		if isListening(conn) {
+			listeners = append(listeners, line)
+		} else {
+			connections = append(connections, line)
+		}
+	}
+
+	for _, key := range sortedKeys(old) {
+		if _, exists := current[key]; !exists {
+			add("- "+key, old[key])
+		}
+	}
+	for _, key := range sortedKeys(current) {
+		if _, exists := old[key]; !exists {
+			add("+ "+key, current[key])
+		}
+	}
+
+	return append(listeners, connections...)
+}
+
+// snapshotSockets() describes every socket in a snapshot, e.g. "TCP *:8080 LISTEN node (PID 1234)", so the same
+// socket has the same description in both snapshots
+func snapshotSockets(s snapshot) map[string]connection {
+	sockets := make(map[string]connection)
+
+	for _, proc := range s.Processes {
+		for _, conn := range proc.Connections {
+			parts := []string{conn.Protocol, conn.LocalAddress + ":" + conn.LocalPort}
+			if conn.RemotePort != "" {
+				parts = append(parts, "-> "+conn.RemoteAddress+":"+conn.RemotePort)
+			}
+			if conn.Status != "" {
+				parts = append(parts, conn.Status)
+			}
+			parts = append(parts, processLabel(proc))
+
+			sockets[strings.Join(parts, " ")] = conn
+		}
+	}
+
+	return sockets
+}
+
+// sortedKeys() returns the keys of a map of sockets in order
+func sortedKeys(sockets map[string]connection) []string {
+	keys := make([]string, 0, len(sockets))
+	for key := range sockets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Sparkline
+// In watch mode, a count going up from one refresh to the next is easy to miss, and a spike that's come and gone is
+// invisible. So the number of connections in the table is remembered for the last few refreshes, and drawn as a
+// sparkline next to the search bar (and in the dashboard):
+//
+//	> type to search      ▁▁▂▂▃▇█▅▂▁ 212
+//
+// The sparkline is scaled between the smallest and biggest counts it shows, so small changes on a quiet machine still
+// show up.
+
+// How many refreshes the sparkline covers
+const sparklineLength = 30
+
+// The bars a sparkline is drawn with, from lowest to highest
+var sparklineBars = []rune("▁▂▃▄▅▆▇█")
+
+// recordConnectionCount() remembers how many connections the table has after a refresh, forgetting the oldest once
+// there are more than the sparkline shows. A new slice is made each time, as the dashboard is built from it in a
+// goroutine.
+func (m *model) recordConnectionCount(processes []process) {
+	total := 0
+	for _, proc := range processes {
+		total += len(proc.Connections)
+	}
+
+	counts := append(append([]int{}, m.connectionCounts...), total)
+	if len(counts) > sparklineLength {
+		counts = counts[len(counts)-sparklineLength:]
+	}
+	m.connectionCounts = counts
+}
+
+// sparkline() draws counts as a line of bars
+func sparkline(counts []int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+
+	lowest, highest := counts[0], counts[0]
+	for _, count := range counts {
+		if count < lowest {
+			lowest = count
+		}
+		if count > highest {
+			highest = count
+		}
+	}
+
+	var b strings.Builder
+	for _, count := range counts {
+		bar := 0
+		if highest > lowest {
+			bar = (count - lowest) * (len(sparklineBars) - 1) / (highest - lowest)
+		}
+		b.WriteRune(sparklineBars[bar])
+	}
+	return b.String()
+}
+
+// sparklineView() returns the sparkline shown next to the search bar, or "" if it isn't watch mode or there's only
+// been one refresh so far
+func (m model) sparklineView() string {
+	if m.settings.interval == 0 || len(m.connectionCounts) < 2 {
+		return ""
+	}
+	return noticeStyle.Render(tr("sparkline", sparkline(m.connectionCounts), m.connectionCounts[len(m.connectionCounts)-1]))
+}
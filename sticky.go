@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Sticky Process Rows
+// Only the first row of a process has its PID and Name, so after scrolling past it through a long list of connections
+// (a browser's, say) there's no telling whose they are. While the first row of a process is scrolled off the top of
+// the table, the top row borrows its details, marked with ↑ so it's clear they belong to a row above.
+//
+// The table doesn't say how far it's scrolled, so the model keeps track of it by following the same rules the table
+// does: it only scrolls when the cursor goes off the top or bottom.
+
+// The mark put before the details a row has borrowed
+const stickyMark = "↑"
+
+// trackTop() works out which row is at the top of the table, after the cursor has moved or the table has changed
+func (m *model) trackTop() {
+	cursor, height := m.table.Cursor(), m.table.Height()
+
+	if cursor < m.tableTop {
+		m.tableTop = cursor
+	} else if cursor > m.tableTop+height-1 {
+		m.tableTop = cursor - height + 1
+	}
+
+	// If the rows it was scrolled to are gone, the table jumps to the bottom
+	if m.tableTop > len(m.rows)-1 {
+		m.tableTop = len(m.rows) - height
+	}
+	if m.tableTop < 0 {
+		m.tableTop = 0
+	}
+}
+
+// tableView() renders the table, with the top row borrowing its process' details if they've been scrolled away
+func (m model) tableView() string {
+	top := m.tableTop
+	if top <= 0 || top >= len(m.rows) {
+		return m.table.View()
+	}
+
+	// Find the first row of the process the top row belongs to
+	first := -1
+	for _, start := range m.rowStarts {
+		if start > top {
+			break
+		}
+		first = start
+	}
+	if first == -1 || first == top {
+		return m.table.View()
+	}
+
+	// The rows are shared with the row cache, so the top row is copied rather than changed
+	sticky := make(table.Row, len(m.rows[top]))
+	copy(sticky, m.rows[top])
+	filled := copyProcessColumns(sticky, m.rows[first], m.settings)
+	if filled == -1 {
+		return m.table.View()
+	}
+	sticky[filled] = stickyMark + sticky[filled]
+
+	rows := make([]table.Row, len(m.rows))
+	copy(rows, m.rows)
+	rows[top] = sticky
+
+	t := m.table
+	t.SetRows(rows)
+	return t.View()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Pausing Processes
+// s pauses the selected process (with SIGSTOP) and c resumes it (with SIGCONT). A paused process keeps its sockets
+// open, so nothing else can take its ports, but stops using the CPU - handy for freeing up the machine for a moment,
+// or for holding one side of a race still while debugging. Paused processes (however they were paused) have a ⏸ badge
+// on the Status of each of their rows.
+
+// The badge shown on the rows of a paused process
+const stoppedBadge = "⏸ "
+
+// stopMsg says a process was paused or resumed
+type stopMsg struct{}
+
+// stopProcess() pauses or resumes a process
+func stopProcess(proc process, stop bool, collectedAt time.Time) tea.Cmd {
+	return func() tea.Msg {
+		if proc.ID == 0 || proc.Windows {
+			return errMsg{errors.New(tr("error.unknown-process"))}
+		}
+		if err := checkSameProcess(proc, collectedAt); err != nil {
+			return errMsg{err}
+		}
+
+		var err error
+		if stop {
+			err = pvw.Stop(proc.ID)
+		} else {
+			err = pvw.Continue(proc.ID)
+		}
+		if err != nil {
+			return exitedError(proc, err)
+		}
+		return stopMsg{}
+	}
+}
+
+// markStopped() sets Stopped on every process that's paused. On Linux the state comes from /proc/<pid>/stat, and
+// elsewhere from a single `ps -o stat=` for every process.
+func markStopped(processes []process) {
+	if runtime.GOOS == "linux" {
+		for i := range processes {
+			if !processes[i].Windows && processes[i].ID != 0 {
+				processes[i].Stopped = procStopped(processes[i].ID)
+			}
+		}
+		return
+	}
+
+	var pids []string
+	for _, proc := range processes {
+		if !proc.Windows && proc.ID != 0 {
+			pids = append(pids, strconv.Itoa(proc.ID))
+		}
+	}
+	if len(pids) == 0 || !commandExists("ps") {
+		return
+	}
+
+	// Command is `ps -o pid= -o stat= -p PID,PID...`. Stopped processes have a state starting with T.
+	out, _ := exec.Command("ps", "-o", "pid=", "-o", "stat=", "-p", strings.Join(pids, ",")).Output()
+	stopped := make(map[int]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.HasPrefix(fields[1], "T") {
+			pid, _ := strconv.Atoi(fields[0])
+			stopped[pid] = true
+		}
+	}
+
+	for i := range processes {
+		processes[i].Stopped = stopped[processes[i].ID]
+	}
+}
+
+// procStopped() checks if a process is paused, from the state in /proc/<pid>/stat
+func procStopped(pid int) bool {
+	fields, found := procStatFields(pid)
+	return found && (fields[0] == "T" || fields[0] == "t")
+}
+
+// procStatFields() reads /proc/<pid>/stat, and returns the fields after the name, starting with the state. It looks
+// like `1234 (node) T 1 ...`, and the name can have spaces and brackets in it, so the fields are found after the last ).
+func procStatFields(pid int) ([]string, bool) {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, false
+	}
+
+	end := strings.LastIndexByte(string(stat), ')')
+	if end == -1 {
+		return nil, false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	return fields, len(fields) > 0
+}
+
+// fillStoppedBadge() puts the paused badge on the Status of every row of a paused process, or on the first column of
+// its first row if the Status column isn't shown
+func fillStoppedBadge(rows []table.Row, proc process, options settings) {
+	if !proc.Stopped || len(rows) == 0 || len(options.columns) == 0 {
+		return
+	}
+
+	if column := columnIndex(options, "Status"); column != -1 {
+		for _, row := range rows {
+			row[column] = stoppedBadge + row[column]
+		}
+		return
+	}
+
+	rows[0][0] = stoppedBadge + rows[0][0]
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/allyring/pvw/internal/lsof"
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// TCP Health
+// When a connection is slow, the first question is whether it's the network or the app. The kernel keeps TCP's view of
+// every connection: how long its segments take to be acknowledged (the smoothed round trip time), how many it's had to
+// send again because they were lost, and how many it can have in flight (the congestion window, which shrinks every
+// time one is lost). i on an established TCP connection shows them at the top of the detail pane, with what they
+// suggest.
+//
+// The diag backend already has them, and with any other backend on Linux, the kernel is asked over netlink about the
+// one connection. Connections in other network namespaces can't be asked about, and other systems don't have netlink.
+
+// How long asking the kernel about a connection can take
+const tcpInfoTimeout = 2 * time.Second
+
+// Round trip times at or above this are slow enough to mention, even when nothing's lost
+const slowRTT = 200 * time.Millisecond
+
+// The smallest congestion window TCP shrinks to after losing segments (a connection starts with 10)
+const minimumCwnd = 2
+
+// hasTCPHealth() checks if a connection is one TCP keeps a view of worth showing
+func hasTCPHealth(conn connection) bool {
+	return conn.Protocol == "TCP" && conn.Status == "ESTABLISHED" && conn.RemotePort != ""
+}
+
+// tcpHealth() returns a connection with TCP's view of it filled in, asking the kernel if the backend didn't
+func tcpHealth(proc process, conn connection) (connection, error) {
+	if conn.CongestionWindow > 0 {
+		return conn, nil
+	}
+	if runtime.GOOS != "linux" || proc.Windows || proc.NetNamespace != "" {
+		return conn, errors.New(tr("error.no-tcp-info"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tcpInfoTimeout)
+	defer cancel()
+	return pvw.LookupTCPInfo(ctx, conn)
+}
+
+// tcpHealthFields() lists TCP's view of a connection for the detail pane
+func tcpHealthFields(conn connection) [][2]string {
+	return [][2]string{
+		{tr("tcp.rtt"), lsof.FormatRTT(conn.RTT)},
+		{tr("tcp.retransmits"), strconv.Itoa(conn.Retransmits)},
+		{tr("tcp.cwnd"), tr("tcp.segments", conn.CongestionWindow)},
+	}
+}
+
+// tcpHealthVerdict() says what TCP's view of a connection suggests, worst first
+func tcpHealthVerdict(conn connection) string {
+	switch {
+	case conn.CongestionWindow <= minimumCwnd:
+		return tr("tcp.verdict-cwnd")
+	case conn.Retransmits > 0:
+		return tr("tcp.verdict-retransmits", conn.Retransmits)
+	case conn.RTT >= slowRTT:
+		return tr("tcp.verdict-slow", lsof.FormatRTT(conn.RTT))
+	}
+	return tr("tcp.verdict-healthy")
+}
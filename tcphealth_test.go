@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTCPHealthVerdict checks the worst of what TCP's view of a connection suggests is what's said about it
+func TestTCPHealthVerdict(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		rtt         time.Duration
+		retransmits int
+		cwnd        int
+		expected    string
+	}{
+		{"healthy", 2 * time.Millisecond, 0, 10, tr("tcp.verdict-healthy")},
+		{"retransmits", 2 * time.Millisecond, 3, 10, tr("tcp.verdict-retransmits", 3)},
+		{"smallest window", 2 * time.Millisecond, 3, 1, tr("tcp.verdict-cwnd")},
+		{"slow", 250 * time.Millisecond, 0, 10, tr("tcp.verdict-slow", "250ms")},
+		{"lost beats slow", 250 * time.Millisecond, 1, 10, tr("tcp.verdict-retransmits", 1)},
+	} {
+		conn := connection{RTT: test.rtt, Retransmits: test.retransmits, CongestionWindow: test.cwnd}
+		if verdict := tcpHealthVerdict(conn); verdict != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, verdict)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Own Terminal
+// Terminating something running in the same terminal as pvw (a dev server you started before it, your editor's
+// language server, tmux itself) tends to have confusing results, like the terminal you're looking at going away. So
+// processes running in pvw's terminal get a ⌨ badge, and t asks before terminating one even if confirm_terminate is off.
+//
+// Inside tmux, every pane in the same tmux session counts as pvw's terminal, as that's where everything you started
+// from the session is running. Terminals are compared by name: pts/3 on Linux, ttys003 on macOS.
+
+// The badge shown on the rows of processes running in pvw's terminal
+const terminalBadge = "⌨ "
+
+// ownTerminals() returns the terminals that count as pvw's: the one it's running in, and every pane of its tmux
+// session if it's running in tmux
+func ownTerminals() map[string]bool {
+	terminals := make(map[string]bool)
+	if runtime.GOOS == "windows" {
+		return terminals
+	}
+
+	if runtime.GOOS == "linux" {
+		if terminal := procTerminal(os.Getpid()); terminal != "" {
+			terminals[terminal] = true
+		}
+	} else if commandExists("ps") {
+		// Command is `ps -o tty= -p PID`
+		out, _ := exec.Command("ps", "-o", "tty=", "-p", strconv.Itoa(os.Getpid())).Output()
+		if terminal := psTerminal(string(out)); terminal != "" {
+			terminals[terminal] = true
+		}
+	}
+
+	if os.Getenv("TMUX") != "" && commandExists("tmux") {
+		// Command is `tmux list-panes -s -F '#{pane_tty}'`, which lists the terminal of every pane in the session
+		out, err := exec.Command("tmux", "list-panes", "-s", "-F", "#{pane_tty}").Output()
+		if err != nil {
+			debugf("tmux failed to list the session's panes: %v", err)
+		}
+		for _, line := range strings.Fields(string(out)) {
+			terminals[strings.TrimPrefix(line, "/dev/")] = true
+		}
+	}
+
+	return terminals
+}
+
+// onTerminals() finds which processes are running in one of the terminals. On Linux each process' terminal comes from
+// /proc/<pid>/stat, and elsewhere from a single `ps -A -o tty=`.
+func onTerminals(processes []process, terminals map[string]bool) map[int]bool {
+	found := make(map[int]bool)
+	if len(terminals) == 0 {
+		return found
+	}
+
+	if runtime.GOOS == "linux" {
+		for _, proc := range processes {
+			if proc.ID != 0 && !proc.Windows && terminals[procTerminal(proc.ID)] {
+				found[proc.ID] = true
+			}
+		}
+		return found
+	}
+
+	if !commandExists("ps") {
+		return found
+	}
+
+	// Command is `ps -A -o pid= -o tty=`
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "tty=").Output()
+	if err != nil {
+		return found
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !terminals[psTerminal(fields[1])] {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[0]); err == nil {
+			found[pid] = true
+		}
+	}
+	return found
+}
+
+// procTerminal() names a process' controlling terminal from its device number in /proc/<pid>/stat, or returns "" if
+// it doesn't have one (or it isn't a terminal we know how to name). Pseudo-terminals are major 136 to 143, and virtual
+// consoles major 4.
+func procTerminal(pid int) string {
+	fields, found := procStatFields(pid)
+	if !found || len(fields) < 5 {
+		return ""
+	}
+	device, err := strconv.Atoi(fields[4])
+	if err != nil || device == 0 {
+		return ""
+	}
+
+	major := (device >> 8) & 0xfff
+	minor := (device & 0xff) | ((device >> 12) & 0xfff00)
+	switch {
+	case major >= 136 && major <= 143:
+		return "pts/" + strconv.Itoa((major-136)<<8|minor)
+	case major == 4 && minor < 64:
+		return "tty" + strconv.Itoa(minor)
+	}
+	return ""
+}
+
+// psTerminal() tidies up the terminal ps gives, which is ? (or ??) for processes without one
+func psTerminal(terminal string) string {
+	terminal = strings.TrimSpace(terminal)
+	if strings.Trim(terminal, "?") == "" {
+		return ""
+	}
+	return terminal
+}
+
+// fillTerminalBadge() puts the terminal badge on the Status of every row of a process running in pvw's terminal, or on
+// the first column if the Status column isn't shown
+func fillTerminalBadge(rows []table.Row, proc process, options settings) {
+	if !options.onTerminal[proc.ID] || len(options.columns) == 0 {
+		return
+	}
+
+	column := badgeColumn(options)
+	for _, row := range rows {
+		row[column] = terminalBadge + row[column]
+	}
+}
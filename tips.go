@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Tips
+// pvw has a lot of keys, and most people never find out about half of them. So for the first few times pvw is opened,
+// a line above the search bar suggests a key that's useful for what's on screen, like / to search when there's more
+// than fits in the table. Once a tip's key has been pressed it's been learned, and the next tip takes its place.
+//
+// X hides the tips for good, and so does "hide_tips": true in the config file. How many times pvw has been opened, and
+// whether the tips were hidden, are kept in the state directory ($XDG_STATE_HOME/pvw/tips.json, usually ~/.local/state).
+
+// How many times pvw shows tips before it stops on its own
+const tipSessions = 5
+
+// tip is a suggestion for a key, shown while it applies and until the key is pressed
+type tip struct {
+	text    string                   // The locale key for what's suggested
+	binding func(keyMap) key.Binding // The key being suggested
+	applies func(model) bool         // Whether the key would do something useful right now
+}
+
+// The tips, in the order they're suggested
+var tips = []tip{
+	{"tips.search", func(k keyMap) key.Binding { return k.Search }, func(m model) bool {
+		return !m.settings.displaySearch && m.settings.searchTerm == "" && len(m.rows) > m.table.Height()
+	}},
+	{"tips.info", func(k keyMap) key.Binding { return k.Info }, func(m model) bool {
+		return len(m.processes) > 0
+	}},
+	{"tips.filter", func(k keyMap) key.Binding { return k.Filter }, func(m model) bool {
+		return len(m.settings.filters) == 0 && len(m.processes) > 0
+	}},
+	{"tips.collapse", func(k keyMap) key.Binding { return k.Toggle }, func(m model) bool {
+		return len(m.rows) > len(m.processes)
+	}},
+	{"tips.help", func(k keyMap) key.Binding { return k.Help }, func(m model) bool { return true }},
+}
+
+// tipsState is what's remembered about the tips from one run of pvw to the next
+type tipsState struct {
+	Sessions  int  `json:"sessions"`            // How many times pvw has been opened with the tips on
+	Dismissed bool `json:"dismissed,omitempty"` // Whether X was pressed
+}
+
+// tipsPath() returns where the tips' state is kept
+func tipsPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "tips.json")
+}
+
+// loadTipsState() reads the tips' state, which is empty if it hasn't been saved before
+func loadTipsState() tipsState {
+	var state tipsState
+
+	raw, err := os.ReadFile(tipsPath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		debugf("ignoring the tips' state in %s: %v", tipsPath(), err)
+	}
+	return state
+}
+
+// saveTipsState() writes the tips' state out
+func saveTipsState(state tipsState) error {
+	path := tipsPath()
+	if path == "" {
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	temporary := path + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(temporary, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// startTips() decides whether this run of pvw shows tips, counting it as one of the runs that do if so
+func startTips(hidden bool) bool {
+	if hidden || tipsPath() == "" {
+		return false
+	}
+
+	state := loadTipsState()
+	if state.Dismissed || state.Sessions >= tipSessions {
+		return false
+	}
+
+	state.Sessions++
+	if err := saveTipsState(state); err != nil {
+		debugf("couldn't save the tips' state: %v", err)
+	}
+	return true
+}
+
+// currentTip() returns the line to show for the first tip that applies and hasn't been learned yet, or "" if there
+// isn't one (or the tips are off)
+func (m model) currentTip() string {
+	if !m.settings.tips {
+		return ""
+	}
+	for _, t := range tips {
+		if !m.tipsLearned[t.text] && t.binding(m.keys).Enabled() && t.applies(m) {
+			return tr("tips.line", tr(t.text))
+		}
+	}
+	return ""
+}
+
+// learnTip() marks the tips for a key as learned once it's been pressed
+func (m *model) learnTip(msg tea.KeyMsg) {
+	if !m.settings.tips {
+		return
+	}
+	for _, t := range tips {
+		if key.Matches(msg, t.binding(m.keys)) {
+			if m.tipsLearned == nil {
+				m.tipsLearned = make(map[string]bool)
+			}
+			m.tipsLearned[t.text] = true
+		}
+	}
+	m.fitTable()
+}
+
+// dismissTips() hides the tips, and remembers to keep them hidden next time
+func (m *model) dismissTips() tea.Cmd {
+	m.settings.tips = false
+	m.keys.DismissTips.SetEnabled(false)
+	m.fitTable()
+
+	return func() tea.Msg {
+		state := loadTipsState()
+		state.Dismissed = true
+		if err := saveTipsState(state); err != nil {
+			return errMsg{err}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Terminal Title
+// With --title, pvw sets the terminal's title (or the tmux pane's, which tmux can show in its status line or pane
+// borders) to a summary like "pvw: 14 listeners, 212 conns", and updates it after every refresh, so what's open can be
+// seen at a glance without switching to pvw. Terminals can't be asked what their title is, so the old one is saved on
+// the terminal's title stack when pvw starts and put back when it quits (terminals without a stack keep pvw's).
+
+// The escape codes for saving and restoring the title, and setting it (OSC 2, which sets tmux's pane title)
+const (
+	pushTitle = "\x1b[22;0t"
+	popTitle  = "\x1b[23;0t"
+	setTitle  = "\x1b]2;%s\x07"
+)
+
+// saveTitle() saves the terminal's title, to be restored by restoreTitle()
+func saveTitle() {
+	fmt.Fprint(os.Stdout, pushTitle)
+}
+
+// restoreTitle() puts back the title saveTitle() saved
+func restoreTitle() {
+	fmt.Fprint(os.Stdout, popTitle)
+}
+
+// updateTitle() sets the terminal's title to a summary of what's shown
+func updateTitle(processes []process) tea.Cmd {
+	listeners, connections := 0, 0
+	for _, proc := range processes {
+		for _, conn := range proc.Connections {
+			if isListening(conn) {
+				listeners++
+			} else {
+				connections++
+			}
+		}
+	}
+
+	// Control characters would end the escape code early
+	title := strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, tr("title.summary", listeners, connections))
+
+	return func() tea.Msg {
+		fmt.Fprintf(os.Stdout, setTitle, title)
+		return nil
+	}
+}
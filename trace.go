@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Tracing Processes
+// a attaches strace (on Linux) or dtruss (on macOS) to the selected process, for a quick look at what it's doing right
+// now. On Linux only the network system calls are traced (socket, connect, accept, send, recv...), with timestamps,
+// as they're usually what's interesting about a process with ports open; the rest are left out so they don't drown
+// them. dtruss can only be told about one system call at a time, so it traces everything.
+//
+// Threads and children are traced too. Like w (see capture.go), it opens in a new tmux pane if pvw is running inside
+// tmux, and takes over the terminal until ctrl+c otherwise. Attaching needs root, so sudo is put in front if needed.
+
+// traceTool() returns the tracer for this system, or "" if there isn't one installed
+func traceTool() string {
+	switch {
+	case runtime.GOOS == "linux" && commandExists("strace"):
+		return "strace"
+	case runtime.GOOS == "darwin" && commandExists("dtruss"):
+		return "dtruss"
+	}
+	return ""
+}
+
+// traceCommand() builds the command to trace a process with
+func traceCommand(pid int) []string {
+	if traceTool() == "dtruss" {
+		// Command is `dtruss -f -p PID`
+		return withPrivileges([]string{"dtruss", "-f", "-p", strconv.Itoa(pid)})
+	}
+	// Command is `strace -f -tt -e trace=network -p PID`
+	return withPrivileges([]string{"strace", "-f", "-tt", "-e", "trace=network", "-p", strconv.Itoa(pid)})
+}
+
+// traceProcess() attaches the tracer to a process
+func traceProcess(proc process) tea.Cmd {
+	if proc.ID == 0 || proc.Windows {
+		return func() tea.Msg { return errMsg{errors.New(tr("error.unknown-process"))} }
+	}
+	return handOff(traceCommand(proc.ID))
+}
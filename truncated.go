@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Truncated Values
+// Columns only grow so far (see widths.go), so a long directory or address can still be cut off with a …. Rather than
+// having to widen anything to read it, the full values of the selected row's cut off cells are shown on a line under
+// the table:
+//
+//	Directory: /home/sam/projects/website/node_modules/.bin/vite   Address: fe80::1c2f:3aff:fe41:9b2e
+//
+// The line is only there while something in the table is cut off, and stays put while the cursor moves (blank when
+// the selected row fits), so the table doesn't change height under it.
+
+// The style used for the column names on the line
+var truncatedTitleStyle = lipgloss.NewStyle().Bold(true)
+
+// anyTruncated() checks if any cell in the rows is too wide for its column
+func anyTruncated(columns []table.Column, rows []table.Row) bool {
+	for _, row := range rows {
+		if len(truncatedCells(columns, row)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// truncatedCells() returns the indexes of the cells in a row that are too wide for their columns
+func truncatedCells(columns []table.Column, row table.Row) []int {
+	var cells []int
+	for i, c := range columns {
+		if i < len(row) && lipgloss.Width(row[i]) > c.Width {
+			cells = append(cells, i)
+		}
+	}
+	return cells
+}
+
+// truncatedLine() returns the line under the table with the selected row's cut off values in full. It's empty if
+// nothing in the table is cut off, and blank if the selected row fits.
+func (m model) truncatedLine() (string, bool) {
+	if !m.truncated || m.showDetail {
+		return "", false
+	}
+
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.rows) {
+		return "", true
+	}
+
+	columns := m.columnWidths()
+	var values []string
+	for _, i := range truncatedCells(columns, m.rows[cursor]) {
+		values = append(values, truncatedTitleStyle.Render(columnTitle(columns[i].Title)+":")+" "+m.rows[cursor][i])
+	}
+
+	// It has to stay on one line, or the table would be pushed up
+	line := strings.Join(values, "   ")
+	if m.help.Width > 0 {
+		line = lipgloss.NewStyle().MaxWidth(m.help.Width).Render(line)
+	}
+	return line, true
+}
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"golang.org/x/exp/slices"
+)
+
+// TestStartup checks the first collection fills the table
+func TestStartup(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000", "3001"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	if len(h.m.rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(h.m.rows))
+	}
+	if h.selectedPID() != 100 {
+		t.Errorf("expected the first row to be selected, got PID %d", h.selectedPID())
+	}
+	h.expectView("node", "postgres", "3001", "5432", "LISTEN")
+}
+
+// TestNavigation checks moving the cursor selects the right process, including on rows after the first of a process
+func TestNavigation(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000", "3001"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	for _, step := range []struct {
+		press string
+		pid   int
+	}{{"j", 100}, {"j", 200}, {"j", 200}, {"k", 100}, {"G", 200}, {"g", 100}} {
+		h.press(step.press)
+		if h.selectedPID() != step.pid {
+			t.Errorf("after %s, expected PID %d to be selected, got %d", step.press, step.pid, h.selectedPID())
+		}
+	}
+}
+
+// TestRefreshKeepsSelection checks the same connection stays selected when a refresh moves the rows around, so the
+// selected row never ends up pointing at a different process
+func TestRefreshKeepsSelection(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{
+		{listeningProcess(100, "node", "3000"), listeningProcess(200, "postgres", "5432")},
+		{
+			listeningProcess(50, "redis", "6379"),
+			listeningProcess(100, "node", "3000"),
+			listeningProcess(200, "postgres", "5432"),
+		},
+	}}
+	h := testModel(t, backend, nil)
+
+	h.press("j")
+	if h.selectedPID() != 200 {
+		t.Fatalf("expected PID 200 to be selected, got %d", h.selectedPID())
+	}
+
+	h.press("r")
+	if !slices.Equal(h.shownPIDs(), []int{50, 100, 200}) {
+		t.Fatalf("expected the refresh to show PIDs 50, 100 and 200, got %v", h.shownPIDs())
+	}
+	if h.selectedPID() != 200 {
+		t.Errorf("expected PID 200 to still be selected after the refresh, got %d", h.selectedPID())
+	}
+	h.expectView("redis")
+}
+
+// TestRefreshReorders checks a refresh that only changes the order of the processes still updates the table, so each
+// row's actions go to the process it shows
+func TestRefreshReorders(t *testing.T) {
+	node, postgres := listeningProcess(100, "node", "3000"), listeningProcess(200, "postgres", "5432")
+	backend := &fakeBackend{snapshots: [][]process{{node, postgres}, {postgres, node}}}
+	h := testModel(t, backend, nil)
+
+	h.press("r")
+	if !slices.Equal(h.shownPIDs(), []int{200, 100}) {
+		t.Fatalf("expected the refresh to show PIDs 200 and 100, got %v", h.shownPIDs())
+	}
+	if h.selectedPID() != 100 {
+		t.Errorf("expected PID 100 to still be selected after the refresh, got %d", h.selectedPID())
+	}
+	if row := h.m.table.SelectedRow(); row[0] != "100" {
+		t.Errorf("expected the selected row to show PID 100, got %v", row)
+	}
+}
+
+// TestSearch checks searching narrows the table to matching names as they're typed, keeps them narrowed once the search
+// bar is closed, and puts everything back once the search is deleted
+func TestSearch(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	h.press("/", "post")
+	if !slices.Equal(h.shownPIDs(), []int{200}) {
+		t.Errorf("expected searching for post to only show PID 200, got %v", h.shownPIDs())
+	}
+
+	h.press("esc")
+	if !slices.Equal(h.shownPIDs(), []int{200}) {
+		t.Errorf("expected the search to still apply once the bar is closed, got %v", h.shownPIDs())
+	}
+
+	h.press("/", "backspace", "backspace", "backspace", "backspace")
+	if !slices.Equal(h.shownPIDs(), []int{100, 200}) {
+		t.Errorf("expected deleting the search to show every process again, got %v", h.shownPIDs())
+	}
+}
+
+// TestSearchDuringCollection checks a collection that started before the search changed doesn't put back the rows the
+// old search picked when it finishes
+func TestSearchDuringCollection(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	collection := h.m.refresh()
+	h.press("/", "post")
+	h.run(collection)
+	if !slices.Equal(h.shownPIDs(), []int{200}) {
+		t.Errorf("expected the collection to be searched for post too, got %v", h.shownPIDs())
+	}
+}
+
+// TestFilterBar checks filters typed into the filter bar apply, show up as chips, and come off with x
+func TestFilterBar(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	h.press("F", "port:5432", "enter")
+	if !slices.Equal(h.shownPIDs(), []int{200}) {
+		t.Fatalf("expected port:5432 to only show PID 200, got %v", h.shownPIDs())
+	}
+	h.expectView("port:5432")
+
+	h.press("x")
+	if !slices.Equal(h.shownPIDs(), []int{100, 200}) {
+		t.Errorf("expected x to remove the filter, got %v", h.shownPIDs())
+	}
+}
+
+// TestTerminate checks t terminates the selected process, and not any other. A real process is started for it to
+// terminate, so nothing else on the machine is at risk.
+func TestTerminate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+
+	child := exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Skip("can't start sleep:", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+	defer child.Process.Kill()
+
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(child.Process.Pid, "sleep", "7000"),
+	}}}
+
+	// Read-only mode doesn't terminate anything
+	h := testModel(t, backend, func(s *settings) { s.readOnly = true })
+	h.press("t")
+	select {
+	case <-exited:
+		t.Fatal("expected t to do nothing in read-only mode")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Saying no when asked to confirm doesn't either
+	h = testModel(t, backend, func(s *settings) { s.confirmTerminate = true })
+	h.press("t")
+	h.expectView("Terminate sleep (PID " + strconv.Itoa(child.Process.Pid) + ")")
+	h.press("n", "enter")
+	select {
+	case <-exited:
+		t.Fatal("expected answering n to leave the process running")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	h.press("t", "y", "enter")
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected t to terminate the selected process")
+	}
+}
+
+// TestUnknownProcess checks t refuses to terminate a process the backend couldn't name, rather than guessing
+func TestUnknownProcess(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{listeningProcess(0, "", "7000")}}}
+	h := testModel(t, backend, nil)
+
+	h.press("t")
+	if h.m.err == nil {
+		t.Error("expected an error terminating an unknown process")
+	}
+}
+
+// TestCollapse checks enter collapses a process to one row and expands it again
+func TestCollapse(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000", "3001", "3002"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	h.press("enter")
+	if len(h.m.rows) != 2 {
+		t.Fatalf("expected collapsing node to leave 2 rows, got %d", len(h.m.rows))
+	}
+	h.expectView("3 conns")
+
+	h.press("enter")
+	if len(h.m.rows) != 4 {
+		t.Errorf("expected expanding node to bring back 4 rows, got %d", len(h.m.rows))
+	}
+}
+
+// TestPartialResults checks what the first collection has found so far is shown while it's running, and that a partial
+// result turning up after the collection has finished doesn't replace the whole thing
+func TestPartialResults(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000"),
+		listeningProcess(200, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	found := []process{listeningProcess(100, "node", "3000")}
+	rows, ends, _, _ := formatLsofIncremental(found, nil, h.m.settings)
+	partial := processesMsg{all: found, processes: found, rows: rows, ends: ends, partial: true}
+
+	h.send(partial)
+	if !slices.Equal(h.shownPIDs(), []int{100, 200}) {
+		t.Fatalf("expected a partial result after the collection to be ignored, got %v", h.shownPIDs())
+	}
+
+	// Pretend the first collection is still running
+	h.m.partials = make(chan processesMsg)
+	close(h.m.partials)
+	h.send(partial)
+	if !slices.Equal(h.shownPIDs(), []int{100}) {
+		t.Errorf("expected the partial result to be shown while collecting, got %v", h.shownPIDs())
+	}
+}
+
+// TestExitedProcess checks a process that exits stays in the table greyed out in watch mode, and that terminating it
+// says it's already exited rather than showing the error from signalling it
+func TestExitedProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs true")
+	}
+
+	// A process that's been and gone, so its PID is free
+	child := exec.Command("true")
+	if err := child.Run(); err != nil {
+		t.Skip("can't run true:", err)
+	}
+	gone := child.Process.Pid
+
+	backend := &fakeBackend{snapshots: [][]process{
+		{listeningProcess(100, "node", "3000"), listeningProcess(gone, "worker", "7000")},
+		{listeningProcess(100, "node", "3000")},
+	}}
+	h := testModel(t, backend, func(s *settings) { s.interval = time.Hour })
+
+	h.press("r")
+	if !slices.Equal(h.shownPIDs(), []int{100, gone}) {
+		t.Fatalf("expected the exited process to still be shown, got %v", h.shownPIDs())
+	}
+	h.expectView(exitedBadge + "LISTEN")
+
+	h.press("j", "t")
+	if h.m.err == nil || !strings.Contains(h.m.err.Error(), "already exited") {
+		t.Errorf("expected terminating the exited process to say it's already exited, got %v", h.m.err)
+	}
+}
+
+// TestClosedPortsNotExited checks a process that's still running but has closed its ports just leaves the table, rather
+// than being shown as exited
+func TestClosedPortsNotExited(t *testing.T) {
+	running := os.Getpid()
+	backend := &fakeBackend{snapshots: [][]process{
+		{listeningProcess(100, "node", "3000"), listeningProcess(running, "worker", "7000")},
+		{listeningProcess(100, "node", "3000")},
+	}}
+	h := testModel(t, backend, func(s *settings) { s.interval = time.Hour })
+
+	h.press("r")
+	if !slices.Equal(h.shownPIDs(), []int{100}) {
+		t.Errorf("expected the running process to leave the table, got %v", h.shownPIDs())
+	}
+}
+
+// TestPIDReused checks t refuses to terminate a process whose PID now belongs to something else, by collecting a real
+// process under a different name
+func TestPIDReused(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+
+	child := exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Skip("can't start sleep:", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+	defer child.Process.Kill()
+
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(child.Process.Pid, "postgres", "5432"),
+	}}}
+	h := testModel(t, backend, nil)
+
+	h.press("t")
+	if h.m.err == nil || !strings.Contains(h.m.err.Error(), "different process") {
+		t.Errorf("expected terminating a reused PID to be refused, got %v", h.m.err)
+	}
+	select {
+	case <-exited:
+		t.Fatal("expected the process now using the PID to be left running")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestProtected checks t on a protected process only terminates it once its PID has been typed in
+func TestProtected(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+
+	child := exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Skip("can't start sleep:", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+	defer child.Process.Kill()
+	pid := strconv.Itoa(child.Process.Pid)
+
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(child.Process.Pid, "sleep", "7000"),
+	}}}
+	h := testModel(t, backend, func(s *settings) { s.protected = []string{"sl*"} })
+
+	h.press("t")
+	h.expectView("on the protected list")
+	h.press("y", "enter")
+	select {
+	case <-exited:
+		t.Fatal("expected answering y to leave a protected process running")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if h.m.err == nil {
+		t.Error("expected an error saying the PID didn't match")
+	}
+
+	h.press("t", pid, "enter")
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected typing the PID to terminate the protected process")
+	}
+}
+
+// TestHideSelf checks pvw's own connections are left out with --hide-self, and S brings them back
+func TestHideSelf(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(100, "node", "3000"),
+		listeningProcess(os.Getpid(), "pvw", "9999"),
+	}}}
+	h := testModel(t, backend, func(s *settings) { s.hideSelf = true })
+
+	if !slices.Equal(h.shownPIDs(), []int{100}) {
+		t.Fatalf("expected pvw's own process to be hidden, got %v", h.shownPIDs())
+	}
+
+	h.press("S")
+	if !slices.Equal(h.shownPIDs(), []int{100, os.Getpid()}) {
+		t.Errorf("expected S to show pvw's own process, got %v", h.shownPIDs())
+	}
+}
+
+// TestKeyHelp checks ? opens the help overlay, that typing searches it, and that keys which can't be used are greyed out
+func TestKeyHelp(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{listeningProcess(100, "node", "3000")}}}
+	h := testModel(t, backend, func(s *settings) { s.height = 40 })
+	h.m.keys.Terminate.SetEnabled(false)
+
+	h.press("?")
+	if !h.m.showKeyHelp {
+		t.Fatal("expected ? to open the help overlay")
+	}
+	h.expectView("Navigation", "Filtering", "Actions", "Views", "terminate selected process (not available now)")
+
+	// q is typed into the search rather than quitting
+	h.press("q", "u", "i", "t")
+	if view := h.m.View(); strings.Contains(view, "toggle the search bar") || !strings.Contains(view, "quit") {
+		t.Errorf("expected searching for quit to leave only the quit key, got:\n%s", view)
+	}
+
+	h.press("esc")
+	h.expectView("toggle the search bar")
+	h.press("esc")
+	if h.m.showKeyHelp {
+		t.Error("expected esc on an empty search to close the help overlay")
+	}
+}
+
+// TestTips checks a tip is shown until its key is pressed, and that X hides the tips for good
+func TestTips(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	backend := &fakeBackend{snapshots: [][]process{{listeningProcess(100, "node", "3000")}}}
+	h := testModel(t, backend, func(s *settings) { s.tips = true })
+	h.m.keys.DismissTips.SetEnabled(true)
+
+	h.expectView("Tip: press i for details of the selected process")
+	h.press("i", "esc")
+	h.expectView("Tip: press F to filter by column")
+
+	h.press("X")
+	if strings.Contains(h.m.View(), "Tip:") {
+		t.Error("expected X to hide the tips")
+	}
+	if startTips(false) {
+		t.Error("expected the tips to stay hidden next time")
+	}
+}
+
+// TestSortByMemory checks --sort memory puts the process using the most memory first, and that O moves on to the next
+// order
+func TestSortByMemory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("resources aren't collected on Windows")
+	}
+	child := exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Skip("can't start sleep:", err)
+	}
+	defer child.Process.Kill()
+
+	// The test binary uses far more memory than sleep
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(child.Process.Pid, "sleep", "7000"),
+		listeningProcess(os.Getpid(), "pvw", "9999"),
+	}}}
+	h := testModel(t, backend, func(s *settings) { s.sortBy = "memory" })
+
+	if !slices.Equal(h.shownPIDs(), []int{os.Getpid(), child.Process.Pid}) {
+		t.Fatalf("expected the process using the most memory first, got %v", h.shownPIDs())
+	}
+
+	// Both processes were first seen together, so sorting by age next leaves them in the backend's order
+	h.press("O")
+	if !slices.Equal(h.shownPIDs(), []int{child.Process.Pid, os.Getpid()}) {
+		t.Errorf("expected O to sort by age, leaving the backend's order, got %v", h.shownPIDs())
+	}
+}
+
+// TestConnectionAge checks a connection that turns up later gets a fresh age, and that sorting by age puts the process
+// with the oldest connection first
+func TestConnectionAge(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{
+		{listeningProcess(4100, "node", "3000")},
+		{listeningProcess(4200, "redis", "6379"), listeningProcess(4100, "node", "3000")},
+	}}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := testModel(t, backend, func(s *settings) {
+		s.columns = append(append([]table.Column{}, testColumns...), table.Column{Title: "Age", Width: 4})
+		s.showAge = true
+		s.sortBy = "age"
+		s.now = func() time.Time { return now }
+	})
+
+	now = now.Add(90 * time.Second)
+	h.press("r")
+	if !slices.Equal(h.shownPIDs(), []int{4100, 4200}) {
+		t.Fatalf("expected the process with the oldest connection first, got %v", h.shownPIDs())
+	}
+	h.expectView(">1m", "0s")
+}
+
+// TestWaits checks W lists a process with a connection in CLOSE_WAIT and explains it, and leaves out one that only has a
+// few in TIME_WAIT
+func TestWaits(t *testing.T) {
+	leaking := listeningProcess(4300, "node", "3000")
+	leaking.Connections = append(leaking.Connections, connection{
+		Protocol: "TCP", Status: "CLOSE_WAIT", LocalAddress: "127.0.0.1", LocalPort: "3000",
+		RemoteAddress: "127.0.0.1", RemotePort: "51000",
+	})
+	closing := listeningProcess(4400, "curl", "")
+	closing.Connections[0].Status = "TIME_WAIT"
+	backend := &fakeBackend{snapshots: [][]process{{leaking, closing}}}
+	h := testModel(t, backend, func(s *settings) { s.height = 20 })
+
+	h.press("W")
+	h.expectView("node (PID 4300)", "1 in CLOSE_WAIT", "a leak in node")
+	if strings.Contains(h.m.View(), "curl") {
+		t.Errorf("expected a single connection in TIME_WAIT to be left out, got:\n%s", h.m.View())
+	}
+}
+
+// TestBackendFailures checks a failure that usually goes away by itself is tried again straight away, and that one
+// that won't says what to do about it
+func TestBackendFailures(t *testing.T) {
+	// So the test isn't waiting on the retries
+	defer func(delay time.Duration) { collectRetryDelay = delay }(collectRetryDelay)
+	collectRetryDelay = time.Millisecond
+
+	backend := &fakeBackend{
+		snapshots: [][]process{{listeningProcess(4500, "node", "3000")}},
+		failures:  []error{&os.PathError{Op: "open", Path: "/proc/net/tcp", Err: syscall.EMFILE}},
+	}
+	h := testModel(t, backend, nil)
+	if !slices.Equal(h.shownPIDs(), []int{4500}) {
+		t.Fatalf("expected the collection to be tried again after running out of file descriptors, got %v",
+			h.shownPIDs())
+	}
+
+	backend = &fakeBackend{
+		snapshots: [][]process{{listeningProcess(4500, "node", "3000")}},
+		failures:  []error{&os.PathError{Op: "open", Path: "/proc/net/tcp", Err: os.ErrPermission}},
+	}
+	h = testModel(t, backend, nil)
+	h.expectView("try running with sudo")
+}
+
+// TestCloseConnection checks ctrl+k won't close a listening socket, and asks before closing a connection when
+// confirm_terminate is set
+func TestCloseConnection(t *testing.T) {
+	proc := listeningProcess(4500, "node", "3000")
+	proc.Connections = append(proc.Connections, connection{
+		Protocol: "TCP", Status: "ESTABLISHED", LocalAddress: "127.0.0.1", LocalPort: "3000",
+		RemoteAddress: "127.0.0.1", RemotePort: "51000",
+	})
+	backend := &fakeBackend{snapshots: [][]process{{proc}}}
+	h := testModel(t, backend, func(s *settings) { s.confirmTerminate = true })
+
+	h.press("ctrl+k")
+	h.expectView("only TCP connections with a remote end can be closed")
+
+	h.press("j", "ctrl+k")
+	h.expectView("Close the connection from 127.0.0.1:51000 to node?")
+	h.press("n", "enter")
+	if len(h.m.processes[0].Connections) != 2 {
+		t.Errorf("expected answering no to leave the connection alone")
+	}
+}
+
+// TestPeers checks a loopback connection is labelled with the process at the other end, and J jumps to its row
+func TestPeers(t *testing.T) {
+	client := listeningProcess(4700, "api", "")
+	client.Connections[0] = connection{
+		Protocol: "TCP", Status: "ESTABLISHED", LocalAddress: "127.0.0.1", LocalPort: "51000",
+		RemoteAddress: "127.0.0.1", RemotePort: "6379",
+	}
+	server := listeningProcess(4242, "redis-server", "6379")
+	server.Connections = append(server.Connections, connection{
+		Protocol: "TCP", Status: "ESTABLISHED", LocalAddress: "127.0.0.1", LocalPort: "6379",
+		RemoteAddress: "127.0.0.1", RemotePort: "51000",
+	})
+	backend := &fakeBackend{snapshots: [][]process{{client, server}}}
+	h := testModel(t, backend, func(s *settings) {
+		s.columns = append(append([]table.Column{}, testColumns...), table.Column{Title: "Peer", Width: 12})
+		s.showPeers = true
+	})
+
+	h.expectView("4242 redis-server", "4700 api")
+	h.press("J")
+	if h.selectedPID() != 4242 || h.m.table.Cursor() != 2 {
+		t.Errorf("expected J to jump to redis-server's end of the connection, got row %d", h.m.table.Cursor())
+	}
+	h.press("k", "J")
+	h.expectView("isn't a process on this machine")
+}
+
+// TestRawRecord checks ctrl+r shows the record the selected row was parsed from, once --debug has turned it on
+func TestRawRecord(t *testing.T) {
+	proc := listeningProcess(4600, "node", "3000")
+	proc.Connections[0].Record = "p4600\ncnode\nf3\nPTCP\nn*:3000"
+	backend := &fakeBackend{snapshots: [][]process{{proc}}}
+	h := testModel(t, backend, func(s *settings) { s.height = 20 })
+
+	h.press("ctrl+r")
+	if h.m.showDetail {
+		t.Fatal("expected ctrl+r to do nothing without --debug")
+	}
+
+	keys.Record.SetEnabled(true)
+	defer keys.Record.SetEnabled(false)
+	h.press("ctrl+r")
+	h.expectView("Raw record for node (PID 4600)", "n*:3000")
+}
+
+// TestEmptyState checks a table with nothing left in it says why, rather than just being empty
+func TestEmptyState(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{listeningProcess(4700, "node", "3000")}}}
+	h := testModel(t, backend, func(s *settings) { s.portFilter = []string{"8080"} })
+
+	h.expectView("No connections to show", "Showing only:", "--ports 8080")
+}
+
+// TestProjectColumn checks the Project column names a process after the nearest package.json above its directory
+func TestProjectColumn(t *testing.T) {
+	root := t.TempDir()
+	manifest := []byte(`{"name": "my-shop-frontend"}`)
+	if err := os.WriteFile(filepath.Join(root, "package.json"), manifest, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	directory := filepath.Join(root, "src", "server")
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	proc := listeningProcess(4800, "node", "3000")
+	proc.Directory = directory
+	backend := &fakeBackend{snapshots: [][]process{{proc}}}
+	h := testModel(t, backend, func(s *settings) {
+		s.columns = append(append([]table.Column{}, testColumns...), table.Column{Title: "Project", Width: 20})
+		s.showProject = true
+	})
+
+	h.expectView("my-shop-frontend")
+}
+
+// TestOpenDirectory checks E asks where to open the selected process' working directory, and explains a wrong answer
+func TestOpenDirectory(t *testing.T) {
+	proc := listeningProcess(4900, "node", "3000")
+	proc.Directory = "/srv/my-shop-frontend"
+	backend := &fakeBackend{snapshots: [][]process{{proc}}}
+	h := testModel(t, backend, nil)
+
+	h.press("E")
+	h.expectView("Open /srv/my-shop-frontend in the editor (e)")
+	h.press("backspace", "x", "enter")
+	h.expectView(`"x" isn't e, f or t`)
+}
+
+// TestSharedPort checks the sockets of two processes bound to the same port on different addresses are badged, and
+// the detail pane says which gets the connections to each address
+func TestSharedPort(t *testing.T) {
+	node := listeningProcess(4700, "node", "3000")
+	node.Connections[0].LocalAddress = "127.0.0.1"
+	python := listeningProcess(4800, "python", "3000")
+	backend := &fakeBackend{snapshots: [][]process{{node, python}}}
+	h := testModel(t, backend, func(s *settings) { s.height = 30 })
+
+	h.expectView("⇄")
+	h.press("i")
+	h.expectView("Shares TCP port 3000", "Connections to 127.0.0.1:3000 go to")
+}
+
+// TestReusedPort checks processes bound to the same address and port are kept together and badged with how many
+// there are
+func TestReusedPort(t *testing.T) {
+	backend := &fakeBackend{snapshots: [][]process{{
+		listeningProcess(5000, "nginx", "8080"),
+		listeningProcess(5100, "node", "3000"),
+		listeningProcess(5001, "nginx", "8080"),
+	}}}
+	h := testModel(t, backend, func(s *settings) { s.height = 30 })
+
+	if pids := h.shownPIDs(); len(pids) != 3 || pids[0] != 5000 || pids[1] != 5001 {
+		t.Errorf("expected the nginx workers to be next to each other, got %v", pids)
+	}
+	h.expectView("⧉2")
+	h.press("i")
+	h.expectView("Spreads TCP connections to *:8080 between 2 processes")
+}
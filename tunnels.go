@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// SSH Tunnels
+// The processes developers most often go looking for by port are ssh tunnels: one left over from yesterday holding the
+// port a new one needs, or one that's hung after the laptop slept. U lists every ssh process forwarding a port (see
+// forwards.go for how the forwards are read from its arguments), whether or not the filters hide it:
+//
+//	> ssh (PID 4100)
+//	    127.0.0.1:5432 → db.internal:5432 (via 203.0.113.7:22)
+//	  ssh (PID 4300)
+//	    127.0.0.1:1080 → anywhere (SOCKS proxy)
+//	    9000 on the server → localhost:3000
+//
+// ↑ and ↓ pick a tunnel, t closes it (asking first, like it does in the table), and e re-establishes it: the old ssh
+// is terminated, and the same command is started again in the background, in its own session so it outlives pvw. That
+// only works if ssh can log in without asking for anything, as there's nowhere for it to ask; if it can't, it fails
+// straight away, and what it said is shown. Only your own tunnels can be re-established, as they'd be started as you.
+
+// How long to wait for the old ssh to go, and for the new one to fail if it's going to
+const (
+	tunnelExitTimeout  = 3 * time.Second
+	tunnelStartTimeout = 2 * time.Second
+)
+
+// sshTunnel is an ssh process forwarding at least one port
+type sshTunnel struct {
+	proc   process
+	args   []string
+	chains []forwardChain
+}
+
+// tunnelsMsg opens the tunnels panel with the tunnels found
+type tunnelsMsg struct{ tunnels []sshTunnel }
+
+// tunnelMsg is sent once a tunnel has been re-established
+type tunnelMsg struct{ proc process }
+
+// findTunnels() finds the ssh processes that are forwarding ports
+func findTunnels(processes []process) []sshTunnel {
+	var tunnels []sshTunnel
+	for _, proc := range processes {
+		if forwarderKind(proc) != "ssh" {
+			continue
+		}
+		args := processArgs(proc.ID)
+		if chains := chainsFromArgs(proc, args); len(chains) > 0 {
+			tunnels = append(tunnels, sshTunnel{proc: proc, args: args, chains: chains})
+		}
+	}
+	return tunnels
+}
+
+// showTunnels() opens the tunnels panel
+func showTunnels(processes []process) tea.Cmd {
+	return func() tea.Msg {
+		return tunnelsMsg{tunnels: findTunnels(processes)}
+	}
+}
+
+// tunnelsOpen() checks if the detail pane is showing the tunnels panel
+func (m model) tunnelsOpen() bool {
+	return m.showDetail && m.detailTitle == tr("tunnels.title")
+}
+
+// openTunnels() shows the tunnels in the detail pane, keeping the same one selected if it's still there
+func (m *model) openTunnels(tunnels []sshTunnel) {
+	selected := 0
+	if m.tunnelCursor < len(m.tunnels) {
+		selected = m.tunnels[m.tunnelCursor].proc.ID
+	}
+	m.tunnels = tunnels
+
+	m.tunnelCursor = 0
+	for i, tunnel := range tunnels {
+		if tunnel.proc.ID == selected {
+			m.tunnelCursor = i
+		}
+	}
+	m.openDetail(detailMsg{title: tr("tunnels.title"), body: m.tunnelsReport()})
+}
+
+// tunnelsReport() lists the tunnels and their forwards, with the selected one marked
+func (m model) tunnelsReport() string {
+	if len(m.tunnels) == 0 {
+		return tr("tunnels.none") + "\n"
+	}
+
+	var b strings.Builder
+	for i, tunnel := range m.tunnels {
+		marker := "  "
+		if i == m.tunnelCursor {
+			marker = "> "
+		}
+		fmt.Fprintln(&b, marker+processLabel(tunnel.proc))
+		for _, chain := range tunnel.chains {
+			fmt.Fprintln(&b, "    "+chain.String())
+		}
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, tr("tunnels.keys"))
+	return b.String()
+}
+
+// refreshTunnels() finds the tunnels again if the panel's open, like refreshDashboard()
+func (m *model) refreshTunnels() {
+	if m.tunnelsOpen() && m.output == nil {
+		m.openTunnels(findTunnels(m.snapshot))
+	}
+}
+
+// moveTunnelCursor() selects the next or previous tunnel
+func (m *model) moveTunnelCursor(by int) {
+	if cursor := m.tunnelCursor + by; cursor >= 0 && cursor < len(m.tunnels) {
+		m.tunnelCursor = cursor
+		m.openDetail(detailMsg{title: tr("tunnels.title"), body: m.tunnelsReport()})
+	}
+}
+
+// selectedTunnel() returns the selected tunnel, if there are any
+func (m model) selectedTunnel() (sshTunnel, bool) {
+	if m.tunnelCursor >= len(m.tunnels) {
+		return sshTunnel{}, false
+	}
+	return m.tunnels[m.tunnelCursor], true
+}
+
+// closeTunnel() terminates the selected tunnel, asking first in the same cases as terminating from the table
+func (m *model) closeTunnel() tea.Cmd {
+	tunnel, exists := m.selectedTunnel()
+	if !exists || m.settings.readOnly {
+		return nil
+	}
+
+	proc := tunnel.proc
+	if reason := protectedReason(proc, m.settings); reason != "" {
+		return m.confirmProtected(proc, reason)
+	}
+	if m.settings.confirmTerminate {
+		collectedAt := m.collectedAt
+		m.openPrompt(tr("prompt.terminate", proc.Name, proc.ID), "", func(answer string) tea.Cmd {
+			if !strings.HasPrefix(strings.ToLower(answer), "y") {
+				return nil
+			}
+			return terminateProcess(proc, collectedAt)
+		})
+		return textinput.Blink
+	}
+	return terminateProcess(proc, m.collectedAt)
+}
+
+// reestablishTunnel() terminates a tunnel's ssh, and starts the same command again in the background
+func reestablishTunnel(tunnel sshTunnel, collectedAt time.Time) tea.Cmd {
+	return func() tea.Msg {
+		proc := tunnel.proc
+		if err := checkSameProcess(proc, collectedAt); err != nil {
+			return errMsg{err}
+		}
+		// Backends that can't see owners leave it empty, in which case ssh is left to fail if it's someone else's
+		if current, err := user.Current(); proc.Username != "" && (err != nil || current.Username != proc.Username) {
+			return errMsg{errors.New(tr("error.tunnel-owner", processLabel(proc), proc.Username))}
+		}
+		if len(tunnel.args) == 0 {
+			return errMsg{errors.New(tr("error.tunnel-command", processLabel(proc)))}
+		}
+
+		// The new ssh needs the ports the old one is holding, so it has to be gone first
+		if err := pvw.Kill(proc.ID); err != nil {
+			return exitedError(proc, err)
+		}
+		for waited := time.Duration(0); ; waited += 100 * time.Millisecond {
+			if _, _, running := currentProcess(proc.ID); !running {
+				break
+			}
+			if waited >= tunnelExitTimeout {
+				return errMsg{errors.New(tr("error.tunnel-still-running", processLabel(proc)))}
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		// Command is the old ssh's, exactly as it was run. What it says goes to a file rather than a pipe, as a pipe
+		// would close when pvw quits, and ssh would die the next time it had something to say.
+		stderr, err := os.CreateTemp("", "pvw-ssh-*.log")
+		if err != nil {
+			return errMsg{err}
+		}
+		defer os.Remove(stderr.Name())
+		defer stderr.Close()
+
+		cmd := exec.Command(tunnel.args[0], tunnel.args[1:]...)
+		cmd.Stderr = stderr
+		pvw.Detach(cmd)
+		debugf("re-establishing %s: %s", processLabel(proc), cmd.String())
+		if err := cmd.Start(); err != nil {
+			return errMsg{explainFailure(err, "ssh")}
+		}
+
+		// ssh that can't log in gives up straight away. One run with -f exits 0 once it's backgrounded itself.
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+		select {
+		case err := <-exited:
+			if err != nil {
+				said, _ := os.ReadFile(stderr.Name())
+				line, _, _ := strings.Cut(strings.TrimSpace(string(said)), "\n")
+				return errMsg{errors.New(tr("error.tunnel-failed", err, line))}
+			}
+		case <-time.After(tunnelStartTimeout):
+		}
+		return tunnelMsg{proc: proc}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// TestTunnelChains checks each of an ssh tunnel's forwards is listed from where it listens, with the connection to the
+// server it goes through
+func TestTunnelChains(t *testing.T) {
+	server := connection{Protocol: "TCP", LocalAddress: "10.0.0.2", LocalPort: "50022", RemoteAddress: "203.0.113.7",
+		RemotePort: "22", Status: "ESTABLISHED"}
+	listening := func(addresses ...string) process {
+		proc := process{ID: 4100, Name: "ssh", Connections: []connection{server}}
+		for _, address := range addresses {
+			host, port := address[:strings.LastIndex(address, ":")], address[strings.LastIndex(address, ":")+1:]
+			proc.Connections = append(proc.Connections, connection{
+				Protocol: "TCP", LocalAddress: host, LocalPort: port, Status: "LISTEN",
+			})
+		}
+		return proc
+	}
+
+	tests := []struct {
+		name    string
+		proc    process
+		command string
+		want    []string
+	}{
+		{
+			"local forward",
+			listening("127.0.0.1:8080"),
+			"ssh -N -L 8080:db.internal:5432 bastion",
+			[]string{"127.0.0.1:8080 → db.internal:5432 (via 203.0.113.7:22)"},
+		},
+		{
+			"local forward on both IP versions",
+			listening("127.0.0.1:8080", "[::1]:8080"),
+			"ssh -N -L localhost:8080:db:5432 bastion",
+			[]string{"127.0.0.1:8080 → db:5432 (via 203.0.113.7:22)", "[::1]:8080 → db:5432 (via 203.0.113.7:22)"},
+		},
+		{
+			"SOCKS proxy",
+			listening("127.0.0.1:1080"),
+			"ssh -D 127.0.0.1:1080 host",
+			[]string{"127.0.0.1:1080 → anywhere (SOCKS proxy) (via 203.0.113.7:22)"},
+		},
+		{
+			"remote forward",
+			listening(),
+			"ssh -N -R 9000:localhost:3000 host",
+			[]string{"9000 on the server → localhost:3000 (via 203.0.113.7:22)"},
+		},
+		{
+			"remote forward with a bind address",
+			listening(),
+			"ssh -N -R 0.0.0.0:9000:localhost:3000 host",
+			[]string{"0.0.0.0:9000 on the server → localhost:3000 (via 203.0.113.7:22)"},
+		},
+		{
+			"local and remote",
+			listening("127.0.0.1:8080"),
+			"ssh -L 8080:web:80 -R 9000:localhost:3000 host",
+			[]string{
+				"127.0.0.1:8080 → web:80 (via 203.0.113.7:22)",
+				"9000 on the server → localhost:3000 (via 203.0.113.7:22)",
+			},
+		},
+		{
+			"forward from ssh's config",
+			listening("127.0.0.1:2222"),
+			"ssh devbox",
+			[]string{"127.0.0.1:2222 → somewhere not on its command line (via 203.0.113.7:22)"},
+		},
+		{"not forwarding", listening(), "ssh host uptime", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, chain := range chainsFromArgs(test.proc, strings.Fields(test.command)) {
+				got = append(got, chain.String())
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
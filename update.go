@@ -0,0 +1,476 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allyring/pvw/pkg/pvw"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Version and Updates
+// Lots of people install pvw by dropping the binary from the releases page somewhere on their PATH, which means nothing
+// tells them when there's a new one. So:
+//
+//	pvw version            prints the version, and the commit and date it was built from
+//	pvw version --check    also asks GitHub for the latest release, and says if it's newer
+//	pvw self-update        downloads the latest release for this OS and architecture, and replaces the binary with it
+//
+// Nothing talks to GitHub unless asked to. "check_updates": true in the config file makes the TUI check once when it
+// starts, and show a notice if there's a newer release.
+//
+// The version, commit and date are set when releasing, with -ldflags "-X main.version=... -X main.commit=..." (which
+// goreleaser does by default). Builds from `go install` get what they can from the module and VCS information Go
+// embeds instead, and anything else is a "dev" build, which self-update won't replace without --force. Downloads are
+// checked against the release's checksums.txt before anything is replaced. $PVW_RELEASES_URL points the check at a
+// different releases API, e.g. a mirror.
+
+// Set with -ldflags when releasing
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// Where the latest release is asked for
+const defaultReleasesURL = "https://api.github.com/repos/allyring/pvw/releases/latest"
+
+// How long to wait for GitHub when the TUI checks for updates, as it's only worth a notice
+const updateCheckTimeout = 5 * time.Second
+
+// How long a download can take, including reading the archive, so a stalled connection can't hang pvw forever
+const downloadTimeout = 2 * time.Minute
+
+// The client downloads are made with, which unlike http.DefaultClient gives up eventually
+var downloadClient = &http.Client{Timeout: downloadTimeout}
+
+// release is the part of GitHub's description of a release that's needed
+type release struct {
+	Tag    string         `json:"tag_name"`
+	URL    string         `json:"html_url"`
+	Assets []releaseAsset `json:"assets"`
+}
+
+// releaseAsset is a file attached to a release
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// updateMsg is sent when the TUI's check finds a newer release
+type updateMsg struct{ latest release }
+
+// buildInfo() returns the version, commit and date pvw was built from, filling in what -ldflags didn't set from the
+// information Go embeds
+func buildInfo() (string, string, string) {
+	v, c, d := version, commit, date
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v, c, d
+	}
+
+	if v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && c == "":
+			c = setting.Value
+		case setting.Key == "vcs.time" && d == "":
+			d = setting.Value
+		}
+	}
+	return v, c, d
+}
+
+// runVersion() prints the version, and with --check whether there's a newer one. Being out of date still exits with 0,
+// as only a bad flag or failing to check is an error.
+func runVersion(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("version", pflag.ContinueOnError)
+	flagCheck := flags.Bool("check", false, tr("flag.check-updates"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("version.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	v, c, d := buildInfo()
+	if pvw.Pure {
+		v += " (pure)"
+	}
+	fmt.Fprintf(w, "pvw %s\n", v)
+	if c != "" {
+		fmt.Fprintf(w, "%s: %s\n", tr("version.commit"), c)
+	}
+	if d != "" {
+		fmt.Fprintf(w, "%s: %s\n", tr("version.date"), d)
+	}
+	fmt.Fprintf(w, "%s: %s %s/%s\n", tr("version.go"), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	if !*flagCheck {
+		return exitOK
+	}
+
+	latest, err := latestRelease(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+	switch {
+	case !isRelease(v):
+		fmt.Fprintln(w, tr("version.latest-dev", latest.Tag))
+	case newerVersion(latest.Tag, v):
+		// Being out of date isn't something going wrong, or anything in the way, so it isn't an exit code of its own
+		fmt.Fprintln(w, tr("version.newer", latest.Tag, latest.URL))
+	default:
+		fmt.Fprintln(w, tr("version.up-to-date"))
+	}
+	return exitOK
+}
+
+// runSelfUpdate() replaces the running binary with the latest release
+func runSelfUpdate(args []string, w io.Writer) int {
+	flags := pflag.NewFlagSet("self-update", pflag.ContinueOnError)
+	flagForce := flags.Bool("force", false, tr("flag.force-update"))
+	flags.String("lang", "", tr("flag.lang"))
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tr("self-update.usage"))
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	v, _, _ := buildInfo()
+	latest, err := latestRelease(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	switch {
+	case !isRelease(v) && !*flagForce:
+		fmt.Fprintln(os.Stderr, tr("error.running", tr("error.update-dev", latest.Tag)))
+		return exitError
+	case isRelease(v) && !newerVersion(latest.Tag, v) && !*flagForce:
+		fmt.Fprintln(w, tr("version.up-to-date"))
+		return exitOK
+	}
+
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	fmt.Fprintln(w, tr("version.downloading", latest.Tag))
+	binary, err := downloadRelease(context.Background(), latest)
+	if err == nil {
+		err = replaceExecutable(executable, binary)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("error.running", err))
+		return exitError
+	}
+
+	fmt.Fprintln(w, tr("version.updated", executable, latest.Tag))
+	return exitOK
+}
+
+// releasesURL() returns where to ask for the latest release
+func releasesURL() string {
+	if url := os.Getenv("PVW_RELEASES_URL"); url != "" {
+		return url
+	}
+	return defaultReleasesURL
+}
+
+// latestRelease() asks GitHub for the latest release
+func latestRelease(ctx context.Context) (release, error) {
+	var latest release
+	body, err := download(ctx, releasesURL())
+	if err != nil {
+		return latest, err
+	}
+	if err := json.Unmarshal(body, &latest); err != nil {
+		return latest, err
+	}
+	if latest.Tag == "" {
+		return latest, errors.New(tr("error.no-release"))
+	}
+	return latest, nil
+}
+
+// download() fetches a URL, and fails if it doesn't come back with 200 OK
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "pvw/"+version)
+
+	debugf("downloading %s", url)
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(tr("error.download", url, resp.Status))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// isRelease() checks if a version looks like a release (v1.2.3), rather than a dev build or a pseudo-version
+func isRelease(v string) bool {
+	_, ok := versionNumbers(v)
+	return ok
+}
+
+// versionNumbers() splits v1.2.3 into its numbers. Anything after a - (like -rc1) means it's not a release.
+func versionNumbers(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if v == "" || strings.ContainsAny(v, "-+") {
+		return nil, false
+	}
+
+	var numbers []int
+	for _, part := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, true
+}
+
+// newerVersion() checks if latest is a later release than current
+func newerVersion(latest, current string) bool {
+	a, okA := versionNumbers(latest)
+	b, okB := versionNumbers(current)
+	if !okA || !okB {
+		return false
+	}
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+// releaseArchive() finds the archive for this OS and architecture among a release's files. goreleaser names them
+// like pvw_1.2.3_linux_amd64.tar.gz, though older releases used Linux_x86_64.
+func releaseArchive(assets []releaseAsset) (releaseAsset, bool) {
+	arches := map[string][]string{"amd64": {"amd64", "x86_64"}, "386": {"386", "i386"}, "arm64": {"arm64", "aarch64"}}
+	names := arches[runtime.GOARCH]
+	if names == nil {
+		names = []string{runtime.GOARCH}
+	}
+
+	for _, asset := range assets {
+		name := strings.ToLower(asset.Name)
+		if !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".zip") {
+			continue
+		}
+		if !strings.Contains(name, "_"+runtime.GOOS+"_") {
+			continue
+		}
+		for _, arch := range names {
+			if strings.Contains(name, "_"+arch+".") || strings.Contains(name, "_"+arch+"_") {
+				return asset, true
+			}
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// downloadRelease() downloads the archive for this OS and architecture, checks it against the release's checksums, and
+// returns the pvw binary inside it
+func downloadRelease(ctx context.Context, latest release) ([]byte, error) {
+	archive, found := releaseArchive(latest.Assets)
+	if !found {
+		return nil, errors.New(tr("error.no-release-archive", latest.Tag, runtime.GOOS, runtime.GOARCH))
+	}
+	raw, err := download(ctx, archive.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// goreleaser always publishes checksums.txt, so a release without it (or without this archive in it) is suspect
+	var sums []byte
+	for _, asset := range latest.Assets {
+		if strings.HasSuffix(asset.Name, "checksums.txt") {
+			if sums, err = download(ctx, asset.URL); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := checkChecksum(raw, archive.Name, sums); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(strings.ToLower(archive.Name), ".zip") {
+		return binaryFromZip(raw)
+	}
+	return binaryFromTarGz(raw)
+}
+
+// checkChecksum() checks a download against its line in checksums.txt, which is "<sha256>  <name>" for each file
+func checkChecksum(raw []byte, name string, sums []byte) error {
+	sum := sha256.Sum256(raw)
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+				return errors.New(tr("error.checksum", name))
+			}
+			return nil
+		}
+	}
+	return errors.New(tr("error.no-checksum", name))
+}
+
+// binaryName() is what pvw's binary is called in the release archives
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "pvw.exe"
+	}
+	return "pvw"
+}
+
+// binaryFromTarGz() finds pvw's binary in a .tar.gz
+func binaryFromTarGz(raw []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == binaryName() {
+			return io.ReadAll(archive)
+		}
+	}
+	return nil, errors.New(tr("error.no-binary", binaryName()))
+}
+
+// binaryFromZip() finds pvw's binary in a .zip
+func binaryFromZip(raw []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archive.File {
+		if filepath.Base(file.Name) == binaryName() {
+			f, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return io.ReadAll(f)
+		}
+	}
+	return nil, errors.New(tr("error.no-binary", binaryName()))
+}
+
+// replaceExecutable() swaps the binary for the new one. The new one is written next to it first and renamed over it, so
+// pvw is never left half-written. Windows won't replace a running executable, but will rename it, so there the old one
+// is moved out of the way first (and left behind as pvw.exe.old).
+func replaceExecutable(executable string, binary []byte) error {
+	info, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+
+	temporary := executable + ".new" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(temporary, binary, info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			os.Remove(temporary)
+			return err
+		}
+	}
+	if err := os.Rename(temporary, executable); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return nil
+}
+
+// checkForUpdate() is the TUI's check, which sends an updateMsg if there's a newer release. Failing is only logged, as
+// nobody asked for the check right now.
+func checkForUpdate() tea.Msg {
+	v, _, _ := buildInfo()
+	if !isRelease(v) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+	latest, err := latestRelease(ctx)
+	if err != nil {
+		debugf("couldn't check for updates: %v", err)
+		return nil
+	}
+	if !newerVersion(latest.Tag, v) {
+		return nil
+	}
+	return updateMsg{latest}
+}
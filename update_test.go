@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.4", "v1.2.3", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "v1.99.99", true},
+		{"1.3.0", "v1.2.0", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "v1.2.4", false},
+		{"v1.2", "v1.2.0", false},
+		{"v1.2.1", "v1.2", true},
+		{"v1.3.0-rc1", "v1.2.0", false},
+		{"v1.3.0", "dev", false},
+		{"v1.3.0", "v0.0.0-20240101000000-abcdef123456", false},
+		{"", "v1.2.3", false},
+	}
+
+	for _, test := range tests {
+		if got := newerVersion(test.latest, test.current); got != test.want {
+			t.Errorf("newerVersion(%q, %q) = %t, want %t", test.latest, test.current, got, test.want)
+		}
+	}
+}
+
+func TestCheckChecksum(t *testing.T) {
+	raw := []byte("pvw's archive")
+	sum := sha256.Sum256(raw)
+	good := hex.EncodeToString(sum[:])
+	bad := strings.Repeat("0", len(good))
+
+	tests := []struct {
+		name  string
+		sums  string
+		valid bool
+	}{
+		{"matches", good + "  pvw_1.2.3_linux_amd64.tar.gz\n", true},
+		{"matches in capitals", strings.ToUpper(good) + "  pvw_1.2.3_linux_amd64.tar.gz\n", true},
+		{
+			"among other files",
+			bad + "  pvw_1.2.3_darwin_arm64.tar.gz\n" + good + "  pvw_1.2.3_linux_amd64.tar.gz\n",
+			true,
+		},
+		{"doesn't match", bad + "  pvw_1.2.3_linux_amd64.tar.gz\n", false},
+		{"not listed", good + "  pvw_1.2.3_darwin_arm64.tar.gz\n", false},
+		{"no checksums", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkChecksum(raw, "pvw_1.2.3_linux_amd64.tar.gz", []byte(test.sums))
+			if (err == nil) != test.valid {
+				t.Errorf("got %v, want valid to be %t", err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Stuck Connections
+// Connection leaks show up as sockets that never finish closing. W looks for the two kinds and explains them in the
+// detail pane, worst first:
+//
+//   - CLOSE_WAIT: the other end has closed the connection, but the process hasn't closed its side. Any of these that
+//     hang around mean a leak in that process, so every process with one is listed, with how long the oldest has been
+//     seen for (see age.go).
+//   - TIME_WAIT: whichever end closed first waits a minute or two for stray packets. That's normal, but hundreds of
+//     them mean something is making a new connection for every request, and can run out of ports.
+//
+// Like the dashboard, it's built from what the table is showing, and kept up to date while it's open. The details of a
+// process (see info.go) explain its own stuck connections too.
+
+// How many TIME_WAIT sockets a process needs before they're worth mentioning
+const timeWaitThreshold = 100
+
+// How wide the explanations are wrapped to
+const waitsTextWidth = 80
+
+// The style used for the explanations, indented under what they explain
+var waitsTextStyle = lipgloss.NewStyle().Width(waitsTextWidth).PaddingLeft(2)
+
+// stuckConnections is how many of a process' connections are stuck closing
+type stuckConnections struct {
+	proc      process
+	closeWait int
+	timeWait  int
+	oldest    connectionAge // When the oldest CLOSE_WAIT connection was first seen
+	aged      bool          // Whether oldest is known
+}
+
+// worthMentioning() checks if a process' stuck connections are more than the usual churn
+func (s stuckConnections) worthMentioning() bool {
+	return s.closeWait > 0 || s.timeWait >= timeWaitThreshold
+}
+
+// countStuck() counts a process' connections in CLOSE_WAIT and TIME_WAIT, aging them with ages if it's given
+func countStuck(proc process, ages map[rowKey]connectionAge) stuckConnections {
+	stuck := stuckConnections{proc: proc}
+	for _, conn := range proc.Connections {
+		switch connectionState(conn) {
+		case "CLOSE_WAIT":
+			stuck.closeWait++
+			age, found := ages[connectionKey(proc.ID, conn)]
+			if found && (!stuck.aged || age.since.Before(stuck.oldest.since)) {
+				stuck.oldest, stuck.aged = age, true
+			}
+		case "TIME_WAIT":
+			stuck.timeWait++
+		}
+	}
+	return stuck
+}
+
+// showWaits() shows the stuck connections in the detail pane, for the processes in the table
+func showWaits(processes []process, ages map[rowKey]connectionAge, now time.Time) tea.Cmd {
+	return func() tea.Msg {
+		return detailMsg{title: tr("waits.title"), body: waitsReport(processes, ages, now)}
+	}
+}
+
+// waitsReport() lists the processes with connections stuck closing, the most in CLOSE_WAIT first as they're the leaks
+func waitsReport(processes []process, ages map[rowKey]connectionAge, now time.Time) string {
+	var stuck []stuckConnections
+	for _, proc := range processes {
+		if s := countStuck(proc, ages); s.worthMentioning() {
+			stuck = append(stuck, s)
+		}
+	}
+	sort.SliceStable(stuck, func(i, j int) bool {
+		if stuck[i].closeWait != stuck[j].closeWait {
+			return stuck[i].closeWait > stuck[j].closeWait
+		}
+		return stuck[i].timeWait > stuck[j].timeWait
+	})
+
+	var b strings.Builder
+	if len(stuck) == 0 {
+		fmt.Fprintln(&b, tr("waits.none", timeWaitThreshold))
+		return b.String()
+	}
+	for i, s := range stuck {
+		if i > 0 {
+			fmt.Fprintln(&b)
+		}
+		fmt.Fprintln(&b, processLabel(s.proc))
+		writeStuck(&b, s, now)
+	}
+	return b.String()
+}
+
+// writeStuck() explains a process' stuck connections, with a line for each kind and what's likely behind it
+func writeStuck(b *strings.Builder, s stuckConnections, now time.Time) {
+	name := s.proc.Name
+	if s.proc.ID == 0 {
+		name = tr("check.unknown-process")
+	}
+
+	if s.closeWait > 0 {
+		line := tr("waits.close-wait", s.closeWait)
+		if s.aged {
+			line = tr("waits.close-wait-aged", s.closeWait, formatAge(s.oldest, now))
+		}
+		fmt.Fprintln(b, "  "+line)
+		fmt.Fprintln(b, waitsTextStyle.Render(tr("waits.close-wait-cause", name)))
+	}
+	if s.timeWait >= timeWaitThreshold {
+		fmt.Fprintln(b, "  "+tr("waits.time-wait", s.timeWait))
+		fmt.Fprintln(b, waitsTextStyle.Render(tr("waits.time-wait-cause", name)))
+	}
+}
+
+// refreshWaits() rebuilds the stuck connections if they're open, like refreshDashboard()
+func (m *model) refreshWaits() {
+	if m.showDetail && m.detailTitle == tr("waits.title") && m.output == nil {
+		m.openDetail(detailMsg{title: tr("waits.title"), body: waitsReport(m.processes, m.settings.ages, m.settings.currentTime())})
+	}
+}
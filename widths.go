@@ -0,0 +1,148 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/exp/slices"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Column Widths
+// Each column is given room for its widest value every refresh, rather than always being the same width, so long
+// usernames, directories and IPv6 addresses aren't cut off when there's room for them. The widths the columns are
+// set up with are the least they shrink to, so the table doesn't jump around as short values come and go, and each
+// column has a cap so one very long value (a deeply nested directory, say) can't push everything else off screen.
+// Anything past the cap is still cut off with a …. --fixed-widths keeps the columns at the widths they're set up with.
+
+// The widest a column grows to, if it isn't defaultColumnCap
+var columnCaps = map[string]int{
+	"PID":            8,
+	"Name":           24,
+	"Project":        24,
+	"Framework":      20,
+	"Owner":          16,
+	"Nice":           4,
+	"CPU":            6,
+	"Memory":         7,
+	"Protocol":       4,
+	"Port":           12, // Service names with --show-proto-names can be longer than the port
+	"Local Port":     12,
+	"Remote Port":    12,
+	"Address":        45, // A whole IPv6 address, with its brackets
+	"Local Address":  45,
+	"Remote Address": 45,
+	"Status":         13,
+	"Age":            8,
+	"Peer":           24,
+	"Interface":      15, // The longest name Linux allows
+	"Privilege":      17,
+	"Recv-Q":         10,
+	"Send-Q":         10,
+	"Backlog":        7,
+	"RTT":            8,
+	"Retrans":        7,
+	"Cwnd":           6,
+	"Protocol Guess": 14,
+	"Pod":            63, // The longest name Kubernetes allows
+}
+
+// The widest any other column grows to, including those from the config file
+const defaultColumnCap = 40
+
+// fitColumnWidths() returns the columns with their widths fitted to the widest of their values in the rows
+func fitColumnWidths(columns []table.Column, rows []table.Row) []table.Column {
+	fitted := make([]table.Column, len(columns))
+	for i, c := range columns {
+		widest := 0
+		for _, row := range rows {
+			if i < len(row) {
+				if width := lipgloss.Width(row[i]); width > widest {
+					widest = width
+				}
+			}
+		}
+
+		// Columns set up wider than their cap (by the config file) keep that width
+		limit, capped := columnCaps[c.Title]
+		if !capped {
+			limit = defaultColumnCap
+		}
+		if c.Width > limit {
+			limit = c.Width
+		}
+
+		fitted[i] = c
+		if widest > c.Width {
+			fitted[i].Width = widest
+		}
+		if fitted[i].Width > limit {
+			fitted[i].Width = limit
+		}
+	}
+	return fitted
+}
+
+// fitColumns() resizes the table's columns to fit its rows. The table can't change its columns once it's been made, so
+// if they need to change a new table is made in its place, scrolled to the same place with the same row selected.
+func (m *model) fitColumns() {
+	if m.settings.fixedWidths {
+		return
+	}
+
+	columns := fitColumnWidths(m.settings.columns, m.rows)
+	if columnsEqual(columns, m.columnWidths()) {
+		return
+	}
+	m.columns = columns
+
+	cursor, height := m.table.Cursor(), m.table.Height()
+	t := table.New(
+		table.WithColumns(translateColumns(columns)),
+		table.WithRows(m.rows),
+		table.WithFocused(m.table.Focused()),
+		table.WithHeight(height),
+		table.WithStyles(tableStyles(m.settings.density, m.settings.theme)),
+	)
+	t.KeyMap = m.table.KeyMap
+
+	// Moving down to the bottom of what was shown scrolls the new table to the same place, then moving back up to the
+	// selected row leaves it scrolled there
+	t.MoveDown(m.tableTop + height - 1)
+	t.MoveUp(t.Cursor() - cursor)
+	m.table = t
+}
+
+// columnWidths() returns the columns as they're currently shown
+func (m model) columnWidths() []table.Column {
+	if m.columns != nil {
+		return m.columns
+	}
+	return m.settings.columns
+}
+
+// columnIndex() returns where the column with a title is in the table, or -1 if it isn't shown
+func columnIndex(options settings, title string) int {
+	return slices.IndexFunc(options.columns, func(c table.Column) bool { return c.Title == title })
+}
+
+// badgeColumn() returns the column badges go in front of: Status, or the first column if it isn't shown
+func badgeColumn(options settings) int {
+	if column := columnIndex(options, "Status"); column != -1 {
+		return column
+	}
+	return 0
+}
+
+// columnsEqual() checks if two sets of columns are the same
+func columnsEqual(a []table.Column, b []table.Column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// Working Directories
+// Finding the dev server on port 3000 is usually the first step of getting back to the code behind it. E asks where to
+// open the selected process' working directory, and opens it there:
+//
+//	e  In $VISUAL or $EDITOR (vi if neither is set), which takes over the terminal until it's closed, like an action
+//	f  In the file manager, with open on macOS, explorer on Windows, wslview under WSL, or xdg-open
+//	t  In a new terminal tab, in whichever terminal pvw is running in
+//
+// For the terminal, tmux gets a new window, kitty, WezTerm, Windows Terminal, GNOME Terminal and Konsole get a new tab
+// (kitty needs allow_remote_control on), and macOS' Terminal or iTerm gets a new window. Anything else gets a new
+// x-terminal-emulator, if there is one. The working directory doesn't need --show-cwd, it's looked up when it's asked
+// for.
+
+// workingDirectory() returns a process' working directory, looking it up if it wasn't collected
+func workingDirectory(proc process) (string, error) {
+	if proc.ID == 0 || proc.Windows {
+		return "", errors.New(tr("error.unknown-process"))
+	}
+	directory := proc.Directory
+	if directory == "" && canGetCwd() {
+		directory, _ = getCwd(proc.ID)
+	}
+	if directory == "" {
+		return "", errors.New(tr("error.no-directory", processLabel(proc)))
+	}
+	return directory, nil
+}
+
+// askOpenDirectory() asks where to open the selected process' working directory
+func (m *model) askOpenDirectory(proc process) tea.Cmd {
+	directory, err := workingDirectory(proc)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+
+	m.openPrompt(tr("prompt.open-directory", directory), "e", func(answer string) tea.Cmd {
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "e", "editor":
+			return editDirectory(directory)
+		case "f", "files":
+			return openDirectory(directory, fileManagerCommand(directory))
+		case "t", "terminal":
+			return openDirectory(directory, terminalCommand(directory))
+		}
+		return func() tea.Msg { return errMsg{errors.New(tr("error.open-directory-choice", answer))} }
+	})
+	return nil
+}
+
+// editDirectory() hands the terminal over to the editor, in the directory
+func editDirectory(directory string) tea.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// $EDITOR can have arguments of its own ("code --wait")
+	command := append(strings.Fields(editor), ".")
+	if pvw.Pure {
+		return func() tea.Msg { return errMsg{errors.New(tr("error.pure-command", command[0]))} }
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = directory
+	debugf("editing %s with %q", directory, cmd.String())
+
+	return tea.Exec(actionCommand{cmd}, func(err error) tea.Msg {
+		if err != nil {
+			return errMsg{errors.New(tr("error.open-directory", directory, err))}
+		}
+		return nil
+	})
+}
+
+// fileManagerCommand() returns the command that opens a directory in the file manager
+func fileManagerCommand(directory string) []string {
+	switch {
+	case runtime.GOOS == "darwin":
+		return []string{"open", directory}
+	case runtime.GOOS == "windows":
+		return []string{"explorer", directory}
+	case commandExists("wslview"):
+		return []string{"wslview", directory}
+	default:
+		return []string{"xdg-open", directory}
+	}
+}
+
+// terminalCommand() returns the command that opens a new terminal tab in a directory, for the terminal pvw is running
+// in, or nil if it doesn't know how
+func terminalCommand(directory string) []string {
+	switch {
+	case os.Getenv("TMUX") != "" && commandExists("tmux"):
+		return []string{"tmux", "new-window", "-c", directory}
+	case os.Getenv("KITTY_WINDOW_ID") != "" && commandExists("kitty"):
+		return []string{"kitty", "@", "launch", "--type=tab", "--cwd", directory}
+	case os.Getenv("WEZTERM_PANE") != "" && commandExists("wezterm"):
+		return []string{"wezterm", "cli", "spawn", "--cwd", directory}
+	case os.Getenv("WT_SESSION") != "" && commandExists("wt.exe"):
+		// Under WSL, the new tab has to be told to start WSL, as Windows Terminal would start its default profile
+		if runtime.GOOS == "windows" {
+			return []string{"wt.exe", "-w", "0", "new-tab", "-d", directory}
+		}
+		return []string{"wt.exe", "-w", "0", "new-tab", "wsl.exe", "--cd", directory}
+	case runtime.GOOS == "darwin" && os.Getenv("TERM_PROGRAM") == "iTerm.app":
+		return []string{"open", "-a", "iTerm", directory}
+	case runtime.GOOS == "darwin":
+		return []string{"open", "-a", "Terminal", directory}
+	case os.Getenv("GNOME_TERMINAL_SCREEN") != "" && commandExists("gnome-terminal"):
+		return []string{"gnome-terminal", "--tab", "--working-directory=" + directory}
+	case os.Getenv("KONSOLE_VERSION") != "" && commandExists("konsole"):
+		return []string{"konsole", "--new-tab", "--workdir", directory}
+	case commandExists("x-terminal-emulator"):
+		// Started in the directory, as there's no telling which terminal it is or what its flags are
+		return []string{"x-terminal-emulator"}
+	}
+	return nil
+}
+
+// openDirectory() runs a command that opens a directory somewhere else, without waiting for it to close
+func openDirectory(directory string, command []string) tea.Cmd {
+	return func() tea.Msg {
+		if len(command) == 0 {
+			return errMsg{errors.New(tr("error.no-terminal"))}
+		}
+		if pvw.Pure {
+			return errMsg{errors.New(tr("error.pure-command", command[0]))}
+		}
+
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Dir = directory
+		debugf("opening %s with %q", directory, cmd.String())
+		if err := cmd.Start(); err != nil {
+			return errMsg{errors.New(tr("error.open-directory", directory, explainFailure(err, command[0])))}
+		}
+
+		// Don't leave a zombie process behind once it's finished
+		go cmd.Wait()
+		return nil
+	}
+}
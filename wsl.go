@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/allyring/pvw/pkg/pvw"
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// WSL
+// Under WSL2, a Windows program listening on a port stops Linux programs using it too, which shows up as "address
+// already in use" with nothing in pvw to explain it. When pvw is running under WSL, the Windows side's listeners are
+// added to the table as well, with their names starting with "win:".
+//
+// Windows also listens on ports on behalf of Linux, using wslrelay.exe to forward localhost from Windows into WSL. Those
+// are the Linux processes' own ports (which are already in the table), so they're left out.
+
+// The Windows process that forwards localhost ports into WSL
+const wslRelayName = "wslrelay.exe"
+
+// windowsListeners() finds the Windows processes listening on ports, apart from the WSL relay
+func windowsListeners(ctx context.Context) ([]process, []parseWarning, error) {
+	all, warnings, err := pvw.Windows().Collect(ctx)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	listeners := make([]process, 0, len(all))
+	for _, proc := range all {
+		if strings.EqualFold(proc.Name, wslRelayName) {
+			continue
+		}
+
+		var connections []connection
+		for _, conn := range proc.Connections {
+			if isListening(conn) {
+				connections = append(connections, conn)
+			}
+		}
+
+		if len(connections) > 0 {
+			proc.Connections = connections
+			listeners = append(listeners, proc)
+		}
+	}
+
+	return listeners, warnings, nil
+}